@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// User roles. Each can do everything the ones before it can: moderators
+// work through reported chirps, admins run the admin API.
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{roleUser: 0, roleModerator: 1, roleAdmin: 2}
+
+var (
+	errTokenRevoked   = errors.New("access token has been revoked")
+	errAccountDeleted = errors.New("account has been deleted")
+)
+
+// authenticate validates an access token and loads its user. Tokens issued
+// before the user's tokens were revoked are refused. Issue times are whole
+// seconds, so every token issued in the second of the revocation is
+// refused too, including any issued just after it.
+// Deleted accounts are refused and suspended users get a suspensionError.
+func (cfg *apiConfig) authenticate(ctx context.Context, token string) (database.User, auth.AccessToken, error) {
+	claims, err := auth.ParseJWT(ctx, token, cfg.secret)
+	if err != nil {
+		return database.User{}, auth.AccessToken{}, err
+	}
+	user, err := cfg.db.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return database.User{}, auth.AccessToken{}, err
+	}
+	if err := tokenRefused(user, claims); err != nil {
+		return database.User{}, auth.AccessToken{}, err
+	}
+	return user, claims, nil
+}
+
+// tokenRefused says why user may no longer use the access token with
+// claims, or returns nil when they may.
+func tokenRefused(user database.User, claims auth.AccessToken) error {
+	if revoked := user.TokensRevokedAt; revoked.Valid && !claims.IssuedAt.After(revoked.Time.Truncate(time.Second)) {
+		return errTokenRevoked
+	}
+	if user.DeletedAt.Valid {
+		return errAccountDeleted
+	}
+	if suspended(user) {
+		return suspensionError{user}
+	}
+	return nil
+}
+
+// validateToken is auth.ValidateJWT for routes that only need the user's ID.
+func (cfg *apiConfig) validateToken(ctx context.Context, token string) (uuid.UUID, error) {
+	user, _, err := cfg.authenticate(ctx, token)
+	return user.ID, err
+}
+
+// bearerUser authenticates the request from its bearer token, writing a
+// 401, or a 403 for suspended users, when it can't.
+func (cfg *apiConfig) bearerUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Could not find JWT", err)
+		return database.User{}, false
+	}
+
+	user, _, err := cfg.authenticate(r.Context(), token)
+	if err != nil {
+		respondWithTokenError(w, err)
+		return database.User{}, false
+	}
+	setRequestUser(w, user.ID)
+	return user, true
+}
+
+type contextUserKey struct{}
+
+// contextUser is the user requireRole let through, if any. Requests made
+// with ADMIN_KEY have none.
+func contextUser(ctx context.Context) (database.User, bool) {
+	user, ok := ctx.Value(contextUserKey{}).(database.User)
+	return user, ok
+}
+
+// requireRole lets only users with at least role through to next, putting
+// the user on the request context. ADMIN_KEY also passes as an admin, for
+// scripts and for appointing the first admin.
+func (cfg *apiConfig) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if role == roleAdmin && strings.HasPrefix(r.Header.Get("Authorization"), "ApiKey ") {
+			key, err := auth.GetAPIKey(r.Header)
+			if err != nil || cfg.adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(cfg.adminKey)) != 1 {
+				respondWithError(w, http.StatusUnauthorized, "Could not validate admin key", err)
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		user, ok := cfg.bearerUser(w, r)
+		if !ok {
+			return
+		}
+		if roleRank[user.Role] < roleRank[role] {
+			respondWithError(w, http.StatusForbidden, "You need the "+role+" role to do this", nil)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), contextUserKey{}, user)))
+	}
+}
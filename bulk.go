@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/chirptext"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// Record types in a bulk transfer stream.
+const (
+	bulkUser  = "user"
+	bulkChirp = "chirp"
+)
+
+// Conflict policies for importing a record whose ID is already taken.
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictFail      = "fail"
+)
+
+const (
+	// bulkPage is how many rows an export reads at a time.
+	bulkPage = 500
+	// bulkProgressEvery is how many records pass between progress reports.
+	bulkProgressEvery = 1000
+	// bulkMaxLine is the longest record an import accepts.
+	bulkMaxLine = 1 << 20
+)
+
+var errBulkConflict = errors.New("already exists")
+
+// bulkRecord is one line of a bulk transfer stream: a user or a chirp with
+// its ID and timestamps, so it can be moved between servers as is. Users
+// come before the chirps that refer to them, and chirps before their
+// replies. Users carry their password hash so they can still log in;
+// sessions, follows, likes and the rest are left behind.
+type bulkRecord struct {
+	Type      string    `json:"type"`
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Email          string     `json:"email,omitempty"`
+	HashedPassword string     `json:"hashed_password,omitempty"`
+	Username       string     `json:"username,omitempty"`
+	Role           string     `json:"role,omitempty"`
+	IsChirpyRed    bool       `json:"is_chirpy_red,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	BanReason      string     `json:"ban_reason,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+	Body      string     `json:"body,omitempty"`
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+func bulkRecordFromUser(u database.User) bulkRecord {
+	rec := bulkRecord{
+		Type:           bulkUser,
+		ID:             u.ID,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
+		Email:          u.Email,
+		HashedPassword: u.HashedPassword,
+		Username:       u.Username.String,
+		Role:           u.Role,
+		IsChirpyRed:    u.IsChirpyRed,
+		BanReason:      u.BanReason,
+	}
+	if u.SuspendedUntil.Valid {
+		rec.SuspendedUntil = &u.SuspendedUntil.Time
+	}
+	if u.DeletedAt.Valid {
+		rec.DeletedAt = &u.DeletedAt.Time
+	}
+	return rec
+}
+
+func bulkRecordFromChirp(c database.Chirp) bulkRecord {
+	rec := bulkRecord{
+		Type:      bulkChirp,
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		UserID:    &c.UserID,
+		Body:      c.Body,
+		Status:    c.Status,
+	}
+	if c.ReplyToID.Valid {
+		rec.ReplyToID = &c.ReplyToID.UUID
+	}
+	if c.PublishAt.Valid {
+		rec.PublishAt = &c.PublishAt.Time
+	}
+	return rec
+}
+
+func nullTimePtr(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func nullUUIDPtr(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
+
+// bulkStats counts a bulk transfer's records as it goes.
+type bulkStats struct {
+	Users       int  `json:"users"`
+	Chirps      int  `json:"chirps"`
+	Created     int  `json:"created,omitempty"`
+	Overwritten int  `json:"overwritten,omitempty"`
+	Skipped     int  `json:"skipped,omitempty"`
+	DryRun      bool `json:"dry_run,omitempty"`
+}
+
+func (s bulkStats) records() int {
+	return s.Users + s.Chirps
+}
+
+func (s bulkStats) String() string {
+	out := fmt.Sprintf("%d users, %d chirps", s.Users, s.Chirps)
+	if s.Created+s.Overwritten+s.Skipped > 0 {
+		out += fmt.Sprintf(" (%d created, %d overwritten, %d skipped)", s.Created, s.Overwritten, s.Skipped)
+	}
+	if s.DryRun {
+		out += ", dry run"
+	}
+	return out
+}
+
+// exportBulk writes every user and then every chirp to w as JSON Lines,
+// calling progress every bulkProgressEvery records. Rows written while it
+// runs may be missed.
+func exportBulk(ctx context.Context, db store.Store, w io.Writer, progress func(bulkStats)) (bulkStats, error) {
+	var stats bulkStats
+	enc := json.NewEncoder(w)
+	write := func(rec bulkRecord, count *int) error {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+		*count++
+		if progress != nil && stats.records()%bulkProgressEvery == 0 {
+			progress(stats)
+		}
+		return nil
+	}
+
+	for offset := 0; ; offset += bulkPage {
+		users, err := db.SearchUsers(ctx, database.SearchUsersParams{PageSize: bulkPage, PageOffset: int32(offset)})
+		if err != nil {
+			return stats, err
+		}
+		for _, u := range users {
+			if err := write(bulkRecordFromUser(u), &stats.Users); err != nil {
+				return stats, err
+			}
+		}
+		if len(users) < bulkPage {
+			break
+		}
+	}
+	for offset := 0; ; offset += bulkPage {
+		chirps, err := db.ListChirpsPage(ctx, database.ListChirpsPageParams{PageSize: bulkPage, PageOffset: int32(offset)})
+		if err != nil {
+			return stats, err
+		}
+		for _, c := range chirps {
+			if err := write(bulkRecordFromChirp(c), &stats.Chirps); err != nil {
+				return stats, err
+			}
+		}
+		if len(chirps) < bulkPage {
+			break
+		}
+	}
+	return stats, nil
+}
+
+// bulkImportOptions say what an import does with what it reads.
+type bulkImportOptions struct {
+	// OnConflict is conflictSkip, conflictOverwrite or conflictFail.
+	OnConflict string
+	// DryRun checks every record and counts what would happen without
+	// writing anything.
+	DryRun bool
+}
+
+func validConflictPolicy(policy string) bool {
+	return policy == conflictSkip || policy == conflictOverwrite || policy == conflictFail
+}
+
+// bulkImporter remembers the IDs it has seen so a dry run can check
+// records against the ones before them, which it never wrote.
+type bulkImporter struct {
+	db     store.Store
+	opts   bulkImportOptions
+	stats  bulkStats
+	users  map[uuid.UUID]bool
+	chirps map[uuid.UUID]bool
+}
+
+// importBulk reads records written by exportBulk from r and stores them
+// with their IDs and timestamps, calling progress every bulkProgressEvery
+// records. It stops at the first bad record, naming its line; the records
+// before it stay imported. Imported chirps keep their status and don't go
+// through moderation, notifications or federation.
+func importBulk(ctx context.Context, db store.Store, r io.Reader, opts bulkImportOptions, progress func(bulkStats)) (bulkStats, error) {
+	if !validConflictPolicy(opts.OnConflict) {
+		return bulkStats{}, fmt.Errorf("unknown conflict policy %q, want skip, overwrite or fail", opts.OnConflict)
+	}
+	im := &bulkImporter{
+		db:     db,
+		opts:   opts,
+		stats:  bulkStats{DryRun: opts.DryRun},
+		users:  map[uuid.UUID]bool{},
+		chirps: map[uuid.UUID]bool{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), bulkMaxLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec bulkRecord
+		err := json.Unmarshal(scanner.Bytes(), &rec)
+		if err == nil {
+			err = im.importRecord(ctx, rec)
+		}
+		if err != nil {
+			return im.stats, fmt.Errorf("line %d: %w", line, err)
+		}
+		if progress != nil && im.stats.records()%bulkProgressEvery == 0 {
+			progress(im.stats)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return im.stats, fmt.Errorf("line %d: %w", line+1, err)
+	}
+	return im.stats, nil
+}
+
+func (im *bulkImporter) importRecord(ctx context.Context, rec bulkRecord) error {
+	if rec.ID == uuid.Nil {
+		return errors.New("record has no id")
+	}
+	if rec.CreatedAt.IsZero() {
+		return errors.New("record has no created_at")
+	}
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = rec.CreatedAt
+	}
+	switch rec.Type {
+	case bulkUser:
+		return im.importUser(ctx, rec)
+	case bulkChirp:
+		return im.importChirp(ctx, rec)
+	default:
+		return fmt.Errorf("unknown record type %q, want user or chirp", rec.Type)
+	}
+}
+
+// resolve applies the conflict policy to a record that exists already
+// when exists is true, and reports whether to write it.
+func (im *bulkImporter) resolve(kind string, id uuid.UUID, exists bool) (bool, error) {
+	if !exists {
+		im.stats.Created++
+		return true, nil
+	}
+	switch im.opts.OnConflict {
+	case conflictSkip:
+		im.stats.Skipped++
+		return false, nil
+	case conflictOverwrite:
+		im.stats.Overwritten++
+		return true, nil
+	default:
+		return false, fmt.Errorf("%s %s %w", kind, id, errBulkConflict)
+	}
+}
+
+func (im *bulkImporter) importUser(ctx context.Context, rec bulkRecord) error {
+	if rec.Email == "" || rec.HashedPassword == "" {
+		return fmt.Errorf("user %s needs an email and a hashed_password", rec.ID)
+	}
+	if rec.Role == "" {
+		rec.Role = roleUser
+	}
+	if _, ok := roleRank[rec.Role]; !ok {
+		return fmt.Errorf("user %s has unknown role %q", rec.ID, rec.Role)
+	}
+	if rec.Username != "" {
+		if username, ok := chirptext.NormalizeUsername(rec.Username); !ok || username != rec.Username {
+			return fmt.Errorf("user %s has invalid username %q", rec.ID, rec.Username)
+		}
+	}
+
+	exists, err := im.userExists(ctx, rec.ID)
+	if err != nil {
+		return err
+	}
+	if !exists || im.opts.OnConflict == conflictOverwrite {
+		if err := im.checkUnique(ctx, rec); err != nil {
+			return err
+		}
+	}
+
+	im.stats.Users++
+	write, err := im.resolve(bulkUser, rec.ID, exists)
+	im.users[rec.ID] = true
+	if !write || err != nil || im.opts.DryRun {
+		return err
+	}
+	return im.db.ImportUser(ctx, database.ImportUserParams{
+		ID:             rec.ID,
+		CreatedAt:      rec.CreatedAt.UTC(),
+		UpdatedAt:      rec.UpdatedAt.UTC(),
+		Email:          rec.Email,
+		HashedPassword: rec.HashedPassword,
+		IsChirpyRed:    rec.IsChirpyRed,
+		Username:       nullString(rec.Username),
+		Role:           rec.Role,
+		SuspendedUntil: nullTimePtr(rec.SuspendedUntil),
+		BanReason:      rec.BanReason,
+		DeletedAt:      nullTimePtr(rec.DeletedAt),
+	})
+}
+
+// checkUnique makes sure rec's email and username don't belong to someone
+// else, so that a dry run catches the clash too.
+func (im *bulkImporter) checkUnique(ctx context.Context, rec bulkRecord) error {
+	other, err := im.db.GetUserByEmail(ctx, rec.Email)
+	if err == nil && other.ID != rec.ID {
+		return fmt.Errorf("user %s has the email of user %s", rec.ID, other.ID)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if rec.Username == "" {
+		return nil
+	}
+	other, err = im.db.GetUserByUsername(ctx, nullString(rec.Username))
+	if err == nil && other.ID != rec.ID {
+		return fmt.Errorf("user %s has the username of user %s", rec.ID, other.ID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+func (im *bulkImporter) importChirp(ctx context.Context, rec bulkRecord) error {
+	if rec.UserID == nil || rec.Body == "" {
+		return fmt.Errorf("chirp %s needs a user_id and a body", rec.ID)
+	}
+	if rec.Status == "" {
+		rec.Status = chirpPublished
+	}
+	switch rec.Status {
+	case chirpPublished, chirpHeld, chirpHidden, chirpBanned, chirpWithdrawn, chirpScheduled:
+	default:
+		return fmt.Errorf("chirp %s has unknown status %q", rec.ID, rec.Status)
+	}
+	if rec.Status == chirpScheduled && rec.PublishAt == nil {
+		return fmt.Errorf("chirp %s is scheduled but has no publish_at", rec.ID)
+	}
+	if ok, err := im.userExists(ctx, *rec.UserID); err != nil || !ok {
+		if err == nil {
+			err = fmt.Errorf("chirp %s is by user %s, who isn't in the database or earlier in the stream", rec.ID, *rec.UserID)
+		}
+		return err
+	}
+	if rec.ReplyToID != nil {
+		if ok, err := im.chirpExists(ctx, *rec.ReplyToID); err != nil || !ok {
+			if err == nil {
+				err = fmt.Errorf("chirp %s replies to chirp %s, which isn't in the database or earlier in the stream", rec.ID, *rec.ReplyToID)
+			}
+			return err
+		}
+	}
+
+	exists, err := im.chirpExists(ctx, rec.ID)
+	if err != nil {
+		return err
+	}
+	im.stats.Chirps++
+	write, err := im.resolve(bulkChirp, rec.ID, exists)
+	im.chirps[rec.ID] = true
+	if !write || err != nil || im.opts.DryRun {
+		return err
+	}
+	return im.db.ImportChirp(ctx, database.ImportChirpParams{
+		ID:        rec.ID,
+		CreatedAt: rec.CreatedAt.UTC(),
+		UpdatedAt: rec.UpdatedAt.UTC(),
+		Body:      rec.Body,
+		UserID:    *rec.UserID,
+		ReplyToID: nullUUIDPtr(rec.ReplyToID),
+		Status:    rec.Status,
+		PublishAt: nullTimePtr(rec.PublishAt),
+	})
+}
+
+func (im *bulkImporter) userExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	if im.users[id] {
+		return true, nil
+	}
+	_, err := im.db.GetUserByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (im *bulkImporter) chirpExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	if im.chirps[id] {
+		return true, nil
+	}
+	_, err := im.db.GetSingleChirp(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// runBulkCommand implements `chirpy bulk export|import`. Records go to or
+// come from a file, or stdout and stdin when none is given; progress is
+// reported on errOut.
+func runBulkCommand(ctx context.Context, out, errOut io.Writer, in io.Reader, db store.Store, args []string) error {
+	const usage = "usage: chirpy bulk export [-o file] | chirpy bulk import [-dry-run] [-on-conflict skip|overwrite|fail] [file]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	report := func(verb string) func(bulkStats) {
+		return func(s bulkStats) { fmt.Fprintf(errOut, "%s %s\n", verb, s) }
+	}
+
+	fs := flag.NewFlagSet("chirpy bulk "+args[0], flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	switch args[0] {
+	case "export":
+		output := fs.String("o", "", "write to this file instead of stdout")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() > 0 {
+			return errors.New(usage)
+		}
+		w := out
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		buf := bufio.NewWriter(w)
+		stats, err := exportBulk(ctx, db, buf, report("exported"))
+		if err == nil {
+			err = buf.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(errOut, "done: exported %s\n", stats)
+		return nil
+	case "import":
+		var opts bulkImportOptions
+		fs.BoolVar(&opts.DryRun, "dry-run", false, "check the records without writing them")
+		fs.StringVar(&opts.OnConflict, "on-conflict", conflictFail, "what to do with records whose ID exists: skip, overwrite or fail")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		switch fs.NArg() {
+		case 0:
+		case 1:
+			f, err := os.Open(fs.Arg(0))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		default:
+			return errors.New(usage)
+		}
+		stats, err := importBulk(ctx, db, in, opts, report("imported"))
+		if err != nil {
+			fmt.Fprintf(errOut, "stopped after %s\n", stats)
+			return err
+		}
+		fmt.Fprintf(errOut, "done: imported %s\n", stats)
+		return nil
+	default:
+		return fmt.Errorf("unknown bulk command %q, want export or import", args[0])
+	}
+}
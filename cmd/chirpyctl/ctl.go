@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// ctl is what every command runs with.
+type ctl struct {
+	db    store.Store
+	sqlDB *sql.DB
+	dbURL string
+	in    io.Reader
+	out   io.Writer
+	json  bool
+}
+
+// print writes v as indented JSON with -json, and otherwise lets text
+// describe it.
+func (c *ctl) print(v any, text func(w io.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	text(c.out)
+	return nil
+}
+
+// findUser looks a user up by id, email or username.
+func (c *ctl) findUser(ctx context.Context, ref string) (database.User, error) {
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(ref); parseErr == nil {
+		user, err = c.db.GetUserByID(ctx, id)
+	} else if strings.Contains(ref, "@") {
+		user, err = c.db.GetUserByEmail(ctx, ref)
+	} else {
+		user, err = c.db.GetUserByUsername(ctx, sql.NullString{String: strings.ToLower(ref), Valid: true})
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, fmt.Errorf("no user %q", ref)
+	}
+	return user, err
+}
+
+// password returns flagValue, or the first line of stdin when it is empty.
+func (c *ctl) password(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	line, err := bufio.NewReader(c.in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("no password given; pass -password or write it to stdin")
+	}
+	return line, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// ctlUser is a user as chirpyctl prints them.
+type ctlUser struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Email       string    `json:"email"`
+	Username    string    `json:"username,omitempty"`
+	Role        string    `json:"role"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+func userFromDB(u database.User) ctlUser {
+	return ctlUser{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		Email:       u.Email,
+		Username:    u.Username.String,
+		Role:        u.Role,
+		IsChirpyRed: u.IsChirpyRed,
+	}
+}
+
+// name is how text output refers to a user.
+func (u ctlUser) name() string {
+	if u.Username != "" {
+		return u.Email + " (@" + u.Username + ")"
+	}
+	return u.Email
+}
+
+// oneArg returns the only positional argument, or an error naming what it
+// should have been.
+func oneArg(args []string, what string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("want one %s, got %d arguments", what, len(args))
+	}
+	return args[0], nil
+}
@@ -0,0 +1,128 @@
+// Command chirpyctl does the operational chores that would otherwise take
+// curl or psql: managing users and their tokens, migrating the schema and
+// printing stats. It talks to the database directly, so it works while the
+// server is down.
+//
+//	chirpyctl [-db-url URL] [-json] command [args]
+//
+// DB_URL is read from the environment or .env when -db-url isn't given.
+// Output is text meant for people unless -json is given.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/enderbd/chirpy/internal/storage"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("chirpyctl: ")
+
+	err := run(context.Background(), os.Args[1:], os.Getenv, os.Stdin, os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// command is a chirpyctl subcommand, named by one or two words.
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(ctx context.Context, c *ctl, args []string) error
+}
+
+var commands = []command{
+	{"user create", "-email EMAIL [-username NAME] [-role ROLE] [-password PASSWORD]", "create a user", userCreate},
+	{"user reset-password", "[-password PASSWORD] USER", "set a user's password and sign them out everywhere", userResetPassword},
+	{"red grant", "USER", "give a user Chirpy Red", redGrant},
+	{"red revoke", "USER", "take Chirpy Red away from a user", redRevoke},
+	{"tokens revoke", "USER", "sign a user out everywhere", tokensRevoke},
+	{"tokens purge", "[-expired-for DURATION]", "delete refresh tokens that have expired", tokensPurge},
+	{"migrate", "up|down|status", "apply, roll back or list migrations", migrate},
+	{"stats", "", "count users, chirps and sessions", stats},
+}
+
+// findCommand returns the command args start with and the args after its
+// name.
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+func usage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: chirpyctl [-db-url URL] [-json] command [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\n    \t%s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.usage)
+	}
+	fmt.Fprintln(w, "\nUSER is an id, an email or a username. Passwords not given with")
+	fmt.Fprintln(w, "-password are read from the first line of stdin.")
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+}
+
+func run(ctx context.Context, args []string, getenv func(string) string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("chirpyctl", flag.ContinueOnError)
+	dbURL := fs.String("db-url", "", "postgres:// or sqlite: database URL; DB_URL by default")
+	jsonOut := fs.Bool("json", false, "print JSON instead of text")
+	fs.Usage = func() { usage(fs.Output(), fs) }
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cmd, rest, ok := findCommand(fs.Args())
+	if !ok {
+		fs.Usage()
+		if fs.NArg() == 0 {
+			return errors.New("no command given")
+		}
+		return fmt.Errorf("unknown command %q", strings.Join(fs.Args(), " "))
+	}
+
+	if *dbURL == "" {
+		*dbURL = getenv("DB_URL")
+	}
+	if *dbURL == "" {
+		dotenv, err := godotenv.Read()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		*dbURL = dotenv["DB_URL"]
+	}
+	if *dbURL == "" {
+		return errors.New("DB_URL is required, set it or pass -db-url")
+	}
+	db, sqlDB, err := storage.Open(*dbURL, 0)
+	if err != nil {
+		return fmt.Errorf("could not open the chirpy database: %w", err)
+	}
+	defer sqlDB.Close()
+
+	// Everything but migrate needs the schema the binary was built for.
+	if cmd.name != "migrate" {
+		if err := storage.Prepare(ctx, sqlDB, *dbURL, false); err != nil {
+			return err
+		}
+	}
+
+	c := &ctl{db: db, sqlDB: sqlDB, dbURL: *dbURL, in: stdin, out: stdout, json: *jsonOut}
+	return cmd.run(ctx, c, rest)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// chirpyctl runs a command against the database at dbURL and returns its
+// output.
+func chirpyctl(t *testing.T, dbURL, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	getenv := func(key string) string {
+		if key == "DB_URL" {
+			return dbURL
+		}
+		return ""
+	}
+	err := run(t.Context(), args, getenv, strings.NewReader(stdin), &out)
+	return out.String(), err
+}
+
+func mustChirpyctl(t *testing.T, dbURL, stdin string, args ...string) string {
+	t.Helper()
+	out, err := chirpyctl(t, dbURL, stdin, args...)
+	if err != nil {
+		t.Fatalf("chirpyctl %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
+
+func TestChirpyctl(t *testing.T) {
+	dbURL := "sqlite:" + filepath.Join(t.TempDir(), "chirpy.db")
+
+	if _, err := chirpyctl(t, dbURL, "", "stats"); err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("stats before migrating: %v", err)
+	}
+	if out := mustChirpyctl(t, dbURL, "", "migrate", "up"); !strings.Contains(out, "applied 001_initial.sql") {
+		t.Errorf("migrate up:\n%s", out)
+	}
+	var status []migration
+	json.Unmarshal([]byte(mustChirpyctl(t, dbURL, "", "-json", "migrate", "status")), &status)
+	if len(status) == 0 || status[0].AppliedAt == nil {
+		t.Errorf("migrate status: %+v", status)
+	}
+
+	var alice ctlUser
+	out := mustChirpyctl(t, dbURL, "hunter2\n", "-json", "user", "create", "-email", "alice@example.com", "-username", "Alice", "-role", "admin")
+	if err := json.Unmarshal([]byte(out), &alice); err != nil {
+		t.Fatal(err)
+	}
+	if alice.Username != "alice" || alice.Role != "admin" {
+		t.Errorf("created %+v", alice)
+	}
+	if _, err := chirpyctl(t, dbURL, "", "user", "create", "-email", "bob@example.com"); err == nil {
+		t.Error("creating a user without a password succeeded")
+	}
+	if _, err := chirpyctl(t, dbURL, "", "user", "create", "-email", "bob@example.com", "-password", "x", "-role", "owner"); err == nil {
+		t.Error("creating a user with an unknown role succeeded")
+	}
+
+	if out := mustChirpyctl(t, dbURL, "", "red", "grant", "alice"); out != "alice@example.com (@alice) now has Chirpy Red\n" {
+		t.Errorf("red grant: %q", out)
+	}
+	if out := mustChirpyctl(t, dbURL, "", "stats"); !regexp.MustCompile(`chirpy red +1\n`).MatchString(out) {
+		t.Errorf("stats after granting red:\n%s", out)
+	}
+	mustChirpyctl(t, dbURL, "", "red", "revoke", alice.ID.String())
+
+	var stats statsResult
+	json.Unmarshal([]byte(mustChirpyctl(t, dbURL, "", "-json", "stats")), &stats)
+	if stats.Users != 1 || stats.ChirpyRed != 0 || stats.Chirps != 0 {
+		t.Errorf("stats: %+v", stats)
+	}
+
+	if out := mustChirpyctl(t, dbURL, "", "user", "reset-password", "-password", "hunter3", "alice@example.com"); !strings.Contains(out, "revoked 0 refresh tokens") {
+		t.Errorf("reset-password: %q", out)
+	}
+	if _, err := chirpyctl(t, dbURL, "", "tokens", "revoke", "nobody"); err == nil || !strings.Contains(err.Error(), `no user "nobody"`) {
+		t.Errorf("revoking tokens for nobody: %v", err)
+	}
+	if out := mustChirpyctl(t, dbURL, "", "tokens", "purge", "-expired-for", "24h"); out != "deleted 0 expired refresh tokens\n" {
+		t.Errorf("tokens purge: %q", out)
+	}
+
+	if _, err := chirpyctl(t, dbURL, "", "reticulate", "splines"); err == nil {
+		t.Error("unknown command succeeded")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/storage"
+	"github.com/pressly/goose/v3"
+)
+
+// migration is a migration as chirpyctl prints it. AppliedAt is only set
+// by status, Duration only by up and down.
+type migration struct {
+	Path      string     `json:"path"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+}
+
+func migrationResult(r *goose.MigrationResult) migration {
+	return migration{Path: r.Source.Path, Duration: r.Duration.Round(time.Millisecond).String()}
+}
+
+// migrate works like `chirpy migrate`, with JSON output when asked for.
+func migrate(ctx context.Context, c *ctl, args []string) error {
+	verb, err := oneArg(args, "of up, down or status")
+	if err != nil {
+		return err
+	}
+	migrator, err := storage.NewMigrator(c.sqlDB, c.dbURL)
+	if err != nil {
+		return err
+	}
+
+	switch verb {
+	case "up":
+		results, err := migrator.Up(ctx)
+		applied := []migration{}
+		for _, r := range results {
+			applied = append(applied, migrationResult(r))
+		}
+		if printErr := c.print(applied, func(w io.Writer) {
+			for _, m := range applied {
+				fmt.Fprintf(w, "applied %s in %s\n", m.Path, m.Duration)
+			}
+			if len(applied) == 0 && err == nil {
+				fmt.Fprintln(w, "no migrations to apply")
+			}
+		}); printErr != nil {
+			return printErr
+		}
+		return err
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		m := migrationResult(result)
+		return c.print(m, func(w io.Writer) {
+			fmt.Fprintf(w, "rolled back %s in %s\n", m.Path, m.Duration)
+		})
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		out := []migration{}
+		for _, s := range statuses {
+			m := migration{Path: s.Source.Path}
+			if s.State == goose.StateApplied {
+				m.AppliedAt = &s.AppliedAt
+			}
+			out = append(out, m)
+		}
+		return c.print(out, func(w io.Writer) {
+			for _, m := range out {
+				applied := "pending"
+				if m.AppliedAt != nil {
+					applied = m.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%-25s %s\n", applied, m.Path)
+			}
+		})
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", verb)
+	}
+}
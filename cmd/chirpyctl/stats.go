@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// statsResult is what chirpyctl stats prints. Suspended users include
+// banned ones; deleted users are waiting out their grace period.
+type statsResult struct {
+	Users          int64            `json:"users"`
+	ChirpyRed      int64            `json:"chirpy_red"`
+	Suspended      int64            `json:"suspended"`
+	Deleted        int64            `json:"deleted"`
+	Chirps         int64            `json:"chirps"`
+	ChirpsByStatus map[string]int64 `json:"chirps_by_status"`
+	Follows        int64            `json:"follows"`
+	Likes          int64            `json:"likes"`
+	ActiveSessions int64            `json:"active_sessions"`
+}
+
+func stats(ctx context.Context, c *ctl, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("stats takes no arguments")
+	}
+	counts, err := c.db.GetStats(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	byStatus, err := c.db.CountChirpsByStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	out := statsResult{
+		Users:          counts.Users,
+		ChirpyRed:      counts.RedUsers,
+		Suspended:      counts.SuspendedUsers,
+		Deleted:        counts.DeletedUsers,
+		Chirps:         counts.Chirps,
+		ChirpsByStatus: map[string]int64{},
+		Follows:        counts.Follows,
+		Likes:          counts.Likes,
+		ActiveSessions: counts.ActiveSessions,
+	}
+	for _, row := range byStatus {
+		out.ChirpsByStatus[row.Status] = row.Count
+	}
+	return c.print(out, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "users\t%d\n", out.Users)
+		fmt.Fprintf(tw, "  chirpy red\t%d\n", out.ChirpyRed)
+		fmt.Fprintf(tw, "  suspended\t%d\n", out.Suspended)
+		fmt.Fprintf(tw, "  deleted\t%d\n", out.Deleted)
+		fmt.Fprintf(tw, "chirps\t%d\n", out.Chirps)
+		for _, row := range byStatus {
+			fmt.Fprintf(tw, "  %s\t%d\n", row.Status, row.Count)
+		}
+		fmt.Fprintf(tw, "follows\t%d\n", out.Follows)
+		fmt.Fprintf(tw, "likes\t%d\n", out.Likes)
+		fmt.Fprintf(tw, "active sessions\t%d\n", out.ActiveSessions)
+		tw.Flush()
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// signOut revokes a user's refresh tokens and the access tokens already
+// issued to them, returning how many refresh tokens were revoked.
+func (c *ctl) signOut(ctx context.Context, userID uuid.UUID) (int64, error) {
+	n, err := c.db.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("could not revoke refresh tokens: %w", err)
+	}
+	if err := c.db.RevokeAccessTokens(ctx, userID); err != nil {
+		return n, fmt.Errorf("could not revoke access tokens: %w", err)
+	}
+	return n, nil
+}
+
+func tokensRevoke(ctx context.Context, c *ctl, args []string) error {
+	ref, err := oneArg(args, "user")
+	if err != nil {
+		return err
+	}
+	user, err := c.findUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	revoked, err := c.signOut(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		UserID               uuid.UUID `json:"user_id"`
+		RefreshTokensRevoked int64     `json:"refresh_tokens_revoked"`
+	}
+	return c.print(result{UserID: user.ID, RefreshTokensRevoked: revoked}, func(w io.Writer) {
+		fmt.Fprintf(w, "signed %s out everywhere, revoking %d refresh tokens\n", userFromDB(user).name(), revoked)
+	})
+}
+
+// tokensPurge deletes refresh tokens that expired long enough ago. Revoked
+// tokens that haven't expired are kept, they still show in data exports.
+func tokensPurge(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("chirpyctl tokens purge", flag.ContinueOnError)
+	expiredFor := fs.Duration("expired-for", 0, "only delete tokens that expired at least this long ago")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 || *expiredFor < 0 {
+		return fmt.Errorf("usage: chirpyctl tokens purge [-expired-for DURATION]")
+	}
+
+	n, err := c.db.DeleteExpiredRefreshTokens(ctx, time.Now().UTC().Add(-*expiredFor))
+	if err != nil {
+		return err
+	}
+	type result struct {
+		RefreshTokensDeleted int64 `json:"refresh_tokens_deleted"`
+	}
+	return c.print(result{RefreshTokensDeleted: n}, func(w io.Writer) {
+		fmt.Fprintf(w, "deleted %d expired refresh tokens\n", n)
+	})
+}
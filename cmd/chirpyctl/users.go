@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/chirptext"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var roles = []string{"user", "moderator", "admin"}
+
+func userCreate(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("chirpyctl user create", flag.ContinueOnError)
+	email := fs.String("email", "", "the user's email")
+	username := fs.String("username", "", "the user's username")
+	role := fs.String("role", "user", "user, moderator or admin")
+	password := fs.String("password", "", "the user's password; read from stdin when not given")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" || fs.NArg() > 0 {
+		return errors.New("usage: chirpyctl user create -email EMAIL [-username NAME] [-role ROLE] [-password PASSWORD]")
+	}
+	if !slices.Contains(roles, *role) {
+		return fmt.Errorf("unknown role %q, want user, moderator or admin", *role)
+	}
+	name := ""
+	if *username != "" {
+		var ok bool
+		name, ok = chirptext.NormalizeUsername(*username)
+		if !ok {
+			return errors.New("username may only contain up to 30 letters, digits and underscores")
+		}
+	}
+	pw, err := c.password(*password)
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(ctx, pw)
+	if err != nil {
+		return err
+	}
+
+	user, err := c.db.CreateUser(ctx, database.CreateUserParams{
+		Email:          *email,
+		HashedPassword: hash,
+		Username:       nullString(name),
+	})
+	if err != nil {
+		return fmt.Errorf("could not create the user: %w", err)
+	}
+	if *role != user.Role {
+		if _, err := c.db.SetUserRole(ctx, database.SetUserRoleParams{Role: *role, ID: user.ID}); err != nil {
+			return fmt.Errorf("created user %s but could not make them %s: %w", user.ID, *role, err)
+		}
+		user.Role = *role
+	}
+
+	out := userFromDB(user)
+	return c.print(out, func(w io.Writer) {
+		fmt.Fprintf(w, "created %s %s with role %s\n", out.ID, out.name(), out.Role)
+	})
+}
+
+// userResetPassword sets a new password and revokes every session, so
+// whoever had the old one is signed out.
+func userResetPassword(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("chirpyctl user reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "the new password; read from stdin when not given")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ref, err := oneArg(fs.Args(), "user")
+	if err != nil {
+		return err
+	}
+	user, err := c.findUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	pw, err := c.password(*password)
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(ctx, pw)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.db.UpdateUser(ctx, database.UpdateUserParams{ID: user.ID, Email: user.Email, HashedPassword: hash})
+	if err != nil {
+		return fmt.Errorf("could not set the password: %w", err)
+	}
+	revoked, err := c.signOut(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		UserID               uuid.UUID `json:"user_id"`
+		RefreshTokensRevoked int64     `json:"refresh_tokens_revoked"`
+	}
+	return c.print(result{UserID: user.ID, RefreshTokensRevoked: revoked}, func(w io.Writer) {
+		fmt.Fprintf(w, "reset the password of %s and revoked %d refresh tokens\n", userFromDB(user).name(), revoked)
+	})
+}
+
+func redGrant(ctx context.Context, c *ctl, args []string) error {
+	return setRed(ctx, c, args, true)
+}
+
+func redRevoke(ctx context.Context, c *ctl, args []string) error {
+	return setRed(ctx, c, args, false)
+}
+
+func setRed(ctx context.Context, c *ctl, args []string, red bool) error {
+	ref, err := oneArg(args, "user")
+	if err != nil {
+		return err
+	}
+	user, err := c.findUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if red {
+		err = c.db.UpgradeRed(ctx, user.ID)
+	} else {
+		err = c.db.DowngradeRed(ctx, user.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	user.IsChirpyRed = red
+	out := userFromDB(user)
+	return c.print(out, func(w io.Writer) {
+		if red {
+			fmt.Fprintf(w, "%s now has Chirpy Red\n", out.name())
+		} else {
+			fmt.Fprintf(w, "%s no longer has Chirpy Red\n", out.name())
+		}
+	})
+}
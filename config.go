@@ -0,0 +1,449 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/storage"
+	"github.com/enderbd/chirpy/internal/tracing"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything Chirpy reads at startup. Each setting can come from,
+// in increasing order of precedence: its default, the config file, .env,
+// the environment, and a command-line flag. The environment variable name
+// is the setting's key; the file key is the same in lower case and the flag
+// is the same in lower case with dashes, e.g. DB_URL, db_url and -db-url.
+type Config struct {
+	Port     string
+	FileRoot string
+	DBURL    string
+	Migrate  bool
+	Platform string
+	Secret   string
+	PolkaKey string
+	// BaseURL is the public origin, e.g. https://chirpy.example. Setting it
+	// turns on ActivityPub federation.
+	BaseURL string
+
+	// SMTPAddr turns on email notifications for users who opt in.
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+
+	Timeouts serverTimeouts
+
+	LogLevel  string
+	LogFormat string
+	// LogSlowQuery is how long a query may take before it is logged at
+	// warn level.
+	LogSlowQuery time.Duration
+
+	TraceExporter    string
+	TraceFile        string
+	TraceSampleRatio float64
+
+	// RateLimitStore is memory, postgres or off. RateLimits overrides the
+	// per-route limits, see parseRateLimits.
+	RateLimitStore string
+	RateLimits     string
+	// TrustProxy takes the client address from X-Forwarded-For.
+	TrustProxy bool
+
+	// ModerationRules is a wordlist file used instead of the default
+	// rules, see moderation.ParseRules.
+	ModerationRules string
+	// AdminKey is an API key accepted in place of an admin's access token
+	// on the admin endpoints.
+	AdminKey string
+
+	// DeletionGracePeriod is how long a user who deleted their account can
+	// log in to restore it before it is deleted for good.
+	DeletionGracePeriod time.Duration
+
+	// sources records where each setting's value came from, by key.
+	sources map[string]string
+}
+
+// setting describes one configuration key. field returns a pointer to the
+// *string, *bool, *float64 or *time.Duration it is stored in.
+type setting struct {
+	key    string
+	usage  string
+	secret bool
+	field  func(c *Config) any
+}
+
+var settings = []setting{
+	{key: "PORT", usage: "port to listen on", field: func(c *Config) any { return &c.Port }},
+	{key: "FILE_ROOT", usage: "directory served under /app/", field: func(c *Config) any { return &c.FileRoot }},
+	{key: "DB_URL", usage: "postgres:// or sqlite: database URL", field: func(c *Config) any { return &c.DBURL }},
+	{key: "MIGRATE", usage: "apply pending database migrations at startup instead of refusing to start", field: func(c *Config) any { return &c.Migrate }},
+	{key: "PLATFORM", usage: "dev or prod; dev enables /admin/reset for admins", field: func(c *Config) any { return &c.Platform }},
+	{key: "SECRET", usage: "key used to sign access tokens", secret: true, field: func(c *Config) any { return &c.Secret }},
+	{key: "POLKA_KEY", usage: "API key Polka webhooks must present", secret: true, field: func(c *Config) any { return &c.PolkaKey }},
+	{key: "BASE_URL", usage: "public origin; enables ActivityPub federation", field: func(c *Config) any { return &c.BaseURL }},
+	{key: "SMTP_ADDR", usage: "host:port of the SMTP server for email notifications", field: func(c *Config) any { return &c.SMTPAddr }},
+	{key: "SMTP_FROM", usage: "sender address for email notifications", field: func(c *Config) any { return &c.SMTPFrom }},
+	{key: "SMTP_USERNAME", usage: "SMTP username", field: func(c *Config) any { return &c.SMTPUsername }},
+	{key: "SMTP_PASSWORD", usage: "SMTP password", secret: true, field: func(c *Config) any { return &c.SMTPPassword }},
+	{key: "HTTP_READ_HEADER_TIMEOUT", usage: "time allowed to read request headers", field: func(c *Config) any { return &c.Timeouts.ReadHeader }},
+	{key: "HTTP_READ_TIMEOUT", usage: "time allowed to read a whole request", field: func(c *Config) any { return &c.Timeouts.Read }},
+	{key: "HTTP_WRITE_TIMEOUT", usage: "time allowed to write a response, streams excepted", field: func(c *Config) any { return &c.Timeouts.Write }},
+	{key: "HTTP_IDLE_TIMEOUT", usage: "how long idle keep-alive connections are kept", field: func(c *Config) any { return &c.Timeouts.Idle }},
+	{key: "SHUTDOWN_DELAY", usage: "how long to report not ready before draining connections on shutdown", field: func(c *Config) any { return &c.Timeouts.ShutdownDelay }},
+	{key: "SHUTDOWN_TIMEOUT", usage: "how long in-flight requests get to finish on shutdown", field: func(c *Config) any { return &c.Timeouts.Shutdown }},
+	{key: "LOG_LEVEL", usage: "debug, info, warn or error", field: func(c *Config) any { return &c.LogLevel }},
+	{key: "LOG_FORMAT", usage: "text or json", field: func(c *Config) any { return &c.LogFormat }},
+	{key: "LOG_SLOW_QUERY", usage: "queries slower than this are logged as warnings; 0 disables", field: func(c *Config) any { return &c.LogSlowQuery }},
+	{key: "TRACE_EXPORTER", usage: "none, otlp, stdout or file; otlp reads the OTEL_EXPORTER_OTLP_* variables", field: func(c *Config) any { return &c.TraceExporter }},
+	{key: "TRACE_FILE", usage: "file the file trace exporter appends to", field: func(c *Config) any { return &c.TraceFile }},
+	{key: "TRACE_SAMPLE_RATIO", usage: "fraction of new traces to record, from 0 to 1", field: func(c *Config) any { return &c.TraceSampleRatio }},
+	{key: "RATE_LIMIT_STORE", usage: "memory, postgres or off; use postgres when running several instances", field: func(c *Config) any { return &c.RateLimitStore }},
+	{key: "RATE_LIMITS", usage: "per-route limit overrides, e.g. \"POST /api/chirps=20/m,120/m; POST /api/login=off\"", field: func(c *Config) any { return &c.RateLimits }},
+	{key: "TRUST_PROXY", usage: "rate limit by the last X-Forwarded-For address; only set behind a proxy that adds it", field: func(c *Config) any { return &c.TrustProxy }},
+	{key: "MODERATION_RULES", usage: "wordlist file replacing the default moderation rules", field: func(c *Config) any { return &c.ModerationRules }},
+	{key: "ADMIN_KEY", usage: "API key accepted as an admin on the admin endpoints; unset, only admin users get in", secret: true, field: func(c *Config) any { return &c.AdminKey }},
+	{key: "DELETION_GRACE_PERIOD", usage: "how long a deleted account can be restored by logging in", field: func(c *Config) any { return &c.DeletionGracePeriod }},
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:                "8080",
+		FileRoot:            ".",
+		Platform:            "prod",
+		Timeouts:            defaultServerTimeouts,
+		LogLevel:            "info",
+		LogFormat:           "text",
+		LogSlowQuery:        200 * time.Millisecond,
+		TraceExporter:       tracing.ExporterNone,
+		TraceFile:           "chirpy-traces.jsonl",
+		TraceSampleRatio:    1,
+		RateLimitStore:      rateLimitMemory,
+		DeletionGracePeriod: 30 * 24 * time.Hour,
+		sources:             map[string]string{},
+	}
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.key), "_", "-")
+}
+
+func (s setting) set(c *Config, raw string) error {
+	switch p := s.field(c).(type) {
+	case *string:
+		*p = raw
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", raw)
+		}
+		*p = b
+	case *float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", raw)
+		}
+		*p = f
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return fmt.Errorf("must be a non-negative duration such as 30s, got %q", raw)
+		}
+		*p = d
+	}
+	return nil
+}
+
+func (s setting) get(c *Config) string {
+	switch p := s.field(c).(type) {
+	case *string:
+		return *p
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *float64:
+		return strconv.FormatFloat(*p, 'g', -1, 64)
+	case *time.Duration:
+		return p.String()
+	}
+	return ""
+}
+
+// flagValue holds a flag's raw value until it is applied with the other
+// sources. Boolean settings can be given as a bare -flag.
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	return v.raw
+}
+
+func (v *flagValue) Set(raw string) error {
+	v.raw = raw
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// configError lists every problem found while loading the configuration.
+type configError struct {
+	problems []string
+}
+
+func (e *configError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.problems, "\n  ")
+}
+
+func (e *configError) addf(format string, args ...any) {
+	e.problems = append(e.problems, fmt.Sprintf(format, args...))
+}
+
+// loadConfig builds the Config from args (without the program name) and
+// getenv, returning the arguments left after the flags. The config file is
+// named by -config or CHIRPY_CONFIG and .env is read from the working
+// directory if it exists.
+//
+// The returned Config is filled in as far as possible even when err is
+// non-nil, so it can still be printed.
+func loadConfig(args []string, getenv func(string) string) (Config, []string, error) {
+	cfg := defaultConfig()
+	problems := &configError{}
+
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("CHIRPY_CONFIG"), "path to a YAML or TOML config file")
+	for _, s := range settings {
+		_, isBool := s.field(&cfg).(*bool)
+		fs.Var(&flagValue{isBool: isBool}, s.flagName(), s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *configPath != "" {
+		values, err := readConfigFile(*configPath)
+		if err != nil {
+			problems.addf("%s: %s", *configPath, err)
+		}
+		cfg.apply("file", values, problems)
+	}
+
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		problems.addf(".env: %s", err)
+	}
+	cfg.apply(".env", dotenv, problems)
+
+	env := map[string]string{}
+	for _, s := range settings {
+		if v := getenv(s.key); v != "" {
+			env[s.key] = v
+		}
+	}
+	cfg.apply("env", env, problems)
+
+	set := map[string]string{}
+	for _, s := range settings {
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == s.flagName() {
+				set[s.key] = f.Value.String()
+			}
+		})
+	}
+	cfg.apply("flag", set, problems)
+
+	cfg.validate(problems)
+	if len(problems.problems) > 0 {
+		return cfg, fs.Args(), problems
+	}
+	return cfg, fs.Args(), nil
+}
+
+// apply sets every known key in values, which are keyed by setting key.
+func (c *Config) apply(source string, values map[string]string, problems *configError) {
+	for _, s := range settings {
+		raw, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.set(c, raw); err != nil {
+			problems.addf("%s (from %s) %s", s.key, source, err)
+			continue
+		}
+		c.sources[s.key] = source
+	}
+}
+
+func (c *Config) validate(problems *configError) {
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems.addf("PORT must be a number between 1 and 65535, got %q", c.Port)
+	}
+	if info, err := os.Stat(c.FileRoot); err != nil || !info.IsDir() {
+		problems.addf("FILE_ROOT must be an existing directory, got %q", c.FileRoot)
+	}
+	if c.DBURL == "" {
+		problems.addf("DB_URL is required")
+	}
+	if c.Platform != "dev" && c.Platform != "prod" {
+		problems.addf("PLATFORM must be dev or prod, got %q", c.Platform)
+	}
+	if c.Secret == "" {
+		problems.addf("SECRET is required")
+	}
+	if c.PolkaKey == "" {
+		problems.addf("POLKA_KEY is required")
+	}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems.addf("BASE_URL must be an http or https URL such as https://chirpy.example, got %q", c.BaseURL)
+		}
+		c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		problems.addf("LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		problems.addf("LOG_FORMAT must be text or json, got %q", c.LogFormat)
+	}
+	if !slices.Contains(tracing.Exporters, c.TraceExporter) {
+		problems.addf("TRACE_EXPORTER must be one of %s, got %q", strings.Join(tracing.Exporters, ", "), c.TraceExporter)
+	}
+	if c.TraceExporter == tracing.ExporterFile && c.TraceFile == "" {
+		problems.addf("TRACE_FILE is required when TRACE_EXPORTER is file")
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		problems.addf("TRACE_SAMPLE_RATIO must be between 0 and 1, got %g", c.TraceSampleRatio)
+	}
+	switch c.RateLimitStore {
+	case rateLimitMemory, rateLimitOff:
+	case rateLimitPostgres:
+		if _, ok := storage.SQLitePath(c.DBURL); ok {
+			problems.addf("RATE_LIMIT_STORE postgres needs a Postgres DB_URL")
+		}
+	default:
+		problems.addf("RATE_LIMIT_STORE must be memory, postgres or off, got %q", c.RateLimitStore)
+	}
+	if _, err := parseRateLimits(c.RateLimits); err != nil {
+		problems.addf("RATE_LIMITS %s", strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+	if c.ModerationRules != "" {
+		if _, err := readModerationRules(c.ModerationRules); err != nil {
+			problems.addf("MODERATION_RULES %s", strings.ReplaceAll(err.Error(), "\n", "; "))
+		}
+	}
+	if c.DeletionGracePeriod < 0 {
+		problems.addf("DELETION_GRACE_PERIOD must not be negative, got %s", c.DeletionGracePeriod)
+	}
+	if c.SMTPAddr != "" && c.SMTPFrom == "" {
+		problems.addf("SMTP_FROM is required when SMTP_ADDR is set")
+	}
+}
+
+// readConfigFile reads a flat YAML or TOML file, chosen by extension, and
+// returns its values keyed by setting key.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unknown config file type %q, want .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	var unknown []string
+	for k, v := range raw {
+		key := strings.ToUpper(k)
+		if !knownSetting(key) {
+			unknown = append(unknown, k)
+			continue
+		}
+		switch v.(type) {
+		case map[string]any, []any:
+			return nil, fmt.Errorf("%s must be a single value", k)
+		}
+		values[key] = fmt.Sprint(v)
+	}
+	if len(unknown) > 0 {
+		return values, fmt.Errorf("unknown settings %s", strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+func knownSetting(key string) bool {
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// print writes every setting as KEY=value with where it came from.
+// Secrets are redacted, as is the password in DB_URL.
+func (c *Config) print(w io.Writer) {
+	for _, s := range settings {
+		value := s.get(c)
+		switch {
+		case s.secret && value != "":
+			value = "[redacted]"
+		case s.key == "DB_URL":
+			value = redactDBURL(value)
+		}
+		source := c.sources[s.key]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(w, "%s=%s  # %s\n", s.key, value, source)
+	}
+}
+
+// dsnPassword matches a password=value pair, quoted or not, in a key=value
+// connection string or in a URL's query.
+var dsnPassword = regexp.MustCompile(`(?i)\bpassword\s*=\s*('(?:[^'\\]|\\.)*'|[^\s&]*)`)
+
+// redactDBURL hides the password in a database URL or in a key=value
+// connection string such as "host=db user=chirpy password=hunter2".
+func redactDBURL(dbURL string) string {
+	if u, err := url.Parse(dbURL); err == nil && u.Scheme != "" {
+		dbURL = u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dbURL, "password=xxxxx")
+}
+
+// runConfigCommand implements `chirpy config print`.
+func runConfigCommand(w io.Writer, cfg Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New("usage: chirpy config print")
+	}
+	cfg.print(w)
+	return nil
+}
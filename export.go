@@ -0,0 +1,388 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	exportInterval = 5 * time.Second
+	exportBatch    = 5
+	// exportRetention is how long a finished archive can be downloaded.
+	exportRetention = 7 * 24 * time.Hour
+	// exportURLTTL is how long a signed download URL works.
+	exportURLTTL = 15 * time.Minute
+	// exportNotificationPage is how many notifications are read at a time.
+	exportNotificationPage = 500
+)
+
+// exportArchive is data.json in a data export: everything Chirpy holds
+// about a user. Refresh tokens are listed as sessions without the tokens
+// themselves.
+type exportArchive struct {
+	GeneratedAt             time.Time                `json:"generated_at"`
+	Profile                 AdminUser                `json:"profile"`
+	Chirps                  []Chirp                  `json:"chirps"`
+	Likes                   []exportLike             `json:"likes"`
+	Following               []exportRelation         `json:"following"`
+	Followers               []exportRelation         `json:"followers"`
+	Blocks                  []exportRelation         `json:"blocks"`
+	Mutes                   []exportRelation         `json:"mutes"`
+	Sessions                []exportSession          `json:"sessions"`
+	Notifications           []Notification           `json:"notifications"`
+	NotificationPreferences []notificationPreference `json:"notification_preferences"`
+	Reports                 []exportReport           `json:"reports"`
+}
+
+type exportLike struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// exportRelation is another user the exported user follows, is followed
+// by, blocks or mutes.
+type exportRelation struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// exportReport is a report the user filed.
+type exportReport struct {
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details,omitempty"`
+	Resolution string    `json:"resolution,omitempty"`
+}
+
+// collectExport gathers everything about userID for an archive.
+func (cfg *apiConfig) collectExport(ctx context.Context, userID uuid.UUID) (exportArchive, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	out := exportArchive{
+		GeneratedAt: time.Now().UTC(),
+		Profile:     adminUserFromDB(user),
+		Chirps:      []Chirp{},
+		Likes:       []exportLike{},
+		Following:   []exportRelation{},
+		Followers:   []exportRelation{},
+		Blocks:      []exportRelation{},
+		Mutes:       []exportRelation{},
+		Sessions:    []exportSession{},
+		Reports:     []exportReport{},
+	}
+
+	chirps, err := cfg.db.GetChirpsByUserID(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	slices.SortFunc(chirps, func(a, b database.Chirp) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, c := range chirps {
+		out.Chirps = append(out.Chirps, chirpFromDB(c))
+	}
+
+	likes, err := cfg.db.ListUserLikes(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	for _, l := range likes {
+		out.Likes = append(out.Likes, exportLike{ChirpID: l.ChirpID, CreatedAt: l.CreatedAt})
+	}
+
+	follows, err := cfg.db.ListUserFollows(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	for _, f := range follows {
+		if f.FollowerID == userID {
+			out.Following = append(out.Following, exportRelation{UserID: f.FolloweeID, CreatedAt: f.CreatedAt})
+		} else {
+			out.Followers = append(out.Followers, exportRelation{UserID: f.FollowerID, CreatedAt: f.CreatedAt})
+		}
+	}
+
+	blocks, err := cfg.db.ListBlocks(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	for _, b := range blocks {
+		out.Blocks = append(out.Blocks, exportRelation{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+	mutes, err := cfg.db.ListMutes(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	for _, m := range mutes {
+		out.Mutes = append(out.Mutes, exportRelation{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+	}
+
+	tokens, err := cfg.db.ListUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	for _, t := range tokens {
+		session := exportSession{CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+		if t.RevokedAt.Valid {
+			session.RevokedAt = &t.RevokedAt.Time
+		}
+		out.Sessions = append(out.Sessions, session)
+	}
+
+	out.Notifications, err = cfg.allNotifications(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	out.NotificationPreferences, err = cfg.notificationPreferences(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+
+	reports, err := cfg.db.ListUserChirpReports(ctx, userID)
+	if err != nil {
+		return exportArchive{}, err
+	}
+	for _, r := range reports {
+		out.Reports = append(out.Reports, exportReport{
+			ChirpID:    r.ChirpID,
+			CreatedAt:  r.CreatedAt,
+			Reason:     r.Reason,
+			Details:    r.Details,
+			Resolution: r.Resolution.String,
+		})
+	}
+	return out, nil
+}
+
+// allNotifications pages through every notification of userID, newest
+// first.
+func (cfg *apiConfig) allNotifications(ctx context.Context, userID uuid.UUID) ([]Notification, error) {
+	out := []Notification{}
+	before, beforeID := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), uuid.Nil
+	for {
+		rows, err := cfg.db.ListNotifications(ctx, database.ListNotificationsParams{
+			UserID:   userID,
+			Before:   before,
+			BeforeID: beforeID,
+			PageSize: exportNotificationPage,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range rows {
+			out = append(out, notificationFromDB(n))
+		}
+		if len(rows) < exportNotificationPage {
+			return out, nil
+		}
+		last := rows[len(rows)-1]
+		before, beforeID = last.CreatedAt, last.ID
+	}
+}
+
+var exportIndex = template.Must(template.New("index.html").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Your Chirpy data</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.25em 0.5em; border-bottom: 1px solid #ddd; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Your Chirpy data</h1>
+<p>Exported {{date .GeneratedAt}}. The same data is in data.json.</p>
+
+<h2>Profile</h2>
+<table>
+<tr><th>ID</th><td>{{.Profile.ID}}</td></tr>
+<tr><th>Email</th><td>{{.Profile.Email}}</td></tr>
+{{with .Profile.Username}}<tr><th>Username</th><td>{{.}}</td></tr>{{end}}
+<tr><th>Joined</th><td>{{date .Profile.CreatedAt}}</td></tr>
+<tr><th>Role</th><td>{{.Profile.Role}}</td></tr>
+<tr><th>Chirpy Red</th><td>{{if .Profile.IsChirpyRed}}yes{{else}}no{{end}}</td></tr>
+{{with .Profile.SuspendedUntil}}<tr><th>Suspended until</th><td>{{date .}}</td></tr>{{end}}
+</table>
+
+<h2>Chirps ({{len .Chirps}})</h2>
+<table>
+{{range .Chirps}}<tr><td>{{date .CreatedAt}}</td><td>{{.Body}}</td><td>{{if ne .Status "published"}}{{.Status}}{{end}}</td></tr>
+{{else}}<tr><td>None.</td></tr>
+{{end}}</table>
+
+<h2>Likes ({{len .Likes}})</h2>
+<table>
+{{range .Likes}}<tr><td>{{date .CreatedAt}}</td><td>chirp {{.ChirpID}}</td></tr>
+{{else}}<tr><td>None.</td></tr>
+{{end}}</table>
+
+{{define "relations"}}<table>
+{{range .}}<tr><td>{{date .CreatedAt}}</td><td>user {{.UserID}}</td></tr>
+{{else}}<tr><td>None.</td></tr>
+{{end}}</table>{{end}}
+<h2>Following ({{len .Following}})</h2>
+{{template "relations" .Following}}
+<h2>Followers ({{len .Followers}})</h2>
+{{template "relations" .Followers}}
+<h2>Blocked ({{len .Blocks}})</h2>
+{{template "relations" .Blocks}}
+<h2>Muted ({{len .Mutes}})</h2>
+{{template "relations" .Mutes}}
+
+<h2>Sessions ({{len .Sessions}})</h2>
+<table>
+<tr><th>Signed in</th><th>Expires</th><th>Signed out</th></tr>
+{{range .Sessions}}<tr><td>{{date .CreatedAt}}</td><td>{{date .ExpiresAt}}</td><td>{{with .RevokedAt}}{{date .}}{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Notifications ({{len .Notifications}})</h2>
+<table>
+{{range .Notifications}}<tr><td>{{date .CreatedAt}}</td><td>{{.Kind}} from user {{.ActorID}}</td><td>{{if .Read}}read{{end}}</td></tr>
+{{else}}<tr><td>None.</td></tr>
+{{end}}</table>
+
+<h2>Notification settings</h2>
+<table>
+<tr><th>Kind</th><th>In the app</th><th>By email</th></tr>
+{{range .NotificationPreferences}}<tr><td>{{.Kind}}</td><td>{{if .InApp}}yes{{else}}no{{end}}</td><td>{{if .Email}}yes{{else}}no{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Reports you filed ({{len .Reports}})</h2>
+<table>
+{{range .Reports}}<tr><td>{{date .CreatedAt}}</td><td>chirp {{.ChirpID}}</td><td>{{.Reason}}{{with .Details}}: {{.}}{{end}}</td><td>{{.Resolution}}</td></tr>
+{{else}}<tr><td>None.</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// buildExportArchive zips data.json and an index.html for reading it in a
+// browser.
+func (cfg *apiConfig) buildExportArchive(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	data, err := cfg.collectExport(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var index bytes.Buffer
+	if err := exportIndex.Execute(&index, data); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"chirpy-export/index.html", index.Bytes()},
+		{"chirpy-export/data.json", jsonData},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: data.GeneratedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (cfg *apiConfig) runExportBuilder(ctx context.Context) {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg.buildDueExports(ctx)
+		}
+	}
+}
+
+// buildDueExports deletes expired archives and builds the ones waiting.
+// An export whose instance died while building it is claimed again once
+// its lease runs out.
+func (cfg *apiConfig) buildDueExports(ctx context.Context) {
+	if err := cfg.db.DeleteDataExportsBefore(ctx, time.Now().UTC().Add(-exportRetention)); err != nil {
+		logging.FromContext(ctx).Error("Could not delete expired data exports", logging.Err(err))
+	}
+
+	exports, err := cfg.db.ClaimDataExports(ctx, exportBatch)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not claim data exports", logging.Err(err))
+		return
+	}
+	for _, e := range exports {
+		archive, err := cfg.buildExportArchive(ctx, e.UserID)
+		var failure sql.NullString
+		if err != nil {
+			logging.FromContext(ctx).Error("Could not build data export", "export_id", e.ID.String(), "user_id", e.UserID.String(), logging.Err(err))
+			failure = sql.NullString{String: err.Error(), Valid: true}
+		}
+		err = cfg.db.CompleteDataExport(ctx, database.CompleteDataExportParams{Archive: archive, Error: failure, ID: e.ID})
+		if err != nil {
+			logging.FromContext(ctx).Error("Could not store data export", "export_id", e.ID.String(), logging.Err(err))
+		}
+	}
+}
+
+// exportSignature signs a download link for an export that works until
+// expires.
+func (cfg *apiConfig) exportSignature(exportID uuid.UUID, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(cfg.secret))
+	mac.Write([]byte("data-export\n" + exportID.String() + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// exportDownloadURL returns a signed link to an export's archive and when
+// it stops working.
+func (cfg *apiConfig) exportDownloadURL(exportID uuid.UUID) (string, time.Time) {
+	expires := time.Now().Add(exportURLTTL).Truncate(time.Second)
+	unix := expires.Unix()
+	return cfg.baseURL + "/api/exports/" + exportID.String() + "/download?expires=" + strconv.FormatInt(unix, 10) +
+		"&signature=" + cfg.exportSignature(exportID, unix), expires.UTC()
+}
+
+// validExportSignature checks a download link's signature and expiry.
+func (cfg *apiConfig) validExportSignature(exportID uuid.UUID, expires, signature string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(cfg.exportSignature(exportID, unix)))
+}
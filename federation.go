@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/activitypub"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+const (
+	deliveryInterval    = 5 * time.Second
+	deliveryBatch       = 20
+	deliveryMaxAttempts = 8
+	// deliveryDrainTimeout bounds the last round of deliveries made while
+	// shutting down.
+	deliveryDrainTimeout = 30 * time.Second
+)
+
+// federationEnabled reports whether ActivityPub is switched on. Actor and
+// object IDs must be stable public URLs, so it needs BASE_URL.
+func (cfg *apiConfig) federationEnabled() bool {
+	return cfg.baseURL != ""
+}
+
+func (cfg *apiConfig) actorURL(userID uuid.UUID) string {
+	return fmt.Sprintf("%s/ap/users/%s", cfg.baseURL, userID)
+}
+
+func (cfg *apiConfig) actorKeyID(userID uuid.UUID) string {
+	return cfg.actorURL(userID) + "#main-key"
+}
+
+func (cfg *apiConfig) noteURL(chirpID uuid.UUID) string {
+	return fmt.Sprintf("%s/ap/chirps/%s", cfg.baseURL, chirpID)
+}
+
+// actorKey returns the user's signing key, generating it the first time the
+// user is seen by the fediverse.
+func (cfg *apiConfig) actorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	key, err := cfg.db.GetActorKey(ctx, userID)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.ActorKey{}, err
+	}
+
+	publicPEM, privatePEM, err := activitypub.GenerateKeyPair()
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	// Two requests may race to create the key, the loser's insert is a no-op
+	// and both read back the winner's key.
+	err = cfg.db.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:        userID,
+		PublicKeyPem:  publicPEM,
+		PrivateKeyPem: privatePEM,
+	})
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	return cfg.db.GetActorKey(ctx, userID)
+}
+
+func (cfg *apiConfig) signingKey(ctx context.Context, userID uuid.UUID) (*rsa.PrivateKey, error) {
+	key, err := cfg.actorKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return activitypub.ParsePrivateKey(key.PrivateKeyPem)
+}
+
+func (cfg *apiConfig) chirpNote(chirp database.Chirp) activitypub.Note {
+	return activitypub.Note{
+		ID:           cfg.noteURL(chirp.ID),
+		Type:         "Note",
+		AttributedTo: cfg.actorURL(chirp.UserID),
+		Content:      "<p>" + html.EscapeString(chirp.Body) + "</p>",
+		Published:    chirpTime(chirp).UTC().Format(time.RFC3339),
+		To:           []string{activitypub.Public},
+		Cc:           []string{cfg.actorURL(chirp.UserID) + "/followers"},
+	}
+}
+
+func (cfg *apiConfig) createActivity(chirp database.Chirp) activitypub.Activity {
+	note := cfg.chirpNote(chirp)
+	return activitypub.Activity{
+		ID:        note.ID + "/activity",
+		Type:      "Create",
+		Actor:     note.AttributedTo,
+		Object:    note,
+		Published: note.Published,
+		To:        note.To,
+		Cc:        note.Cc,
+	}
+}
+
+// federateChirp queues a Create or Delete for every remote server that
+// follows the author. Delivery happens in the background.
+func (cfg *apiConfig) federateChirp(ctx context.Context, kind string, chirp database.Chirp) {
+	if !cfg.federationEnabled() {
+		return
+	}
+
+	var activity activitypub.Activity
+	switch kind {
+	case stream.EventChirpCreated:
+		activity = cfg.createActivity(chirp)
+	case stream.EventChirpDeleted:
+		activity = activitypub.Activity{
+			ID:     cfg.noteURL(chirp.ID) + "#delete",
+			Type:   "Delete",
+			Actor:  cfg.actorURL(chirp.UserID),
+			Object: map[string]string{"id": cfg.noteURL(chirp.ID), "type": "Tombstone"},
+			To:     []string{activitypub.Public},
+		}
+	default:
+		return
+	}
+
+	inboxes, err := cfg.db.GetRemoteFollowerInboxes(ctx, chirp.UserID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not get remote followers", "user_id", chirp.UserID, logging.Err(err))
+		return
+	}
+	for _, inbox := range inboxes {
+		cfg.enqueueActivity(ctx, chirp.UserID, inbox, activity)
+	}
+}
+
+func (cfg *apiConfig) enqueueActivity(ctx context.Context, userID uuid.UUID, inbox string, activity activitypub.Activity) {
+	activity.Context = activitypub.Context
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not marshal activity", "type", activity.Type, logging.Err(err))
+		return
+	}
+
+	err = cfg.db.EnqueueDelivery(ctx, database.EnqueueDeliveryParams{
+		UserID:  userID,
+		Inbox:   inbox,
+		Payload: string(payload),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not queue activity", "type", activity.Type, "inbox", inbox, logging.Err(err))
+	}
+}
+
+// runDeliveryWorker drains the delivery queue until ctx is cancelled. Rows
+// are claimed with SKIP LOCKED, so several instances can run it at once.
+// It is stopped after everything that queues deliveries and makes one last
+// round then, so the activities queued just before go out. The queue is
+// stored, so anything still left is delivered once an instance is back.
+func (cfg *apiConfig) runDeliveryWorker(ctx context.Context) {
+	ticker := time.NewTicker(deliveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deliveryDrainTimeout)
+			defer cancel()
+			cfg.deliverDue(drainCtx)
+			return
+		case <-ticker.C:
+			cfg.deliverDue(ctx)
+		}
+	}
+}
+
+func (cfg *apiConfig) deliverDue(ctx context.Context) {
+	deliveries, err := cfg.db.ClaimDeliveries(ctx, database.ClaimDeliveriesParams{
+		Attempts: deliveryMaxAttempts,
+		Limit:    deliveryBatch,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not claim ActivityPub deliveries", logging.Err(err))
+		return
+	}
+
+	for _, d := range deliveries {
+		err := cfg.deliver(ctx, d)
+		if err == nil {
+			if err := cfg.db.DeleteDelivery(ctx, d.ID); err != nil {
+				logging.FromContext(ctx).Error("Could not remove delivery", "delivery_id", d.ID, logging.Err(err))
+			}
+			continue
+		}
+
+		// Claiming counted this attempt, so a failed last attempt is dropped
+		// rather than left in the queue where nothing would claim it again.
+		if d.Attempts >= deliveryMaxAttempts {
+			logging.FromContext(ctx).Error("Giving up on delivery", "delivery_id", d.ID, "inbox", d.Inbox, "attempts", d.Attempts, logging.Err(err))
+			if err := cfg.db.DeleteDelivery(ctx, d.ID); err != nil {
+				logging.FromContext(ctx).Error("Could not remove delivery", "delivery_id", d.ID, logging.Err(err))
+			}
+			continue
+		}
+		logging.FromContext(ctx).Warn("Delivery failed", "delivery_id", d.ID, "inbox", d.Inbox, "attempt", d.Attempts, logging.Err(err))
+		err = cfg.db.RescheduleDelivery(ctx, database.RescheduleDeliveryParams{
+			NextAttemptAt: time.Now().UTC().Add(deliveryBackoff(d.Attempts)),
+			LastError:     sql.NullString{String: err.Error(), Valid: true},
+			ID:            d.ID,
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Could not reschedule delivery", "delivery_id", d.ID, logging.Err(err))
+		}
+	}
+}
+
+func (cfg *apiConfig) deliver(ctx context.Context, d database.ApDelivery) error {
+	key, err := cfg.signingKey(ctx, d.UserID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return cfg.ap.Deliver(ctx, d.Inbox, cfg.actorKeyID(d.UserID), key, []byte(d.Payload))
+}
+
+// deliveryBackoff doubles the wait after every failed attempt, starting at
+// 30 seconds and capped at six hours.
+func deliveryBackoff(attempts int32) time.Duration {
+	backoff := 30 * time.Second
+	for i := int32(1); i < attempts; i++ {
+		backoff *= 2
+		if backoff >= 6*time.Hour {
+			return 6 * time.Hour
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/activitypub"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/store/memory"
+	"github.com/google/uuid"
+)
+
+func TestDeliveryGivesUp(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	cfg.ap = activitypub.NewClient("chirpy-test")
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+
+	store := cfg.db.(*memory.Store)
+	now := time.Now()
+	store.Now = func() time.Time { return now }
+	// Plain http is refused before anything is sent, so every attempt fails.
+	err := cfg.db.EnqueueDelivery(t.Context(), database.EnqueueDeliveryParams{UserID: alice.ID, Inbox: "http://example.com/inbox", Payload: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range deliveryMaxAttempts {
+		cfg.deliverDue(t.Context())
+		// Past the longest backoff.
+		now = now.Add(7 * time.Hour)
+	}
+	left, err := cfg.db.ClaimDeliveries(t.Context(), database.ClaimDeliveriesParams{Attempts: 2 * deliveryMaxAttempts, Limit: 10})
+	if err != nil || len(left) != 0 {
+		t.Errorf("deliveries left after %d failed attempts: %+v, %v", deliveryMaxAttempts, left, err)
+	}
+}
+
+func TestLocalActor(t *testing.T) {
+	cfg := &apiConfig{baseURL: "https://chirpy.test"}
+	id := uuid.New()
+	if got, err := cfg.localActor(cfg.actorURL(id)); err != nil || got != id {
+		t.Errorf("localActor(our actor) = %s, %v", got, err)
+	}
+	for _, actor := range []string{
+		id.String(),
+		"https://remote.test/ap/users/" + id.String(),
+		"https://chirpy.test/ap/users/not-a-uuid",
+	} {
+		if got, err := cfg.localActor(actor); err == nil {
+			t.Errorf("localActor(%q) = %s, want an error", actor, got)
+		}
+	}
+}
+
+func TestInboxErrors(t *testing.T) {
+	cfg := &apiConfig{baseURL: "https://chirpy.test"}
+	undo := activitypub.IncomingActivity{
+		Type:   "Undo",
+		Actor:  "https://remote.test/users/bob",
+		Object: json.RawMessage(`{"type": "Follow", "object": "https://remote.test/users/alice"}`),
+	}
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{cfg.inboxUndo(nil, undo), http.StatusBadRequest},
+		{sql.ErrNoRows, http.StatusNotFound},
+		{fmt.Errorf("%w: %w", errActorFetch, errors.New("connection refused")), http.StatusBadGateway},
+		{errors.New("database is locked"), http.StatusInternalServerError},
+	} {
+		w := httptest.NewRecorder()
+		respondWithInboxError(w, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("%v: status %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -3,17 +3,11 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"strings"
-)
 
+	"github.com/enderbd/chirpy/internal/logging"
+)
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(http.StatusText(http.StatusOK)))
-}
 
 func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type",  "text/html; charset=utf-8")
@@ -58,12 +52,11 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 }
 
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
-	}
+	// The error goes on the request's access log line.
+	recordError(w, err)
 
 	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+		responseLogger(w).Error("Responding with 5XX error", "message", msg)
 	}
 
 	type errorResponse struct {
@@ -79,10 +72,16 @@ func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
 
 
 func respondWithJson(w http.ResponseWriter, code int, payload any) {
-	w.Header().Set("Content-Type", "application/json")
+	respondWithTypedJson(w, code, "application/json", payload)
+}
+
+// respondWithTypedJson is respondWithJson for JSON dialects such as
+// application/activity+json.
+func respondWithTypedJson(w http.ResponseWriter, code int, contentType string, payload any) {
+	w.Header().Set("Content-Type", contentType)
 	data, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		responseLogger(w).Error("Error marshalling JSON", logging.Err(err))
 		w.WriteHeader(500)
 		return
 	}
@@ -90,23 +89,3 @@ func respondWithJson(w http.ResponseWriter, code int, payload any) {
 	w.WriteHeader(code)
 	w.Write(data)
 }
-
-func removeProfanity(body string) string {
-	badWords := map[string]struct{} {
-		"kerfuffle": {}, 
-		"sharbert": {},
-		"fornax": {},
-	}
-	words := strings.Split(body, " ")
-
-
-	for i, word := range words {
-		lowered := strings.ToLower(word)
-		if _, ok := badWords[lowered]; ok {
-			words[i] = "****"
-		}
-	}
-	
-	cleaned := strings.Join(words, " ")
-	return cleaned
-}
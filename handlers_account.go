@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+// accountPurgeInterval is how often accounts past their grace period are
+// deleted.
+const accountPurgeInterval = time.Hour
+
+// handlerDeleteAccount deletes the signed in user's account once they
+// confirm their password. At first the account is only withdrawn: the user
+// is signed out everywhere and their chirps are set aside. Logging in again
+// within the grace period restores it; after that the purger deletes it for
+// good.
+func (cfg *apiConfig) handlerDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.bearerUser(w, r)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		Password string `json:"password"`
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+	match, err := auth.CheckPasswordHash(r.Context(), params.Password, user.HashedPassword)
+	if err != nil || !match {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", err)
+		return
+	}
+
+	deletedAt := time.Now().UTC()
+	if err := cfg.withdrawAccount(r.Context(), user.ID, deletedAt); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete the account", err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Account deleted", "user_id", user.ID.String())
+
+	type response struct {
+		DeletedAt time.Time `json:"deleted_at"`
+		PurgeAt   time.Time `json:"purge_at"`
+	}
+	respondWithJson(w, http.StatusAccepted, response{DeletedAt: deletedAt, PurgeAt: deletedAt.Add(cfg.deletionGrace)})
+}
+
+// withdrawAccount marks a user's account deleted at at, signs them out
+// everywhere and sets their published chirps aside, telling stream clients
+// and remote followers they are gone.
+func (cfg *apiConfig) withdrawAccount(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	err := cfg.db.SetUserDeleted(ctx, database.SetUserDeletedParams{
+		DeletedAt: sql.NullTime{Time: at, Valid: true},
+		ID:        userID,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.db.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := cfg.db.RevokeAccessTokens(ctx, userID); err != nil {
+		return err
+	}
+	chirps, err := cfg.db.SetUserChirpsStatus(ctx, database.SetUserChirpsStatusParams{
+		ToStatus:   chirpWithdrawn,
+		UserID:     userID,
+		FromStatus: chirpPublished,
+	})
+	if err != nil {
+		return err
+	}
+	for _, chirp := range chirps {
+		cfg.publishChirpEvent(ctx, stream.EventChirpDeleted, chirp)
+		cfg.federateChirp(ctx, stream.EventChirpDeleted, chirp)
+	}
+	return nil
+}
+
+// restoreAccount undoes withdrawAccount, announcing the chirps again.
+// Mentions and replies were notified the first time and aren't again.
+func (cfg *apiConfig) restoreAccount(ctx context.Context, userID uuid.UUID) error {
+	if err := cfg.db.SetUserDeleted(ctx, database.SetUserDeletedParams{ID: userID}); err != nil {
+		return err
+	}
+	chirps, err := cfg.db.SetUserChirpsStatus(ctx, database.SetUserChirpsStatusParams{
+		ToStatus:   chirpPublished,
+		UserID:     userID,
+		FromStatus: chirpWithdrawn,
+	})
+	if err != nil {
+		return err
+	}
+	for _, chirp := range chirps {
+		cfg.publishChirpEvent(ctx, stream.EventChirpCreated, chirp)
+		cfg.federateChirp(ctx, stream.EventChirpCreated, chirp)
+	}
+	return nil
+}
+
+// restorable reports whether a deleted account's grace period is still
+// running.
+func (cfg *apiConfig) restorable(user database.User) bool {
+	return time.Since(user.DeletedAt.Time) < cfg.deletionGrace
+}
+
+func (cfg *apiConfig) runAccountPurger(ctx context.Context) {
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.purgeDeletedAccounts(ctx); err != nil {
+				logging.FromContext(ctx).Error("Could not purge deleted accounts", logging.Err(err))
+			}
+		}
+	}
+}
+
+// purgeDeletedAccounts deletes the accounts whose grace period is over.
+// Everything that refers to a user goes with them through the foreign
+// keys: chirps, tokens, follows, likes, blocks, notifications, reports,
+// the stream's event log, federation keys and followers, and the queued
+// deliveries that carry their chirps to other servers. Stream clients and
+// remote followers were told the chirps were deleted when the account was
+// withdrawn. Polka webhooks are never stored, and the logs only ever name
+// users by ID, which no longer leads anywhere.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context) error {
+	ids, err := cfg.db.PurgeDeletedUsers(ctx, sql.NullTime{Time: time.Now().UTC().Add(-cfg.deletionGrace), Valid: true})
+	for _, id := range ids {
+		logging.FromContext(ctx).Info("Account purged", "user_id", id.String())
+	}
+	return err
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/enderbd/chirpy/internal/activitypub"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	outboxMaxItems = 20
+	maxInboxBody   = 1 << 20
+)
+
+// errBadActivity marks an activity that will never be accepted, so the
+// sender shouldn't send it again. errActorFetch marks a failure to fetch
+// the sender's actor, which may well work next time.
+var (
+	errBadActivity = errors.New("malformed activity")
+	errActorFetch  = errors.New("could not fetch the actor")
+)
+
+func preferredUsername(user database.User) string {
+	if user.Username.Valid {
+		return user.Username.String
+	}
+	return user.ID.String()
+}
+
+func (cfg *apiConfig) handlerWebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing resource parameter", nil)
+		return
+	}
+
+	base, err := url.Parse(cfg.baseURL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "BASE_URL is not a valid URL", err)
+		return
+	}
+
+	var user database.User
+	switch {
+	case strings.HasPrefix(resource, "acct:"):
+		name, host, ok := strings.Cut(strings.TrimPrefix(resource, "acct:"), "@")
+		if !ok || !strings.EqualFold(host, base.Host) {
+			respondWithError(w, http.StatusNotFound, "User not found", nil)
+			return
+		}
+		user, err = cfg.userByHandle(r, name)
+	case strings.HasPrefix(resource, cfg.baseURL+"/ap/users/"):
+		user, err = cfg.userByHandle(r, strings.TrimPrefix(resource, cfg.baseURL+"/ap/users/"))
+	default:
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	actor := cfg.actorURL(user.ID)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	respondWithTypedJson(w, http.StatusOK, activitypub.JRDContentType, activitypub.WebFinger{
+		Subject: "acct:" + preferredUsername(user) + "@" + base.Host,
+		Aliases: []string{actor},
+		Links: []activitypub.WebFingerLink{
+			{Rel: "self", Type: activitypub.ContentType, Href: actor},
+		},
+	})
+}
+
+// userByHandle accepts either a username or a user ID.
+func (cfg *apiConfig) userByHandle(r *http.Request, handle string) (database.User, error) {
+	if id, err := uuid.Parse(handle); err == nil {
+		return cfg.db.GetUserByID(r.Context(), id)
+	}
+	return cfg.db.GetUserByUsername(r.Context(), nullString(strings.ToLower(handle)))
+}
+
+func (cfg *apiConfig) handlerActor(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.apUser(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := cfg.actorKey(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not load the actor key", err)
+		return
+	}
+
+	actor := cfg.actorURL(user.ID)
+	respondWithTypedJson(w, http.StatusOK, activitypub.ContentType, activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                actor,
+		Type:              "Person",
+		PreferredUsername: preferredUsername(user),
+		URL:               cfg.baseURL + "/api/chirps?author_id=" + user.ID.String(),
+		Inbox:             actor + "/inbox",
+		Outbox:            actor + "/outbox",
+		Followers:         actor + "/followers",
+		PublicKey: activitypub.PublicKey{
+			ID:           cfg.actorKeyID(user.ID),
+			Owner:        actor,
+			PublicKeyPem: key.PublicKeyPem,
+		},
+		Endpoints: &activitypub.Endpoints{SharedInbox: cfg.baseURL + "/ap/inbox"},
+	})
+}
+
+func (cfg *apiConfig) handlerOutbox(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.apUser(w, r)
+	if !ok {
+		return
+	}
+
+	chirps, err := cfg.db.GetChirpsByUserID(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not the chirps for the provided user", err)
+		return
+	}
+	chirps = publishedChirps(chirps)
+	sort.Slice(chirps, func(i, j int) bool {
+		return chirpTime(chirps[i]).After(chirpTime(chirps[j]))
+	})
+
+	outbox := activitypub.OrderedCollection{
+		Context:    activitypub.Context,
+		ID:         cfg.actorURL(user.ID) + "/outbox",
+		Type:       "OrderedCollection",
+		TotalItems: len(chirps),
+	}
+	for i, chirp := range chirps {
+		if i == outboxMaxItems {
+			break
+		}
+		outbox.OrderedItems = append(outbox.OrderedItems, cfg.createActivity(chirp))
+	}
+	respondWithTypedJson(w, http.StatusOK, activitypub.ContentType, outbox)
+}
+
+func (cfg *apiConfig) handlerFollowers(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.apUser(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := cfg.db.CountRemoteFollowers(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not count followers", err)
+		return
+	}
+
+	// Follower lists are private, only the size is published.
+	respondWithTypedJson(w, http.StatusOK, activitypub.ContentType, activitypub.OrderedCollection{
+		Context:    activitypub.Context,
+		ID:         cfg.actorURL(user.ID) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: int(count),
+	})
+}
+
+func (cfg *apiConfig) handlerNote(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is not a valid uuid", err)
+		return
+	}
+
+	chirp, err := cfg.db.GetSingleChirp(r.Context(), chirpUUID)
+	if err == nil && chirp.Status != chirpPublished {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	note := cfg.chirpNote(chirp)
+	note.Context = activitypub.Context
+	respondWithTypedJson(w, http.StatusOK, activitypub.ContentType, note)
+}
+
+func (cfg *apiConfig) apUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return database.User{}, false
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// handlerInbox serves both the per-user and the shared inbox. Every
+// request must carry an HTTP Signature from the actor it claims to be from.
+func (cfg *apiConfig) handlerInbox(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxInboxBody))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not read the request body", err)
+		return
+	}
+
+	signer, err := activitypub.VerifyRequest(r, body, cfg.ap.LookupKey)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Could not verify the HTTP signature", err)
+		return
+	}
+
+	var activity activitypub.IncomingActivity
+	err = json.Unmarshal(body, &activity)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode the activity", err)
+		return
+	}
+	if activity.Actor != signer {
+		respondWithError(w, http.StatusUnauthorized, "Activity actor does not match the signature", nil)
+		return
+	}
+
+	// Anything else, such as a Create for a remote note, is accepted and
+	// ignored.
+	switch activity.Type {
+	case "Follow":
+		err = cfg.inboxFollow(r, activity)
+	case "Undo":
+		err = cfg.inboxUndo(r, activity)
+	case "Delete":
+		err = cfg.inboxDelete(r, activity)
+	}
+	if err != nil {
+		respondWithInboxError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// respondWithInboxError tells the sender whether an activity it sent was
+// rejected for good, with a 4xx, or may be accepted if sent again, with a
+// 5xx.
+func respondWithInboxError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errBadActivity):
+		respondWithError(w, http.StatusBadRequest, "Could not process the activity", err)
+	case errors.Is(err, sql.ErrNoRows):
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+	case errors.Is(err, errActorFetch):
+		respondWithError(w, http.StatusBadGateway, "Could not fetch the actor", err)
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Could not process the activity", err)
+	}
+}
+
+func (cfg *apiConfig) inboxFollow(r *http.Request, activity activitypub.IncomingActivity) error {
+	userID, err := cfg.localActor(activitypub.ObjectID(activity.Object))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errBadActivity, err)
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), userID); err != nil {
+		return err
+	}
+
+	remote, err := cfg.ap.FetchActor(r.Context(), activity.Actor)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errActorFetch, err)
+	}
+	err = cfg.db.AddRemoteFollower(r.Context(), database.AddRemoteFollowerParams{
+		UserID:  userID,
+		ActorID: remote.ID,
+		Inbox:   remote.DeliveryInbox(),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.enqueueActivity(r.Context(), userID, remote.Inbox, activitypub.Activity{
+		ID:     cfg.actorURL(userID) + "#accepts/" + uuid.NewString(),
+		Type:   "Accept",
+		Actor:  cfg.actorURL(userID),
+		Object: activity,
+	})
+	return nil
+}
+
+func (cfg *apiConfig) inboxUndo(r *http.Request, activity activitypub.IncomingActivity) error {
+	inner := activitypub.ParseObject(activity.Object)
+	if inner.Type != "Follow" {
+		return nil
+	}
+	userID, err := cfg.localActor(activitypub.ObjectID(inner.Object))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errBadActivity, err)
+	}
+	return cfg.db.RemoveRemoteFollower(r.Context(), database.RemoveRemoteFollowerParams{
+		UserID:  userID,
+		ActorID: activity.Actor,
+	})
+}
+
+// inboxDelete forgets a remote actor that deleted itself. Deletes of its
+// notes need nothing, as they aren't kept.
+func (cfg *apiConfig) inboxDelete(r *http.Request, activity activitypub.IncomingActivity) error {
+	if activitypub.ObjectID(activity.Object) != activity.Actor {
+		return nil
+	}
+	return cfg.db.RemoveRemoteActor(r.Context(), activity.Actor)
+}
+
+// localActor maps one of our actor URLs back to the user ID.
+func (cfg *apiConfig) localActor(actorURL string) (uuid.UUID, error) {
+	id, ok := strings.CutPrefix(actorURL, cfg.baseURL+"/ap/users/")
+	if !ok {
+		return uuid.Nil, fmt.Errorf("%q is not a local actor", actorURL)
+	}
+	return uuid.Parse(id)
+}
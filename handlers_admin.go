@@ -0,0 +1,306 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	adminUsersDefaultLimit = 50
+	adminUsersMaxLimit     = 200
+)
+
+// AdminUser is a user as the admin API sees them.
+type AdminUser struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Email          string     `json:"email"`
+	Username       string     `json:"username,omitempty"`
+	Role           string     `json:"role"`
+	IsChirpyRed    bool       `json:"is_chirpy_red"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	Banned         bool       `json:"banned"`
+	BanReason      string     `json:"ban_reason,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+func adminUserFromDB(u database.User) AdminUser {
+	out := AdminUser{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Email:       u.Email,
+		Username:    u.Username.String,
+		Role:        u.Role,
+		IsChirpyRed: u.IsChirpyRed,
+	}
+	if suspended(u) {
+		out.SuspendedUntil = &u.SuspendedUntil.Time
+		out.Banned = banned(u)
+		out.BanReason = u.BanReason
+	}
+	if u.DeletedAt.Valid {
+		out.DeletedAt = &u.DeletedAt.Time
+	}
+	return out
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// logAdminAction records who did what to which user.
+func logAdminAction(r *http.Request, action string, target uuid.UUID) {
+	by := "admin_key"
+	if admin, ok := contextUser(r.Context()); ok {
+		by = admin.ID.String()
+	}
+	logging.FromContext(r.Context()).Info("Admin action", "action", action, "user_id", target.String(), "by", by)
+}
+
+// adminTargetUser loads the user named in the path, writing a 404 when
+// there isn't one.
+func (cfg *apiConfig) adminTargetUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "User ID is not a valid uuid", err)
+		return database.User{}, false
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get the user", err)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// handlerListUsers lists users, oldest first, optionally matching q
+// against email and username or filtered to one role.
+func (cfg *apiConfig) handlerListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := adminUsersDefaultLimit
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > adminUsersMaxLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 200", err)
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if s := query.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			respondWithError(w, http.StatusBadRequest, "offset must be a non-negative number", err)
+			return
+		}
+		offset = n
+	}
+	role := query.Get("role")
+	if _, ok := roleRank[role]; role != "" && !ok {
+		respondWithError(w, http.StatusBadRequest, "role must be user, moderator or admin", nil)
+		return
+	}
+
+	users, err := cfg.db.SearchUsers(r.Context(), database.SearchUsersParams{
+		Query:      likeEscaper.Replace(strings.TrimSpace(query.Get("q"))),
+		Role:       role,
+		PageSize:   int32(limit),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not list users", err)
+		return
+	}
+
+	out := []AdminUser{}
+	for _, user := range users {
+		out = append(out, adminUserFromDB(user))
+	}
+	respondWithJson(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handlerAdminGetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	respondWithJson(w, http.StatusOK, adminUserFromDB(user))
+}
+
+func (cfg *apiConfig) handlerSetUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "User ID is not a valid uuid", err)
+		return
+	}
+	type parameters struct {
+		Role string `json:"role"`
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+	if _, ok := roleRank[params.Role]; !ok {
+		respondWithError(w, http.StatusBadRequest, "Role must be user, moderator or admin", nil)
+		return
+	}
+
+	n, err := cfg.db.SetUserRole(r.Context(), database.SetUserRoleParams{Role: params.Role, ID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not set the role", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+	logAdminAction(r, "set_role:"+params.Role, userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerSuspendUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		Until  time.Time `json:"until"`
+		Reason string    `json:"reason"`
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+	if !params.Until.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "until must be in the future", nil)
+		return
+	}
+	if params.Until.After(banEnd) {
+		params.Until = banEnd
+	}
+
+	user.SuspendedUntil = sql.NullTime{Time: params.Until.UTC(), Valid: true}
+	user.BanReason = strings.TrimSpace(params.Reason)
+	if err := cfg.suspendUser(r.Context(), user.ID, user.SuspendedUntil.Time, user.BanReason, false); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not suspend the user", err)
+		return
+	}
+	logAdminAction(r, "suspend", user.ID)
+
+	respondWithJson(w, http.StatusOK, adminUserFromDB(user))
+}
+
+// handlerBanUser suspends a user for good, optionally setting their chirps
+// aside.
+func (cfg *apiConfig) handlerBanUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	type parameters struct {
+		Reason     string `json:"reason"`
+		HideChirps bool   `json:"hide_chirps"`
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+
+	user.SuspendedUntil = sql.NullTime{Time: banEnd, Valid: true}
+	user.BanReason = strings.TrimSpace(params.Reason)
+	if err := cfg.suspendUser(r.Context(), user.ID, banEnd, user.BanReason, params.HideChirps); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not ban the user", err)
+		return
+	}
+	logAdminAction(r, "ban", user.ID)
+
+	respondWithJson(w, http.StatusOK, adminUserFromDB(user))
+}
+
+// handlerUnsuspendUser lifts a suspension or a ban.
+func (cfg *apiConfig) handlerUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.liftSuspension(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not lift the suspension", err)
+		return
+	}
+	logAdminAction(r, "unsuspend", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGrantRed(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.db.UpgradeRed(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not upgrade the user", err)
+		return
+	}
+	logAdminAction(r, "grant_red", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRevokeRed(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.db.DowngradeRed(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not downgrade the user", err)
+		return
+	}
+	logAdminAction(r, "revoke_red", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerRevokeUserTokens signs a user out everywhere: their refresh tokens
+// are revoked and access tokens already issued stop working.
+func (cfg *apiConfig) handlerRevokeUserTokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := cfg.db.RevokeUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not revoke refresh tokens", err)
+		return
+	}
+	if err := cfg.db.RevokeAccessTokens(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not revoke access tokens", err)
+		return
+	}
+	logAdminAction(r, "revoke_tokens", user.ID)
+
+	type response struct {
+		RefreshTokensRevoked int64 `json:"refresh_tokens_revoked"`
+	}
+	respondWithJson(w, http.StatusOK, response{RefreshTokensRevoked: n})
+}
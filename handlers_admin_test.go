@@ -0,0 +1,205 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/auth"
+)
+
+func TestAdminUsers(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	createTestUser(t, srv, "carol_1@example.com", "carol")
+
+	if code := doJSON(t, srv, "GET", "/admin/api/users", alice.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("listing users as a user: status %d", code)
+	}
+	if code := doJSON(t, srv, "GET", "/admin/api/users", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("listing users signed out: status %d", code)
+	}
+	if code := doAdmin(t, srv, "PUT", "/admin/api/users/"+alice.ID.String()+"/role", map[string]string{"role": roleAdmin}, nil); code != http.StatusNoContent {
+		t.Fatalf("making alice an admin: status %d", code)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"alice", "bob", "carol"}},
+		{"?q=BOB", []string{"bob"}},
+		{"?q=example.com&limit=1&offset=1", []string{"bob"}},
+		// Wildcards are matched literally.
+		{"?q=_", []string{"carol"}},
+		{"?role=admin", []string{"alice"}},
+	} {
+		var users []AdminUser
+		if code := doJSON(t, srv, "GET", "/admin/api/users"+tt.query, alice.Token, nil, &users); code != http.StatusOK {
+			t.Errorf("list %q: status %d", tt.query, code)
+			continue
+		}
+		var got []string
+		for _, user := range users {
+			got = append(got, user.Username)
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+			t.Errorf("list %q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	for _, query := range []string{"?limit=0", "?limit=201", "?offset=-1", "?role=owner"} {
+		if code := doJSON(t, srv, "GET", "/admin/api/users"+query, alice.Token, nil, nil); code != http.StatusBadRequest {
+			t.Errorf("list %q: status %d", query, code)
+		}
+	}
+
+	bobPath := "/admin/api/users/" + bob.ID.String()
+	past := map[string]any{"until": time.Now().Add(-time.Minute)}
+	if code := doJSON(t, srv, "POST", bobPath+"/suspension", alice.Token, past, nil); code != http.StatusBadRequest {
+		t.Errorf("suspending into the past: status %d", code)
+	}
+	var suspendedBob AdminUser
+	future := map[string]any{"until": time.Now().Add(time.Hour)}
+	if code := doJSON(t, srv, "POST", bobPath+"/suspension", alice.Token, future, &suspendedBob); code != http.StatusOK || suspendedBob.SuspendedUntil == nil {
+		t.Fatalf("suspend: status %d, %+v", code, suspendedBob)
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "hello?"}, nil); code != http.StatusForbidden {
+		t.Errorf("chirping while suspended: status %d", code)
+	}
+	if code := doJSON(t, srv, "DELETE", bobPath+"/suspension", alice.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("lift suspension: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "hello!"}, nil); code != http.StatusCreated {
+		t.Errorf("chirping after the suspension: status %d", code)
+	}
+
+	isRed := func() bool {
+		var user AdminUser
+		doJSON(t, srv, "GET", bobPath, alice.Token, nil, &user)
+		return user.IsChirpyRed
+	}
+	if code := doJSON(t, srv, "POST", bobPath+"/red", alice.Token, nil, nil); code != http.StatusNoContent || !isRed() {
+		t.Errorf("grant red: status %d", code)
+	}
+	if code := doJSON(t, srv, "DELETE", bobPath+"/red", alice.Token, nil, nil); code != http.StatusNoContent || isRed() {
+		t.Errorf("revoke red: status %d", code)
+	}
+
+	if code := doJSON(t, srv, "GET", "/admin/api/users/00000000-0000-0000-0000-000000000000", alice.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("unknown user: status %d", code)
+	}
+}
+
+func TestAdminRevokeTokens(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+
+	// Revoke early in a second, so a token issued just after lands in it.
+	nextSecond()
+	var result struct {
+		RefreshTokensRevoked int `json:"refresh_tokens_revoked"`
+	}
+	if code := doAdmin(t, srv, "POST", "/admin/api/users/"+alice.ID.String()+"/tokens/revoke", nil, &result); code != http.StatusOK {
+		t.Fatalf("revoke: status %d", code)
+	}
+	if result.RefreshTokensRevoked != 1 {
+		t.Errorf("revoked %d refresh tokens", result.RefreshTokensRevoked)
+	}
+
+	if code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "still here"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("old access token: status %d", code)
+	}
+	sameSecond, err := auth.MakeJWT(t.Context(), alice.ID, testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps", sameSecond, map[string]string{"body": "sneaking in"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("access token issued in the second of the revocation: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/refresh", alice.RefreshToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("old refresh token: status %d", code)
+	}
+
+	// A token issued in the second of the revocation is refused.
+	nextSecond()
+	var user User
+	doJSON(t, srv, "POST", "/api/login", "", map[string]string{"email": "alice@example.com", "password": "hunter2"}, &user)
+	if code := doJSON(t, srv, "POST", "/api/chirps", user.Token, map[string]string{"body": "back again"}, nil); code != http.StatusCreated {
+		t.Errorf("new access token: status %d", code)
+	}
+}
+
+func TestBanUser(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "first"}, nil)
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "second"}, nil)
+
+	countChirps := func() int {
+		var chirps []Chirp
+		doJSON(t, srv, "GET", "/api/chirps?author_id="+alice.ID.String(), bob.Token, nil, &chirps)
+		return len(chirps)
+	}
+
+	alicePath := "/admin/api/users/" + alice.ID.String()
+	var user AdminUser
+	ban := map[string]any{"reason": "spam", "hide_chirps": true}
+	if code := doAdmin(t, srv, "POST", alicePath+"/ban", ban, &user); code != http.StatusOK {
+		t.Fatalf("ban: status %d", code)
+	}
+	if !user.Banned || user.BanReason != "spam" {
+		t.Errorf("banned user: %+v", user)
+	}
+	if n := countChirps(); n != 0 {
+		t.Errorf("banned user's chirps: %d listed", n)
+	}
+
+	var refused struct {
+		Error string `json:"error"`
+	}
+	login := map[string]string{"email": "alice@example.com", "password": "hunter2"}
+	if code := doJSON(t, srv, "POST", "/api/login", "", login, &refused); code != http.StatusForbidden || refused.Error != "Your account is banned: spam" {
+		t.Errorf("login while banned: status %d, %q", code, refused.Error)
+	}
+	if code := doJSON(t, srv, "PUT", "/api/users", alice.Token, login, nil); code != http.StatusForbidden {
+		t.Errorf("old access token: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/refresh", alice.RefreshToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("old refresh token: status %d", code)
+	}
+
+	if code := doAdmin(t, srv, "DELETE", alicePath+"/ban", nil, nil); code != http.StatusNoContent {
+		t.Fatalf("unban: status %d", code)
+	}
+	if n := countChirps(); n != 2 {
+		t.Errorf("after unbanning: %d chirps listed", n)
+	}
+	if code := doJSON(t, srv, "POST", "/api/login", "", login, nil); code != http.StatusOK {
+		t.Errorf("login after unbanning: status %d", code)
+	}
+}
+
+func TestReset(t *testing.T) {
+	cfg, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+
+	if code := doJSON(t, srv, "POST", "/admin/reset", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("resetting signed out: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/admin/reset", alice.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("resetting as a user: status %d", code)
+	}
+	cfg.platform = "prod"
+	if code := doAdmin(t, srv, "POST", "/admin/reset", nil, nil); code != http.StatusForbidden {
+		t.Errorf("resetting outside dev: status %d", code)
+	}
+	cfg.platform = "dev"
+	if code := doAdmin(t, srv, "POST", "/admin/reset", nil, nil); code != http.StatusOK {
+		t.Errorf("resetting as an admin: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/login", "", map[string]string{"email": "alice@example.com", "password": "hunter2"}, nil); code == http.StatusOK {
+		t.Error("alice can still log in after the reset")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var errBlocked = errors.New("users have blocked each other")
+
+// BlockedUser is an entry in a user's block or mute list.
+type BlockedUser struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// blockedBetween reports whether either user blocks the other.
+func (cfg *apiConfig) blockedBetween(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	n, err := cfg.db.CountBlocksBetween(ctx, database.CountBlocksBetweenParams{UserID: userID, OtherID: otherID})
+	return n > 0, err
+}
+
+// hiddenAuthors is whose chirps viewer doesn't see: users on either side
+// of a block with them and, when withMuted, the users they mute.
+func (cfg *apiConfig) hiddenAuthors(ctx context.Context, viewer uuid.UUID, withMuted bool) (map[uuid.UUID]bool, error) {
+	hidden := map[uuid.UUID]bool{}
+	if viewer == uuid.Nil {
+		return hidden, nil
+	}
+	blocked, err := cfg.db.GetBlockedEitherWayIDs(ctx, viewer)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range blocked {
+		hidden[id] = true
+	}
+	if withMuted {
+		muted, err := cfg.db.GetMutedUserIDs(ctx, viewer)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range muted {
+			hidden[id] = true
+		}
+	}
+	return hidden, nil
+}
+
+// visibleChirp is chirpVisible that also hides chirps across a block.
+func (cfg *apiConfig) visibleChirp(ctx context.Context, chirp database.Chirp, viewer uuid.UUID) (bool, error) {
+	if !chirpVisible(chirp, viewer) {
+		return false, nil
+	}
+	if viewer == uuid.Nil || viewer == chirp.UserID {
+		return true, nil
+	}
+	blocked, err := cfg.blockedBetween(ctx, viewer, chirp.UserID)
+	return !blocked, err
+}
+
+// relationTarget reads the user_id a block or mute is about, writing an
+// error when it is missing, the caller themselves, or not a user.
+func (cfg *apiConfig) relationTarget(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (uuid.UUID, bool) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return uuid.Nil, false
+	}
+	if params.UserID == uuid.Nil {
+		respondWithError(w, http.StatusBadRequest, "user_id is required", nil)
+		return uuid.Nil, false
+	}
+	if params.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can not do this to yourself", nil)
+		return uuid.Nil, false
+	}
+	_, err := cfg.db.GetUserByID(r.Context(), params.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return uuid.Nil, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get the user", err)
+		return uuid.Nil, false
+	}
+	return params.UserID, true
+}
+
+func (cfg *apiConfig) handlerListBlocks(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	blocks, err := cfg.db.ListBlocks(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not list blocked users", err)
+		return
+	}
+	out := []BlockedUser{}
+	for _, b := range blocks {
+		out = append(out, BlockedUser{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+	respondWithJson(w, http.StatusOK, out)
+}
+
+// handlerBlockUser blocks a user and ends any follow between the two.
+func (cfg *apiConfig) handlerBlockUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+	blockedID, ok := cfg.relationTarget(w, r, userId)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.BlockUser(r.Context(), database.BlockUserParams{BlockerID: userId, BlockedID: blockedID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not block the user", err)
+		return
+	}
+	for _, follow := range []database.UnfollowUserParams{
+		{FollowerID: userId, FolloweeID: blockedID},
+		{FollowerID: blockedID, FolloweeID: userId},
+	} {
+		if err := cfg.db.UnfollowUser(r.Context(), follow); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not remove follows", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+	blockedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "User ID is not a valid uuid", err)
+		return
+	}
+
+	n, err := cfg.db.UnblockUser(r.Context(), database.UnblockUserParams{BlockerID: userId, BlockedID: blockedID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not unblock the user", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "User is not blocked", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerListMutes(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	mutes, err := cfg.db.ListMutes(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not list muted users", err)
+		return
+	}
+	out := []BlockedUser{}
+	for _, m := range mutes {
+		out = append(out, BlockedUser{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+	}
+	respondWithJson(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handlerMuteUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+	mutedID, ok := cfg.relationTarget(w, r, userId)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.MuteUser(r.Context(), database.MuteUserParams{MuterID: userId, MutedID: mutedID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not mute the user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+	mutedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "User ID is not a valid uuid", err)
+		return
+	}
+
+	n, err := cfg.db.UnmuteUser(r.Context(), database.UnmuteUserParams{MuterID: userId, MutedID: mutedID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not unmute the user", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "User is not muted", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
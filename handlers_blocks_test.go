@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// chirpAuthors lists the authors of the chirps token sees at path, one
+// entry per chirp.
+func chirpAuthors(t *testing.T, srv *httptest.Server, path, token string) map[uuid.UUID]int {
+	t.Helper()
+	var chirps []Chirp
+	if code := doJSON(t, srv, "GET", path, token, nil, &chirps); code != http.StatusOK {
+		t.Fatalf("GET %s: status %d", path, code)
+	}
+	authors := map[uuid.UUID]int{}
+	for _, chirp := range chirps {
+		authors[chirp.UserId]++
+	}
+	return authors
+}
+
+func countNotifications(t *testing.T, srv *httptest.Server, token string) int {
+	t.Helper()
+	var page struct {
+		Notifications []Notification `json:"notifications"`
+	}
+	doJSON(t, srv, "GET", "/api/notifications", token, nil, &page)
+	return len(page.Notifications)
+}
+
+func TestBlockUser(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	carol := createTestUser(t, srv, "carol@example.com", "carol")
+
+	doJSON(t, srv, "POST", "/api/users/"+alice.ID.String()+"/follow", bob.Token, nil, nil)
+	var hello Chirp
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "hello"}, &hello)
+	doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "hi"}, nil)
+
+	for _, tt := range []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"bob", map[string]string{"user_id": bob.ID.String()}, http.StatusNoContent},
+		{"bob again", map[string]string{"user_id": bob.ID.String()}, http.StatusNoContent},
+		{"herself", map[string]string{"user_id": alice.ID.String()}, http.StatusBadRequest},
+		{"nobody", map[string]string{"user_id": uuid.NewString()}, http.StatusNotFound},
+	} {
+		if code := doJSON(t, srv, "POST", "/api/blocks", alice.Token, tt.body, nil); code != tt.want {
+			t.Errorf("block %s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+	var blocks []BlockedUser
+	doJSON(t, srv, "GET", "/api/blocks", alice.Token, nil, &blocks)
+	if len(blocks) != 1 || blocks[0].UserID != bob.ID {
+		t.Errorf("blocks: %+v", blocks)
+	}
+
+	// The block works both ways.
+	for _, tt := range []struct{ from, to User }{{alice, bob}, {bob, alice}} {
+		if code := doJSON(t, srv, "POST", "/api/users/"+tt.to.ID.String()+"/follow", tt.from.Token, nil, nil); code != http.StatusForbidden {
+			t.Errorf("%s following %s: status %d", tt.from.Email, tt.to.Email, code)
+		}
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps", bob.Token)[alice.ID]; n != 0 {
+		t.Errorf("bob sees %d of alice's chirps", n)
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps", alice.Token)[bob.ID]; n != 0 {
+		t.Errorf("alice sees %d of bob's chirps", n)
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps", carol.Token); n[alice.ID] != 1 || n[bob.ID] != 1 {
+		t.Errorf("carol sees %v", n)
+	}
+	if code := doJSON(t, srv, "GET", "/api/chirps/"+hello.ID.String(), bob.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob getting alice's chirp: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps/"+hello.ID.String()+"/likes", bob.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob liking alice's chirp: status %d", code)
+	}
+	reply := map[string]string{"body": "rude", "reply_to_id": hello.ID.String()}
+	if code := doJSON(t, srv, "POST", "/api/chirps", bob.Token, reply, nil); code != http.StatusForbidden {
+		t.Errorf("bob replying to alice: status %d", code)
+	}
+
+	before := countNotifications(t, srv, alice.Token)
+	doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "hey @alice"}, nil)
+	if n := countNotifications(t, srv, alice.Token); n != before {
+		t.Errorf("alice was notified of bob's mention")
+	}
+	doJSON(t, srv, "POST", "/api/chirps", carol.Token, map[string]string{"body": "hey @alice"}, nil)
+	if n := countNotifications(t, srv, alice.Token); n != before+1 {
+		t.Errorf("alice wasn't notified of carol's mention")
+	}
+
+	path := "/api/blocks/" + bob.ID.String()
+	if code := doJSON(t, srv, "DELETE", path, alice.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("unblock: status %d", code)
+	}
+	if code := doJSON(t, srv, "DELETE", path, alice.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("unblock again: status %d", code)
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps", bob.Token)[alice.ID]; n != 1 {
+		t.Errorf("after unblocking, bob sees %d of alice's chirps", n)
+	}
+	// Blocking ended bob's follow, so following again is news to alice.
+	before = countNotifications(t, srv, alice.Token)
+	if code := doJSON(t, srv, "POST", "/api/users/"+alice.ID.String()+"/follow", bob.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("following after unblocking: status %d", code)
+	}
+	if n := countNotifications(t, srv, alice.Token); n != before+1 {
+		t.Errorf("following after unblocking: %d notifications, want %d", n, before+1)
+	}
+}
+
+func TestMuteUser(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+
+	var hello Chirp
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "hello"}, &hello)
+	doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "hi"}, nil)
+
+	if code := doJSON(t, srv, "POST", "/api/mutes", alice.Token, map[string]string{"user_id": bob.ID.String()}, nil); code != http.StatusNoContent {
+		t.Fatalf("mute: status %d", code)
+	}
+	var mutes []BlockedUser
+	doJSON(t, srv, "GET", "/api/mutes", alice.Token, nil, &mutes)
+	if len(mutes) != 1 || mutes[0].UserID != bob.ID {
+		t.Errorf("mutes: %+v", mutes)
+	}
+
+	if n := chirpAuthors(t, srv, "/api/chirps", alice.Token)[bob.ID]; n != 0 {
+		t.Errorf("alice's timeline has %d of bob's chirps", n)
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps?author_id="+bob.ID.String(), alice.Token)[bob.ID]; n != 1 {
+		t.Errorf("bob's own page shows alice %d chirps", n)
+	}
+
+	// Bob doesn't know and can still interact; alice just doesn't hear
+	// about it.
+	if n := chirpAuthors(t, srv, "/api/chirps", bob.Token)[alice.ID]; n != 1 {
+		t.Errorf("bob sees %d of alice's chirps", n)
+	}
+	reply := map[string]string{"body": "@alice hi back", "reply_to_id": hello.ID.String()}
+	if code := doJSON(t, srv, "POST", "/api/chirps", bob.Token, reply, nil); code != http.StatusCreated {
+		t.Errorf("bob replying: status %d", code)
+	}
+	if n := countNotifications(t, srv, alice.Token); n != 0 {
+		t.Errorf("alice has %d notifications from a muted user", n)
+	}
+
+	if code := doJSON(t, srv, "DELETE", "/api/mutes/"+bob.ID.String(), alice.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("unmute: status %d", code)
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps", alice.Token)[bob.ID]; n != 2 {
+		t.Errorf("after unmuting, alice's timeline has %d of bob's chirps", n)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/logging"
+)
+
+// bulkProgress is a line of the import API's response. The last one has
+// Done or Error set.
+type bulkProgress struct {
+	bulkStats
+	Done  bool   `json:"done,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// handlerBulkExport streams every user and chirp as JSON Lines, the same
+// as `chirpy bulk export`.
+func (cfg *apiConfig) handlerBulkExport(w http.ResponseWriter, r *http.Request) {
+	// A full export outlives the server's write timeout.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-`+time.Now().UTC().Format("2006-01-02")+`.jsonl"`)
+	w.Header().Set("Cache-Control", "no-store")
+	buf := bufio.NewWriter(w)
+	stats, err := exportBulk(r.Context(), cfg.db, buf, nil)
+	if err == nil {
+		err = buf.Flush()
+	}
+	logger := logging.FromContext(r.Context())
+	if err != nil {
+		// The status has gone out already; the client sees a short stream.
+		logger.Error("Bulk export failed", "users", stats.Users, "chirps", stats.Chirps, logging.Err(err))
+		return
+	}
+	logger.Info("Bulk export", "users", stats.Users, "chirps", stats.Chirps)
+}
+
+// handlerBulkImport reads JSON Lines written by the export from the request
+// body. on_conflict picks the conflict policy, fail by default, and
+// dry_run=true only checks the records. Progress is streamed back as JSON
+// Lines while the import runs, so a failure shows up in the last line
+// rather than the status code.
+func (cfg *apiConfig) handlerBulkImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := bulkImportOptions{OnConflict: conflictFail}
+	if s := query.Get("on_conflict"); s != "" {
+		opts.OnConflict = s
+	}
+	if !validConflictPolicy(opts.OnConflict) {
+		respondWithError(w, http.StatusBadRequest, "on_conflict must be skip, overwrite or fail", nil)
+		return
+	}
+	if s := query.Get("dry_run"); s != "" {
+		dryRun, err := strconv.ParseBool(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "dry_run must be true or false", err)
+			return
+		}
+		opts.DryRun = dryRun
+	}
+
+	// Progress goes out while the body is still coming in, for as long as
+	// the import takes.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	if err := rc.EnableFullDuplex(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not stream the import", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	send := func(p bulkProgress) {
+		enc.Encode(p)
+		rc.Flush()
+	}
+	stats, err := importBulk(r.Context(), cfg.db, r.Body, opts, func(s bulkStats) {
+		send(bulkProgress{bulkStats: s})
+	})
+
+	logger := logging.FromContext(r.Context())
+	if err != nil {
+		logger.Warn("Bulk import stopped", "users", stats.Users, "chirps", stats.Chirps, "dry_run", opts.DryRun, logging.Err(err))
+		send(bulkProgress{bulkStats: stats, Error: err.Error()})
+		return
+	}
+	logger.Info("Bulk import", "users", stats.Users, "chirps", stats.Chirps, "created", stats.Created,
+		"overwritten", stats.Overwritten, "skipped", stats.Skipped, "dry_run", opts.DryRun)
+	send(bulkProgress{bulkStats: stats, Done: true})
+}
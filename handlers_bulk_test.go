@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/enderbd/chirpy/internal/store/memory"
+)
+
+// bulkRequest sends a bulk API request as an admin and returns the
+// response body.
+func bulkRequest(t *testing.T, srv *httptest.Server, method, path string, body []byte) []byte {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "ApiKey "+testAdminKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s %s: status %d: %s", method, path, resp.StatusCode, out)
+	}
+	return out
+}
+
+// bulkImportAPI imports records through the API and returns the last
+// progress line.
+func bulkImportAPI(t *testing.T, srv *httptest.Server, query string, records []byte) bulkProgress {
+	t.Helper()
+	var last bulkProgress
+	scanner := bufio.NewScanner(bytes.NewReader(bulkRequest(t, srv, "POST", "/admin/api/bulk/import"+query, records)))
+	for scanner.Scan() {
+		last = bulkProgress{}
+		if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return last
+}
+
+func TestBulkTransfer(t *testing.T) {
+	_, from := newModeratedServer(t)
+	alice := createTestUser(t, from, "alice@example.com", "alice")
+	var hello, reply Chirp
+	doJSON(t, from, "POST", "/api/chirps", alice.Token, map[string]string{"body": "hello"}, &hello)
+	doJSON(t, from, "POST", "/api/chirps", alice.Token, map[string]string{"body": "me again", "reply_to_id": hello.ID.String()}, &reply)
+
+	records := bulkRequest(t, from, "GET", "/admin/api/bulk/export", nil)
+	if n := bytes.Count(records, []byte("\n")); n != 3 {
+		t.Fatalf("export has %d lines, want 3:\n%s", n, records)
+	}
+
+	_, to := newModeratedServer(t)
+	got := bulkImportAPI(t, to, "?dry_run=true", records)
+	if !got.Done || !got.DryRun || got.Users != 1 || got.Chirps != 2 || got.Created != 3 {
+		t.Errorf("dry run: %+v", got)
+	}
+	if code := doJSON(t, to, "GET", "/api/chirps/"+hello.ID.String(), "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("dry run imported a chirp: status %d", code)
+	}
+
+	got = bulkImportAPI(t, to, "", records)
+	if !got.Done || got.Created != 3 {
+		t.Errorf("import: %+v", got)
+	}
+	var imported Chirp
+	doJSON(t, to, "GET", "/api/chirps/"+reply.ID.String(), "", nil, &imported)
+	if imported.Body != "me again" || imported.UserId != alice.ID || !imported.CreatedAt.Equal(reply.CreatedAt) {
+		t.Errorf("imported chirp %+v, want %+v", imported, reply)
+	}
+	var user User
+	login := map[string]string{"email": "alice@example.com", "password": "hunter2"}
+	if code := doJSON(t, to, "POST", "/api/login", "", login, &user); code != http.StatusOK || user.ID != alice.ID {
+		t.Errorf("logging in as an imported user: status %d, id %s", code, user.ID)
+	}
+
+	got = bulkImportAPI(t, to, "", records)
+	if got.Done || !strings.Contains(got.Error, "line 1") || !strings.Contains(got.Error, "already exists") {
+		t.Errorf("importing again with on_conflict=fail: %+v", got)
+	}
+	got = bulkImportAPI(t, to, "?on_conflict=skip", records)
+	if !got.Done || got.Skipped != 3 || got.Created != 0 {
+		t.Errorf("importing again with on_conflict=skip: %+v", got)
+	}
+	edited := bytes.Replace(records, []byte(`"body":"hello"`), []byte(`"body":"hello, new server"`), 1)
+	got = bulkImportAPI(t, to, "?on_conflict=overwrite", edited)
+	if !got.Done || got.Overwritten != 3 {
+		t.Errorf("importing again with on_conflict=overwrite: %+v", got)
+	}
+	doJSON(t, to, "GET", "/api/chirps/"+hello.ID.String(), "", nil, &imported)
+	if imported.Body != "hello, new server" {
+		t.Errorf("overwritten chirp has body %q", imported.Body)
+	}
+}
+
+func TestBulkImportRejects(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	for _, tt := range []struct {
+		name, records, want string
+	}{
+		{"bad json", `{"type":`, "line 1"},
+		{"unknown type", `{"type":"like","id":"6a0bd7a4-3f77-4a4f-8c1c-1ad2b6a0e9b1","created_at":"2024-01-01T00:00:00Z"}`, "unknown record type"},
+		{"orphan chirp", `{"type":"chirp","id":"6a0bd7a4-3f77-4a4f-8c1c-1ad2b6a0e9b1","created_at":"2024-01-01T00:00:00Z","user_id":"0d8e5d35-58a5-4b4e-9d37-cc3c51b4b8f4","body":"hi"}`, "isn't in the database"},
+		{"no password", "\n" + `{"type":"user","id":"0d8e5d35-58a5-4b4e-9d37-cc3c51b4b8f4","created_at":"2024-01-01T00:00:00Z","email":"a@example.com"}`, "line 2"},
+	} {
+		got := bulkImportAPI(t, srv, "", []byte(tt.records))
+		if got.Done || !strings.Contains(got.Error, tt.want) {
+			t.Errorf("%s: %+v, want an error mentioning %q", tt.name, got, tt.want)
+		}
+	}
+
+	if code := doAdmin(t, srv, "POST", "/admin/api/bulk/import?on_conflict=merge", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("unknown conflict policy: status %d", code)
+	}
+}
+
+func TestBulkCommand(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "hello"}, nil)
+
+	var records, progress bytes.Buffer
+	if err := runBulkCommand(t.Context(), &records, &progress, nil, cfg.db, []string{"export"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(progress.String(), "exported 1 users, 1 chirps") {
+		t.Errorf("export progress: %q", progress.String())
+	}
+
+	db := memory.New()
+	progress.Reset()
+	if err := runBulkCommand(t.Context(), io.Discard, &progress, &records, db, []string{"import", "-on-conflict", "skip"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(progress.String(), "(2 created, 0 overwritten, 0 skipped)") {
+		t.Errorf("import progress: %q", progress.String())
+	}
+	if _, err := db.GetUserByID(t.Context(), alice.ID); err != nil {
+		t.Errorf("imported user: %v", err)
+	}
+
+	if err := runBulkCommand(t.Context(), io.Discard, io.Discard, nil, db, []string{"reticulate"}); err == nil {
+		t.Error("unknown bulk command succeeded")
+	}
+}
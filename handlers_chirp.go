@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"sort"
 	"strings"
@@ -9,6 +12,8 @@ import (
 
 	"github.com/enderbd/chirpy/internal/auth"
 	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/moderation"
+	"github.com/enderbd/chirpy/internal/stream"
 	"github.com/google/uuid"
 )
 
@@ -18,12 +23,131 @@ type Chirp struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body     string    `json:"body"`
 	UserId uuid.UUID `json:"user_id"`
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty"`
+	Status string `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
 }
 
+const maxChirpLength = 140
+
+var (
+	errChirpTooLong  = errors.New("chirp is too long")
+	errReplyNotFound = errors.New("chirp being replied to does not exist")
+	errChirpRejected = errors.New("chirp rejected by moderation")
+)
+
+func chirpFromDB(chirp database.Chirp) Chirp {
+	out := Chirp{
+		ID: chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body: chirp.Body,
+		UserId: chirp.UserID,
+		Status: chirp.Status,
+	}
+	if chirp.ReplyToID.Valid {
+		out.ReplyToID = &chirp.ReplyToID.UUID
+	}
+	if chirp.PublishAt.Valid {
+		out.PublishAt = &chirp.PublishAt.Time
+	}
+	return out
+}
+
+// chirpTime is when a chirp went out: when it was published if it was
+// scheduled, otherwise when it was created. Timelines are ordered by it.
+func chirpTime(chirp database.Chirp) time.Time {
+	if chirp.PublishAt.Valid {
+		return chirp.PublishAt.Time
+	}
+	return chirp.CreatedAt
+}
+
+// createChirp validates, moderates and stores a chirp for userID,
+// optionally as a reply or scheduled for publishAt. Unless moderation holds
+// it or it is scheduled, the chirp is announced to stream subscribers and
+// anyone it notifies. A rejected chirp returns errChirpRejected along with
+// the decision saying why. It is shared by the REST and WebSocket APIs.
+func (cfg *apiConfig) createChirp(ctx context.Context, userID uuid.UUID, body string, replyToID uuid.NullUUID, publishAt sql.NullTime) (database.Chirp, moderation.Decision, error) {
+	if len(body) > maxChirpLength {
+		return database.Chirp{}, moderation.Decision{}, errChirpTooLong
+	}
+	if publishAt.Valid {
+		if err := checkSchedule(publishAt.Time); err != nil {
+			return database.Chirp{}, moderation.Decision{}, err
+		}
+		publishAt.Time = publishAt.Time.UTC()
+	}
+
+	author, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return database.Chirp{}, moderation.Decision{}, err
+	}
+	if suspended(author) {
+		return database.Chirp{}, moderation.Decision{}, suspensionError{author}
+	}
+
+	var replyTo *database.Chirp
+	if replyToID.Valid {
+		parent, err := cfg.db.GetSingleChirp(ctx, replyToID.UUID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !chirpVisible(parent, userID)) {
+			return database.Chirp{}, moderation.Decision{}, errReplyNotFound
+		}
+		if err != nil {
+			return database.Chirp{}, moderation.Decision{}, err
+		}
+		blocked, err := cfg.blockedBetween(ctx, userID, parent.UserID)
+		if err != nil {
+			return database.Chirp{}, moderation.Decision{}, err
+		}
+		if blocked {
+			return database.Chirp{}, moderation.Decision{}, errBlocked
+		}
+		replyTo = &parent
+	}
+
+	decision := cfg.moderationPipeline().Moderate(body)
+	if decision.Action == moderation.ActionReject {
+		return database.Chirp{}, decision, errChirpRejected
+	}
+	status := chirpPublished
+	if decision.Action == moderation.ActionHold {
+		status = chirpHeld
+	} else if publishAt.Valid {
+		status = chirpScheduled
+	}
+
+	chirp, err := cfg.db.CreateChirp(ctx, database.CreateChirpParams{
+		Body: decision.Body,
+		UserID: userID,
+		ReplyToID: replyToID,
+		Status: status,
+		PublishAt: publishAt,
+	})
+	if err != nil {
+		return database.Chirp{}, decision, err
+	}
+	cfg.metrics.ChirpCreated()
+	if status == chirpPublished {
+		cfg.announceChirp(ctx, chirp, replyTo)
+	}
+
+	return chirp, decision, nil
+}
+
+// announceChirp tells everyone who should know about a newly published
+// chirp.
+func (cfg *apiConfig) announceChirp(ctx context.Context, chirp database.Chirp, replyTo *database.Chirp) {
+	cfg.publishChirpEvent(ctx, stream.EventChirpCreated, chirp)
+	cfg.federateChirp(ctx, stream.EventChirpCreated, chirp)
+	cfg.notifyChirp(ctx, chirp, replyTo)
+}
 
 func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Body string `json:"body"`
+		ReplyToID *uuid.UUID `json:"reply_to_id"`
+		PublishAt *time.Time `json:"publish_at"`
 	}
 
 	token, err := auth.GetBearerToken(r.Header)
@@ -32,11 +156,12 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, cfg.secret)
+	userId, err := cfg.validateToken(r.Context(), token)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Could not validate JWT", err)
+		respondWithTokenError(w, err)
 		return
 	}
+	setRequestUser(w, userId)
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
@@ -46,32 +171,70 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
+	var replyToID uuid.NullUUID
+	if params.ReplyToID != nil {
+		replyToID = uuid.NullUUID{UUID: *params.ReplyToID, Valid: true}
+	}
+
+	chirp, decision, err := cfg.createChirp(r.Context(), userId, params.Body, replyToID, nullTimePtr(params.PublishAt))
+	if errors.Is(err, errChirpRejected) {
+		respondWithRejection(w, params.Body, decision, err)
+		return
+	}
+	if errors.Is(err, errUserSuspended) {
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
+	if errors.Is(err, errChirpTooLong) {
 		respondWithError(w, http.StatusBadRequest, "Chirp it too long", err)
 		return
 	}
-	
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body: removeProfanity(params.Body),
-		UserID: userId,
-	}) 
+	if errors.Is(err, errReplyNotFound) {
+		respondWithError(w, http.StatusBadRequest, "The chirp you are replying to does not exist", err)
+		return
+	}
+	if errors.Is(err, errBlocked) {
+		respondWithError(w, http.StatusForbidden, "You can not reply to this user", err)
+		return
+	}
+	if errors.Is(err, errBadSchedule) {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not add the Chirp", err)
+		return
 	}
 
-	outChirp := Chirp{
-		ID: chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body: chirp.Body,
-		UserId: chirp.UserID,
+	type response struct {
+		Chirp
+		Moderation *moderationReport `json:"moderation,omitempty"`
+	}
+	code := http.StatusCreated
+	if chirp.Status == chirpHeld {
+		code = http.StatusAccepted
 	}
 
-	respondWithJson(w, http.StatusCreated, outChirp)
+	respondWithJson(w, code, response{
+		Chirp: chirpFromDB(chirp),
+		Moderation: newModerationReport(params.Body, decision),
+	})
 	
 }
 
+// respondWithRejection tells the author moderation rejected body and which
+// rules it broke.
+func respondWithRejection(w http.ResponseWriter, body string, decision moderation.Decision, err error) {
+	recordError(w, err)
+	respondWithJson(w, http.StatusUnprocessableEntity, struct {
+		Error      string            `json:"error"`
+		Moderation *moderationReport `json:"moderation"`
+	}{
+		Error:      "Chirp was rejected by moderation",
+		Moderation: newModerationReport(body, decision),
+	})
+}
+
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	var outChirps []Chirp
 	var chirps []database.Chirp
@@ -98,28 +261,29 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	for _, chirp := range chirps {
-		out := Chirp {
-			ID: chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body: chirp.Body,
-			UserId: chirp.UserID,
-		}
-
-		outChirps = append(outChirps, out)
+	viewer := cfg.optionalUser(w, r)
+	// Muted users only drop out of the timeline, not their own page.
+	hidden, err := cfg.hiddenAuthors(r.Context(), viewer, authorID == "")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get blocked users", err)
+		return
 	}
 
 	sortOrder := strings.ToLower(r.URL.Query().Get("sort"))
 	if sortOrder != "asc" && sortOrder != "desc" {
         	sortOrder = "asc" // default
 	}
-	sort.Slice(outChirps, func(i, j int) bool {
+	sort.Slice(chirps, func(i, j int) bool {
         	if sortOrder == "asc" {
-	            return outChirps[i].CreatedAt.Before(outChirps[j].CreatedAt)
+	            return chirpTime(chirps[i]).Before(chirpTime(chirps[j]))
         	}
-	        return outChirps[i].CreatedAt.After(outChirps[j].CreatedAt)
+	        return chirpTime(chirps[i]).After(chirpTime(chirps[j]))
 	})
+	for _, chirp := range chirps {
+		if chirpVisible(chirp, viewer) && !hidden[chirp.UserID] {
+			outChirps = append(outChirps, chirpFromDB(chirp))
+		}
+	}
 	respondWithJson(w, http.StatusOK, outChirps)
 
 }
@@ -140,18 +304,19 @@ func (cfg *apiConfig) handlerGetSingleChirp(w http.ResponseWriter, r *http.Reque
 	}
 
 	chirp, err := cfg.db.GetSingleChirp(r.Context(), chirpUUID)
+	if err == nil {
+		var visible bool
+		visible, err = cfg.visibleChirp(r.Context(), chirp, cfg.optionalUser(w, r))
+		if err == nil && !visible {
+			err = sql.ErrNoRows
+		}
+	}
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
 
-	respondWithJson(w, http.StatusOK, Chirp{
-		ID: chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body: chirp.Body,
-		UserId: chirp.UserID,
-	})
+	respondWithJson(w, http.StatusOK, chirpFromDB(chirp))
 
 }
 
@@ -182,11 +347,12 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 	}
 
 
-	userId, err := auth.ValidateJWT(token, cfg.secret)
+	userId, err := cfg.validateToken(r.Context(), token)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Could not validate JWT", err)
+		respondWithTokenError(w, err)
 		return
 	}
+	setRequestUser(w, userId)
 
 	if userId != dbChirp.UserID {
 		respondWithError(w, http.StatusForbidden, "Chirp user is different from the JWT user!", err)
@@ -199,6 +365,10 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusNotFound, "Could not delete the chirp", err)
 		return
 	}
+	if dbChirp.Status == chirpPublished {
+		cfg.publishChirpEvent(r.Context(), stream.EventChirpDeleted, dbChirp)
+		cfg.federateChirp(r.Context(), stream.EventChirpDeleted, dbChirp)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 
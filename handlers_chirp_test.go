@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCreateAndGetChirps(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+
+	var first Chirp
+	code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{
+		"body": "What a kerfuffle this is",
+	}, &first)
+	if code != http.StatusCreated {
+		t.Fatalf("create: status %d", code)
+	}
+	if first.Body != "What a **** this is" || first.UserId != alice.ID {
+		t.Errorf("create: got %+v", first)
+	}
+
+	var second Chirp
+	doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "hello"}, &second)
+
+	var all []Chirp
+	if code := doJSON(t, srv, "GET", "/api/chirps", "", nil, &all); code != http.StatusOK {
+		t.Fatalf("list: status %d", code)
+	}
+	if len(all) != 2 || all[0].ID != first.ID {
+		t.Errorf("list: got %+v", all)
+	}
+
+	var desc []Chirp
+	doJSON(t, srv, "GET", "/api/chirps?sort=desc", "", nil, &desc)
+	if len(desc) != 2 || desc[0].ID != second.ID {
+		t.Errorf("list desc: got %+v", desc)
+	}
+
+	var byBob []Chirp
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+bob.ID.String(), "", nil, &byBob)
+	if len(byBob) != 1 || byBob[0].ID != second.ID {
+		t.Errorf("list by author: got %+v", byBob)
+	}
+
+	var single Chirp
+	if code := doJSON(t, srv, "GET", "/api/chirps/"+first.ID.String(), "", nil, &single); code != http.StatusOK {
+		t.Fatalf("get: status %d", code)
+	}
+	if single.ID != first.ID {
+		t.Errorf("get: got %+v", single)
+	}
+}
+
+func TestCreateChirpValidation(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "")
+
+	code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{
+		"body": strings.Repeat("a", 141),
+	}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("long chirp: status %d, want 400", code)
+	}
+
+	code = doJSON(t, srv, "POST", "/api/chirps", "", map[string]string{"body": "hi"}, nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", code)
+	}
+
+	code = doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{
+		"body":        "hi",
+		"reply_to_id": "00000000-0000-0000-0000-000000000001",
+	}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("reply to missing chirp: status %d, want 400", code)
+	}
+}
+
+func TestDeleteChirp(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "")
+	bob := createTestUser(t, srv, "bob@example.com", "")
+
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "mine"}, &chirp)
+	path := "/api/chirps/" + chirp.ID.String()
+
+	if code := doJSON(t, srv, "DELETE", path, bob.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("delete by someone else: status %d, want 403", code)
+	}
+	if code := doJSON(t, srv, "DELETE", path, alice.Token, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("delete: status %d", code)
+	}
+	if code := doJSON(t, srv, "GET", path, "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("get after delete: status %d, want 404", code)
+	}
+}
+
+func TestReplyAndMentionNotify(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	carol := createTestUser(t, srv, "carol@example.com", "carol")
+
+	var parent Chirp
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "first!"}, &parent)
+
+	var reply Chirp
+	code := doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{
+		"body":        "@alice @carol look at this",
+		"reply_to_id": parent.ID.String(),
+	}, &reply)
+	if code != http.StatusCreated {
+		t.Fatalf("reply: status %d", code)
+	}
+	if reply.ReplyToID == nil || *reply.ReplyToID != parent.ID {
+		t.Errorf("reply: got %+v", reply)
+	}
+
+	type page struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+	}
+	var forAlice, forCarol page
+	doJSON(t, srv, "GET", "/api/notifications", alice.Token, nil, &forAlice)
+	doJSON(t, srv, "GET", "/api/notifications", carol.Token, nil, &forCarol)
+
+	if len(forAlice.Notifications) != 1 || forAlice.Notifications[0].Kind != "reply" {
+		t.Errorf("alice: got %+v", forAlice)
+	}
+	if len(forCarol.Notifications) != 1 || forCarol.Notifications[0].Kind != "mention" || forCarol.UnreadCount != 1 {
+		t.Errorf("carol: got %+v", forCarol)
+	}
+}
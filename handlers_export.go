@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Data export statuses.
+const (
+	exportPending = "pending"
+	exportReady   = "ready"
+	exportFailed  = "failed"
+)
+
+// DataExport is a data export as its owner sees it. DownloadURL is only set
+// once the archive is ready and stops working at DownloadURLExpiresAt; ask
+// for the export again to get a fresh one.
+type DataExport struct {
+	ID                   uuid.UUID  `json:"id"`
+	CreatedAt            time.Time  `json:"created_at"`
+	Status               string     `json:"status"`
+	CompletedAt          *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt            time.Time  `json:"expires_at"`
+	DownloadURL          string     `json:"download_url,omitempty"`
+	DownloadURLExpiresAt *time.Time `json:"download_url_expires_at,omitempty"`
+}
+
+func (cfg *apiConfig) dataExportFromDB(e database.DataExport) DataExport {
+	out := DataExport{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		Status:    exportPending,
+		ExpiresAt: e.CreatedAt.Add(exportRetention),
+	}
+	if !e.CompletedAt.Valid {
+		return out
+	}
+	out.CompletedAt = &e.CompletedAt.Time
+	if e.Error.Valid {
+		out.Status = exportFailed
+		return out
+	}
+	out.Status = exportReady
+	url, expires := cfg.exportDownloadURL(e.ID)
+	out.DownloadURL = url
+	out.DownloadURLExpiresAt = &expires
+	return out
+}
+
+// handlerRequestExport asks for an archive of everything Chirpy holds about
+// the user. It is built in the background; poll the export until it is
+// ready. Asking again while one is being built returns that one.
+func (cfg *apiConfig) handlerRequestExport(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	export, err := cfg.db.GetPendingDataExport(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		export, err = cfg.db.CreateDataExport(r.Context(), userId)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not request a data export", err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/users/me/exports/"+export.ID.String())
+	respondWithJson(w, http.StatusAccepted, cfg.dataExportFromDB(export))
+}
+
+// handlerGetExport reports on one of the user's exports, with a fresh
+// download link once it is ready.
+func (cfg *apiConfig) handlerGetExport(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Export ID is not a valid uuid", err)
+		return
+	}
+
+	export, err := cfg.liveExport(r, exportID)
+	if err == nil && export.UserID != userId {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Export not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get the export", err)
+		return
+	}
+	respondWithJson(w, http.StatusOK, cfg.dataExportFromDB(export))
+}
+
+// handlerDownloadExport serves an export's archive to whoever has a signed
+// link to it, so it can be opened in a browser or handed to a download
+// manager.
+func (cfg *apiConfig) handlerDownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Export ID is not a valid uuid", err)
+		return
+	}
+	query := r.URL.Query()
+	if !cfg.validExportSignature(exportID, query.Get("expires"), query.Get("signature")) {
+		respondWithError(w, http.StatusForbidden, "Download link is invalid or has expired", nil)
+		return
+	}
+
+	export, err := cfg.liveExport(r, exportID)
+	if err == nil && (!export.CompletedAt.Valid || export.Error.Valid) {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Export not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get the export", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-export-`+export.CreatedAt.Format("2006-01-02")+`.zip"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(export.Archive)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(export.Archive)
+}
+
+// liveExport loads an export, treating one past its retention that the
+// builder hasn't deleted yet as gone.
+func (cfg *apiConfig) liveExport(r *http.Request, exportID uuid.UUID) (database.DataExport, error) {
+	export, err := cfg.db.GetDataExport(r.Context(), exportID)
+	if err == nil && time.Since(export.CreatedAt) > exportRetention {
+		return database.DataExport{}, sql.ErrNoRows
+	}
+	return export, err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDataExport(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "export me"}, nil)
+
+	var export DataExport
+	if code := doJSON(t, srv, "POST", "/api/users/me/export", alice.Token, nil, &export); code != http.StatusAccepted {
+		t.Fatalf("request export: status %d", code)
+	}
+	if export.Status != exportPending {
+		t.Errorf("new export is %s", export.Status)
+	}
+	var again DataExport
+	doJSON(t, srv, "POST", "/api/users/me/export", alice.Token, nil, &again)
+	if again.ID != export.ID {
+		t.Errorf("asking again started a second export")
+	}
+
+	cfg.buildDueExports(t.Context())
+
+	path := "/api/users/me/exports/" + export.ID.String()
+	if code := doJSON(t, srv, "GET", path, alice.Token, nil, &export); code != http.StatusOK {
+		t.Fatalf("get export: status %d", code)
+	}
+	if export.Status != exportReady || export.DownloadURL == "" {
+		t.Fatalf("built export: %+v", export)
+	}
+	if code := doJSON(t, srv, "GET", path, bob.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob getting alice's export: status %d", code)
+	}
+
+	resp, err := http.Get(srv.URL + export.DownloadURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("download: status %d", resp.StatusCode)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data exportArchive
+	for _, f := range zr.File {
+		if f.Name != "chirpy-export/data.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := io.ReadAll(rc)
+		rc.Close()
+		if strings.Contains(string(raw), alice.RefreshToken) {
+			t.Errorf("archive contains a refresh token")
+		}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data.Profile.ID != alice.ID || len(data.Chirps) != 1 || data.Chirps[0].Body != "export me" {
+		t.Errorf("archive profile %v, chirps %+v", data.Profile.ID, data.Chirps)
+	}
+	if len(data.Sessions) != 1 {
+		t.Errorf("archive has %d sessions, want 1", len(data.Sessions))
+	}
+
+	tampered := strings.Replace(export.DownloadURL, "signature=", "signature=x", 1)
+	resp, err = http.Get(srv.URL + tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("tampered link: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/enderbd/chirpy/internal/chirptext"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/feed"
+	"github.com/google/uuid"
+)
+
+const (
+	feedMaxItems   = 50
+	feedTitleRunes = 60
+)
+
+type feedFormat struct {
+	contentType string
+	write       func(w *bytes.Buffer, f feed.Feed) error
+}
+
+var (
+	feedRSS = feedFormat{
+		contentType: "application/rss+xml; charset=utf-8",
+		write:       func(w *bytes.Buffer, f feed.Feed) error { return feed.WriteRSS(w, f) },
+	}
+	feedAtom = feedFormat{
+		contentType: "application/atom+xml; charset=utf-8",
+		write:       func(w *bytes.Buffer, f feed.Feed) error { return feed.WriteAtom(w, f) },
+	}
+)
+
+func (cfg *apiConfig) handlerUserFeedRSS(w http.ResponseWriter, r *http.Request) {
+	cfg.serveUserFeed(w, r, feedRSS)
+}
+
+func (cfg *apiConfig) handlerUserFeedAtom(w http.ResponseWriter, r *http.Request) {
+	cfg.serveUserFeed(w, r, feedAtom)
+}
+
+func (cfg *apiConfig) handlerTagFeedRSS(w http.ResponseWriter, r *http.Request) {
+	cfg.serveTagFeed(w, r, feedRSS)
+}
+
+func (cfg *apiConfig) handlerTagFeedAtom(w http.ResponseWriter, r *http.Request) {
+	cfg.serveTagFeed(w, r, feedAtom)
+}
+
+func (cfg *apiConfig) serveUserFeed(w http.ResponseWriter, r *http.Request, format feedFormat) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "User ID is not a valid uuid", err)
+		return
+	}
+
+	_, err = cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	chirps, err := cfg.db.GetChirpsByUserID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not the chirps for the provided user", err)
+		return
+	}
+	chirps = publishedChirps(chirps)
+
+	base := requestBaseURL(r)
+	f := feed.Feed{
+		Title:       fmt.Sprintf("Chirps by %s", userID),
+		Description: fmt.Sprintf("The latest chirps posted by %s on Chirpy", userID),
+		Link:        fmt.Sprintf("%s/api/chirps?author_id=%s", base, userID),
+		SelfLink:    base + r.URL.Path,
+		ID:          "urn:uuid:" + userID.String(),
+	}
+	serveFeed(w, r, format, f, chirps, base)
+}
+
+func (cfg *apiConfig) serveTagFeed(w http.ResponseWriter, r *http.Request, format feedFormat) {
+	tag := strings.ToLower(r.PathValue("tag"))
+	if !chirptext.ValidTag(tag) {
+		respondWithError(w, http.StatusBadRequest, "Tag may only contain letters, digits and underscores", nil)
+		return
+	}
+
+	all, err := cfg.db.GetChirps(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get all the chirps", err)
+		return
+	}
+
+	var chirps []database.Chirp
+	for _, chirp := range publishedChirps(all) {
+		if chirptext.HasHashtag(chirp.Body, tag) {
+			chirps = append(chirps, chirp)
+		}
+	}
+
+	base := requestBaseURL(r)
+	f := feed.Feed{
+		Title:       "Chirps tagged #" + tag,
+		Description: fmt.Sprintf("The latest chirps tagged #%s on Chirpy", tag),
+		Link:        base + "/app/",
+		SelfLink:    base + r.URL.Path,
+		// A name based UUID keeps the feed ID stable for a given tag.
+		ID: "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte(base+"/tags/"+tag)).String(),
+	}
+	serveFeed(w, r, format, f, chirps, base)
+}
+
+// serveFeed renders the newest chirps into f. http.ServeContent takes care
+// of ETag, Last-Modified and the matching conditional request headers.
+func serveFeed(w http.ResponseWriter, r *http.Request, format feedFormat, f feed.Feed, chirps []database.Chirp, base string) {
+	sort.Slice(chirps, func(i, j int) bool {
+		return chirpTime(chirps[i]).After(chirpTime(chirps[j]))
+	})
+	if len(chirps) > feedMaxItems {
+		chirps = chirps[:feedMaxItems]
+	}
+
+	// An empty feed still needs a stable Updated value for caching.
+	f.Updated = time.Unix(0, 0).UTC()
+	for _, chirp := range chirps {
+		if chirp.UpdatedAt.After(f.Updated) {
+			f.Updated = chirp.UpdatedAt
+		}
+		f.Items = append(f.Items, feed.Item{
+			ID:        "urn:uuid:" + chirp.ID.String(),
+			Title:     feedTitle(chirp.Body),
+			Link:      fmt.Sprintf("%s/api/chirps/%s", base, chirp.ID),
+			Content:   chirp.Body,
+			Author:    chirp.UserID.String(),
+			Published: chirpTime(chirp),
+			Updated:   chirp.UpdatedAt,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := format.write(&buf, f); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not render the feed", err)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	http.ServeContent(w, r, "", f.Updated, bytes.NewReader(buf.Bytes()))
+}
+
+func feedTitle(body string) string {
+	body = strings.Join(strings.Fields(body), " ")
+	if utf8.RuneCountInString(body) <= feedTitleRunes {
+		return body
+	}
+	runes := []rune(body)
+	return string(runes[:feedTitleRunes-1]) + "…"
+}
+
+// requestBaseURL reconstructs the public origin of the request so that feed
+// links are absolute, honouring a TLS terminating proxy.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
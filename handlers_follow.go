@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/notify"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	followerID, followeeID, ok := cfg.followParams(w, r)
+	if !ok {
+		return
+	}
+
+	if followerID == followeeID {
+		respondWithError(w, http.StatusBadRequest, "You can not follow yourself", nil)
+		return
+	}
+
+	_, err := cfg.db.GetUserByID(r.Context(), followeeID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	blocked, err := cfg.blockedBetween(r.Context(), followerID, followeeID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not check for blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can not follow this user", errBlocked)
+		return
+	}
+
+	n, err := cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not follow the user", err)
+		return
+	}
+	if n > 0 {
+		cfg.notify(r.Context(), followeeID, followerID, notify.KindFollow, uuid.NullUUID{})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followerID, followeeID, ok := cfg.followParams(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not unfollow the user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) followParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "User ID is not a valid uuid", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Could not find JWT", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	followerID, err := cfg.validateToken(r.Context(), token)
+	if err != nil {
+		respondWithTokenError(w, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	setRequestUser(w, followerID)
+
+	return followerID, followeeID, true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/notify"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userId, chirp, ok := cfg.likeParams(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userId,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not like the chirp", err)
+		return
+	}
+	// Liking twice is a no-op and must not notify the author again.
+	if n > 0 {
+		cfg.notify(r.Context(), chirp.UserID, userId, notify.KindLike, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userId, chirp, ok := cfg.likeParams(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userId,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not unlike the chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) likeParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Chirp, bool) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is not a valid uuid", err)
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	chirp, err := cfg.db.GetSingleChirp(r.Context(), chirpUUID)
+	if err == nil {
+		var visible bool
+		visible, err = cfg.visibleChirp(r.Context(), chirp, userId)
+		if err == nil && !visible {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	return userId, chirp, true
+}
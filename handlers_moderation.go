@@ -0,0 +1,225 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+type ModerationRule struct {
+	// ID is only set for rules stored in the database, the ones that can
+	// be deleted.
+	ID        *uuid.UUID `json:"id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Term      string     `json:"term"`
+	Action    string     `json:"action"`
+	Source    string     `json:"source"`
+}
+
+func moderationRuleFromDB(r database.ModerationRule) ModerationRule {
+	return ModerationRule{
+		ID:        &r.ID,
+		CreatedAt: &r.CreatedAt,
+		Term:      r.Term,
+		Action:    r.Action,
+		Source:    moderation.SourceDatabase,
+	}
+}
+
+// HeldChirp is a chirp waiting for a moderator, with the rules it breaks
+// now.
+type HeldChirp struct {
+	Chirp
+	Moderation *moderationReport `json:"moderation,omitempty"`
+}
+
+func (cfg *apiConfig) handlerListModerationRules(w http.ResponseWriter, r *http.Request) {
+	static, err := cfg.staticModerationRules()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not read the moderation rules file", err)
+		return
+	}
+	stored, err := cfg.db.ListModerationRules(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not list moderation rules", err)
+		return
+	}
+
+	rules := []ModerationRule{}
+	for _, rule := range static {
+		rules = append(rules, ModerationRule{Term: rule.Term, Action: string(rule.Action), Source: rule.Source})
+	}
+	for _, rule := range stored {
+		rules = append(rules, moderationRuleFromDB(rule))
+	}
+	respondWithJson(w, http.StatusOK, rules)
+}
+
+func (cfg *apiConfig) handlerCreateModerationRule(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Term   string `json:"term"`
+		Action string `json:"action"`
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Action must be mask, hold or reject", err)
+		return
+	}
+	term := strings.TrimSpace(params.Term)
+	key := moderation.TermKey(term)
+	if key == "" {
+		respondWithError(w, http.StatusBadRequest, "Term must contain at least one word", nil)
+		return
+	}
+
+	// Terms that normalize the same are the same rule, which the unique
+	// key on term can't see.
+	stored, err := cfg.db.ListModerationRules(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not list moderation rules", err)
+		return
+	}
+	if slices.ContainsFunc(stored, func(rule database.ModerationRule) bool { return moderation.TermKey(rule.Term) == key }) {
+		respondWithError(w, http.StatusConflict, "A rule for this term already exists", nil)
+		return
+	}
+
+	rule, err := cfg.db.CreateModerationRule(r.Context(), database.CreateModerationRuleParams{
+		Term:   term,
+		Action: string(action),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create the moderation rule", err)
+		return
+	}
+	if err := cfg.reloadModeration(r.Context()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not reload moderation rules", err)
+		return
+	}
+
+	respondWithJson(w, http.StatusCreated, moderationRuleFromDB(rule))
+}
+
+func (cfg *apiConfig) handlerDeleteModerationRule(w http.ResponseWriter, r *http.Request) {
+	ruleID, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Rule ID is not a valid uuid", err)
+		return
+	}
+	n, err := cfg.db.DeleteModerationRule(r.Context(), ruleID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete the moderation rule", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Moderation rule not found", nil)
+		return
+	}
+	if err := cfg.reloadModeration(r.Context()); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not reload moderation rules", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerListHeldChirps(w http.ResponseWriter, r *http.Request) {
+	chirps, err := cfg.db.GetChirpsByStatus(r.Context(), chirpHeld)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get held chirps", err)
+		return
+	}
+
+	pipeline := cfg.moderationPipeline()
+	held := []HeldChirp{}
+	for _, chirp := range chirps {
+		held = append(held, HeldChirp{
+			Chirp:      chirpFromDB(chirp),
+			Moderation: newModerationReport(chirp.Body, pipeline.Moderate(chirp.Body)),
+		})
+	}
+	respondWithJson(w, http.StatusOK, held)
+}
+
+// heldChirp loads the held chirp named in the path, writing a 404 when
+// there isn't one.
+func (cfg *apiConfig) heldChirp(w http.ResponseWriter, r *http.Request) (database.Chirp, bool) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is not a valid uuid", err)
+		return database.Chirp{}, false
+	}
+	chirp, err := cfg.db.GetSingleChirp(r.Context(), chirpUUID)
+	if err == nil && chirp.Status != chirpHeld {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Held chirp not found", err)
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
+
+// handlerApproveHeldChirp publishes a held chirp as of now. One scheduled
+// for later than its approval waits for the publisher instead. The chirp
+// is announced the way the publisher announces scheduled ones, so a crash
+// in between only delays the announcement.
+func (cfg *apiConfig) handlerApproveHeldChirp(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := cfg.heldChirp(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	chirp, err := cfg.db.ApproveHeldChirp(r.Context(), database.ApproveHeldChirpParams{
+		Now:        now,
+		AnnounceAt: sql.NullTime{Time: now.Add(announceLease), Valid: true},
+		ID:         chirp.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Rejected or approved by someone else in the meantime.
+		respondWithError(w, http.StatusNotFound, "Held chirp not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not publish the chirp", err)
+		return
+	}
+	if chirp.Status == chirpPublished {
+		cfg.announcePublished(r.Context(), []database.Chirp{chirp})
+	}
+
+	respondWithJson(w, http.StatusOK, chirpFromDB(chirp))
+}
+
+func (cfg *apiConfig) handlerRejectHeldChirp(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := cfg.heldChirp(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := cfg.db.DeleteHeldChirp(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not delete the chirp", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Held chirp not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testAdminKey = "test-admin-key"
+
+func newModeratedServer(t *testing.T) (*apiConfig, *httptest.Server) {
+	t.Helper()
+	cfg, srv := newTestServer(t)
+	cfg.adminKey = testAdminKey
+	return cfg, srv
+}
+
+func doAdmin(t *testing.T, srv *httptest.Server, method, path string, body, out any) int {
+	t.Helper()
+	return doJSONAuth(t, srv, method, path, "ApiKey "+testAdminKey, body, out)
+}
+
+func addRule(t *testing.T, srv *httptest.Server, action, term string) ModerationRule {
+	t.Helper()
+	var rule ModerationRule
+	code := doAdmin(t, srv, "POST", "/admin/api/moderation/rules", map[string]string{"action": action, "term": term}, &rule)
+	if code != http.StatusCreated {
+		t.Fatalf("adding %s %q: status %d", action, term, code)
+	}
+	return rule
+}
+
+func TestModerateChirps(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	addRule(t, srv, "hold", "buy followers")
+	addRule(t, srv, "reject", "spamlink")
+
+	type created struct {
+		Chirp
+		Moderation *moderationReport `json:"moderation"`
+	}
+	var held created
+	code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "BUY f0llowers, fornax"}, &held)
+	if code != http.StatusAccepted {
+		t.Fatalf("held chirp: status %d", code)
+	}
+	if held.Status != chirpHeld || held.Body != "BUY f0llowers, ****" {
+		t.Errorf("held chirp: got %+v", held.Chirp)
+	}
+	if r := held.Moderation; r == nil || r.Action != "hold" || len(r.Rules) != 2 ||
+		r.Rules[0].Term != "buy followers" || r.Rules[0].Match != "BUY f0llowers" || r.Rules[0].Source != "database" ||
+		r.Rules[1].Term != "fornax" || r.Rules[1].Source != "default" {
+		t.Errorf("held chirp report: %+v", r)
+	}
+
+	// Only the author sees a held chirp.
+	countChirps := func(token string) int {
+		var chirps []Chirp
+		doJSON(t, srv, "GET", "/api/chirps", token, nil, &chirps)
+		return len(chirps)
+	}
+	if n := countChirps(""); n != 0 {
+		t.Errorf("signed out: %d chirps listed", n)
+	}
+	if n := countChirps(bob.Token); n != 0 {
+		t.Errorf("bob: %d chirps listed", n)
+	}
+	if n := countChirps(alice.Token); n != 1 {
+		t.Errorf("alice: %d chirps listed", n)
+	}
+	if code := doJSON(t, srv, "GET", "/api/chirps/"+held.ID.String(), bob.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob getting the held chirp: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps/"+held.ID.String()+"/likes", bob.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob liking the held chirp: status %d", code)
+	}
+
+	var queue []HeldChirp
+	doAdmin(t, srv, "GET", "/admin/api/moderation/held", nil, &queue)
+	if len(queue) != 1 || queue[0].ID != held.ID || queue[0].Moderation == nil {
+		t.Fatalf("held queue: %+v", queue)
+	}
+	if code := doAdmin(t, srv, "POST", "/admin/api/moderation/held/"+held.ID.String()+"/approve", nil, nil); code != http.StatusOK {
+		t.Fatalf("approve: status %d", code)
+	}
+	if n := countChirps(""); n != 1 {
+		t.Errorf("after approval: %d chirps listed", n)
+	}
+	if code := doAdmin(t, srv, "POST", "/admin/api/moderation/held/"+held.ID.String()+"/reject", nil, nil); code != http.StatusNotFound {
+		t.Errorf("rejecting a published chirp: status %d", code)
+	}
+
+	var rejected struct {
+		Error      string            `json:"error"`
+		Moderation *moderationReport `json:"moderation"`
+	}
+	code = doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "see my $pamlink"}, &rejected)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("rejected chirp: status %d", code)
+	}
+	if r := rejected.Moderation; r == nil || r.Action != "reject" || len(r.Rules) != 1 || r.Rules[0].Match != "$pamlink" {
+		t.Errorf("rejected chirp report: %+v", r)
+	}
+	if n := countChirps(alice.Token); n != 1 {
+		t.Errorf("rejected chirp was stored")
+	}
+}
+
+func TestModerationRulesAdmin(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	if code := doAdmin(t, srv, "GET", "/admin/api/moderation/rules", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("without ADMIN_KEY: status %d", code)
+	}
+	cfg.adminKey = testAdminKey
+	if code := doJSONAuth(t, srv, "GET", "/admin/api/moderation/rules", "ApiKey wrong", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("wrong key: status %d", code)
+	}
+
+	rule := addRule(t, srv, "reject", "Spam Link")
+	for _, body := range []map[string]string{
+		{"action": "reject", "term": "spam  l1nk"},
+		{"action": "hold", "term": "SPAM LINK"},
+	} {
+		if code := doAdmin(t, srv, "POST", "/admin/api/moderation/rules", body, nil); code != http.StatusConflict {
+			t.Errorf("duplicate %v: status %d", body, code)
+		}
+	}
+	for _, body := range []map[string]string{
+		{"action": "ban", "term": "spam"},
+		{"action": "mask", "term": "  !!  "},
+	} {
+		if code := doAdmin(t, srv, "POST", "/admin/api/moderation/rules", body, nil); code != http.StatusBadRequest {
+			t.Errorf("invalid %v: status %d", body, code)
+		}
+	}
+
+	var rules []ModerationRule
+	doAdmin(t, srv, "GET", "/admin/api/moderation/rules", nil, &rules)
+	if len(rules) != 4 || rules[3].ID == nil || *rules[3].ID != *rule.ID || rules[0].Source != "default" {
+		t.Errorf("rules: %+v", rules)
+	}
+
+	path := "/admin/api/moderation/rules/" + rule.ID.String()
+	if code := doAdmin(t, srv, "DELETE", path, nil, nil); code != http.StatusNoContent {
+		t.Errorf("delete: status %d", code)
+	}
+	if code := doAdmin(t, srv, "DELETE", path, nil, nil); code != http.StatusNotFound {
+		t.Errorf("delete again: status %d", code)
+	}
+	if d := cfg.moderationPipeline().Moderate("spam link"); len(d.Matches) != 0 {
+		t.Errorf("deleted rule still applies: %+v", d)
+	}
+}
+
+func TestModerationRulesFile(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	user := createTestUser(t, srv, "alice@example.com", "alice")
+
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	if err := os.WriteFile(path, []byte("# house rules\nhold giveaway\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.moderationFile = path
+	if err := cfg.reloadModeration(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file replaces the default rules.
+	var chirp Chirp
+	if code := doJSON(t, srv, "POST", "/api/chirps", user.Token, map[string]string{"body": "fornax"}, &chirp); code != http.StatusCreated || chirp.Body != "fornax" {
+		t.Errorf("default rule applied: status %d, %+v", code, chirp)
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps", user.Token, map[string]string{"body": "Giveaway!"}, nil); code != http.StatusAccepted {
+		t.Errorf("file rule: status %d", code)
+	}
+
+	if _, err := readModerationRules(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("missing file read")
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/notify"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationsDefaultLimit = 20
+	notificationsMaxLimit     = 100
+)
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Kind      string     `json:"kind"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+	Read      bool       `json:"read"`
+}
+
+func notificationFromDB(n database.Notification) Notification {
+	out := Notification{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Kind:      n.Kind,
+		ActorID:   n.ActorID,
+		Read:      n.ReadAt.Valid,
+	}
+	if n.ChirpID.Valid {
+		out.ChirpID = &n.ChirpID.UUID
+	}
+	return out
+}
+
+// requireUser authenticates the request from its bearer token, writing a
+// 401 when it can't.
+func (cfg *apiConfig) requireUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	user, ok := cfg.bearerUser(w, r)
+	return user.ID, ok
+}
+
+func (cfg *apiConfig) handlerListNotifications(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := notificationsDefaultLimit
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > notificationsMaxLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100", err)
+			return
+		}
+		limit = n
+	}
+
+	// Without a cursor, start after every possible notification.
+	before, beforeID := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), uuid.Nil
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		before, beforeID, err = decodeNotificationCursor(cursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+	}
+
+	// Fetch one extra row to learn whether there is another page.
+	rows, err := cfg.db.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:     userId,
+		Before:     before,
+		BeforeID:   beforeID,
+		UnreadOnly: query.Get("unread") == "true",
+		PageSize:   int32(limit + 1),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get notifications", err)
+		return
+	}
+
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not count unread notifications", err)
+		return
+	}
+
+	type response struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+		NextCursor    string         `json:"next_cursor,omitempty"`
+	}
+	resp := response{
+		Notifications: []Notification{},
+		UnreadCount:   unread,
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		resp.NextCursor = encodeNotificationCursor(last.CreatedAt, last.ID)
+	}
+	for _, n := range rows {
+		resp.Notifications = append(resp.Notifications, notificationFromDB(n))
+	}
+
+	respondWithJson(w, http.StatusOK, resp)
+}
+
+func encodeNotificationCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeNotificationCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	notificationID, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	return createdAt, notificationID, nil
+}
+
+func (cfg *apiConfig) handlerMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Notification ID is not a valid uuid", err)
+		return
+	}
+
+	n, err := cfg.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not mark the notification as read", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Notification not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.MarkAllNotificationsRead(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not mark the notifications as read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get notification preferences", err)
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, prefs)
+}
+
+func (cfg *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var params []notificationPreference
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+	for _, p := range params {
+		if !notify.ValidKind(p.Kind) {
+			respondWithError(w, http.StatusBadRequest, "Unknown notification kind: "+p.Kind, nil)
+			return
+		}
+	}
+
+	for _, p := range params {
+		err := cfg.db.UpsertNotificationPreference(r.Context(), database.UpsertNotificationPreferenceParams{
+			UserID: userId,
+			Kind:   p.Kind,
+			InApp:  p.InApp,
+			Email:  p.Email,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not update notification preferences", err)
+			return
+		}
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get notification preferences", err)
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, prefs)
+}
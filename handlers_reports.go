@@ -0,0 +1,267 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+// reportReasons are the reasons a chirp can be reported for.
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "self_harm", "misinformation", "other"}
+
+const maxReportDetailsLength = 500
+
+// Moderator actions on a reported chirp.
+const (
+	actionDismiss = "dismiss"
+	actionHide    = "hide"
+	actionSuspend = "suspend"
+	actionBan     = "ban"
+)
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ChirpID    uuid.UUID  `json:"chirp_id"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy *uuid.UUID `json:"resolved_by,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+}
+
+func reportFromDB(r database.ChirpReport) Report {
+	out := Report{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		ChirpID:    r.ChirpID,
+		ReporterID: r.ReporterID,
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Resolution: r.Resolution.String,
+	}
+	if r.ResolvedAt.Valid {
+		out.ResolvedAt = &r.ResolvedAt.Time
+	}
+	if r.ResolvedBy.Valid {
+		out.ResolvedBy = &r.ResolvedBy.UUID
+	}
+	return out
+}
+
+func (cfg *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is not a valid uuid", err)
+		return
+	}
+
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Reason must be one of %v", reportReasons), nil)
+		return
+	}
+	if utf8.RuneCountInString(params.Details) > maxReportDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "Details are too long", nil)
+		return
+	}
+
+	chirp, err := cfg.db.GetSingleChirp(r.Context(), chirpUUID)
+	if err == nil && !chirpVisible(chirp, userId) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if chirp.UserID == userId {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp", nil)
+		return
+	}
+
+	report, err := cfg.db.CreateChirpReport(r.Context(), database.CreateChirpReportParams{
+		ChirpID:    chirp.ID,
+		ReporterID: userId,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "You have already reported this chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not report the chirp", err)
+		return
+	}
+
+	respondWithJson(w, http.StatusCreated, reportFromDB(report))
+}
+
+// ReportedChirp is an entry in the moderation queue: a chirp and its open
+// reports.
+type ReportedChirp struct {
+	Chirp   Chirp    `json:"chirp"`
+	Reports []Report `json:"reports"`
+}
+
+// handlerListReports is the moderation queue, oldest report first.
+func (cfg *apiConfig) handlerListReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := cfg.db.ListOpenChirpReports(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not list reports", err)
+		return
+	}
+
+	queue := []ReportedChirp{}
+	index := map[uuid.UUID]int{}
+	for _, report := range reports {
+		i, ok := index[report.ChirpID]
+		if !ok {
+			chirp, err := cfg.db.GetSingleChirp(r.Context(), report.ChirpID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Could not get a reported chirp", err)
+				return
+			}
+			i = len(queue)
+			index[report.ChirpID] = i
+			queue = append(queue, ReportedChirp{Chirp: chirpFromDB(chirp)})
+		}
+		queue[i].Reports = append(queue[i].Reports, reportFromDB(report))
+	}
+
+	respondWithJson(w, http.StatusOK, queue)
+}
+
+// handlerModerateChirp applies a moderator's action to a reported chirp and
+// resolves its open reports with it.
+func (cfg *apiConfig) handlerModerateChirp(w http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is not a valid uuid", err)
+		return
+	}
+
+	moderator, _ := contextUser(r.Context())
+
+	type parameters struct {
+		Action string `json:"action"`
+		// SuspendedUntil is when a suspension ends, by default a week
+		// from now.
+		SuspendedUntil *time.Time `json:"suspended_until"`
+		// Reason is shown to a suspended or banned author, by default
+		// the reasons the chirp was reported for.
+		Reason string `json:"reason"`
+		// HideChirps sets a banned author's chirps aside.
+		HideChirps bool `json:"hide_chirps"`
+	}
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+	if !slices.Contains([]string{actionDismiss, actionHide, actionSuspend, actionBan}, params.Action) {
+		respondWithError(w, http.StatusBadRequest, "Action must be dismiss, hide, suspend or ban", nil)
+		return
+	}
+	until := time.Now().UTC().Add(defaultSuspension)
+	if params.SuspendedUntil != nil {
+		until = params.SuspendedUntil.UTC()
+	}
+	if params.Action == actionSuspend && !until.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future", nil)
+		return
+	}
+
+	chirp, err := cfg.db.GetSingleChirp(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	reports, err := cfg.db.ListOpenChirpReports(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not list reports", err)
+		return
+	}
+	var reasons []string
+	for _, report := range reports {
+		if report.ChirpID == chirp.ID && !slices.Contains(reasons, report.Reason) {
+			reasons = append(reasons, report.Reason)
+		}
+	}
+	if len(reasons) == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp has no open reports", nil)
+		return
+	}
+	reason := strings.TrimSpace(params.Reason)
+	if reason == "" {
+		reason = strings.Join(reasons, ", ")
+	}
+
+	type response struct {
+		ChirpID         uuid.UUID  `json:"chirp_id"`
+		Action          string     `json:"action"`
+		ModeratorID     uuid.UUID  `json:"moderator_id"`
+		ResolvedReports int64      `json:"resolved_reports"`
+		SuspendedUntil  *time.Time `json:"suspended_until,omitempty"`
+	}
+	out := response{ChirpID: chirp.ID, Action: params.Action, ModeratorID: moderator.ID}
+
+	switch params.Action {
+	case actionHide:
+		err = cfg.db.SetChirpStatus(r.Context(), database.SetChirpStatusParams{Status: chirpHidden, ID: chirp.ID})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not hide the chirp", err)
+			return
+		}
+		if chirp.Status == chirpPublished {
+			cfg.publishChirpEvent(r.Context(), stream.EventChirpDeleted, chirp)
+			cfg.federateChirp(r.Context(), stream.EventChirpDeleted, chirp)
+		}
+	case actionSuspend, actionBan:
+		hideChirps := false
+		if params.Action == actionBan {
+			until, hideChirps = banEnd, params.HideChirps
+		}
+		if err := cfg.suspendUser(r.Context(), chirp.UserID, until, reason, hideChirps); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not suspend the user", err)
+			return
+		}
+		out.SuspendedUntil = &until
+	}
+
+	out.ResolvedReports, err = cfg.db.ResolveChirpReports(r.Context(), database.ResolveChirpReportsParams{
+		ResolvedBy: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Resolution: sql.NullString{String: params.Action, Valid: true},
+		ChirpID:    chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not resolve the reports", err)
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, out)
+}
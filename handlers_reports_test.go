@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeModerator(t *testing.T, srv *httptest.Server, user User) {
+	t.Helper()
+	code := doAdmin(t, srv, "PUT", "/admin/api/users/"+user.ID.String()+"/role", map[string]string{"role": roleModerator}, nil)
+	if code != http.StatusNoContent {
+		t.Fatalf("making %s a moderator: status %d", user.Email, code)
+	}
+}
+
+func TestReportAndHideChirp(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	carol := createTestUser(t, srv, "carol@example.com", "carol")
+	mod := createTestUser(t, srv, "mod@example.com", "mod")
+
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "buy my stuff"}, &chirp)
+	reportPath := "/api/chirps/" + chirp.ID.String() + "/reports"
+
+	var report Report
+	if code := doJSON(t, srv, "POST", reportPath, bob.Token, map[string]string{"reason": "spam", "details": "ads"}, &report); code != http.StatusCreated {
+		t.Fatalf("report: status %d", code)
+	}
+	if report.ChirpID != chirp.ID || report.ReporterID != bob.ID || report.Reason != "spam" || report.ResolvedAt != nil {
+		t.Errorf("report: got %+v", report)
+	}
+	for _, tt := range []struct {
+		name  string
+		token string
+		body  map[string]string
+		want  int
+	}{
+		{"again", bob.Token, map[string]string{"reason": "spam"}, http.StatusConflict},
+		{"own chirp", alice.Token, map[string]string{"reason": "spam"}, http.StatusBadRequest},
+		{"unknown reason", carol.Token, map[string]string{"reason": "boring"}, http.StatusBadRequest},
+		{"signed out", "", map[string]string{"reason": "spam"}, http.StatusUnauthorized},
+	} {
+		if code := doJSON(t, srv, "POST", reportPath, tt.token, tt.body, nil); code != tt.want {
+			t.Errorf("report %s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+	doJSON(t, srv, "POST", reportPath, carol.Token, map[string]string{"reason": "harassment"}, nil)
+
+	if code := doJSON(t, srv, "GET", "/api/moderation/reports", bob.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("queue as a user: status %d", code)
+	}
+	makeModerator(t, srv, mod)
+	var queue []ReportedChirp
+	doJSON(t, srv, "GET", "/api/moderation/reports", mod.Token, nil, &queue)
+	if len(queue) != 1 || queue[0].Chirp.ID != chirp.ID || len(queue[0].Reports) != 2 {
+		t.Fatalf("queue: %+v", queue)
+	}
+
+	actionPath := "/api/moderation/chirps/" + chirp.ID.String()
+	var result struct {
+		ModeratorID     string `json:"moderator_id"`
+		ResolvedReports int    `json:"resolved_reports"`
+	}
+	if code := doJSON(t, srv, "POST", actionPath, mod.Token, map[string]string{"action": "hide"}, &result); code != http.StatusOK {
+		t.Fatalf("hide: status %d", code)
+	}
+	if result.ModeratorID != mod.ID.String() || result.ResolvedReports != 2 {
+		t.Errorf("hide: got %+v", result)
+	}
+
+	// Hidden chirps are gone for everyone but their author.
+	var chirps []Chirp
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+alice.ID.String(), bob.Token, nil, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("bob still sees %d chirps", len(chirps))
+	}
+	doJSON(t, srv, "GET", "/api/chirps?author_id="+alice.ID.String(), alice.Token, nil, &chirps)
+	if len(chirps) != 1 || chirps[0].Status != chirpHidden {
+		t.Errorf("alice sees %+v", chirps)
+	}
+
+	doJSON(t, srv, "GET", "/api/moderation/reports", mod.Token, nil, &queue)
+	if len(queue) != 0 {
+		t.Errorf("queue after hiding: %+v", queue)
+	}
+	if code := doJSON(t, srv, "POST", actionPath, mod.Token, map[string]string{"action": "dismiss"}, nil); code != http.StatusNotFound {
+		t.Errorf("acting on a resolved chirp: status %d", code)
+	}
+}
+
+func TestSuspendAuthor(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	mod := createTestUser(t, srv, "mod@example.com", "mod")
+	makeModerator(t, srv, mod)
+
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "you are all terrible"}, &chirp)
+	doJSON(t, srv, "POST", "/api/chirps/"+chirp.ID.String()+"/reports", bob.Token, map[string]string{"reason": "harassment"}, nil)
+
+	actionPath := "/api/moderation/chirps/" + chirp.ID.String()
+	past := map[string]any{"action": "suspend", "suspended_until": time.Now().Add(-time.Hour)}
+	if code := doJSON(t, srv, "POST", actionPath, mod.Token, past, nil); code != http.StatusBadRequest {
+		t.Errorf("suspending into the past: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", actionPath, mod.Token, map[string]string{"action": "suspend"}, nil); code != http.StatusOK {
+		t.Fatalf("suspend: status %d", code)
+	}
+
+	var refused struct {
+		Error string `json:"error"`
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "hello?"}, &refused); code != http.StatusForbidden {
+		t.Errorf("chirping while suspended: status %d", code)
+	}
+	if !strings.HasPrefix(refused.Error, "Your account is suspended until ") || !strings.HasSuffix(refused.Error, ": harassment") {
+		t.Errorf("chirping while suspended: error %q", refused.Error)
+	}
+	if code := doJSON(t, srv, "GET", "/api/notifications", alice.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("reading notifications while suspended: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/refresh", alice.RefreshToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("refreshing while suspended: status %d", code)
+	}
+	login := map[string]string{"email": "alice@example.com", "password": "hunter2"}
+	if code := doJSON(t, srv, "POST", "/api/login", "", login, nil); code != http.StatusForbidden {
+		t.Errorf("logging in while suspended: status %d", code)
+	}
+	// The chirp itself stays up.
+	if code := doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID.String(), "", nil, nil); code != http.StatusOK {
+		t.Errorf("suspended author's chirp: status %d", code)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxScheduleAhead is how far ahead a chirp can be scheduled.
+	maxScheduleAhead = 365 * 24 * time.Hour
+	// scheduledPublishInterval is how late a scheduled chirp can go out.
+	scheduledPublishInterval = 15 * time.Second
+	scheduledPublishBatch    = 100
+	// announceLease is how long an instance has to announce the chirps it
+	// published before another one takes over.
+	announceLease = time.Minute
+)
+
+var errBadSchedule = errors.New("publish_at must be in the future and at most a year ahead")
+
+// checkSchedule returns errBadSchedule unless a chirp can be scheduled for
+// at.
+func checkSchedule(at time.Time) error {
+	now := time.Now()
+	if !at.After(now) || at.After(now.Add(maxScheduleAhead)) {
+		return errBadSchedule
+	}
+	return nil
+}
+
+// handlerListScheduledChirps lists the user's scheduled chirps, the next
+// one to go out first. Held chirps are listed with the rest of the user's
+// chirps until a moderator approves them.
+func (cfg *apiConfig) handlerListScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	chirps, err := cfg.db.ListScheduledChirps(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get scheduled chirps", err)
+		return
+	}
+	out := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		out = append(out, chirpFromDB(chirp))
+	}
+	respondWithJson(w, http.StatusOK, out)
+}
+
+// scheduledChirp loads the user's scheduled chirp named in the path,
+// writing a 404 when there isn't one.
+func (cfg *apiConfig) scheduledChirp(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Chirp, bool) {
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is not a valid uuid", err)
+		return database.Chirp{}, false
+	}
+	chirp, err := cfg.db.GetSingleChirp(r.Context(), chirpUUID)
+	if err == nil && (chirp.UserID != userID || chirp.Status != chirpScheduled) {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Scheduled chirp not found", err)
+		return database.Chirp{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not get the chirp", err)
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
+
+// handlerUpdateScheduledChirp changes the body or publish_at of a chirp
+// that hasn't gone out yet. A new body is moderated again, and a held one
+// waits for a moderator before it is scheduled again.
+func (cfg *apiConfig) handlerUpdateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body      *string    `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	user, ok := cfg.bearerUser(w, r)
+	if !ok {
+		return
+	}
+	chirp, ok := cfg.scheduledChirp(w, r, user.ID)
+	if !ok {
+		return
+	}
+	if suspended(user) {
+		err := suspensionError{user}
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters", err)
+		return
+	}
+
+	update := database.UpdateScheduledChirpParams{
+		Body:      chirp.Body,
+		PublishAt: chirp.PublishAt,
+		Status:    chirpScheduled,
+		ID:        chirp.ID,
+	}
+	if params.PublishAt != nil {
+		if err := checkSchedule(*params.PublishAt); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		update.PublishAt = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+	}
+	var decision moderation.Decision
+	if params.Body != nil {
+		if len(*params.Body) > maxChirpLength {
+			respondWithError(w, http.StatusBadRequest, "Chirp it too long", errChirpTooLong)
+			return
+		}
+		decision = cfg.moderationPipeline().Moderate(*params.Body)
+		if decision.Action == moderation.ActionReject {
+			respondWithRejection(w, *params.Body, decision, errChirpRejected)
+			return
+		}
+		if decision.Action == moderation.ActionHold {
+			update.Status = chirpHeld
+		}
+		update.Body = decision.Body
+	}
+
+	updated, err := cfg.db.UpdateScheduledChirp(r.Context(), update)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The publisher got to it first.
+		respondWithError(w, http.StatusConflict, "The chirp has already been published", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not update the chirp", err)
+		return
+	}
+
+	type response struct {
+		Chirp
+		Moderation *moderationReport `json:"moderation,omitempty"`
+	}
+	code := http.StatusOK
+	if updated.Status == chirpHeld {
+		code = http.StatusAccepted
+	}
+	out := response{Chirp: chirpFromDB(updated)}
+	if params.Body != nil {
+		out.Moderation = newModerationReport(*params.Body, decision)
+	}
+	respondWithJson(w, code, out)
+}
+
+// handlerCancelScheduledChirp deletes a chirp that hasn't gone out yet.
+func (cfg *apiConfig) handlerCancelScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp ID is not a valid uuid", err)
+		return
+	}
+
+	n, err := cfg.db.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{ID: chirpUUID, UserID: userId})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not cancel the chirp", err)
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "Scheduled chirp not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replyParent loads the chirp that chirp replies to, or nil when it isn't
+// a reply or its parent has been deleted.
+func (cfg *apiConfig) replyParent(ctx context.Context, chirp database.Chirp) (*database.Chirp, error) {
+	if !chirp.ReplyToID.Valid {
+		return nil, nil
+	}
+	parent, err := cfg.db.GetSingleChirp(ctx, chirp.ReplyToID.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &parent, nil
+}
+
+func (cfg *apiConfig) runChirpPublisher(ctx context.Context) {
+	ticker := time.NewTicker(scheduledPublishInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg.publishDueChirps(ctx, time.Now().UTC())
+		}
+	}
+}
+
+// publishDueChirps publishes the scheduled chirps due at now and announces
+// them as if they had just been posted. Every instance runs it: the store
+// hands each due chirp to only one of them. A chirp by a suspended author,
+// or one whose account is waiting to be deleted, stays scheduled until the
+// suspension ends or the account is restored.
+//
+// Publishing a chirp also marks its announcement as owed, and announcing it
+// clears the mark. Announcements an instance crashed before making are
+// picked up again once its lease on them runs out.
+//
+// Cancelling ctx stops it between batches; a batch it has claimed is
+// always announced in full.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context, now time.Time) {
+	lease := sql.NullTime{Time: now.Add(announceLease), Valid: true}
+	batchCtx := context.WithoutCancel(ctx)
+	for ctx.Err() == nil {
+		chirps, err := cfg.db.PublishDueChirps(batchCtx, database.PublishDueChirpsParams{Now: now, AnnounceAt: lease, Limit: scheduledPublishBatch})
+		if err != nil {
+			logging.FromContext(ctx).Error("Could not publish scheduled chirps", logging.Err(err))
+			return
+		}
+		cfg.announcePublished(batchCtx, chirps)
+		if len(chirps) < scheduledPublishBatch {
+			break
+		}
+	}
+	for ctx.Err() == nil {
+		chirps, err := cfg.db.ClaimUnannouncedChirps(batchCtx, database.ClaimUnannouncedChirpsParams{Now: now, AnnounceAt: lease, Limit: scheduledPublishBatch})
+		if err != nil {
+			logging.FromContext(ctx).Error("Could not claim unannounced chirps", logging.Err(err))
+			return
+		}
+		cfg.announcePublished(batchCtx, chirps)
+		if len(chirps) < scheduledPublishBatch {
+			return
+		}
+	}
+}
+
+// announcePublished announces chirps the publisher claimed. One that has
+// been set aside since, say because its author deleted their account, is
+// only marked done.
+func (cfg *apiConfig) announcePublished(ctx context.Context, chirps []database.Chirp) {
+	for _, chirp := range chirps {
+		if chirp.Status == chirpPublished {
+			replyTo, err := cfg.replyParent(ctx, chirp)
+			if err != nil {
+				logging.FromContext(ctx).Error("Could not get the chirp being replied to", "chirp_id", chirp.ID.String(), logging.Err(err))
+			}
+			cfg.announceChirp(ctx, chirp, replyTo)
+		}
+		if err := cfg.db.FinishChirpAnnouncement(ctx, chirp.ID); err != nil {
+			logging.FromContext(ctx).Error("Could not mark the chirp announced", "chirp_id", chirp.ID.String(), logging.Err(err))
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+)
+
+func TestScheduledChirps(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	inAnHour := time.Now().Add(time.Hour).UTC()
+
+	var chirp Chirp
+	code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]any{
+		"body": "good morning @bob", "publish_at": inAnHour,
+	}, &chirp)
+	if code != http.StatusCreated || chirp.Status != chirpScheduled || chirp.PublishAt == nil || !chirp.PublishAt.Equal(inAnHour) {
+		t.Fatalf("scheduling: status %d, %+v", code, chirp)
+	}
+	for _, at := range []time.Time{time.Now().Add(-time.Minute), time.Now().Add(2 * maxScheduleAhead)} {
+		if code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]any{"body": "when?", "publish_at": at}, nil); code != http.StatusBadRequest {
+			t.Errorf("scheduling for %v: status %d, want %d", at, code, http.StatusBadRequest)
+		}
+	}
+
+	for _, path := range []string{"/api/chirps", "/api/chirps?author_id=" + alice.ID.String()} {
+		if n := chirpAuthors(t, srv, path, alice.Token)[alice.ID]; n != 0 {
+			t.Errorf("GET %s shows alice %d scheduled chirps", path, n)
+		}
+	}
+	if code := doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID.String(), bob.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob getting the scheduled chirp: status %d", code)
+	}
+
+	var scheduled []Chirp
+	doJSON(t, srv, "GET", "/api/users/me/scheduled", alice.Token, nil, &scheduled)
+	if len(scheduled) != 1 || scheduled[0].ID != chirp.ID {
+		t.Errorf("alice's scheduled chirps: %+v", scheduled)
+	}
+	doJSON(t, srv, "GET", "/api/users/me/scheduled", bob.Token, nil, &scheduled)
+	if len(scheduled) != 0 {
+		t.Errorf("bob's scheduled chirps: %+v", scheduled)
+	}
+
+	path := "/api/users/me/scheduled/" + chirp.ID.String()
+	if code := doJSON(t, srv, "PUT", path, bob.Token, map[string]string{"body": "mine now"}, nil); code != http.StatusNotFound {
+		t.Errorf("bob editing alice's chirp: status %d", code)
+	}
+	inTwoHours := inAnHour.Add(time.Hour)
+	var edited Chirp
+	code = doJSON(t, srv, "PUT", path, alice.Token, map[string]any{"body": "good afternoon @bob", "publish_at": inTwoHours}, &edited)
+	if code != http.StatusOK || edited.Body != "good afternoon @bob" || !edited.PublishAt.Equal(inTwoHours) {
+		t.Errorf("editing: status %d, %+v", code, edited)
+	}
+
+	var cancelled Chirp
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]any{"body": "never mind", "publish_at": inAnHour}, &cancelled)
+	cancelPath := "/api/users/me/scheduled/" + cancelled.ID.String()
+	if code := doJSON(t, srv, "DELETE", cancelPath, bob.Token, nil, nil); code != http.StatusNotFound {
+		t.Errorf("bob cancelling alice's chirp: status %d", code)
+	}
+	if code := doJSON(t, srv, "DELETE", cancelPath, alice.Token, nil, nil); code != http.StatusNoContent {
+		t.Errorf("cancelling: status %d", code)
+	}
+
+	cfg.publishDueChirps(t.Context(), inAnHour.Add(time.Minute))
+	if n := chirpAuthors(t, srv, "/api/chirps", bob.Token)[alice.ID]; n != 0 {
+		t.Errorf("chirp published before it was due: %d", n)
+	}
+	if n := countNotifications(t, srv, bob.Token); n != 0 {
+		t.Errorf("bob was notified %d times before the chirp went out", n)
+	}
+
+	var bobs Chirp
+	doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "posted while alice's chirp waits"}, &bobs)
+
+	publishedAt := inTwoHours.Add(time.Minute)
+	cfg.publishDueChirps(t.Context(), publishedAt)
+	if n := chirpAuthors(t, srv, "/api/chirps", bob.Token)[alice.ID]; n != 1 {
+		t.Errorf("bob sees %d of alice's chirps once published, want 1", n)
+	}
+	var published Chirp
+	doJSON(t, srv, "GET", "/api/chirps/"+chirp.ID.String(), bob.Token, nil, &published)
+	if !published.CreatedAt.Equal(chirp.CreatedAt) || published.PublishAt == nil || !published.PublishAt.Equal(publishedAt) {
+		t.Errorf("published chirp: %+v, want it created at %v and published at %v", published, chirp.CreatedAt, publishedAt)
+	}
+	var timeline []Chirp
+	doJSON(t, srv, "GET", "/api/chirps", bob.Token, nil, &timeline)
+	if len(timeline) != 2 || timeline[0].ID != bobs.ID || timeline[1].ID != chirp.ID {
+		t.Errorf("timeline: %+v, want bob's chirp then alice's scheduled one", timeline)
+	}
+	if n := countNotifications(t, srv, bob.Token); n != 1 {
+		t.Errorf("bob was notified %d times once the chirp went out, want 1", n)
+	}
+	doJSON(t, srv, "GET", "/api/users/me/scheduled", alice.Token, nil, &scheduled)
+	if len(scheduled) != 0 {
+		t.Errorf("scheduled chirps after publishing: %+v", scheduled)
+	}
+	if code := doJSON(t, srv, "PUT", path, alice.Token, map[string]string{"body": "too late"}, nil); code != http.StatusNotFound {
+		t.Errorf("editing a published chirp: status %d", code)
+	}
+}
+
+func TestScheduledChirpAnnouncedAfterCrash(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	inAnHour := time.Now().Add(time.Hour).UTC()
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]any{"body": "hi @bob", "publish_at": inAnHour}, nil)
+
+	// An instance publishes the chirp and dies before announcing it.
+	at := inAnHour.Add(time.Minute)
+	lease := sql.NullTime{Time: at.Add(announceLease), Valid: true}
+	if due, err := cfg.db.PublishDueChirps(t.Context(), database.PublishDueChirpsParams{Now: at, AnnounceAt: lease, Limit: 10}); err != nil || len(due) != 1 {
+		t.Fatalf("PublishDueChirps = %+v, %v", due, err)
+	}
+
+	cfg.publishDueChirps(t.Context(), at.Add(time.Second))
+	if n := countNotifications(t, srv, bob.Token); n != 0 {
+		t.Errorf("bob was notified %d times while the lease held", n)
+	}
+	cfg.publishDueChirps(t.Context(), lease.Time)
+	if n := countNotifications(t, srv, bob.Token); n != 1 {
+		t.Errorf("bob was notified %d times once the lease ran out, want 1", n)
+	}
+	cfg.publishDueChirps(t.Context(), lease.Time.Add(time.Hour))
+	if n := countNotifications(t, srv, bob.Token); n != 1 {
+		t.Errorf("bob was notified %d times after another run, want 1", n)
+	}
+}
+
+func TestScheduledChirpModeration(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	addRule(t, srv, "hold", "giveaway")
+	addRule(t, srv, "reject", "scam")
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	inAnHour := time.Now().Add(time.Hour).UTC()
+
+	var held Chirp
+	code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]any{"body": "giveaway tomorrow", "publish_at": inAnHour}, &held)
+	if code != http.StatusAccepted || held.Status != chirpHeld {
+		t.Fatalf("scheduling a held chirp: status %d, %+v", code, held)
+	}
+	var approved Chirp
+	doAdmin(t, srv, "POST", "/admin/api/moderation/held/"+held.ID.String()+"/approve", nil, &approved)
+	if approved.Status != chirpScheduled {
+		t.Errorf("approved chirp: %+v, want it scheduled", approved)
+	}
+
+	path := "/api/users/me/scheduled/" + held.ID.String()
+	if code := doJSON(t, srv, "PUT", path, alice.Token, map[string]string{"body": "scam tomorrow"}, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("editing in a rejected word: status %d", code)
+	}
+	var edited Chirp
+	if code := doJSON(t, srv, "PUT", path, alice.Token, map[string]string{"body": "another giveaway"}, &edited); code != http.StatusAccepted || edited.Status != chirpHeld {
+		t.Errorf("editing in a held word: status %d, %+v", code, edited)
+	}
+}
+
+func TestApproveOverdueHeldChirp(t *testing.T) {
+	cfg, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	// Held past the time it was scheduled for.
+	held, err := cfg.db.CreateChirp(t.Context(), database.CreateChirpParams{
+		Body:      "hi @bob",
+		UserID:    alice.ID,
+		Status:    chirpHeld,
+		PublishAt: sql.NullTime{Time: time.Now().Add(-time.Hour).UTC(), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meanwhile Chirp
+	doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "meanwhile"}, &meanwhile)
+
+	before := time.Now().UTC()
+	path := "/admin/api/moderation/held/" + held.ID.String()
+	var approved Chirp
+	if code := doAdmin(t, srv, "POST", path+"/approve", nil, &approved); code != http.StatusOK {
+		t.Fatalf("approve: status %d", code)
+	}
+	if approved.Status != chirpPublished || approved.PublishAt == nil || approved.PublishAt.Before(before) {
+		t.Errorf("approved chirp: %+v, want it published as of its approval", approved)
+	}
+	var chirps []Chirp
+	doJSON(t, srv, "GET", "/api/chirps", "", nil, &chirps)
+	if len(chirps) != 2 || chirps[0].ID != meanwhile.ID || chirps[1].ID != held.ID {
+		t.Errorf("timeline: %+v, want the approved chirp last", chirps)
+	}
+	if n := countNotifications(t, srv, bob.Token); n != 1 {
+		t.Errorf("bob was notified %d times, want 1", n)
+	}
+	if got, _ := cfg.db.GetSingleChirp(t.Context(), held.ID); got.AnnounceAt.Valid {
+		t.Errorf("announcement still owed after approving: %+v", got)
+	}
+
+	if code := doAdmin(t, srv, "POST", path+"/approve", nil, nil); code != http.StatusNotFound {
+		t.Errorf("approving twice: status %d", code)
+	}
+	if code := doAdmin(t, srv, "POST", path+"/reject", nil, nil); code != http.StatusNotFound {
+		t.Errorf("rejecting an approved chirp: status %d", code)
+	}
+}
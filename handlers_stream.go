@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+const (
+	streamHeartbeat   = 15 * time.Second
+	streamReplayLimit = 500
+)
+
+// errBadAuthorID is the one streamFilter error that is the client's fault.
+var errBadAuthorID = errors.New("Author ID is not a valid uuid")
+
+type deletedChirp struct {
+	ID     uuid.UUID `json:"id"`
+	UserId uuid.UUID `json:"user_id"`
+}
+
+func eventFromDB(e database.ChirpEvent) stream.Event {
+	return stream.Event{
+		ID:        e.ID,
+		Type:      e.Kind,
+		ChirpID:   e.ChirpID,
+		UserID:    e.UserID,
+		Body:      e.Body,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// publishChirpEvent records the event so other instances and reconnecting
+// clients can see it, then hands it to the local hub straight away.
+func (cfg *apiConfig) publishChirpEvent(ctx context.Context, kind string, chirp database.Chirp) {
+	body := chirp.Body
+	if kind == stream.EventChirpDeleted {
+		body = ""
+	}
+
+	dbEvent, err := cfg.db.CreateChirpEvent(ctx, database.CreateChirpEventParams{
+		Kind:    kind,
+		ChirpID: chirp.ID,
+		UserID:  chirp.UserID,
+		Body:    body,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not record chirp event", "kind", kind, "chirp_id", chirp.ID, logging.Err(err))
+		return
+	}
+
+	cfg.hub.Publish(eventFromDB(dbEvent))
+}
+
+func (cfg *apiConfig) fetchChirpEvent(ctx context.Context, id int64) (stream.Event, error) {
+	dbEvent, err := cfg.db.GetChirpEvent(ctx, id)
+	if err != nil {
+		return stream.Event{}, err
+	}
+	return eventFromDB(dbEvent), nil
+}
+
+func (cfg *apiConfig) handlerStream(w http.ResponseWriter, r *http.Request) {
+	followerID := uuid.Nil
+	if r.URL.Query().Get("following") == "true" {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Could not find JWT", err)
+			return
+		}
+		followerID, err = cfg.validateToken(r.Context(), token)
+		if err != nil {
+			respondWithTokenError(w, err)
+			return
+		}
+		setRequestUser(w, followerID)
+	}
+
+	viewer := followerID
+	if viewer == uuid.Nil {
+		viewer = cfg.optionalUser(w, r)
+	}
+	filter, err := cfg.streamFilter(r, followerID, viewer)
+	if errors.Is(err, errBadAuthorID) {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not set up the stream", err)
+		return
+	}
+
+	var lastID int64
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	if lastEventID != "" {
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Last-Event-ID is not a valid event id", err)
+			return
+		}
+	}
+
+	// Subscribe before replaying so nothing published in between is lost;
+	// duplicates are skipped by remembering the IDs already sent. Comparing
+	// against the last one would drop an event whose transaction committed
+	// after one with a higher ID.
+	sub := cfg.hub.Subscribe(filter)
+	sent := stream.NewSeenIDs(stream.SeenWindow)
+	defer cfg.hub.Unsubscribe(sub)
+
+	// The stream outlives the server's read and write timeouts.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if lastEventID != "" {
+		if err := cfg.replayChirpEvents(r.Context(), w, lastID, filter, sent); err != nil {
+			return
+		}
+	} else {
+		fmt.Fprint(w, "retry: 3000\n\n")
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	stopping := cfg.streamsStopping()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stopping:
+			// The client reconnects to another instance, or to this one
+			// once it is back, with its Last-Event-ID.
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped by the hub for falling behind, the client will
+				// reconnect with its Last-Event-ID.
+				return
+			}
+			if !sent.Add(event.ID) {
+				continue
+			}
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// replayChirpEvents writes the events after lastID that the client missed,
+// a page at a time. It only returns an error when the client has gone.
+func (cfg *apiConfig) replayChirpEvents(ctx context.Context, w http.ResponseWriter, lastID int64, filter stream.Filter, sent *stream.SeenIDs) error {
+	for {
+		missed, err := cfg.db.GetChirpEventsAfter(ctx, database.GetChirpEventsAfterParams{
+			ID:    lastID,
+			Limit: streamReplayLimit,
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Could not replay chirp events", "after", lastID, logging.Err(err))
+			return nil
+		}
+		for _, dbEvent := range missed {
+			event := eventFromDB(dbEvent)
+			lastID = event.ID
+			if !sent.Add(event.ID) || (filter != nil && !filter(event)) {
+				continue
+			}
+			if err := writeStreamEvent(w, event); err != nil {
+				return err
+			}
+		}
+		if len(missed) < streamReplayLimit {
+			return nil
+		}
+	}
+}
+
+// streamFilter limits the stream to chirps by author_id and, when
+// followerID is set, to the users they follow plus their own chirps. It
+// leaves out users on either side of a block with viewer and, except on
+// an author's own stream, the ones viewer mutes.
+func (cfg *apiConfig) streamFilter(r *http.Request, followerID, viewer uuid.UUID) (stream.Filter, error) {
+	authors := map[uuid.UUID]struct{}{}
+	if authorID := r.URL.Query().Get("author_id"); authorID != "" {
+		authorUUID, err := uuid.Parse(authorID)
+		if err != nil {
+			return nil, errBadAuthorID
+		}
+		authors[authorUUID] = struct{}{}
+	}
+
+	hidden, err := cfg.hiddenAuthors(r.Context(), viewer, r.URL.Query().Get("author_id") == "")
+	if err != nil {
+		return nil, fmt.Errorf("getting blocked users: %w", err)
+	}
+
+	if followerID != uuid.Nil {
+		followees, err := cfg.db.GetFolloweeIDs(r.Context(), followerID)
+		if err != nil {
+			return nil, fmt.Errorf("getting followed users: %w", err)
+		}
+		for _, id := range followees {
+			authors[id] = struct{}{}
+		}
+		authors[followerID] = struct{}{}
+	}
+
+	if len(authors) == 0 && len(hidden) == 0 {
+		return nil, nil
+	}
+	return func(e stream.Event) bool {
+		if hidden[e.UserID] {
+			return false
+		}
+		_, ok := authors[e.UserID]
+		return ok || len(authors) == 0
+	}, nil
+}
+
+// streamPayload renders an event the same way the REST API renders chirps.
+func streamPayload(event stream.Event) any {
+	if event.Type == stream.EventChirpDeleted {
+		return deletedChirp{
+			ID:     event.ChirpID,
+			UserId: event.UserID,
+		}
+	}
+	return Chirp{
+		ID:        event.ChirpID,
+		CreatedAt: event.CreatedAt,
+		UpdatedAt: event.CreatedAt,
+		Body:      event.Body,
+		UserId:    event.UserID,
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event stream.Event) error {
+	data, err := json.Marshal(streamPayload(event))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
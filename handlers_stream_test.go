@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/enderbd/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+func TestStreamReplaysEveryPage(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	want := streamReplayLimit + 10
+	for range want {
+		_, err := cfg.db.CreateChirpEvent(t.Context(), database.CreateChirpEventParams{
+			Kind: stream.EventChirpCreated, ChirpID: uuid.New(), UserID: alice.ID, Body: "hi",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/stream", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	got := 0
+	lines := bufio.NewScanner(resp.Body)
+	for got < want && lines.Scan() {
+		if strings.HasPrefix(lines.Text(), "id: ") {
+			got++
+		}
+	}
+	if got != want {
+		t.Errorf("replayed %d events, want %d", got, want)
+	}
+}
+
+// nextStreamEvent reads the stream up to the end of the next event,
+// returning its type and data.
+func nextStreamEvent(t *testing.T, lines *bufio.Scanner) (string, string) {
+	t.Helper()
+	var kind, data string
+	for lines.Scan() {
+		line := lines.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			kind = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && kind != "":
+			return kind, data
+		}
+	}
+	t.Fatalf("stream ended: %v", lines.Err())
+	return "", ""
+}
+
+func TestStreamAccountDeletion(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	cfg.deletionGrace = time.Hour
+	cfg.baseURL = "https://chirpy.test"
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	var chirp Chirp
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "goodbye"}, &chirp)
+	err := cfg.db.AddRemoteFollower(t.Context(), database.AddRemoteFollowerParams{
+		UserID: alice.ID, ActorID: "https://remote.test/users/bob", Inbox: "https://remote.test/inbox",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+
+	// queued returns the types of the activities queued for remote
+	// followers since the last call.
+	queued := func() []string {
+		t.Helper()
+		deliveries, err := cfg.db.ClaimDeliveries(t.Context(), database.ClaimDeliveriesParams{Attempts: deliveryMaxAttempts, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var types []string
+		for _, d := range deliveries {
+			var activity struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(d.Payload), &activity)
+			types = append(types, activity.Type)
+			cfg.db.DeleteDelivery(t.Context(), d.ID)
+		}
+		return types
+	}
+	queued()
+
+	if code := doJSON(t, srv, "DELETE", "/api/users/me", alice.Token, map[string]string{"password": "hunter2"}, nil); code != http.StatusAccepted {
+		t.Fatalf("delete: status %d", code)
+	}
+	kind, data := nextStreamEvent(t, lines)
+	var deleted deletedChirp
+	json.Unmarshal([]byte(data), &deleted)
+	if kind != stream.EventChirpDeleted || deleted.ID != chirp.ID {
+		t.Errorf("after deleting the account, the stream got %s %s", kind, data)
+	}
+	if got := queued(); !slices.Equal(got, []string{"Delete"}) {
+		t.Errorf("after deleting the account, remote followers were sent %v", got)
+	}
+
+	// Restoring the account brings the chirps back.
+	nextSecond()
+	login := map[string]string{"email": "alice@example.com", "password": "hunter2"}
+	if code := doJSON(t, srv, "POST", "/api/login", "", login, nil); code != http.StatusOK {
+		t.Fatalf("login to restore: status %d", code)
+	}
+	kind, data = nextStreamEvent(t, lines)
+	var restored Chirp
+	json.Unmarshal([]byte(data), &restored)
+	if kind != stream.EventChirpCreated || restored.ID != chirp.ID {
+		t.Errorf("after restoring the account, the stream got %s %s", kind, data)
+	}
+	if got := queued(); !slices.Equal(got, []string{"Create"}) {
+		t.Errorf("after restoring the account, remote followers were sent %v", got)
+	}
+}
+
+// followeesDown fails to load followed users, as if the database were down.
+type followeesDown struct {
+	store.Store
+}
+
+func (followeesDown) GetFolloweeIDs(context.Context, uuid.UUID) ([]uuid.UUID, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestStreamFilterErrors(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	if code := doJSON(t, srv, "GET", "/api/stream?author_id=nobody", "", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("bad author_id: status %d, want 400", code)
+	}
+	cfg.db = followeesDown{cfg.db}
+	if code := doJSON(t, srv, "GET", "/api/stream?following=true", alice.Token, nil, nil); code != http.StatusInternalServerError {
+		t.Errorf("store failure: status %d, want 500", code)
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/chirptext"
 	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/metrics"
 	"github.com/google/uuid"
 )
 
@@ -18,6 +23,11 @@ type User struct {
 	Token string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
 	IsChirpyRed bool `json:"is_chirpy_red"`
+	Username string `json:"username,omitempty"`
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
 
 
@@ -25,6 +35,7 @@ func (cfg* apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 	type parameters struct {
 		Email string `json:"email"`
 		Password string `json:"password"`
+		Username string `json:"username"`
 	}
 
 	if r.Method != http.MethodPost {
@@ -39,7 +50,17 @@ func (cfg* apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	hashedPasswd, err := auth.HashPassword(params.Password)
+	username := ""
+	if params.Username != "" {
+		var ok bool
+		username, ok = chirptext.NormalizeUsername(params.Username)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Username may only contain up to 30 letters, digits and underscores", nil)
+			return
+		}
+	}
+
+	hashedPasswd, err := auth.HashPassword(r.Context(), params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not hash the password", err)
 		return
@@ -47,6 +68,7 @@ func (cfg* apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 	dbUser, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
 		Email: params.Email,
 		HashedPassword: hashedPasswd,
+		Username: nullString(username),
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not create dbUser", err)
@@ -58,6 +80,7 @@ func (cfg* apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		CreatedAt: dbUser.CreatedAt,
 		UpdatedAt: dbUser.UpdatedAt,
 		Email: dbUser.Email,
+		Username: dbUser.Username.String,
 	}
 
 	respondWithJson(w, http.StatusCreated, outUser)
@@ -80,24 +103,49 @@ func (cfg* apiConfig) handlerLoginUser(w http.ResponseWriter, r *http.Request) {
 
 	dbUser, err := cfg.db.GetUserByEmail(r.Context(), userReq.Email)
 	if err != nil {
+		cfg.metrics.Login(metrics.LoginFailure)
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
 
-	match, err := auth.CheckPasswordHash(userReq.Password, dbUser.HashedPassword)
+	match, err := auth.CheckPasswordHash(r.Context(), userReq.Password, dbUser.HashedPassword)
 	if err != nil {
+		cfg.metrics.Login(metrics.LoginFailure)
 		respondWithError(w, http.StatusUnauthorized, "Could not match the password with the hash", err)
 		return
 	}
 
 	if !match {
+		cfg.metrics.Login(metrics.LoginFailure)
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
+	// Past its grace period a deleted account is as good as gone.
+	if dbUser.DeletedAt.Valid && !cfg.restorable(dbUser) {
+		cfg.metrics.Login(metrics.LoginFailure)
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", errAccountDeleted)
+		return
+	}
+	if suspended(dbUser) {
+		cfg.metrics.Login(metrics.LoginFailure)
+		err := suspensionError{dbUser}
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
+	if dbUser.DeletedAt.Valid {
+		err := cfg.restoreAccount(r.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not restore the account", err)
+			return
+		}
+		logging.FromContext(r.Context()).Info("Account restored", "user_id", dbUser.ID.String())
+	}
+	cfg.metrics.Login(metrics.LoginSuccess)
+	setRequestUser(w, dbUser.ID)
 
 	expirationTime := time.Hour
 
-	token, err := auth.MakeJWT(dbUser.ID, cfg.secret, expirationTime)
+	token, err := auth.MakeJWT(r.Context(), dbUser.ID, cfg.secret, expirationTime)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not create JWT token", err)
 		return
@@ -128,6 +176,7 @@ func (cfg* apiConfig) handlerLoginUser(w http.ResponseWriter, r *http.Request) {
 		Token: token,
 		RefreshToken: refreshToken,
 		IsChirpyRed: dbUser.IsChirpyRed,
+		Username: dbUser.Username.String,
 	}
 
 	respondWithJson(w, http.StatusOK, outUser)
@@ -153,7 +202,19 @@ func (cfg* apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't get user for refresh token", err)
 		return
 	}
+	// Deleting an account revokes its refresh tokens, but one issued by a
+	// login racing the deletion would otherwise outlive it.
+	if dbUser.DeletedAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get user for refresh token", errAccountDeleted)
+		return
+	}
+	if suspended(dbUser) {
+		err := suspensionError{dbUser}
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
 	accessToken, err := auth.MakeJWT(
+		r.Context(),
 		dbUser.ID,
 		cfg.secret,
 		time.Hour,
@@ -191,6 +252,7 @@ func (cfg* apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 	type parameters struct {
 		Email string `json:"email"`
 		Password string `json:"password"`
+		Username string `json:"username"`
 	}
 	var params parameters
 	err := json.NewDecoder(r.Body).Decode(&params)
@@ -205,13 +267,36 @@ func (cfg* apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, cfg.secret)
+	userId, err := cfg.validateToken(r.Context(), token)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Could not validate JWT", err)
+		respondWithTokenError(w, err)
 		return
 	}
+	setRequestUser(w, userId)
+
+	// The username is checked before anything is written, and written in
+	// the same statement as the email and password, so a taken name can't
+	// leave the other two changed behind a failed request.
+	username := ""
+	if params.Username != "" {
+		var ok bool
+		username, ok = chirptext.NormalizeUsername(params.Username)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Username may only contain up to 30 letters, digits and underscores", nil)
+			return
+		}
+		owner, err := cfg.db.GetUserByUsername(r.Context(), nullString(username))
+		if err == nil && owner.ID != userId {
+			respondWithError(w, http.StatusConflict, "Username is already taken", nil)
+			return
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Could not check the username", err)
+			return
+		}
+	}
  
-	hashedPasswd, err := auth.HashPassword(params.Password)
+	hashedPasswd, err := auth.HashPassword(r.Context(), params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not hash the password", err)
 		return
@@ -221,10 +306,11 @@ func (cfg* apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		ID: userId,
 		Email: params.Email,
 		HashedPassword: hashedPasswd,
+		Username: nullString(username),
 	})
 
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not update the user email and password!", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not update the user", err)
 		return
 	}
 
@@ -234,6 +320,7 @@ func (cfg* apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		UpdatedAt: dbUser.UpdatedAt,
 		Email: dbUser.Email,
 		IsChirpyRed: dbUser.IsChirpyRed,
+		Username: dbUser.Username.String,
 	}
 
 	respondWithJson(w, http.StatusOK, response)
@@ -241,8 +328,12 @@ func (cfg* apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 }
 
 func (cfg* apiConfig) handlerUpgradeRed(w http.ResponseWriter, r *http.Request) {
+	outcome := metrics.WebhookInvalid
+	defer func() { cfg.metrics.Webhook(outcome) }()
+
 	polkaKey, err := auth.GetAPIKey(r.Header)
 	if polkaKey != cfg.polkaKey {
+		outcome = metrics.WebhookUnauthorized
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized webhook request!", err)
 		return
 	}
@@ -263,6 +354,7 @@ func (cfg* apiConfig) handlerUpgradeRed(w http.ResponseWriter, r *http.Request)
 	}
 
 	if params.Event != "user.upgraded" {
+		outcome = metrics.WebhookIgnored
 		respondWithError(w, http.StatusNoContent, "Not upgrading the user", err)
 		return
 	}
@@ -276,10 +368,12 @@ func (cfg* apiConfig) handlerUpgradeRed(w http.ResponseWriter, r *http.Request)
 
 	err = cfg.db.UpgradeRed(r.Context(), userId)
 	if err != nil {
+		outcome = metrics.WebhookNotFound
 		respondWithError(w, http.StatusNotFound, "User to upgrade not found in the database!", err)
 		return
 	}
 	  
+	outcome = metrics.WebhookUpgraded
 	w.WriteHeader(http.StatusNoContent)
 
 }
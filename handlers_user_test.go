@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+)
+
+func TestCreateAndLoginUser(t *testing.T) {
+	_, srv := newTestServer(t)
+
+	var created User
+	code := doJSON(t, srv, "POST", "/api/users", "", map[string]string{
+		"email":    "alice@example.com",
+		"password": "hunter2",
+		"username": "Alice",
+	}, &created)
+	if code != http.StatusCreated {
+		t.Fatalf("create: status %d", code)
+	}
+	if created.Username != "alice" || created.Token != "" {
+		t.Errorf("create: got %+v", created)
+	}
+
+	var user User
+	code = doJSON(t, srv, "POST", "/api/login", "", map[string]string{
+		"email":    "alice@example.com",
+		"password": "hunter2",
+	}, &user)
+	if code != http.StatusOK {
+		t.Fatalf("login: status %d", code)
+	}
+	if user.ID != created.ID || user.Token == "" || user.RefreshToken == "" {
+		t.Errorf("login: got %+v", user)
+	}
+
+	code = doJSON(t, srv, "POST", "/api/login", "", map[string]string{
+		"email":    "alice@example.com",
+		"password": "wrong",
+	}, nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("login with wrong password: status %d, want 401", code)
+	}
+}
+
+func TestCreateUserRejectsBadUsername(t *testing.T) {
+	_, srv := newTestServer(t)
+
+	code := doJSON(t, srv, "POST", "/api/users", "", map[string]string{
+		"email":    "bob@example.com",
+		"password": "hunter2",
+		"username": "not a handle!",
+	}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", code)
+	}
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	_, srv := newTestServer(t)
+	user := createTestUser(t, srv, "alice@example.com", "")
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	if code := doJSON(t, srv, "POST", "/api/refresh", user.RefreshToken, nil, &refreshed); code != http.StatusOK {
+		t.Fatalf("refresh: status %d", code)
+	}
+	if refreshed.Token == "" {
+		t.Error("refresh: empty access token")
+	}
+
+	if code := doJSON(t, srv, "POST", "/api/revoke", user.RefreshToken, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("revoke: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/refresh", user.RefreshToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("refresh after revoke: status %d, want 401", code)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+
+	var updated User
+	code := doJSON(t, srv, "PUT", "/api/users", alice.Token, map[string]string{
+		"email":    "alice@new.example.com",
+		"password": "correct horse",
+	}, &updated)
+	if code != http.StatusOK {
+		t.Fatalf("update: status %d", code)
+	}
+	if updated.Email != "alice@new.example.com" || updated.Username != "alice" {
+		t.Errorf("update: got %+v", updated)
+	}
+
+	code = doJSON(t, srv, "POST", "/api/login", "", map[string]string{
+		"email":    "alice@new.example.com",
+		"password": "correct horse",
+	}, nil)
+	if code != http.StatusOK {
+		t.Errorf("login with new credentials: status %d", code)
+	}
+
+	code = doJSON(t, srv, "PUT", "/api/users", bob.Token, map[string]string{
+		"email":    "bob@elsewhere.example.com",
+		"password": "swordfish",
+		"username": "alice",
+	}, nil)
+	if code != http.StatusConflict {
+		t.Errorf("taking a used username: status %d, want 409", code)
+	}
+	code = doJSON(t, srv, "PUT", "/api/users", bob.Token, map[string]string{
+		"email":    "bob@elsewhere.example.com",
+		"password": "swordfish",
+		"username": "not a handle!",
+	}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("invalid username: status %d, want 400", code)
+	}
+	// Neither failed update changed bob's credentials.
+	code = doJSON(t, srv, "POST", "/api/login", "", map[string]string{
+		"email":    "bob@example.com",
+		"password": "hunter2",
+	}, nil)
+	if code != http.StatusOK {
+		t.Errorf("login with the old credentials after failed updates: status %d", code)
+	}
+
+	code = doJSON(t, srv, "PUT", "/api/users", "", map[string]string{"email": "x@example.com"}, nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("update without token: status %d, want 401", code)
+	}
+}
+
+func TestUpgradeRed(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	user := createTestUser(t, srv, "alice@example.com", "")
+
+	body := map[string]any{
+		"event": "user.upgraded",
+		"data":  map[string]string{"user_id": user.ID.String()},
+	}
+	if code := postWebhook(t, srv, "wrong", body); code != http.StatusUnauthorized {
+		t.Errorf("wrong api key: status %d, want 401", code)
+	}
+	if code := postWebhook(t, srv, cfg.polkaKey, body); code != http.StatusNoContent {
+		t.Fatalf("upgrade: status %d", code)
+	}
+
+	var again User
+	doJSON(t, srv, "POST", "/api/login", "", map[string]string{
+		"email":    "alice@example.com",
+		"password": "hunter2",
+	}, &again)
+	if !again.IsChirpyRed {
+		t.Error("user was not upgraded to Chirpy Red")
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	cfg.deletionGrace = time.Hour
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "goodbye @bob"}, nil)
+
+	if code := doJSON(t, srv, "DELETE", "/api/users/me", alice.Token, map[string]string{"password": "wrong"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d", code)
+	}
+	var deleted struct {
+		DeletedAt time.Time `json:"deleted_at"`
+		PurgeAt   time.Time `json:"purge_at"`
+	}
+	password := map[string]string{"password": "hunter2"}
+	if code := doJSON(t, srv, "DELETE", "/api/users/me", alice.Token, password, &deleted); code != http.StatusAccepted {
+		t.Fatalf("delete: status %d", code)
+	}
+	if got := deleted.PurgeAt.Sub(deleted.DeletedAt); got != time.Hour {
+		t.Errorf("purge_at is %s after deleted_at", got)
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "still here?"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("access token after deleting: status %d", code)
+	}
+	if code := doJSON(t, srv, "POST", "/api/refresh", alice.RefreshToken, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("refresh token after deleting: status %d", code)
+	}
+	// As if a login had raced the deletion.
+	_, err := cfg.db.CreateRefreshToken(t.Context(), database.CreateRefreshTokenParams{Token: "racing", UserID: alice.ID, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := doJSON(t, srv, "POST", "/api/refresh", "racing", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("refresh token issued while deleting: status %d", code)
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps", bob.Token)[alice.ID]; n != 0 {
+		t.Errorf("bob sees %d chirps of a deleted account", n)
+	}
+
+	// Logging in within the grace period restores the account.
+	nextSecond()
+	login := map[string]string{"email": "alice@example.com", "password": "hunter2"}
+	var restored User
+	if code := doJSON(t, srv, "POST", "/api/login", "", login, &restored); code != http.StatusOK {
+		t.Fatalf("login to restore: status %d", code)
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps", bob.Token)[alice.ID]; n != 1 {
+		t.Errorf("after restoring, bob sees %d chirps", n)
+	}
+	if err := cfg.purgeDeletedAccounts(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if code := doJSON(t, srv, "POST", "/api/chirps", restored.Token, map[string]string{"body": "back"}, nil); code != http.StatusCreated {
+		t.Errorf("chirping after restoring: status %d", code)
+	}
+
+	// Once the grace period is over the account is purged.
+	if code := doJSON(t, srv, "DELETE", "/api/users/me", restored.Token, password, nil); code != http.StatusAccepted {
+		t.Fatalf("delete again: status %d", code)
+	}
+	cfg.deletionGrace = 0
+	if code := doJSON(t, srv, "POST", "/api/login", "", login, nil); code != http.StatusUnauthorized {
+		t.Errorf("login after the grace period: status %d", code)
+	}
+	if err := cfg.purgeDeletedAccounts(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.db.GetUserByID(t.Context(), alice.ID); err == nil {
+		t.Error("the account wasn't purged")
+	}
+	if n := chirpAuthors(t, srv, "/api/chirps", "")[alice.ID]; n != 0 {
+		t.Errorf("%d chirps outlived their author", n)
+	}
+	if n := countNotifications(t, srv, bob.Token); n != 0 {
+		t.Errorf("bob still has %d notifications from the purged account", n)
+	}
+	createTestUser(t, srv, "alice@example.com", "alice")
+}
+
+func postWebhook(t *testing.T, srv *httptest.Server, apiKey string, body any) int {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("POST", srv.URL+"/api/polka/webhooks", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "ApiKey "+apiKey)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/stream"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = (wsPongWait * 9) / 10
+	wsMaxMessageSize = 4096
+	// wsSendBuffer bounds how many outgoing messages may queue up for one
+	// connection. A client that can't keep up is disconnected.
+	wsSendBuffer = 256
+	// wsAccessCheckInterval is how often an open connection checks that
+	// its user hasn't been suspended, deleted or signed out since.
+	wsAccessCheckInterval = time.Minute
+)
+
+const (
+	wsChannelTimeline      = "timeline"
+	wsChannelFollowing     = "following"
+	wsChannelUser          = "user:"
+	wsChannelNotifications = "notifications"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// wsRequest is a message sent by the client.
+type wsRequest struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Body    string `json:"body,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+// wsMessage is a message sent to the client.
+type wsMessage struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type wsClient struct {
+	cfg    *apiConfig
+	ctx    context.Context
+	conn   *websocket.Conn
+	userID uuid.UUID
+
+	send chan wsMessage
+	done chan struct{}
+
+	mu            sync.Mutex
+	subs          map[string]*stream.Subscription
+	notifications chan Notification
+	claims        auth.AccessToken
+	expiry        *time.Timer
+	closeOnce sync.Once
+	closeMsg  []byte
+}
+
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		// Browsers can't set headers on a WebSocket handshake.
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		respondWithError(w, http.StatusUnauthorized, "Could not find JWT", err)
+		return
+	}
+
+	user, claims, err := cfg.authenticate(r.Context(), token)
+	if err != nil {
+		respondWithTokenError(w, err)
+		return
+	}
+	userId, expiresAt := user.ID, claims.ExpiresAt
+	setRequestUser(w, userId)
+
+	cfg.websockets.Add(1)
+	defer cfg.websockets.Done()
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response.
+		logging.FromContext(r.Context()).Info("Could not upgrade to a websocket", logging.Err(err))
+		return
+	}
+
+	client := &wsClient{
+		cfg:    cfg,
+		ctx:    r.Context(),
+		conn:   conn,
+		userID: userId,
+		send:   make(chan wsMessage, wsSendBuffer),
+		done:   make(chan struct{}),
+		subs:   make(map[string]*stream.Subscription),
+		claims: claims,
+	}
+	// Arm the timer only once it is stored: a token about to expire would
+	// otherwise fire tokenExpired before client.expiry is set.
+	client.expiry = time.AfterFunc(time.Duration(math.MaxInt64), client.tokenExpired)
+	client.expiry.Reset(time.Until(expiresAt))
+
+	go client.writePump()
+	go client.watchAccess()
+	go func() {
+		select {
+		case <-cfg.streamsStopping():
+			client.close(websocket.CloseGoingAway, "server shutting down")
+		case <-client.done:
+		}
+	}()
+	client.readPump()
+}
+
+func (c *wsClient) readPump() {
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var req wsRequest
+		err := c.conn.ReadJSON(&req)
+		if err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.enqueue(wsMessage{Type: "error", Error: "Could not decode message"})
+				continue
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logging.FromContext(c.ctx).Warn("Websocket read error", logging.Err(err))
+			}
+			return
+		}
+		c.handle(req)
+	}
+}
+
+func (c *wsClient) writePump() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ping.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			c.flush()
+			c.conn.WriteControl(websocket.CloseMessage, c.closeMsg, time.Now().Add(wsWriteWait))
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping.C:
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			if err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+// flush writes whatever is still queued, such as the token_expired notice,
+// before the connection is closed.
+func (c *wsClient) flush() {
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (c *wsClient) handle(req wsRequest) {
+	switch req.Type {
+	case "subscribe", "chirp":
+		if !c.checkAccess() {
+			return
+		}
+	}
+
+	switch req.Type {
+	case "subscribe":
+		if err := c.subscribe(req.Channel); err != nil {
+			c.enqueue(wsMessage{Type: "error", ID: req.ID, Channel: req.Channel, Error: err.Error()})
+			return
+		}
+		c.enqueue(wsMessage{Type: "subscribed", ID: req.ID, Channel: req.Channel})
+	case "unsubscribe":
+		c.unsubscribe(req.Channel)
+		c.enqueue(wsMessage{Type: "unsubscribed", ID: req.ID, Channel: req.Channel})
+	case "chirp":
+		chirp, _, err := c.cfg.createChirp(c.ctx, c.userID, req.Body, uuid.NullUUID{}, sql.NullTime{})
+		if errors.Is(err, errChirpRejected) {
+			c.enqueue(wsMessage{Type: "error", ID: req.ID, Error: "Chirp was rejected by moderation"})
+			return
+		}
+		if errors.Is(err, errUserSuspended) {
+			c.enqueue(wsMessage{Type: "error", ID: req.ID, Error: err.Error()})
+			return
+		}
+		if errors.Is(err, errChirpTooLong) {
+			c.enqueue(wsMessage{Type: "error", ID: req.ID, Error: "Chirp it too long"})
+			return
+		}
+		if err != nil {
+			logging.FromContext(c.ctx).Error("Could not add a chirp over websocket", logging.Err(err))
+			c.enqueue(wsMessage{Type: "error", ID: req.ID, Error: "Could not add the Chirp"})
+			return
+		}
+		c.enqueue(wsMessage{Type: "ack", ID: req.ID, Data: chirpFromDB(chirp)})
+	case "auth":
+		c.reauthenticate(req)
+	default:
+		c.enqueue(wsMessage{Type: "error", ID: req.ID, Error: "Unknown message type"})
+	}
+}
+
+// reauthenticate lets a client hand over a fresh access token before the
+// current one expires instead of reconnecting.
+func (c *wsClient) reauthenticate(req wsRequest) {
+	user, claims, err := c.cfg.authenticate(c.ctx, req.Token)
+	if err != nil || user.ID != c.userID {
+		c.enqueue(wsMessage{Type: "error", ID: req.ID, Error: "Could not validate JWT"})
+		return
+	}
+	c.mu.Lock()
+	c.claims = claims
+	c.mu.Unlock()
+	c.expiry.Reset(time.Until(claims.ExpiresAt))
+	c.enqueue(wsMessage{Type: "ack", ID: req.ID})
+}
+
+func (c *wsClient) tokenExpired() {
+	c.enqueue(wsMessage{Type: "token_expired"})
+	c.close(websocket.ClosePolicyViolation, "token expired")
+}
+
+// watchAccess checks the user's access every wsAccessCheckInterval, so a
+// connection that only listens is closed too.
+func (c *wsClient) watchAccess() {
+	ticker := time.NewTicker(wsAccessCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.checkAccess()
+		}
+	}
+}
+
+// checkAccess closes the connection, telling the client why, once its user
+// has been suspended, deleted or had their tokens revoked. It reports
+// whether the connection is still open. A failed lookup leaves it open.
+func (c *wsClient) checkAccess() bool {
+	c.mu.Lock()
+	claims := c.claims
+	c.mu.Unlock()
+
+	user, err := c.cfg.db.GetUserByID(c.ctx, c.userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = errAccountDeleted
+	case err != nil:
+		logging.FromContext(c.ctx).Warn("Could not check websocket user", logging.Err(err))
+		return true
+	default:
+		err = tokenRefused(user, claims)
+	}
+	if err == nil {
+		return true
+	}
+	c.enqueue(wsMessage{Type: "access_revoked", Error: err.Error()})
+	c.close(websocket.ClosePolicyViolation, "access revoked")
+	return false
+}
+
+func (c *wsClient) subscribe(channel string) error {
+	if channel == wsChannelNotifications {
+		c.subscribeNotifications()
+		return nil
+	}
+
+	c.mu.Lock()
+	_, exists := c.subs[channel]
+	c.mu.Unlock()
+	if exists {
+		return nil
+	}
+
+	var filter stream.Filter
+	switch {
+	case channel == wsChannelTimeline:
+	case channel == wsChannelFollowing:
+		followees, err := c.cfg.db.GetFolloweeIDs(c.ctx, c.userID)
+		if err != nil {
+			return errors.New("Could not get followed users")
+		}
+		authors := map[uuid.UUID]struct{}{c.userID: {}}
+		for _, id := range followees {
+			authors[id] = struct{}{}
+		}
+		filter = func(e stream.Event) bool {
+			_, ok := authors[e.UserID]
+			return ok
+		}
+	case strings.HasPrefix(channel, wsChannelUser):
+		authorID, err := uuid.Parse(strings.TrimPrefix(channel, wsChannelUser))
+		if err != nil {
+			return errors.New("Author ID is not a valid uuid")
+		}
+		filter = func(e stream.Event) bool {
+			return e.UserID == authorID
+		}
+	default:
+		return errors.New("Unknown channel")
+	}
+
+	// Muted users stay on their own channel.
+	hidden, err := c.cfg.hiddenAuthors(c.ctx, c.userID, !strings.HasPrefix(channel, wsChannelUser))
+	if err != nil {
+		return errors.New("Could not get blocked users")
+	}
+	if len(hidden) > 0 {
+		channelFilter := filter
+		filter = func(e stream.Event) bool {
+			return !hidden[e.UserID] && (channelFilter == nil || channelFilter(e))
+		}
+	}
+
+	sub := c.cfg.hub.Subscribe(filter)
+	c.mu.Lock()
+	c.subs[channel] = sub
+	c.mu.Unlock()
+
+	go c.forward(channel, sub)
+	return nil
+}
+
+// subscribeNotifications sends the user their new in-app notifications as
+// they are created.
+func (c *wsClient) subscribeNotifications() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.notifications != nil {
+		return
+	}
+	c.notifications = c.cfg.notifyHub.subscribe(c.userID)
+	go c.forwardNotifications(c.notifications)
+}
+
+func (c *wsClient) forwardNotifications(notifications chan Notification) {
+	for n := range notifications {
+		c.enqueue(wsMessage{Type: "notification", Channel: wsChannelNotifications, Data: n})
+	}
+
+	c.mu.Lock()
+	dropped := c.notifications == notifications
+	if dropped {
+		c.notifications = nil
+	}
+	c.mu.Unlock()
+	if dropped {
+		c.enqueue(wsMessage{Type: "unsubscribed", Channel: wsChannelNotifications, Error: "Subscription dropped, resubscribe to continue"})
+	}
+}
+
+func (c *wsClient) unsubscribe(channel string) {
+	if channel == wsChannelNotifications {
+		c.mu.Lock()
+		notifications := c.notifications
+		c.notifications = nil
+		c.mu.Unlock()
+		if notifications != nil {
+			c.cfg.notifyHub.unsubscribe(c.userID, notifications)
+		}
+		return
+	}
+
+	c.mu.Lock()
+	sub, ok := c.subs[channel]
+	delete(c.subs, channel)
+	c.mu.Unlock()
+	if ok {
+		c.cfg.hub.Unsubscribe(sub)
+	}
+}
+
+func (c *wsClient) forward(channel string, sub *stream.Subscription) {
+	for event := range sub.C {
+		c.enqueue(wsMessage{Type: event.Type, Channel: channel, Data: streamPayload(event)})
+	}
+
+	// The hub closes the channel when we unsubscribe or when it dropped us
+	// for lagging; only the latter is news to the client.
+	c.mu.Lock()
+	current, ok := c.subs[channel]
+	if ok && current == sub {
+		delete(c.subs, channel)
+	}
+	c.mu.Unlock()
+	if ok && current == sub {
+		c.enqueue(wsMessage{Type: "unsubscribed", Channel: channel, Error: "Subscription dropped, resubscribe to continue"})
+	}
+}
+
+// enqueue queues a message for the writer. If the client is not draining
+// its queue the connection is closed rather than buffering without bound.
+func (c *wsClient) enqueue(msg wsMessage) {
+	select {
+	case <-c.done:
+	case c.send <- msg:
+	default:
+		c.close(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+func (c *wsClient) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeMsg = websocket.FormatCloseMessage(code, reason)
+		c.expiry.Stop()
+
+		c.mu.Lock()
+		subs := c.subs
+		c.subs = map[string]*stream.Subscription{}
+		c.mu.Unlock()
+		for _, sub := range subs {
+			c.cfg.hub.Unsubscribe(sub)
+		}
+		c.unsubscribe(wsChannelNotifications)
+
+		close(c.done)
+	})
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/gorilla/websocket"
+)
+
+// wsEvent is a wsMessage as a client decodes it.
+type wsEvent struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id"`
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+func wsDial(t *testing.T, srv *httptest.Server, token string) *websocket.Conn {
+	t.Helper()
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/ws", header)
+	if err != nil {
+		t.Fatalf("dialing: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func wsSend(t *testing.T, conn *websocket.Conn, req wsRequest) {
+	t.Helper()
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatalf("sending %s: %s", req.Type, err)
+	}
+}
+
+func wsRead(t *testing.T, conn *websocket.Conn) wsEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg wsEvent
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading: %s", err)
+	}
+	return msg
+}
+
+// wsReadUntil reads messages until one of type typ for request id, or any
+// message when id is empty, returning the ones before it.
+func wsReadUntil(t *testing.T, conn *websocket.Conn, typ, id string) (wsEvent, []wsEvent) {
+	t.Helper()
+	var before []wsEvent
+	for {
+		msg := wsRead(t, conn)
+		if msg.Type == typ && (id == "" || msg.ID == id) {
+			return msg, before
+		}
+		before = append(before, msg)
+	}
+}
+
+func TestWebSocketChirps(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	conn := wsDial(t, srv, alice.Token)
+
+	wsSend(t, conn, wsRequest{Type: "subscribe", ID: "1", Channel: wsChannelTimeline})
+	if msg := wsRead(t, conn); msg.Type != "subscribed" || msg.ID != "1" {
+		t.Fatalf("subscribe: %+v", msg)
+	}
+	wsSend(t, conn, wsRequest{Type: "subscribe", ID: "2", Channel: "nowhere"})
+	if msg := wsRead(t, conn); msg.Type != "error" || msg.ID != "2" {
+		t.Errorf("subscribing to an unknown channel: %+v", msg)
+	}
+
+	wsSend(t, conn, wsRequest{Type: "chirp", ID: "3", Body: "hello over the socket"})
+	var ack Chirp
+	msg, _ := wsReadUntil(t, conn, "ack", "3")
+	json.Unmarshal(msg.Data, &ack)
+	if ack.Body != "hello over the socket" {
+		t.Errorf("ack: %+v", msg)
+	}
+	event, _ := wsReadUntil(t, conn, "chirp.created", "")
+	var created Chirp
+	json.Unmarshal(event.Data, &created)
+	if event.Channel != wsChannelTimeline || created.ID != ack.ID {
+		t.Errorf("timeline event: %+v", event)
+	}
+
+	wsSend(t, conn, wsRequest{Type: "unsubscribe", ID: "4", Channel: wsChannelTimeline})
+	wsReadUntil(t, conn, "unsubscribed", "4")
+	wsSend(t, conn, wsRequest{Type: "chirp", ID: "5", Body: "nobody is listening"})
+	wsReadUntil(t, conn, "ack", "5")
+	// An unknown message type gets an error back, after anything the
+	// chirp was going to publish.
+	wsSend(t, conn, wsRequest{Type: "bogus", ID: "6"})
+	if _, before := wsReadUntil(t, conn, "error", "6"); len(before) != 0 {
+		t.Errorf("got %+v after unsubscribing", before)
+	}
+
+	wsSend(t, conn, wsRequest{Type: "chirp", ID: "7", Body: strings.Repeat("x", maxChirpLength+1)})
+	if msg := wsRead(t, conn); msg.Type != "error" || msg.ID != "7" {
+		t.Errorf("chirp too long: %+v", msg)
+	}
+}
+
+func TestWebSocketNotifications(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	conn := wsDial(t, srv, alice.Token)
+
+	wsSend(t, conn, wsRequest{Type: "subscribe", ID: "1", Channel: wsChannelNotifications})
+	if msg := wsRead(t, conn); msg.Type != "subscribed" {
+		t.Fatalf("subscribe: %+v", msg)
+	}
+	doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "hi @alice"}, nil)
+	msg := wsRead(t, conn)
+	var n Notification
+	json.Unmarshal(msg.Data, &n)
+	if msg.Type != "notification" || msg.Channel != wsChannelNotifications || n.Kind != "mention" || n.ActorID != bob.ID {
+		t.Errorf("notification: %+v", msg)
+	}
+
+	wsSend(t, conn, wsRequest{Type: "unsubscribe", ID: "2", Channel: wsChannelNotifications})
+	wsReadUntil(t, conn, "unsubscribed", "2")
+	doJSON(t, srv, "POST", "/api/chirps", bob.Token, map[string]string{"body": "hi again @alice"}, nil)
+	wsSend(t, conn, wsRequest{Type: "bogus", ID: "3"})
+	if _, before := wsReadUntil(t, conn, "error", "3"); len(before) != 0 {
+		t.Errorf("got %+v after unsubscribing", before)
+	}
+}
+
+func TestWebSocketTokens(t *testing.T) {
+	_, srv := newTestServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+
+	short, err := auth.MakeJWT(t.Context(), alice.ID, testSecret, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := wsDial(t, srv, short)
+	expiring := wsDial(t, srv, short)
+
+	wsSend(t, conn, wsRequest{Type: "auth", ID: "1", Token: bob.Token})
+	if msg := wsRead(t, conn); msg.Type != "error" || msg.ID != "1" {
+		t.Errorf("re-authenticating as someone else: %+v", msg)
+	}
+	wsSend(t, conn, wsRequest{Type: "auth", ID: "2", Token: "not a token"})
+	if msg := wsRead(t, conn); msg.Type != "error" || msg.ID != "2" {
+		t.Errorf("re-authenticating with garbage: %+v", msg)
+	}
+
+	// Handing over a fresh token keeps the connection open past the
+	// first one's expiry.
+	wsSend(t, conn, wsRequest{Type: "auth", ID: "3", Token: alice.Token})
+	if msg := wsRead(t, conn); msg.Type != "ack" || msg.ID != "3" {
+		t.Fatalf("re-authenticating: %+v", msg)
+	}
+
+	if msg := wsRead(t, expiring); msg.Type != "token_expired" {
+		t.Errorf("got %+v, want token_expired", msg)
+	}
+	var closeErr *websocket.CloseError
+	_, _, err = expiring.ReadMessage()
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Errorf("closed with %v, want policy violation", err)
+	}
+
+	wsSend(t, conn, wsRequest{Type: "subscribe", ID: "4", Channel: wsChannelTimeline})
+	if msg := wsRead(t, conn); msg.Type != "subscribed" {
+		t.Errorf("after the first token expired: %+v", msg)
+	}
+}
+
+func TestWebSocketAccessRevoked(t *testing.T) {
+	_, srv := newModeratedServer(t)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	revoked := wsDial(t, srv, alice.Token)
+	suspended := wsDial(t, srv, bob.Token)
+	for _, conn := range []*websocket.Conn{revoked, suspended} {
+		wsSend(t, conn, wsRequest{Type: "subscribe", ID: "1", Channel: wsChannelTimeline})
+		if msg := wsRead(t, conn); msg.Type != "subscribed" {
+			t.Fatalf("subscribe: %+v", msg)
+		}
+	}
+
+	wantClosed := func(conn *websocket.Conn, what string) {
+		t.Helper()
+		msg, _ := wsReadUntil(t, conn, "access_revoked", "")
+		if msg.Error == "" {
+			t.Errorf("%s: no reason given", what)
+		}
+		var closeErr *websocket.CloseError
+		_, _, err := conn.ReadMessage()
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+			t.Errorf("%s: closed with %v, want policy violation", what, err)
+		}
+	}
+
+	if code := doAdmin(t, srv, "POST", "/admin/api/users/"+alice.ID.String()+"/tokens/revoke", nil, nil); code != http.StatusOK {
+		t.Fatalf("revoke: status %d", code)
+	}
+	wsSend(t, revoked, wsRequest{Type: "subscribe", ID: "2", Channel: wsChannelFollowing})
+	wantClosed(revoked, "after revoking the tokens")
+
+	until := map[string]time.Time{"until": time.Now().Add(time.Hour)}
+	if code := doAdmin(t, srv, "POST", "/admin/api/users/"+bob.ID.String()+"/suspension", until, nil); code != http.StatusOK {
+		t.Fatalf("suspend: status %d", code)
+	}
+	wsSend(t, suspended, wsRequest{Type: "chirp", ID: "2", Body: "still chirping"})
+	wantClosed(suspended, "after suspending the user")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/storage"
+	"github.com/pressly/goose/v3"
+)
+
+const readyCheckTimeout = 2 * time.Second
+
+// readyCheck is one component of readiness. It returns nil when the
+// component can serve traffic.
+type readyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type componentStatus struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// dbCheck pings the database.
+func dbCheck(db *sql.DB) readyCheck {
+	return readyCheck{name: "database", check: db.PingContext}
+}
+
+// migrationsCheck fails while the schema is behind the binary, e.g. while
+// another instance is still migrating.
+func migrationsCheck(migrator *goose.Provider) readyCheck {
+	return readyCheck{name: "migrations", check: func(ctx context.Context) error {
+		current, target, err := migrator.GetVersions(ctx)
+		if err != nil {
+			return err
+		}
+		if current < target {
+			return fmt.Errorf("%w: at version %d, want %d", storage.ErrPendingMigrations, current, target)
+		}
+		return nil
+	}}
+}
+
+// handlerLiveness reports that the process is up. It checks nothing else,
+// so a database outage doesn't get every instance restarted.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// handlerReadyz runs every readiness check at once, each under
+// readyCheckTimeout, and answers 503 if any fails or a shutdown has begun.
+func (cfg *apiConfig) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := append([]readyCheck{{name: "workers", check: cfg.workers.check}}, cfg.readyChecks...)
+
+	resp := readinessResponse{Status: "ready", Components: map[string]componentStatus{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.check(ctx)
+			status := componentStatus{Status: "ok", Duration: time.Since(start).Round(time.Microsecond).String()}
+			if err != nil {
+				status.Status = "failing"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Components[c.name] = status
+			if err != nil {
+				resp.Status = "not_ready"
+			}
+		})
+	}
+	wg.Wait()
+
+	if cfg.shuttingDown.Load() {
+		resp.Status = "not_ready"
+		resp.Components["shutdown"] = componentStatus{Status: "failing", Error: "shutting down"}
+	}
+
+	code := http.StatusOK
+	if resp.Status != "ready" {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJson(w, code, resp)
+}
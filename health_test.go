@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/storage"
+	"github.com/enderbd/chirpy/internal/store/sqlite"
+)
+
+func TestLiveness(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	cfg.readyChecks = []readyCheck{{name: "database", check: func(context.Context) error {
+		return errors.New("connection refused")
+	}}}
+
+	// Liveness doesn't depend on the database.
+	for _, path := range []string{"/api/livez", "/api/healthz"} {
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	dbErr := error(nil)
+	cfg.readyChecks = []readyCheck{
+		{name: "database", check: func(context.Context) error { return dbErr }},
+	}
+
+	var got readinessResponse
+	if code := doJSON(t, srv, "GET", "/api/readyz", "", nil, &got); code != http.StatusOK {
+		t.Fatalf("status %d: %+v", code, got)
+	}
+	if got.Status != "ready" || got.Components["database"].Status != "ok" || got.Components["workers"].Status != "ok" {
+		t.Errorf("got %+v", got)
+	}
+
+	dbErr = errors.New("connection refused")
+	got = readinessResponse{}
+	if code := doJSON(t, srv, "GET", "/api/readyz", "", nil, &got); code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", code)
+	}
+	if got.Status != "not_ready" || got.Components["database"].Error != "connection refused" || got.Components["workers"].Status != "ok" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestReadinessWorkers(t *testing.T) {
+	cfg, srv := newTestServer(t)
+
+	defer cfg.workers.Stop()
+	cfg.workers.Go(stageProducers, "healthy", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	cfg.workers.Go(stageListeners, "listener", func(context.Context) error {
+		return errors.New("connection lost")
+	})
+
+	// Wait for the failing worker to be recorded.
+	for cfg.workers.check(t.Context()) == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	var got readinessResponse
+	if code := doJSON(t, srv, "GET", "/api/readyz", "", nil, &got); code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", code)
+	}
+	if got.Components["workers"].Error != "workers not running: [listener: connection lost]" {
+		t.Errorf("got %+v", got.Components["workers"])
+	}
+}
+
+func TestWorkersStopInStages(t *testing.T) {
+	var workers workerSet
+	stopped := make(chan string, 3)
+	for _, w := range []struct {
+		stage workerStage
+		name  string
+	}{{stageListeners, "listener"}, {stageSenders, "sender"}, {stageProducers, "producer"}} {
+		workers.Go(w.stage, w.name, func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped <- w.name
+			return nil
+		})
+	}
+
+	workers.Stop()
+	close(stopped)
+	var order []string
+	for name := range stopped {
+		order = append(order, name)
+	}
+	if want := []string{"producer", "sender", "listener"}; !slices.Equal(order, want) {
+		t.Errorf("stopped %v, want %v", order, want)
+	}
+}
+
+func TestReadinessDuringShutdown(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	cfg.shuttingDown.Store(true)
+
+	var got readinessResponse
+	if code := doJSON(t, srv, "GET", "/api/readyz", "", nil, &got); code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", code)
+	}
+	if got.Components["shutdown"].Status != "failing" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestMigrationsCheck(t *testing.T) {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	migrator, err := storage.NewMigrator(db, "sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	check := migrationsCheck(migrator)
+	if err := check.check(ctx); !errors.Is(err, storage.ErrPendingMigrations) {
+		t.Errorf("fresh database: got %v, want storage.ErrPendingMigrations", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := check.check(ctx); err != nil {
+		t.Errorf("migrated database: %s", err)
+	}
+	if err := dbCheck(db).check(ctx); err != nil {
+		t.Errorf("database check: %s", err)
+	}
+}
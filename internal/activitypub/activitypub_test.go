@@ -0,0 +1,216 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRemote is an in-process fediverse server with one actor and an inbox
+// that verifies signatures the way a remote server would.
+type fakeRemote struct {
+	srv        *httptest.Server
+	actorID    string
+	keyID      string
+	publicPEM  string
+	privatePEM string
+	received   chan []byte
+	verify     KeyLookup
+}
+
+func newFakeRemote(t *testing.T) *fakeRemote {
+	t.Helper()
+	pub, priv, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+
+	remote := &fakeRemote{publicPEM: pub, privatePEM: priv, received: make(chan []byte, 1)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Actor{
+			Context:           Context,
+			ID:                remote.actorID,
+			Type:              "Person",
+			PreferredUsername: "alice",
+			Inbox:             remote.srv.URL + "/users/alice/inbox",
+			Outbox:            remote.srv.URL + "/users/alice/outbox",
+			Followers:         remote.srv.URL + "/users/alice/followers",
+			PublicKey: PublicKey{
+				ID:           remote.keyID,
+				Owner:        remote.actorID,
+				PublicKeyPem: remote.publicPEM,
+			},
+			Endpoints: &Endpoints{SharedInbox: remote.srv.URL + "/inbox"},
+		})
+	})
+	// impostor claims to be an actor on another server.
+	mux.HandleFunc("GET /users/impostor", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		victim := "https://victim.example/users/alice"
+		json.NewEncoder(w).Encode(Actor{
+			Context: Context,
+			ID:      victim,
+			Type:    "Person",
+			Inbox:   victim + "/inbox",
+			PublicKey: PublicKey{
+				ID:           remote.srv.URL + "/users/impostor#main-key",
+				Owner:        victim,
+				PublicKeyPem: remote.publicPEM,
+			},
+		})
+	})
+	mux.HandleFunc("POST /inbox", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if _, err := VerifyRequest(r, body, remote.verify); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		remote.received <- body
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	remote.srv = httptest.NewTLSServer(mux)
+	t.Cleanup(remote.srv.Close)
+	remote.actorID = remote.srv.URL + "/users/alice"
+	remote.keyID = remote.actorID + "#main-key"
+	return remote
+}
+
+func TestSignAndVerifyRoundTrip(t *testing.T) {
+	pub, priv, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	privateKey, _ := ParsePrivateKey(priv)
+	publicKey, _ := ParsePublicKey(pub)
+	lookup := func(ctx context.Context, keyID string) (*rsa.PublicKey, string, error) {
+		return publicKey, "https://example.com/users/bob", nil
+	}
+
+	body := []byte(`{"type":"Follow"}`)
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://chirpy.example/ap/inbox", strings.NewReader(string(body)))
+		if err := SignRequest(req, "https://example.com/users/bob#main-key", privateKey, body); err != nil {
+			t.Fatalf("SignRequest() error = %v", err)
+		}
+		return req
+	}
+
+	owner, err := VerifyRequest(newRequest(), body, lookup)
+	if err != nil {
+		t.Fatalf("VerifyRequest() error = %v", err)
+	}
+	if owner != "https://example.com/users/bob" {
+		t.Fatalf("expected owner bob, got %s", owner)
+	}
+
+	if _, err := VerifyRequest(newRequest(), []byte(`{"type":"Delete"}`), lookup); err == nil {
+		t.Fatal("expected tampered body to fail verification")
+	}
+
+	stale := newRequest()
+	stale.Header.Set("Date", time.Now().Add(-2*MaxClockSkew).UTC().Format(http.TimeFormat))
+	if _, err := VerifyRequest(stale, body, lookup); err == nil {
+		t.Fatal("expected a stale Date to fail verification")
+	}
+}
+
+func TestDeliverToFakeRemote(t *testing.T) {
+	remote := newFakeRemote(t)
+	client := NewClient("chirpy-test")
+	client.HTTP = remote.srv.Client()
+
+	// Pretend we are a second actor hosted on the same fake server so the
+	// remote inbox can look our key up.
+	remote.verify = client.LookupKey
+	privateKey, _ := ParsePrivateKey(remote.privatePEM)
+
+	payload := []byte(`{"type":"Create","actor":"` + remote.actorID + `"}`)
+	err := client.Deliver(context.Background(), remote.srv.URL+"/inbox", remote.keyID, privateKey, payload)
+	if err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if got := <-remote.received; string(got) != string(payload) {
+		t.Fatalf("remote received %s, want %s", got, payload)
+	}
+
+	_, otherPriv, _ := GenerateKeyPair()
+	wrongKey, _ := ParsePrivateKey(otherPriv)
+	err = client.Deliver(context.Background(), remote.srv.URL+"/inbox", remote.keyID, wrongKey, payload)
+	if err == nil {
+		t.Fatal("expected delivery signed with the wrong key to be rejected")
+	}
+}
+
+func TestFetchActor(t *testing.T) {
+	remote := newFakeRemote(t)
+	client := NewClient("chirpy-test")
+	client.HTTP = remote.srv.Client()
+
+	actor, err := client.FetchActor(context.Background(), remote.actorID)
+	if err != nil {
+		t.Fatalf("FetchActor() error = %v", err)
+	}
+	if actor.DeliveryInbox() != remote.srv.URL+"/inbox" {
+		t.Fatalf("expected shared inbox, got %s", actor.DeliveryInbox())
+	}
+	if actor.PublicKey == nil {
+		t.Fatal("expected a public key")
+	}
+
+	if _, err := client.FetchActor(context.Background(), "http://127.0.0.1/users/alice"); err == nil {
+		t.Fatal("expected plain http actor IDs to be refused")
+	}
+}
+
+func TestFetchActorRejectsImpostors(t *testing.T) {
+	remote := newFakeRemote(t)
+	client := NewClient("chirpy-test")
+	client.HTTP = remote.srv.Client()
+
+	if _, err := client.FetchActor(context.Background(), remote.srv.URL+"/users/impostor"); err == nil {
+		t.Error("expected an actor claiming another URL to be refused")
+	}
+	if _, _, err := client.LookupKey(context.Background(), remote.srv.URL+"/users/impostor#main-key"); err == nil {
+		t.Error("expected the impostor's key to be refused")
+	}
+	if _, owner, err := client.LookupKey(context.Background(), remote.keyID); err != nil || owner != remote.actorID {
+		t.Errorf("LookupKey(%s) = %s, %v", remote.keyID, owner, err)
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	remote := newFakeRemote(t)
+	client := NewClient("chirpy-test")
+	// Trust the test server's certificate, but dial like NewClient does.
+	transport := guardedTransport()
+	transport.TLSClientConfig = remote.srv.Client().Transport.(*http.Transport).TLSClientConfig
+	client.HTTP.Transport = transport
+
+	if _, err := client.FetchActor(context.Background(), remote.actorID); err == nil || !strings.Contains(err.Error(), "refusing to connect") {
+		t.Errorf("fetching from a loopback address: %v, want it refused", err)
+	}
+
+	_, priv, _ := GenerateKeyPair()
+	key, _ := ParsePrivateKey(priv)
+	if err := client.Deliver(context.Background(), "http://example.com/inbox", remote.keyID, key, []byte("{}")); err == nil {
+		t.Error("expected delivery to a plain http inbox to be refused")
+	}
+
+	for _, addr := range []string{"127.0.0.1:443", "[::1]:443", "10.0.0.1:443", "169.254.169.254:80", "[::ffff:192.168.1.1]:443", "100.64.0.1:443", "0.0.0.0:443"} {
+		if err := refusePrivate("tcp", addr, nil); err == nil {
+			t.Errorf("refusePrivate(%s) = nil", addr)
+		}
+	}
+	if err := refusePrivate("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("refusePrivate(public address) = %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const maxDocumentSize = 1 << 20
+
+// RemoteActor is what Chirpy needs to know about an actor on another server.
+type RemoteActor struct {
+	ID          string
+	Inbox       string
+	SharedInbox string
+	KeyID       string
+	PublicKey   *rsa.PublicKey
+}
+
+// DeliveryInbox prefers the shared inbox so one delivery reaches every
+// follower on the same server.
+func (a RemoteActor) DeliveryInbox() string {
+	if a.SharedInbox != "" {
+		return a.SharedInbox
+	}
+	return a.Inbox
+}
+
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+}
+
+// NewClient returns a client whose requests are traced and carry the
+// caller's trace context. Every URL it fetches comes from remote input, so
+// it only follows https redirects and refuses to connect to loopback,
+// private or link-local addresses, whatever name resolved to them.
+func NewClient(userAgent string) *Client {
+	return &Client{
+		HTTP: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: otelhttp.NewTransport(guardedTransport()),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Scheme != "https" {
+					return fmt.Errorf("refusing redirect to %s", req.URL)
+				}
+				if len(via) >= 5 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+		UserAgent: userAgent,
+	}
+}
+
+// guardedTransport is http.DefaultTransport without proxying, dialing
+// through refusePrivate.
+func guardedTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: refusePrivate,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// cgnat is the carrier-grade NAT range, which netip doesn't count as
+// private.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// refusePrivate is a net.Dialer Control func. It runs after name
+// resolution, so a public name pointing at an internal address is refused
+// too.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := ap.Addr().Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnat.Contains(ip) {
+		return fmt.Errorf("refusing to connect to %s", ip)
+	}
+	return nil
+}
+
+// httpsURL parses raw, requiring an absolute https URL.
+func httpsURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an https URL", raw)
+	}
+	return u, nil
+}
+
+// FetchActor dereferences an actor ID. Only https URLs are fetched, which
+// keeps remote input from steering requests at plain-text internal services.
+func (c *Client) FetchActor(ctx context.Context, actorID string) (RemoteActor, error) {
+	u, err := httpsURL(actorID)
+	if err != nil {
+		return RemoteActor{}, fmt.Errorf("actor: %w", err)
+	}
+	u.Fragment = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	req.Header.Set("Accept", ContentType+", "+LDContentType)
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return RemoteActor{}, fmt.Errorf("fetching %s: %s", u, resp.Status)
+	}
+
+	var actor Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(&actor); err != nil {
+		return RemoteActor{}, fmt.Errorf("decoding actor %s: %w", u, err)
+	}
+	if actor.ID == "" || actor.Inbox == "" {
+		return RemoteActor{}, fmt.Errorf("actor %s has no id or inbox", u)
+	}
+	// A document may only speak for the URL it was fetched from; otherwise
+	// any server could publish a key for an actor on another one.
+	if id, err := url.Parse(actor.ID); err != nil || withoutFragment(id) != u.String() {
+		return RemoteActor{}, fmt.Errorf("actor fetched from %s claims to be %s", u, actor.ID)
+	}
+
+	remote := RemoteActor{
+		ID:    actor.ID,
+		Inbox: actor.Inbox,
+		KeyID: actor.PublicKey.ID,
+	}
+	if actor.Endpoints != nil {
+		remote.SharedInbox = actor.Endpoints.SharedInbox
+	}
+	if _, err := httpsURL(remote.Inbox); err != nil {
+		return RemoteActor{}, fmt.Errorf("actor %s inbox: %w", u, err)
+	}
+	if _, err := httpsURL(remote.SharedInbox); remote.SharedInbox != "" && err != nil {
+		return RemoteActor{}, fmt.Errorf("actor %s shared inbox: %w", u, err)
+	}
+	if actor.PublicKey.PublicKeyPem != "" {
+		if actor.PublicKey.Owner != actor.ID {
+			return RemoteActor{}, fmt.Errorf("key %s is not owned by %s", actor.PublicKey.ID, actor.ID)
+		}
+		remote.PublicKey, err = ParsePublicKey(actor.PublicKey.PublicKeyPem)
+		if err != nil {
+			return RemoteActor{}, fmt.Errorf("actor %s: %w", u, err)
+		}
+	}
+	return remote, nil
+}
+
+// LookupKey is a KeyLookup that fetches the actor a keyId belongs to.
+func (c *Client) LookupKey(ctx context.Context, keyID string) (*rsa.PublicKey, string, error) {
+	actorID, _, _ := strings.Cut(keyID, "#")
+	actor, err := c.FetchActor(ctx, actorID)
+	if err != nil {
+		return nil, "", err
+	}
+	if actor.PublicKey == nil || actor.KeyID != keyID {
+		return nil, "", errors.New("actor does not publish that key")
+	}
+	key, err := url.Parse(keyID)
+	if err != nil {
+		return nil, "", err
+	}
+	owner, err := url.Parse(actor.ID)
+	if err != nil || owner.Host != key.Host {
+		return nil, "", fmt.Errorf("key %s is not on the same host as %s", keyID, actor.ID)
+	}
+	return actor.PublicKey, actor.ID, nil
+}
+
+func withoutFragment(u *url.URL) string {
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// Deliver POSTs a signed activity to a remote inbox, which must be an https
+// URL.
+func (c *Client) Deliver(ctx context.Context, inbox, keyID string, key *rsa.PrivateKey, payload []byte) error {
+	if _, err := httpsURL(inbox); err != nil {
+		return fmt.Errorf("inbox: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", c.UserAgent)
+	if err := SignRequest(req, keyID, key, payload); err != nil {
+		return err
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("delivering to %s: %s", inbox, resp.Status)
+	}
+	return nil
+}
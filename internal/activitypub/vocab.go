@@ -0,0 +1,132 @@
+// Package activitypub implements the small part of ActivityPub, WebFinger
+// and HTTP Signatures Chirpy needs to federate with the fediverse.
+package activitypub
+
+import (
+	"encoding/json"
+)
+
+const (
+	ContentType     = "application/activity+json"
+	LDContentType   = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+	JRDContentType  = "application/jrd+json"
+	Public          = "https://www.w3.org/ns/activitystreams#Public"
+	activityStreams = "https://www.w3.org/ns/activitystreams"
+	securityV1      = "https://w3id.org/security/v1"
+)
+
+// Context is the JSON-LD @context for documents we serve.
+var Context = []string{activityStreams, securityV1}
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox"`
+	Followers         string     `json:"followers"`
+	PublicKey         PublicKey  `json:"publicKey"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Note struct {
+	Context      any      `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo"`
+	Content      string   `json:"content"`
+	Published    string   `json:"published,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	To           []string `json:"to,omitempty"`
+	Cc           []string `json:"cc,omitempty"`
+}
+
+type Activity struct {
+	Context   any      `json:"@context,omitempty"`
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Actor     string   `json:"actor"`
+	Object    any      `json:"object"`
+	Published string   `json:"published,omitempty"`
+	To        []string `json:"to,omitempty"`
+	Cc        []string `json:"cc,omitempty"`
+}
+
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+// IncomingActivity is an activity received in an inbox. The object is kept
+// raw because it may be a bare ID or an embedded object.
+type IncomingActivity struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// IncomingObject holds the fields Chirpy reads from embedded objects.
+type IncomingObject struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	Actor        string          `json:"actor"`
+	Object       json.RawMessage `json:"object"`
+	AttributedTo string          `json:"attributedTo"`
+	Content      string          `json:"content"`
+	Published    string          `json:"published"`
+}
+
+// ObjectID returns the ID of an object that is either a bare IRI or an
+// embedded object with an id.
+func ObjectID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id
+	}
+	var obj IncomingObject
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		return obj.ID
+	}
+	return ""
+}
+
+// ParseObject decodes an embedded object. Bare IRIs yield an object with
+// only the ID set.
+func ParseObject(raw json.RawMessage) IncomingObject {
+	var obj IncomingObject
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		obj.ID = ObjectID(raw)
+	}
+	return obj
+}
+
+// WebFinger is a JSON Resource Descriptor as served by
+// /.well-known/webfinger.
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
@@ -20,7 +20,7 @@ const (
 	TokenTypeAccess TokenType = "chirpy-access"
 )
 
-func HashPassword(password string) (string, error) {
+func hashPassword(password string) (string, error) {
 	if password == "" {
 		return "", fmt.Errorf("Need at least one character in the password")
 	}
@@ -33,7 +33,7 @@ func HashPassword(password string) (string, error) {
 	return hashedPassword, nil
 }
 
-func CheckPasswordHash(password, hash string) (bool, error) {
+func checkPasswordHash(password, hash string) (bool, error) {
 	match, err := argon2id.ComparePasswordAndHash(password, hash)
 	if err != nil {
 		return false, fmt.Errorf("Couild not compare the password and the hash: error %w", err)
@@ -43,7 +43,7 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+func makeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	signingKey := []byte(tokenSecret)
 	// Create the Claims
 	claims := &jwt.RegisteredClaims{
@@ -57,31 +57,48 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return token.SignedString(signingKey)
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+// AccessToken is what a valid access token says.
+type AccessToken struct {
+	UserID    uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func validateJWT(tokenString, tokenSecret string) (AccessToken, error) {
 	claims := jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (any, error) {return []byte(tokenSecret), nil})
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid user")
+		return AccessToken{}, errors.New("invalid user")
+	}
+
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return AccessToken{}, errors.New("token has no expiration time")
+	}
+
+	issuedAt, err := token.Claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return AccessToken{}, errors.New("token has no issue time")
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return AccessToken{}, fmt.Errorf("invalid user ID: %w", err)
 	}
-	return id, nil
+	return AccessToken{UserID: id, IssuedAt: issuedAt.Time, ExpiresAt: expiresAt.Time}, nil
 
 }
 
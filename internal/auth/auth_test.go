@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 	"testing"
 	"time"
@@ -12,8 +13,8 @@ func TestCheckPasswordHash(t *testing.T) {
 	// First, we need to create some hashed passwords for testing
 	password1 := "correctPassword123!"
 	password2 := "anotherPassword456!"
-	hash1, _ := HashPassword(password1)
-	hash2, _ := HashPassword(password2)
+	hash1, _ := HashPassword(context.Background(), password1)
+	hash2, _ := HashPassword(context.Background(), password2)
 
 	tests := []struct {
 		name          string
@@ -61,7 +62,7 @@ func TestCheckPasswordHash(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			match, err := CheckPasswordHash(tt.password, tt.hash)
+			match, err := CheckPasswordHash(context.Background(), tt.password, tt.hash)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CheckPasswordHash() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -74,7 +75,7 @@ func TestCheckPasswordHash(t *testing.T) {
 
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	validToken, _ := MakeJWT(context.Background(), userID, "secret", time.Hour)
 
 	tests := []struct {
 		name        string
@@ -108,7 +109,7 @@ func TestValidateJWT(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
+			gotUserID, err := ValidateJWT(context.Background(), tt.tokenString, tt.tokenSecret)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -120,6 +121,35 @@ func TestValidateJWT(t *testing.T) {
 	}
 }
 
+func TestValidateJWTWithExpiry(t *testing.T) {
+	userID := uuid.New()
+	token, _ := MakeJWT(context.Background(), userID, "secret", time.Hour)
+
+	gotUserID, expiresAt, err := ValidateJWTWithExpiry(context.Background(), token, "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotUserID != userID {
+		t.Fatalf("expected %v, got %v", userID, gotUserID)
+	}
+	if until := time.Until(expiresAt); until <= 59*time.Minute || until > time.Hour {
+		t.Fatalf("expected expiry about an hour from now, got %v", expiresAt)
+	}
+}
+
+func TestParseJWTIssuedAt(t *testing.T) {
+	before := time.Now().Truncate(time.Second)
+	token, _ := MakeJWT(context.Background(), uuid.New(), "secret", time.Hour)
+
+	got, err := ParseJWT(context.Background(), token, "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.IssuedAt.Before(before) || got.IssuedAt.After(time.Now()) {
+		t.Fatalf("expected issued at about now, got %v", got.IssuedAt)
+	}
+}
+
 
 func TestGetTokenFromHeader(t *testing.T) {
     req, _ := http.NewRequest("GET", "/", nil)
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The exported functions below time the expensive parts of authentication,
+// argon2 hashing in particular, as spans under ctx. Tokens and passwords are
+// never recorded.
+
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/enderbd/chirpy/internal/auth")
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func HashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer().Start(ctx, "auth.HashPassword")
+	hash, err := hashPassword(password)
+	endSpan(span, err)
+	return hash, err
+}
+
+func CheckPasswordHash(ctx context.Context, password, hash string) (bool, error) {
+	_, span := tracer().Start(ctx, "auth.CheckPasswordHash")
+	match, err := checkPasswordHash(password, hash)
+	endSpan(span, err)
+	return match, err
+}
+
+func MakeJWT(ctx context.Context, userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	_, span := tracer().Start(ctx, "auth.MakeJWT")
+	token, err := makeJWT(userID, tokenSecret, expiresIn)
+	endSpan(span, err)
+	return token, err
+}
+
+func ValidateJWT(ctx context.Context, tokenString, tokenSecret string) (uuid.UUID, error) {
+	token, err := ParseJWT(ctx, tokenString, tokenSecret)
+	return token.UserID, err
+}
+
+// ValidateJWTWithExpiry is ValidateJWT for long lived connections that need
+// to know when the access token stops being valid.
+func ValidateJWTWithExpiry(ctx context.Context, tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
+	token, err := ParseJWT(ctx, tokenString, tokenSecret)
+	return token.UserID, token.ExpiresAt, err
+}
+
+// ParseJWT validates an access token and returns its claims.
+func ParseJWT(ctx context.Context, tokenString, tokenSecret string) (AccessToken, error) {
+	_, span := tracer().Start(ctx, "auth.ValidateJWT")
+	token, err := validateJWT(tokenString, tokenSecret)
+	endSpan(span, err)
+	return token, err
+}
@@ -0,0 +1,71 @@
+// Package chirptext extracts structure such as hashtags and mentions from
+// chirp bodies.
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+)
+
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([A-Za-z0-9_]{1,30})\b`)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_]{1,30}$`)
+
+// Hashtags returns the distinct hashtags in body, lowercased and without
+// the leading '#', in order of first appearance.
+func Hashtags(body string) []string {
+	var tags []string
+	seen := map[string]struct{}{}
+	for _, m := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(m[1])
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Mentions returns the distinct usernames mentioned as @name in body,
+// lowercased and without the leading '@', in order of first appearance.
+func Mentions(body string) []string {
+	var names []string
+	seen := map[string]struct{}{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.ToLower(m[1])
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names
+}
+
+// HasHashtag reports whether body contains #tag, ignoring case.
+func HasHashtag(body, tag string) bool {
+	tag = strings.ToLower(tag)
+	for _, t := range Hashtags(body) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidTag reports whether tag could appear as a hashtag.
+func ValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// NormalizeUsername lowercases a requested username and checks it is usable
+// as a fediverse handle and can be mentioned.
+func NormalizeUsername(username string) (string, bool) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	return username, usernamePattern.MatchString(username)
+}
@@ -0,0 +1,64 @@
+package chirptext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "no tags here", want: nil},
+		{body: "#go is fun", want: []string{"go"}},
+		{body: "I like #Go and #go, also #café!", want: []string{"go", "café"}},
+		{body: "mail me at a#b or see &#39;", want: nil},
+	}
+
+	for _, tt := range tests {
+		got := Hashtags(tt.body)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Hashtags(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "nobody here", want: nil},
+		{body: "@alice hi", want: []string{"alice"}},
+		{body: "hey @Bob and @bob, cc @carol_1.", want: []string{"bob", "carol_1"}},
+		{body: "write to me@example.com", want: nil},
+	}
+
+	for _, tt := range tests {
+		got := Mentions(tt.body)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Mentions(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     string
+		ok       bool
+	}{
+		{username: " Alice_1 ", want: "alice_1", ok: true},
+		{username: "", want: "", ok: false},
+		{username: "al ice", want: "al ice", ok: false},
+		{username: "thirty_one_characters_is_toooo_long", want: "thirty_one_characters_is_toooo_long", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizeUsername(tt.username)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeUsername(%q) = %q, %v; want %q, %v", tt.username, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -7,29 +7,127 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const approveHeldChirp = `-- name: ApproveHeldChirp :one
+UPDATE chirps SET
+	status = CASE WHEN publish_at > $1 THEN 'scheduled' ELSE 'published' END,
+	publish_at = CASE WHEN publish_at > $1 THEN publish_at ELSE $1 END,
+	announce_at = CASE WHEN publish_at > $1 THEN NULL ELSE $2 END,
+	updated_at = $1
+WHERE id = $3 AND status = 'held'
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at
+`
+
+type ApproveHeldChirpParams struct {
+	Now        time.Time
+	AnnounceAt sql.NullTime
+	ID         uuid.UUID
+}
+
+func (q *Queries) ApproveHeldChirp(ctx context.Context, arg ApproveHeldChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, approveHeldChirp, arg.Now, arg.AnnounceAt, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.AnnounceAt,
+	)
+	return i, err
+}
+
+const claimUnannouncedChirps = `-- name: ClaimUnannouncedChirps :many
+UPDATE chirps SET announce_at = $2
+WHERE id IN (
+	SELECT id FROM chirps
+	WHERE announce_at <= $1
+	ORDER BY announce_at
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at
+`
+
+type ClaimUnannouncedChirpsParams struct {
+	Now        time.Time
+	AnnounceAt sql.NullTime
+	Limit      int32
+}
+
+func (q *Queries) ClaimUnannouncedChirps(ctx context.Context, arg ClaimUnannouncedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, claimUnannouncedChirps, arg.Now, arg.AnnounceAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.AnnounceAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at)
 VALUES (
 	gen_random_uuid(),
 	NOW(),
 	NOW(),
 	$1,
-	$2
+	$2,
+	$3,
+	$4,
+	$5
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	ReplyToID uuid.NullUUID
+	Status    string
+	PublishAt sql.NullTime
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		arg.Status,
+		arg.PublishAt,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +135,10 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.AnnounceAt,
 	)
 	return i, err
 }
@@ -51,9 +153,50 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteHeldChirp = `-- name: DeleteHeldChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND status = 'held'
+`
+
+func (q *Queries) DeleteHeldChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteHeldChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteScheduledChirp = `-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status = 'scheduled'
+`
+
+type DeleteScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteScheduledChirp(ctx context.Context, arg DeleteScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const finishChirpAnnouncement = `-- name: FinishChirpAnnouncement :exec
+UPDATE chirps SET announce_at = NULL
+WHERE id = $1
+`
+
+func (q *Queries) FinishChirpAnnouncement(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, finishChirpAnnouncement, id)
+	return err
+}
+
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id from chirps
-ORDER BY created_at ASC
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at from chirps
+ORDER BY COALESCE(publish_at, created_at) ASC
 `
 
 func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
@@ -71,6 +214,49 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.AnnounceAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByStatus = `-- name: GetChirpsByStatus :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at FROM chirps
+WHERE status = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetChirpsByStatus(ctx context.Context, status string) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByStatus, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.AnnounceAt,
 		); err != nil {
 			return nil, err
 		}
@@ -86,7 +272,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at FROM chirps
 WHERE user_id = $1
 `
 
@@ -105,6 +291,10 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.AnnounceAt,
 		); err != nil {
 			return nil, err
 		}
@@ -120,7 +310,7 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 }
 
 const getSingleChirp = `-- name: GetSingleChirp :one
-SELECT id, created_at, updated_at, body, user_id from chirps
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at from chirps
 WHERE id=$1
 `
 
@@ -133,6 +323,278 @@ func (q *Queries) GetSingleChirp(ctx context.Context, id uuid.UUID) (Chirp, erro
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.AnnounceAt,
+	)
+	return i, err
+}
+
+const importChirp = `-- name: ImportChirp :exec
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (id) DO UPDATE SET
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	body = excluded.body,
+	user_id = excluded.user_id,
+	reply_to_id = excluded.reply_to_id,
+	status = excluded.status,
+	publish_at = excluded.publish_at
+`
+
+type ImportChirpParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	ReplyToID uuid.NullUUID
+	Status    string
+	PublishAt sql.NullTime
+}
+
+func (q *Queries) ImportChirp(ctx context.Context, arg ImportChirpParams) error {
+	_, err := q.db.ExecContext(ctx, importChirp,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		arg.Status,
+		arg.PublishAt,
+	)
+	return err
+}
+
+const listChirpsPage = `-- name: ListChirpsPage :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at FROM chirps
+ORDER BY created_at ASC, id ASC
+LIMIT $1 OFFSET $2
+`
+
+type ListChirpsPageParams struct {
+	PageSize   int32
+	PageOffset int32
+}
+
+func (q *Queries) ListChirpsPage(ctx context.Context, arg ListChirpsPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsPage, arg.PageSize, arg.PageOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.AnnounceAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledChirps = `-- name: ListScheduledChirps :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at FROM chirps
+WHERE user_id = $1 AND status = 'scheduled'
+ORDER BY publish_at ASC
+`
+
+func (q *Queries) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.AnnounceAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+UPDATE chirps SET status = 'published', publish_at = $1, updated_at = $1, announce_at = $2
+WHERE id IN (
+	SELECT id FROM chirps
+	WHERE status = 'scheduled' AND publish_at <= $1
+		AND user_id NOT IN (SELECT id FROM users WHERE deleted_at IS NOT NULL OR suspended_until > $1)
+	ORDER BY publish_at
+	LIMIT $3
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at
+`
+
+type PublishDueChirpsParams struct {
+	Now        time.Time
+	AnnounceAt sql.NullTime
+	Limit      int32
+}
+
+func (q *Queries) PublishDueChirps(ctx context.Context, arg PublishDueChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps, arg.Now, arg.AnnounceAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.AnnounceAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setChirpStatus = `-- name: SetChirpStatus :exec
+UPDATE chirps SET status = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetChirpStatusParams struct {
+	Status string
+	ID     uuid.UUID
+}
+
+func (q *Queries) SetChirpStatus(ctx context.Context, arg SetChirpStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setChirpStatus, arg.Status, arg.ID)
+	return err
+}
+
+const setUserChirpsStatus = `-- name: SetUserChirpsStatus :many
+UPDATE chirps SET status = $1, updated_at = NOW()
+WHERE user_id = $2 AND status = $3
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at
+`
+
+type SetUserChirpsStatusParams struct {
+	ToStatus   string
+	UserID     uuid.UUID
+	FromStatus string
+}
+
+func (q *Queries) SetUserChirpsStatus(ctx context.Context, arg SetUserChirpsStatusParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, setUserChirpsStatus, arg.ToStatus, arg.UserID, arg.FromStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Status,
+			&i.PublishAt,
+			&i.AnnounceAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateScheduledChirp = `-- name: UpdateScheduledChirp :one
+UPDATE chirps SET body = $1, publish_at = $2, status = $3, updated_at = NOW()
+WHERE id = $4 AND status = 'scheduled'
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at, announce_at
+`
+
+type UpdateScheduledChirpParams struct {
+	Body      string
+	PublishAt sql.NullTime
+	Status    string
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateScheduledChirp(ctx context.Context, arg UpdateScheduledChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateScheduledChirp,
+		arg.Body,
+		arg.PublishAt,
+		arg.Status,
+		arg.ID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Status,
+		&i.PublishAt,
+		&i.AnnounceAt,
 	)
 	return i, err
 }
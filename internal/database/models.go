@@ -11,12 +11,118 @@ import (
 	"github.com/google/uuid"
 )
 
+type ActorKey struct {
+	UserID        uuid.UUID
+	CreatedAt     time.Time
+	PublicKeyPem  string
+	PrivateKeyPem string
+}
+
+type ApDelivery struct {
+	ID            int64
+	CreatedAt     time.Time
+	UserID        uuid.UUID
+	Inbox         string
+	Payload       string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
-	ID        uuid.UUID
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	ReplyToID  uuid.NullUUID
+	Status     string
+	PublishAt  sql.NullTime
+	AnnounceAt sql.NullTime
+}
+
+type ChirpEvent struct {
+	ID        int64
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	Kind      string
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
 	Body      string
+}
+
+type ChirpReport struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+	ResolvedAt sql.NullTime
+	ResolvedBy uuid.NullUUID
+	Resolution sql.NullString
+}
+
+type DataExport struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ClaimedUntil sql.NullTime
+	CompletedAt  sql.NullTime
+	Archive      []byte
+	Error        sql.NullString
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
 	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ModerationRule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Term      string
+	Action    string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Kind      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID uuid.UUID
+	Kind   string
+	InApp  bool
+	Email  bool
+}
+
+type RateLimit struct {
+	Key    string
+	FullAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,11 +134,24 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type RemoteFollower struct {
+	UserID    uuid.UUID
+	ActorID   string
+	Inbox     string
+	CreatedAt time.Time
+}
+
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Username        sql.NullString
+	Role            string
+	SuspendedUntil  sql.NullTime
+	TokensRevokedAt sql.NullTime
+	BanReason       string
+	DeletedAt       sql.NullTime
 }
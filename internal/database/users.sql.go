@@ -7,30 +7,34 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
 VALUES (
 	gen_random_uuid(),
 	NOW(),
 	NOW(),
 	$1,
-	$2
+	$2,
+	$3
 	)
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, tokens_revoked_at, ban_reason, deleted_at
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Username       sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Username)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -39,6 +43,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.TokensRevokedAt,
+		&i.BanReason,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -52,8 +62,18 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const downgradeRed = `-- name: DowngradeRed :exec
+UPDATE users set is_chirpy_red=false
+WHERE id=$1
+`
+
+func (q *Queries) DowngradeRed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, downgradeRed, id)
+	return err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, tokens_revoked_at, ban_reason, deleted_at FROM users
 WHERE email=$1
 `
 
@@ -67,19 +87,325 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.TokensRevokedAt,
+		&i.BanReason,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, tokens_revoked_at, ban_reason, deleted_at FROM users
+WHERE id=$1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.TokensRevokedAt,
+		&i.BanReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, tokens_revoked_at, ban_reason, deleted_at FROM users
+WHERE username=$1
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username sql.NullString) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.TokensRevokedAt,
+		&i.BanReason,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUsersByUsernames = `-- name: GetUsersByUsernames :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, tokens_revoked_at, ban_reason, deleted_at FROM users
+WHERE username = ANY($1::text[])
+`
+
+func (q *Queries) GetUsersByUsernames(ctx context.Context, usernames []string) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByUsernames, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Username,
+			&i.Role,
+			&i.SuspendedUntil,
+			&i.TokensRevokedAt,
+			&i.BanReason,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const importUser = `-- name: ImportUser :exec
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, ban_reason, deleted_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (id) DO UPDATE SET
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	email = excluded.email,
+	hashed_password = excluded.hashed_password,
+	is_chirpy_red = excluded.is_chirpy_red,
+	username = excluded.username,
+	role = excluded.role,
+	suspended_until = excluded.suspended_until,
+	ban_reason = excluded.ban_reason,
+	deleted_at = excluded.deleted_at
+`
+
+type ImportUserParams struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Email          string
+	HashedPassword string
+	IsChirpyRed    bool
+	Username       sql.NullString
+	Role           string
+	SuspendedUntil sql.NullTime
+	BanReason      string
+	DeletedAt      sql.NullTime
+}
+
+func (q *Queries) ImportUser(ctx context.Context, arg ImportUserParams) error {
+	_, err := q.db.ExecContext(ctx, importUser,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Email,
+		arg.HashedPassword,
+		arg.IsChirpyRed,
+		arg.Username,
+		arg.Role,
+		arg.SuspendedUntil,
+		arg.BanReason,
+		arg.DeletedAt,
+	)
+	return err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < $1
+RETURNING id
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore sql.NullTime) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAccessTokens = `-- name: RevokeAccessTokens :exec
+UPDATE users SET tokens_revoked_at=NOW(), updated_at=NOW()
+WHERE id=$1
+`
+
+func (q *Queries) RevokeAccessTokens(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAccessTokens, id)
+	return err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, tokens_revoked_at, ban_reason, deleted_at FROM users
+WHERE ($1::text = ''
+	OR email ILIKE '%' || $1 || '%' ESCAPE '\'
+	OR username ILIKE '%' || $1 || '%' ESCAPE '\')
+AND ($2::text = '' OR role = $2)
+ORDER BY created_at ASC, id ASC
+LIMIT $3 OFFSET $4
+`
+
+type SearchUsersParams struct {
+	Query      string
+	Role       string
+	PageSize   int32
+	PageOffset int32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers,
+		arg.Query,
+		arg.Role,
+		arg.PageSize,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Username,
+			&i.Role,
+			&i.SuspendedUntil,
+			&i.TokensRevokedAt,
+			&i.BanReason,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserDeleted = `-- name: SetUserDeleted :exec
+UPDATE users SET deleted_at=$1, updated_at=NOW()
+WHERE id=$2
+`
+
+type SetUserDeletedParams struct {
+	DeletedAt sql.NullTime
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserDeleted(ctx context.Context, arg SetUserDeletedParams) error {
+	_, err := q.db.ExecContext(ctx, setUserDeleted, arg.DeletedAt, arg.ID)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :execrows
+UPDATE users SET role=$1, updated_at=NOW()
+WHERE id=$2
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserRole, arg.Role, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUsername = `-- name: SetUsername :exec
+UPDATE users SET username=$1, updated_at=NOW()
+WHERE id=$2
+`
+
+type SetUsernameParams struct {
+	Username sql.NullString
+	ID       uuid.UUID
+}
+
+func (q *Queries) SetUsername(ctx context.Context, arg SetUsernameParams) error {
+	_, err := q.db.ExecContext(ctx, setUsername, arg.Username, arg.ID)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users SET suspended_until=$1, ban_reason=$2, updated_at=NOW()
+WHERE id=$3
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil sql.NullTime
+	BanReason      string
+	ID             uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedUntil, arg.BanReason, arg.ID)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
-UPDATE users SET email=$1, hashed_password=$2, updated_at=NOW()
-WHERE id  = $3
-RETURNING id, created_at, updated_at, email, is_chirpy_red
+UPDATE users SET email=$1, hashed_password=$2, username=COALESCE($3, username), updated_at=NOW()
+WHERE id  = $4
+RETURNING id, created_at, updated_at, email, is_chirpy_red, username
 `
 
 type UpdateUserParams struct {
 	Email          string
 	HashedPassword string
+	Username       sql.NullString
 	ID             uuid.UUID
 }
 
@@ -89,10 +415,16 @@ type UpdateUserRow struct {
 	UpdatedAt   time.Time
 	Email       string
 	IsChirpyRed bool
+	Username    sql.NullString
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Username,
+		arg.ID,
+	)
 	var i UpdateUserRow
 	err := row.Scan(
 		&i.ID,
@@ -100,6 +432,7 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateU
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Username,
 	)
 	return i, err
 }
@@ -0,0 +1,49 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteEscapesContent(t *testing.T) {
+	f := Feed{
+		Title:    "Chirps tagged #go",
+		Link:     "http://localhost:8080/tags/go",
+		SelfLink: "http://localhost:8080/tags/go/feed.atom",
+		ID:       "urn:uuid:0b8bd3a2-4a38-4bd6-8d57-0cba4b6f8f5e",
+		Updated:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Items: []Item{{
+			ID:        "urn:uuid:7e5d40b3-9e3c-4a4c-a0d5-1d4bd8f0e0a1",
+			Title:     `<script>alert("x")</script> & more`,
+			Content:   `<script>alert("x")</script> & more`,
+			Author:    "someone",
+			Published: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Updated:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}},
+	}
+
+	for name, write := range map[string]func(*bytes.Buffer) error{
+		"rss":  func(b *bytes.Buffer) error { return WriteRSS(b, f) },
+		"atom": func(b *bytes.Buffer) error { return WriteAtom(b, f) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := write(&buf); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			out := buf.String()
+			if strings.Contains(out, "<script>") {
+				t.Fatalf("content was not escaped:\n%s", out)
+			}
+			if !strings.Contains(out, f.Items[0].ID) {
+				t.Fatalf("expected item GUID in output:\n%s", out)
+			}
+			if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+				t.Fatalf("output is not well formed XML: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// DBTX is the query interface shared by the sqlc-generated packages.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// DB logs every query with the logger of the context it runs under. Queries
+// are logged at debug level, slower ones than Slow at warn level and failed
+// ones at error level. Arguments are never logged.
+type DB struct {
+	DBTX
+	Slow time.Duration
+}
+
+// NewDB wraps db.
+func NewDB(db DBTX, slow time.Duration) *DB {
+	return &DB{DBTX: db, Slow: slow}
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := db.DBTX.ExecContext(ctx, query, args...)
+	db.log(ctx, query, start, err)
+	return res, err
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.DBTX.QueryContext(ctx, query, args...)
+	db.log(ctx, query, start, err)
+	return rows, err
+}
+
+// QueryRowContext can only log how long the query took to start; its error
+// surfaces when the row is scanned.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := db.DBTX.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	db.log(ctx, query, start, err)
+	return row
+}
+
+func (db *DB) log(ctx context.Context, query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	level := slog.LevelDebug
+	switch {
+	case err != nil && !errors.Is(err, context.Canceled):
+		level = slog.LevelError
+	case db.Slow > 0 && elapsed >= db.Slow:
+		level = slog.LevelWarn
+	}
+
+	logger := FromContext(ctx)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("query", queryName(query)),
+		slog.Duration("duration", elapsed),
+	}
+	if err != nil {
+		attrs = append(attrs, Err(err))
+	}
+	logger.LogAttrs(ctx, level, "db query", attrs...)
+}
+
+// queryName returns the sqlc name of query, taken from its leading
+// "-- name: GetUser :one" comment, or the first line of the SQL.
+func queryName(query string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(query), "\n")
+	if name, ok := strings.CutPrefix(first, "-- name: "); ok {
+		name, _, _ = strings.Cut(name, " ")
+		return name
+	}
+	return first
+}
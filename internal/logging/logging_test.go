@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("hidden")
+	logger.Info("shown", "n", 1)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("not a single JSON line: %q", buf.String())
+	}
+	if line["msg"] != "shown" || line["n"] != 1.0 {
+		t.Errorf("got %v", line)
+	}
+
+	if _, err := New(&buf, "xml", slog.LevelInfo); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("mail failed", "to", "alice@example.com", Err(errors.New("550 <bob.smith+chirpy@mail.example.org>: no such user")), "user_id", "abc")
+
+	out := buf.String()
+	if strings.Contains(out, "example") || strings.Count(out, "[redacted]") != 2 || !strings.Contains(out, "user_id=abc") {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("expected the default logger without one in the context")
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	ctx := WithLogger(WithRequestID(context.Background(), "abc"), logger)
+	if FromContext(ctx) != logger {
+		t.Error("expected the context logger")
+	}
+	if RequestID(ctx) != "abc" {
+		t.Errorf("RequestID = %q", RequestID(ctx))
+	}
+}
+
+type fakeDB struct {
+	DBTX
+	err   error
+	delay time.Duration
+}
+
+func (db fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	time.Sleep(db.delay)
+	return nil, db.err
+}
+
+func TestDBLogsQueries(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, "text", slog.LevelDebug)
+	ctx := WithLogger(context.Background(), logger.With("request_id", "req-1"))
+
+	const query = "-- name: DeleteUsers :exec\nDELETE FROM users"
+
+	NewDB(fakeDB{}, time.Second).ExecContext(ctx, query, "secret-arg")
+	NewDB(fakeDB{delay: 5 * time.Millisecond}, time.Millisecond).ExecContext(ctx, query)
+	NewDB(fakeDB{err: errors.New("boom")}, time.Second).ExecContext(ctx, query)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines:\n%s", len(lines), buf.String())
+	}
+	for i, level := range []string{"DEBUG", "WARN", "ERROR"} {
+		if !strings.Contains(lines[i], "level="+level) || !strings.Contains(lines[i], "query=DeleteUsers") || !strings.Contains(lines[i], "request_id=req-1") {
+			t.Errorf("line %d: %s", i, lines[i])
+		}
+	}
+	if strings.Contains(buf.String(), "secret-arg") {
+		t.Error("query arguments were logged")
+	}
+	if !strings.Contains(lines[2], "error=boom") {
+		t.Errorf("error not logged: %s", lines[2])
+	}
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestRegisterDB(t *testing.T) {
+	// Opening does not connect, the pool stats are all there is to read.
+	db, err := sql.Open("postgres", "postgres://localhost/chirpy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	m := New()
+	m.RegisterDB(db, "chirpy")
+	if out := scrape(t, m); !strings.Contains(out, `go_sql_max_open_connections{db_name="chirpy"} 7`) {
+		t.Errorf("pool stats missing:\n%s", out)
+	}
+}
+
+func TestSeparateRegistries(t *testing.T) {
+	a, b := New(), New()
+	a.ChirpCreated()
+	if !strings.Contains(scrape(t, a), "chirpy_chirps_created_total 1") {
+		t.Error("counter not exported")
+	}
+	if !strings.Contains(scrape(t, b), "chirpy_chirps_created_total 0") {
+		t.Error("registries share state")
+	}
+}
@@ -0,0 +1,269 @@
+// Package moderation checks chirps against moderation rules and decides
+// what happens to them: published as written, published with the offending
+// words masked, held for a moderator, or rejected.
+//
+// A Pipeline runs any number of Checkers. The one provided here is
+// Wordlist, which matches words and phrases after folding case, accents,
+// look-alike letters and leetspeak, so "F0rnáx" still matches "fornax".
+package moderation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Action is what a rule does to a chirp that breaks it.
+type Action string
+
+const (
+	ActionAllow  Action = "allow"
+	ActionMask   Action = "mask"
+	ActionHold   Action = "hold"
+	ActionReject Action = "reject"
+)
+
+// severity orders the actions: the most severe one a chirp triggers wins.
+var severity = map[Action]int{ActionAllow: 0, ActionMask: 1, ActionHold: 2, ActionReject: 3}
+
+// ParseAction accepts mask, hold or reject.
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case ActionMask, ActionHold, ActionReject:
+		return a, nil
+	}
+	return "", fmt.Errorf("action must be mask, hold or reject, got %q", s)
+}
+
+// Where a rule came from.
+const (
+	SourceDefault  = "default"
+	SourceFile     = "file"
+	SourceDatabase = "database"
+)
+
+type Rule struct {
+	Term   string
+	Action Action
+	Source string
+}
+
+// DefaultRules are used when no rules file is configured.
+var DefaultRules = []Rule{
+	{Term: "kerfuffle", Action: ActionMask, Source: SourceDefault},
+	{Term: "sharbert", Action: ActionMask, Source: SourceDefault},
+	{Term: "fornax", Action: ActionMask, Source: SourceDefault},
+}
+
+// Match is one place a chirp broke a rule. Start and End are byte offsets
+// into the chirp of the text that matched.
+type Match struct {
+	Rule       Rule
+	Start, End int
+}
+
+// A Checker finds the places body breaks its rules.
+type Checker interface {
+	Check(body string) []Match
+}
+
+// Decision is the outcome of moderating a chirp.
+type Decision struct {
+	Action Action
+	// Body is the chirp with every masked match replaced by ****.
+	Body    string
+	Matches []Match
+}
+
+// Pipeline runs every checker over a chirp and combines what they found.
+type Pipeline struct {
+	checkers []Checker
+}
+
+func NewPipeline(checkers ...Checker) *Pipeline {
+	return &Pipeline{checkers: checkers}
+}
+
+// Moderate decides what happens to body. Its action is the most severe of
+// the rules it broke. Mask rules are applied whatever the action, so a
+// held chirp is reviewed, and later published, masked.
+func (p *Pipeline) Moderate(body string) Decision {
+	d := Decision{Action: ActionAllow, Body: body}
+	for _, c := range p.checkers {
+		d.Matches = append(d.Matches, c.Check(body)...)
+	}
+	slices.SortStableFunc(d.Matches, func(a, b Match) int { return a.Start - b.Start })
+
+	var masked strings.Builder
+	last := 0
+	for _, m := range d.Matches {
+		if severity[m.Rule.Action] > severity[d.Action] {
+			d.Action = m.Rule.Action
+		}
+		if m.Rule.Action != ActionMask || m.Start < last {
+			continue
+		}
+		masked.WriteString(body[last:m.Start])
+		masked.WriteString("****")
+		last = m.End
+	}
+	masked.WriteString(body[last:])
+	d.Body = masked.String()
+	return d
+}
+
+// Wordlist matches chirps against a list of words and phrases.
+type Wordlist struct {
+	root *phraseNode
+}
+
+// phraseNode is a word of a term in a trie of every term. Its children
+// are keyed by their squeezed word, so a stretched word finds them too.
+type phraseNode struct {
+	word string
+	next map[string][]*phraseNode
+	rule *Rule
+}
+
+func (n *phraseNode) child(word string) *phraseNode {
+	key := squeeze(word)
+	for _, c := range n.next[key] {
+		if c.word == word {
+			return c
+		}
+	}
+	if n.next == nil {
+		n.next = map[string][]*phraseNode{}
+	}
+	c := &phraseNode{word: word}
+	n.next[key] = append(n.next[key], c)
+	return c
+}
+
+var errEmptyTerm = errors.New("moderation: rule has no words in it")
+
+// NewWordlist indexes rules by their normalized term. When two rules have
+// the same normalized term the later one wins.
+func NewWordlist(rules []Rule) (*Wordlist, error) {
+	wl := &Wordlist{root: &phraseNode{}}
+	for _, r := range rules {
+		key := TermKey(r.Term)
+		if key == "" {
+			return nil, fmt.Errorf("%w: %q", errEmptyTerm, r.Term)
+		}
+		n := wl.root
+		for _, word := range strings.Split(key, " ") {
+			n = n.child(word)
+		}
+		n.rule = &r
+	}
+	return wl, nil
+}
+
+// Check finds every rule whose term appears as whole words in body,
+// preferring the longest phrase at each position. Each word is tried both
+// with and without the symbols around it.
+func (wl *Wordlist) Check(body string) []Match {
+	tokens := tokenize(body)
+	forms := make([][]wordForm, len(tokens))
+	for i, t := range tokens {
+		forms[i] = wordForms(body, t)
+	}
+
+	var matches []Match
+	for i := 0; i < len(tokens); {
+		m, words, ok := wl.matchAt(forms[i:])
+		if !ok {
+			i++
+			continue
+		}
+		matches = append(matches, m)
+		i += words
+	}
+	return matches
+}
+
+// wordForm is a word of a chirp, normalized, and the bytes of the chirp
+// it came from.
+type wordForm struct {
+	word       string
+	start, end int
+}
+
+// wordForms normalizes t whole and, if it has symbols around it, without
+// them.
+func wordForms(body string, t token) []wordForm {
+	forms := []wordForm{{normalize(body[t.start:t.end]), t.start, t.end}}
+	if t.coreStart != t.start || t.coreEnd != t.end {
+		forms = append(forms, wordForm{normalize(body[t.coreStart:t.coreEnd]), t.coreStart, t.coreEnd})
+	}
+	return forms
+}
+
+// matchAt walks the trie from the first word, following every form of
+// each word at once, and returns the longest phrase it completes.
+func (wl *Wordlist) matchAt(forms [][]wordForm) (Match, int, bool) {
+	type state struct {
+		node  *phraseNode
+		start int
+	}
+	var best Match
+	words := 0
+	states := []state{{node: wl.root, start: -1}}
+	for k := 0; k < len(forms) && len(states) > 0; k++ {
+		var next []state
+		for _, s := range states {
+			for _, f := range forms[k] {
+				for _, c := range s.node.next[squeeze(f.word)] {
+					if !stretches(f.word, c.word) {
+						continue
+					}
+					start := s.start
+					if k == 0 {
+						start = f.start
+					}
+					if !slices.Contains(next, state{c, start}) {
+						next = append(next, state{c, start})
+					}
+					if c.rule != nil && words <= k {
+						best, words = Match{Rule: *c.rule, Start: start, End: f.end}, k+1
+					}
+				}
+			}
+		}
+		states = next
+	}
+	return best, words, words > 0
+}
+
+// ParseRules reads rules from a wordlist file: one rule per line, written
+// as the action followed by the word or phrase, e.g. "hold buy followers".
+// Blank lines and lines starting with # are skipped.
+func ParseRules(r io.Reader, source string) ([]Rule, error) {
+	var rules []Rule
+	var errs []error
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		action, term, _ := strings.Cut(text, " ")
+		a, err := ParseAction(action)
+		if err == nil && TermKey(term) == "" {
+			err = errEmptyTerm
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		rules = append(rules, Rule{Term: strings.TrimSpace(term), Action: a, Source: source})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, errors.Join(errs...)
+}
@@ -0,0 +1,142 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"Fornax":       "fornax",
+		"F0RN@X":       "fornax",
+		"fórnáx":       "fornax",
+		"ｆｏｒｎａｘ":       "fornax",
+		"fоrnах":       "fornax", // Cyrillic о, а and х
+		"for\u200bnax": "fornax", // zero-width space
+		"fooorrnaaax":  "fooorrnaaax",
+		"$harbert":     "sharbert",
+		"KERFUUUFFLE":  "kerfuuuffle",
+	}
+	for in, want := range tests {
+		if got := normalize(in); got != want {
+			t.Errorf("normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStretches(t *testing.T) {
+	tests := []struct {
+		word, term string
+		want       bool
+	}{
+		{"fornax", "fornax", true},
+		{"fooorrrnaaax", "fornax", true},
+		{"kerfuuuffle", "kerfuffle", true},
+		{"kerfufle", "kerfuffle", false},
+		{"fornaax", "fornax", false},
+		{"ass", "as", false},
+		{"hell", "hel", false},
+		{"helllo", "hello", true},
+		{"forna", "fornax", false},
+	}
+	for _, tt := range tests {
+		if squeeze(tt.word) != squeeze(tt.term) && tt.want {
+			t.Errorf("squeeze(%q) != squeeze(%q)", tt.word, tt.term)
+		}
+		if got := stretches(tt.word, tt.term); got != tt.want {
+			t.Errorf("stretches(%q, %q) = %v, want %v", tt.word, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	body := "Hi, fornax! ¿qué? @bob $5 ... 日本語"
+	var words, cores []string
+	for _, tok := range tokenize(body) {
+		words = append(words, body[tok.start:tok.end])
+		cores = append(cores, body[tok.coreStart:tok.coreEnd])
+	}
+	if got := strings.Join(words, "|"); got != "Hi|fornax!|qué|@bob|$5|日本語" {
+		t.Errorf("words = %s", got)
+	}
+	if got := strings.Join(cores, "|"); got != "Hi|fornax|qué|bob|5|日本語" {
+		t.Errorf("cores = %s", got)
+	}
+}
+
+func newTestPipeline(t *testing.T, rules string) *Pipeline {
+	t.Helper()
+	parsed, err := ParseRules(strings.NewReader(rules), SourceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wl, err := NewWordlist(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPipeline(wl)
+}
+
+func TestModerate(t *testing.T) {
+	p := newTestPipeline(t, `
+# masked words
+mask fornax
+mask sharbert
+mask ass
+mask hel
+hold buy followers
+reject spamlink
+`)
+
+	tests := []struct {
+		body   string
+		action Action
+		masked string
+		terms  []string
+	}{
+		{body: "hello world", action: ActionAllow, masked: "hello world"},
+		{body: "fornax!", action: ActionMask, masked: "****!", terms: []string{"fornax"}},
+		{body: "What a F0rn@x, $harbert.", action: ActionMask, masked: "What a ****, ****.", terms: []string{"fornax", "sharbert"}},
+		{body: "fornaxes are fine", action: ActionAllow, masked: "fornaxes are fine"},
+		{body: "BUY   Followers now, fornax", action: ActionHold, masked: "BUY   Followers now, ****", terms: []string{"buy followers", "fornax"}},
+		{body: "click my spаmlink", action: ActionReject, masked: "click my spаmlink", terms: []string{"spamlink"}},
+		{body: "fooorrrnaaax!!", action: ActionMask, masked: "****!!", terms: []string{"fornax"}},
+		{body: "as far as I know", action: ActionAllow, masked: "as far as I know"},
+		{body: "ass", action: ActionMask, masked: "****", terms: []string{"ass"}},
+		{body: "hello there", action: ActionAllow, masked: "hello there"},
+		{body: "go to hell", action: ActionAllow, masked: "go to hell"},
+		{body: "hel", action: ActionMask, masked: "****", terms: []string{"hel"}},
+	}
+	for _, tt := range tests {
+		d := p.Moderate(tt.body)
+		var terms []string
+		for _, m := range d.Matches {
+			terms = append(terms, m.Rule.Term)
+		}
+		if d.Action != tt.action || d.Body != tt.masked || strings.Join(terms, ",") != strings.Join(tt.terms, ",") {
+			t.Errorf("Moderate(%q) = %s %q %v; want %s %q %v", tt.body, d.Action, d.Body, terms, tt.action, tt.masked, tt.terms)
+		}
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	wl, err := NewWordlist(DefaultRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := NewPipeline(wl).Moderate("What a kerfuffle this is"); d.Body != "What a **** this is" {
+		t.Errorf("got %q", d.Body)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	_, err := ParseRules(strings.NewReader("mask ok\nban fornax\nhold\nreject !!!\n"), SourceFile)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{"line 2: action must be", "line 3:", "line 4:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q missing from %q", want, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// leet maps the symbols and digits commonly swapped for letters. They only
+// count inside a word, so "fornax!" is still "fornax" but "$harbert" is
+// "sharbert".
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '6': 'g', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't',
+}
+
+// homoglyphs maps Cyrillic and Greek letters that look like Latin ones.
+var homoglyphs = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p',
+	'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x',
+}
+
+// token is a word of a chirp and where it is. core is the word without
+// the symbols around it.
+type token struct {
+	start, end         int
+	coreStart, coreEnd int
+}
+
+func isWordRune(r rune) bool {
+	_, isLeet := leet[r]
+	return isLetterOrDigit(r) || unicode.IsMark(r) || unicode.Is(unicode.Cf, r) || isLeet
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenize splits s into words: runs of letters, digits, combining marks,
+// invisible formatting characters and leet symbols containing at least one
+// letter or digit. Everything else separates words.
+func tokenize(s string) []token {
+	var tokens []token
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+
+		t := token{start: i, coreStart: -1}
+		for i < len(s) {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if !isWordRune(r) {
+				break
+			}
+			if isLetterOrDigit(r) {
+				if t.coreStart < 0 {
+					t.coreStart = i
+				}
+				t.coreEnd = i + size
+			}
+			i += size
+		}
+		t.end = i
+		if t.coreStart >= 0 {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// normalize folds s to the form words are compared in: compatibility
+// decomposed with accents and invisible characters dropped, lower case,
+// and look-alike and leet characters replaced, so "Ｆòrnаaax" becomes
+// "fornaaax". Repeated letters are kept; see stretches.
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.IsMark(r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if l, ok := homoglyphs[r]; ok {
+			r = l
+		}
+		if l, ok := leet[r]; ok {
+			r = l
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// squeeze collapses each run of a repeated letter in a normalized word to
+// one, so "kerfuuuffle" becomes "kerfufle". Words that squeeze the same
+// may stretch one another.
+func squeeze(word string) string {
+	var b strings.Builder
+	var last rune
+	for i, r := range word {
+		if i > 0 && r == last {
+			continue
+		}
+		b.WriteRune(r)
+		last = r
+	}
+	return b.String()
+}
+
+// stretches reports whether word is term, both normalized, with letters
+// repeated for emphasis. Each run of a letter must be as long as term's,
+// or longer and at least three long: "fornaaax" stretches "fornax" but
+// "ass" doesn't stretch "as", nor "hell" "hel".
+func stretches(word, term string) bool {
+	w, t := []rune(word), []rune(term)
+	for len(w) > 0 && len(t) > 0 {
+		if w[0] != t[0] {
+			return false
+		}
+		wn, tn := runLength(w), runLength(t)
+		if wn != tn && wn < max(tn+1, 3) {
+			return false
+		}
+		w, t = w[wn:], t[tn:]
+	}
+	return len(w) == 0 && len(t) == 0
+}
+
+// runLength counts how many times rs[0] repeats at the start of rs.
+func runLength(rs []rune) int {
+	n := 1
+	for n < len(rs) && rs[n] == rs[0] {
+		n++
+	}
+	return n
+}
+
+// TermKey is the form terms are matched in: every word normalized and
+// joined by single spaces. Terms with the same key are the same rule. It
+// is empty if term has no words.
+func TermKey(term string) string {
+	var words []string
+	for _, t := range tokenize(term) {
+		words = append(words, normalize(term[t.start:t.end]))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,106 @@
+// Package notify defines the kinds of notifications Chirpy produces and
+// the senders used to deliver them outside the app.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	KindMention = "mention"
+	KindReply   = "reply"
+	KindLike    = "like"
+	KindFollow  = "follow"
+)
+
+// Kinds lists every notification kind in the order they are shown to users.
+var Kinds = []string{KindMention, KindReply, KindLike, KindFollow}
+
+// ValidKind reports whether kind is one of Kinds.
+func ValidKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Message is a notification rendered for delivery outside the app.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages, e.g. by email.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPSender sends plain-text email through an SMTP relay.
+type SMTPSender struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// NewSMTPSender returns a sender for the relay at addr ("host:port"). The
+// credentials are optional.
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	s := &SMTPSender{Addr: addr, From: from}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		s.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	from, err := parseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("sender: %w", err)
+	}
+	to, err := parseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("recipient: %w", err)
+	}
+	return smtp.SendMail(s.Addr, s.Auth, from.Address, []string{to.Address}, formatMessage(from, to, msg, time.Now()))
+}
+
+// parseAddress parses a single address, refusing any with a line break,
+// which would let it end its header and start another.
+func parseAddress(s string) (*mail.Address, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return nil, fmt.Errorf("address %q contains a line break", s)
+	}
+	return mail.ParseAddress(s)
+}
+
+func formatMessage(from, to *mail.Address, msg Message, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", sanitizeHeader(msg.Subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// sanitizeHeader keeps user-controlled text from injecting extra headers.
+func sanitizeHeader(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
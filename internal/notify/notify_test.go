@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatMessage(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	from := &mail.Address{Name: "Chirpy", Address: "chirpy@example.com"}
+	to := &mail.Address{Address: "alice@example.com"}
+	got := string(formatMessage(from, to, Message{
+		Subject: "hi\r\nBcc: evil@example.com",
+		Body:    "line one\nline two",
+	}, date))
+
+	if strings.Contains(got, "\r\nBcc:") {
+		t.Errorf("subject injected a header:\n%s", got)
+	}
+	if !strings.Contains(got, "To: <alice@example.com>\r\n") || !strings.Contains(got, "From: \"Chirpy\" <chirpy@example.com>\r\n") {
+		t.Errorf("missing address headers:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "\r\n\r\nline one\r\nline two\r\n") {
+		t.Errorf("unexpected body:\n%q", got)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	for _, s := range []string{"alice@example.com", "Alice <alice@example.com>"} {
+		if _, err := parseAddress(s); err != nil {
+			t.Errorf("parseAddress(%q) = %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "not an address", "alice@example.com\r\nBcc: evil@example.com", "alice@example.com\nBcc: evil@example.com", "a@example.com, b@example.com"} {
+		if _, err := parseAddress(s); err == nil {
+			t.Errorf("parseAddress(%q) succeeded", s)
+		}
+	}
+}
+
+func TestSendRefusesBadRecipients(t *testing.T) {
+	// Nothing listens here: a recipient that gets past the check fails to
+	// dial instead.
+	s := NewSMTPSender("127.0.0.1:1", "chirpy@example.com", "", "")
+	err := s.Send(context.Background(), Message{To: "alice@example.com\r\nBcc: evil@example.com", Subject: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "recipient") {
+		t.Errorf("Send() = %v, want a recipient error", err)
+	}
+}
+
+func TestValidKind(t *testing.T) {
+	for _, kind := range Kinds {
+		if !ValidKind(kind) {
+			t.Errorf("ValidKind(%q) = false", kind)
+		}
+	}
+	if ValidKind("poke") {
+		t.Error("ValidKind(\"poke\") = true")
+	}
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps buckets in process memory. It suits a single instance, and
+// is what SQLite deployments use.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]time.Time{}, now: time.Now}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	if err := checkLimit(l); err != nil {
+		return Result{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	fullAt := m.buckets[key]
+	if fullAt.Before(now) {
+		fullAt = now
+	}
+	next := fullAt.Add(l.interval())
+	if next.Sub(now) > l.Period {
+		return result(l, false, fullAt, now), nil
+	}
+	m.buckets[key] = next
+	return result(l, true, next, now), nil
+}
+
+func (m *Memory) Prune(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for key, fullAt := range m.buckets {
+		if fullAt.Before(now) {
+			delete(m.buckets, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/enderbd/chirpy/internal/database"
+)
+
+// Queries is the part of *database.Queries the Postgres store uses.
+type Queries interface {
+	TakeRateLimit(ctx context.Context, arg database.TakeRateLimitParams) (database.TakeRateLimitRow, error)
+	GetRateLimit(ctx context.Context, key string) (database.GetRateLimitRow, error)
+	DeleteFullRateLimits(ctx context.Context) error
+}
+
+// Postgres keeps buckets in the rate_limits table, shared by every
+// instance using the database. A request is taken in a single statement,
+// and the database clock is the only one consulted.
+type Postgres struct {
+	q Queries
+}
+
+func NewPostgres(q Queries) *Postgres {
+	return &Postgres{q: q}
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	if err := checkLimit(l); err != nil {
+		return Result{}, err
+	}
+
+	row, err := p.q.TakeRateLimit(ctx, database.TakeRateLimitParams{
+		Key:        key,
+		IntervalUs: l.interval().Microseconds(),
+		PeriodUs:   l.Period.Microseconds(),
+	})
+	if err == nil {
+		return result(l, true, row.FullAt, row.Now), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Result{}, err
+	}
+
+	// No row means the update's condition failed: the bucket is empty.
+	current, err := p.q.GetRateLimit(ctx, key)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(l, false, current.FullAt, current.Now), nil
+}
+
+func (p *Postgres) Prune(ctx context.Context) error {
+	return p.q.DeleteFullRateLimits(ctx)
+}
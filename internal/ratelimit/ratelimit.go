@@ -0,0 +1,97 @@
+// Package ratelimit implements token bucket rate limiting with stores that
+// keep the buckets in process memory or in Postgres, so several instances
+// can share them.
+//
+// Buckets are tracked the way the generic cell rate algorithm does: each
+// key only remembers when its bucket will be full again. A key with no
+// entry, or one whose time has passed, has a full bucket.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period, all of which may be spent at once.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// interval is how long the bucket takes to regain one request.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// String formats l the way ParseLimit reads it, e.g. 10/1m0s.
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ParseLimit parses a limit written as requests/period, e.g. 10/m, 100/h
+// or 5/30s. A bare unit means one of it.
+func ParseLimit(s string) (Limit, error) {
+	n, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q must look like 10/m", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 1 {
+		return Limit{}, fmt.Errorf("limit %q must allow at least one request", s)
+	}
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("limit %q must have a positive period such as s, m, h or 30s", s)
+	}
+	l := Limit{Requests: requests, Period: d}
+	if l.interval() < time.Microsecond {
+		return Limit{}, fmt.Errorf("limit %q allows more than one request per microsecond", s)
+	}
+	return l, nil
+}
+
+// Result is the outcome of taking a request from a bucket.
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining is how many more requests the bucket allows right now.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed. It is zero
+	// when Allowed is true.
+	RetryAfter time.Duration
+}
+
+// result describes a bucket that is full at fullAt.
+func result(l Limit, allowed bool, fullAt, now time.Time) Result {
+	r := Result{Allowed: allowed, Limit: l, Reset: max(fullAt.Sub(now), 0)}
+	r.Remaining = int((l.Period - r.Reset) / l.interval())
+	if !allowed {
+		r.RetryAfter = max(r.Reset+l.interval()-l.Period, 0)
+	}
+	return r
+}
+
+// Store keeps the buckets. Take spends one request from key's bucket if it
+// has one left. Prune forgets the buckets that have refilled.
+type Store interface {
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+	Prune(ctx context.Context) error
+}
+
+var errInvalidLimit = errors.New("ratelimit: limit must allow at least one request per period")
+
+func checkLimit(l Limit) error {
+	if l.Requests < 1 || l.Period <= 0 {
+		return errInvalidLimit
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+	}{
+		{in: "10/m", want: Limit{Requests: 10, Period: time.Minute}},
+		{in: "100/h", want: Limit{Requests: 100, Period: time.Hour}},
+		{in: " 5/30s ", want: Limit{Requests: 5, Period: 30 * time.Second}},
+		{in: "1/24h", want: Limit{Requests: 1, Period: 24 * time.Hour}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "10", "0/m", "-1/m", "ten/m", "10/", "10/fortnight", "10/-1m", "2000000/s"} {
+		if _, err := ParseLimit(bad); err == nil {
+			t.Errorf("ParseLimit(%q) succeeded", bad)
+		}
+	}
+}
+
+// testStore spends a fresh 3/h bucket, which can't noticeably refill
+// while the test runs.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	l := Limit{Requests: 3, Period: time.Hour}
+
+	for i := 2; i >= 0; i-- {
+		r, err := s.Take(ctx, "a", l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Allowed || r.Remaining != i {
+			t.Fatalf("request %d: got %+v, want allowed with %d remaining", 3-i, r, i)
+		}
+	}
+	r, err := s.Take(ctx, "a", l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Allowed || r.Remaining != 0 {
+		t.Errorf("fourth request: got %+v, want denied", r)
+	}
+	if r.RetryAfter < 19*time.Minute || r.RetryAfter > 20*time.Minute {
+		t.Errorf("RetryAfter = %s, want about 20m", r.RetryAfter)
+	}
+	if r.Reset < 59*time.Minute || r.Reset > time.Hour {
+		t.Errorf("Reset = %s, want about 1h", r.Reset)
+	}
+
+	r, err = s.Take(ctx, "b", l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Allowed {
+		t.Error("an empty bucket limited another key")
+	}
+
+	if err := s.Prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := s.Take(ctx, "a", l); r.Allowed {
+		t.Error("Prune refilled a bucket that wasn't full")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestMemoryRefill(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	l := Limit{Requests: 2, Period: time.Minute}
+
+	m.Take(ctx, "k", l)
+	m.Take(ctx, "k", l)
+	if r, _ := m.Take(ctx, "k", l); r.Allowed || r.RetryAfter != 30*time.Second {
+		t.Fatalf("got %+v, want denied for 30s", r)
+	}
+
+	now = now.Add(30 * time.Second)
+	if r, _ := m.Take(ctx, "k", l); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("after 30s: got %+v, want one request allowed", r)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := m.Prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.buckets) != 0 {
+		t.Errorf("Prune kept %d full buckets", len(m.buckets))
+	}
+	if r, _ := m.Take(ctx, "k", l); !r.Allowed || r.Remaining != 1 {
+		t.Errorf("after refilling: got %+v, want 1 remaining", r)
+	}
+}
+
+// TestPostgres runs against a scratch Postgres database named by
+// CHIRPY_TEST_DB_URL. The rate_limits table in it is emptied.
+func TestPostgres(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("postgres"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(db, "../../sql/schema"); err != nil {
+		t.Fatalf("migrating: %s", err)
+	}
+	if _, err := db.Exec(`TRUNCATE rate_limits`); err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, NewPostgres(database.New(db)))
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	sqlfiles "github.com/enderbd/chirpy/sql"
+	"github.com/pressly/goose/v3"
+)
+
+var ErrPendingMigrations = errors.New("database schema is out of date")
+
+// NewMigrator returns a goose provider for the migrations matching the
+// backend selected by dbURL.
+func NewMigrator(db *sql.DB, dbURL string) (*goose.Provider, error) {
+	dialect, dir := goose.DialectPostgres, "schema"
+	if _, ok := SQLitePath(dbURL); ok {
+		dialect, dir = goose.DialectSQLite3, "sqlite/schema"
+	}
+
+	fsys, err := fs.Sub(sqlfiles.Migrations, dir)
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(dialect, db, fsys)
+}
+
+// Prepare brings the schema up to date when migrate is true. Otherwise it
+// only checks the schema and refuses to run against one that is behind
+// the binary.
+func Prepare(ctx context.Context, db *sql.DB, dbURL string, migrate bool) error {
+	migrator, err := NewMigrator(db, dbURL)
+	if err != nil {
+		return err
+	}
+
+	if migrate {
+		_, err := migrator.Up(ctx)
+		return err
+	}
+
+	current, target, err := migrator.GetVersions(ctx)
+	if err != nil {
+		return err
+	}
+	if current < target {
+		return fmt.Errorf("%w: at version %d, want %d; run `chirpy migrate up` or start with -migrate", ErrPendingMigrations, current, target)
+	}
+	return nil
+}
+
+// RunMigrateCommand implements `chirpy migrate up|down|status`.
+func RunMigrateCommand(ctx context.Context, w io.Writer, db *sql.DB, dbURL string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: chirpy migrate up|down|status")
+	}
+
+	migrator, err := NewMigrator(db, dbURL)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := migrator.Up(ctx)
+		for _, r := range results {
+			fmt.Fprintf(w, "applied %s in %s\n", r.Source.Path, r.Duration.Round(time.Millisecond))
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(w, "no migrations to apply")
+		}
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "rolled back %s in %s\n", result.Source.Path, result.Duration.Round(time.Millisecond))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.State == goose.StateApplied {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%-25s %s\n", applied, s.Source.Path)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", args[0])
+	}
+	return nil
+}
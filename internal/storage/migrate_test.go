@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/enderbd/chirpy/internal/store/sqlite"
+	sqlfiles "github.com/enderbd/chirpy/sql"
+	_ "github.com/lib/pq"
+)
+
+// schemaSnapshot lists every table, column and index, excluding goose's
+// bookkeeping, in a stable order.
+type schemaSnapshot func(t *testing.T, db *sql.DB) []string
+
+func sqliteSchema(t *testing.T, db *sql.DB) []string {
+	return querySchema(t, db, `
+		SELECT type || ' ' || name || ' ' || COALESCE(sql, '') FROM sqlite_master
+		WHERE name != 'goose_db_version' AND name NOT LIKE 'sqlite_%'
+		ORDER BY type, name`)
+}
+
+func postgresSchema(t *testing.T, db *sql.DB) []string {
+	return querySchema(t, db, `
+		SELECT table_name || '.' || column_name || ' ' || data_type || ' ' || is_nullable || ' ' || COALESCE(column_default, '')
+		FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name != 'goose_db_version'
+		UNION ALL
+		SELECT indexdef FROM pg_indexes
+		WHERE schemaname = 'public' AND tablename != 'goose_db_version'
+		UNION ALL
+		SELECT 'trigger ' || trigger_name FROM information_schema.triggers
+		WHERE trigger_schema = 'public'
+		ORDER BY 1`)
+}
+
+func querySchema(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var schema []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatal(err)
+		}
+		schema = append(schema, line)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// testMigrationsRoundTrip applies the migrations one at a time and checks
+// that rolling each back restores the schema it started from, and that it
+// can then be applied again.
+func testMigrationsRoundTrip(t *testing.T, db *sql.DB, dbURL string, snapshot schemaSnapshot) {
+	ctx := context.Background()
+	migrator, err := NewMigrator(db, dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.DownTo(ctx, 0); err != nil {
+		t.Fatalf("resetting: %s", err)
+	}
+
+	for _, source := range migrator.ListSources() {
+		before := snapshot(t, db)
+		if _, err := migrator.UpByOne(ctx); err != nil {
+			t.Fatalf("up %s: %s", source.Path, err)
+		}
+		after := snapshot(t, db)
+
+		if _, err := migrator.Down(ctx); err != nil {
+			t.Fatalf("down %s: %s", source.Path, err)
+		}
+		if got := snapshot(t, db); !reflect.DeepEqual(got, before) {
+			t.Errorf("down %s did not restore the schema:\ngot  %v\nwant %v", source.Path, got, before)
+		}
+
+		if _, err := migrator.UpByOne(ctx); err != nil {
+			t.Fatalf("re-applying %s: %s", source.Path, err)
+		}
+		if got := snapshot(t, db); !reflect.DeepEqual(got, after) {
+			t.Errorf("re-applying %s gave a different schema:\ngot  %v\nwant %v", source.Path, got, after)
+		}
+	}
+}
+
+func TestSQLiteMigrationsRoundTrip(t *testing.T) {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testMigrationsRoundTrip(t, db, "sqlite::memory:", sqliteSchema)
+}
+
+// TestPostgresMigrationsRoundTrip needs a scratch database named by
+// CHIRPY_TEST_DB_URL. It rolls every migration back.
+func TestPostgresMigrationsRoundTrip(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testMigrationsRoundTrip(t, db, dbURL, postgresSchema)
+}
+
+func TestPrepareSchema(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	const dbURL = "sqlite::memory:"
+
+	err = Prepare(ctx, db, dbURL, false)
+	if !errors.Is(err, ErrPendingMigrations) {
+		t.Fatalf("Prepare on an empty database: err = %v, want ErrPendingMigrations", err)
+	}
+	if err := Prepare(ctx, db, dbURL, true); err != nil {
+		t.Fatalf("Prepare with migrate: %s", err)
+	}
+	if err := Prepare(ctx, db, dbURL, false); err != nil {
+		t.Errorf("Prepare after migrating: %s", err)
+	}
+}
+
+func TestMigrateCommand(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	const dbURL = "sqlite::memory:"
+
+	var out bytes.Buffer
+	if err := RunMigrateCommand(ctx, &out, db, dbURL, []string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "pending") {
+		t.Errorf("status before up:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := RunMigrateCommand(ctx, &out, db, dbURL, []string{"up"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "applied 001_initial.sql") {
+		t.Errorf("up:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := RunMigrateCommand(ctx, &out, db, dbURL, []string{"down"}); err != nil {
+		t.Fatal(err)
+	}
+	// down rolls back only the newest migration.
+	files, err := fs.Glob(sqlfiles.Migrations, "sqlite/schema/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("listing migrations: %v", err)
+	}
+	if !strings.Contains(out.String(), "rolled back "+path.Base(files[len(files)-1])) {
+		t.Errorf("down:\n%s", out.String())
+	}
+
+	if err := RunMigrateCommand(ctx, &out, db, dbURL, []string{"sideways"}); err == nil {
+		t.Error("unknown subcommand succeeded")
+	}
+}
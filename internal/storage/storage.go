@@ -0,0 +1,45 @@
+// Package storage opens Chirpy's database and keeps its schema up to date,
+// for the server and chirpyctl alike.
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/enderbd/chirpy/internal/store/sqlite"
+	"github.com/enderbd/chirpy/internal/tracing"
+)
+
+// SQLitePath reports whether dbURL selects the SQLite backend and returns
+// the database file. Both sqlite://chirpy.db and sqlite:chirpy.db are
+// accepted; use sqlite:///abs/path for absolute paths.
+func SQLitePath(dbURL string) (string, bool) {
+	if path, ok := strings.CutPrefix(dbURL, "sqlite://"); ok {
+		return path, true
+	}
+	return strings.CutPrefix(dbURL, "sqlite:")
+}
+
+// Open opens the backend selected by the DB_URL scheme. Anything that
+// isn't sqlite: is handed to the Postgres driver, which the caller must
+// register. Queries are logged with the logger of their context and
+// traced, see logging.DB and tracing.DB.
+func Open(dbURL string, slowQuery time.Duration) (store.Store, *sql.DB, error) {
+	if path, ok := SQLitePath(dbURL); ok {
+		db, err := sqlite.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sqlite.New(logging.NewDB(tracing.NewDB(db, "sqlite"), slowQuery)), db, nil
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return database.New(logging.NewDB(tracing.NewDB(db, "postgresql"), slowQuery)), db, nil
+}
@@ -0,0 +1,24 @@
+package storage
+
+import "testing"
+
+func TestSQLitePath(t *testing.T) {
+	tests := []struct {
+		url    string
+		path   string
+		sqlite bool
+	}{
+		{url: "sqlite://chirpy.db", path: "chirpy.db", sqlite: true},
+		{url: "sqlite:chirpy.db", path: "chirpy.db", sqlite: true},
+		{url: "sqlite:///var/lib/chirpy.db", path: "/var/lib/chirpy.db", sqlite: true},
+		{url: "sqlite::memory:", path: ":memory:", sqlite: true},
+		{url: "postgres://chirpy@localhost/chirpy", sqlite: false},
+	}
+
+	for _, tt := range tests {
+		path, ok := SQLitePath(tt.url)
+		if ok != tt.sqlite || (ok && path != tt.path) {
+			t.Errorf("SQLitePath(%q) = %q, %v; want %q, %v", tt.url, path, ok, tt.path, tt.sqlite)
+		}
+	}
+}
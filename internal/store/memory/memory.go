@@ -0,0 +1,1645 @@
+// Package memory is an in-process store.Store. It mirrors the constraints of
+// the Postgres schema (unique keys, cascading deletes) closely enough that
+// handlers behave the same against it, which makes it suitable for tests and
+// throwaway local runs. Nothing is persisted.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// ErrUniqueViolation is returned when a write would break a unique key.
+var ErrUniqueViolation = errors.New("memory: unique constraint violated")
+
+// ErrForeignKeyViolation is returned when a row references one that does
+// not exist.
+var ErrForeignKeyViolation = errors.New("memory: foreign key constraint violated")
+
+type followKey struct{ follower, followee uuid.UUID }
+
+type likeKey struct{ user, chirp uuid.UUID }
+
+type prefKey struct {
+	user uuid.UUID
+	kind string
+}
+
+type remoteFollowerKey struct {
+	user  uuid.UUID
+	actor string
+}
+
+type Store struct {
+	mu sync.Mutex
+
+	// Now returns the time recorded on new rows. Tests may replace it.
+	Now func() time.Time
+
+	users         map[uuid.UUID]database.User
+	chirps        []database.Chirp
+	tokens        map[string]database.RefreshToken
+	follows       map[followKey]database.Follow
+	blocks        []database.Block
+	mutes         []database.Mute
+	likes         map[likeKey]database.Like
+	notifications []database.Notification
+	prefs         map[prefKey]database.NotificationPreference
+
+	events      []database.ChirpEvent
+	actorKeys   map[uuid.UUID]database.ActorKey
+	remoteFolls map[remoteFollowerKey]database.RemoteFollower
+	deliveries  []database.ApDelivery
+	modRules    []database.ModerationRule
+	reports     []database.ChirpReport
+	exports     []database.DataExport
+	lastEventID int64
+	lastDelivID int64
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		Now:         func() time.Time { return time.Now().UTC() },
+		users:       make(map[uuid.UUID]database.User),
+		tokens:      make(map[string]database.RefreshToken),
+		follows:     make(map[followKey]database.Follow),
+		likes:       make(map[likeKey]database.Like),
+		prefs:       make(map[prefKey]database.NotificationPreference),
+		actorKeys:   make(map[uuid.UUID]database.ActorKey),
+		remoteFolls: make(map[remoteFollowerKey]database.RemoteFollower),
+	}
+}
+
+// Users
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == arg.Email || (arg.Username.Valid && u.Username == arg.Username) {
+			return database.User{}, ErrUniqueViolation
+		}
+	}
+
+	now := s.Now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Username:       arg.Username,
+		Role:           "user",
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *Store) DeleteUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.users {
+		s.deleteUser(id)
+	}
+	return nil
+}
+
+// deleteUser removes a user and everything that cascades from it.
+func (s *Store) deleteUser(id uuid.UUID) {
+	delete(s.users, id)
+
+	for _, c := range append([]database.Chirp(nil), s.chirps...) {
+		if c.UserID == id {
+			s.deleteChirp(c.ID)
+		}
+	}
+	for k, t := range s.tokens {
+		if t.UserID == id {
+			delete(s.tokens, k)
+		}
+	}
+	for k := range s.follows {
+		if k.follower == id || k.followee == id {
+			delete(s.follows, k)
+		}
+	}
+	s.blocks = filter(s.blocks, func(b database.Block) bool { return b.BlockerID != id && b.BlockedID != id })
+	s.mutes = filter(s.mutes, func(m database.Mute) bool { return m.MuterID != id && m.MutedID != id })
+	for k := range s.likes {
+		if k.user == id {
+			delete(s.likes, k)
+		}
+	}
+	s.notifications = filter(s.notifications, func(n database.Notification) bool {
+		return n.UserID != id && n.ActorID != id
+	})
+	for k := range s.prefs {
+		if k.user == id {
+			delete(s.prefs, k)
+		}
+	}
+	s.events = filter(s.events, func(e database.ChirpEvent) bool { return e.UserID != id })
+	delete(s.actorKeys, id)
+	for k := range s.remoteFolls {
+		if k.user == id {
+			delete(s.remoteFolls, k)
+		}
+	}
+	s.deliveries = filter(s.deliveries, func(d database.ApDelivery) bool { return d.UserID != id })
+	s.reports = filter(s.reports, func(r database.ChirpReport) bool { return r.ReporterID != id })
+	s.exports = filter(s.exports, func(e database.DataExport) bool { return e.UserID != id })
+	for i, r := range s.reports {
+		if r.ResolvedBy.Valid && r.ResolvedBy.UUID == id {
+			s.reports[i].ResolvedBy = uuid.NullUUID{}
+		}
+	}
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username sql.NullString) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !username.Valid {
+		return database.User{}, sql.ErrNoRows
+	}
+	for _, u := range s.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return database.User{}, sql.ErrNoRows
+}
+
+func (s *Store) GetUsersByUsernames(ctx context.Context, usernames []string) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.User
+	for _, u := range s.users {
+		for _, name := range usernames {
+			if u.Username.Valid && u.Username.String == name {
+				items = append(items, u)
+				break
+			}
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) SetUsername(ctx context.Context, arg database.SetUsernameParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.ID != arg.ID && arg.Username.Valid && u.Username == arg.Username {
+			return ErrUniqueViolation
+		}
+	}
+	u, ok := s.users[arg.ID]
+	if !ok {
+		return nil
+	}
+	u.Username = arg.Username
+	u.UpdatedAt = s.Now()
+	s.users[arg.ID] = u
+	return nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.UpdateUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[arg.ID]
+	if !ok {
+		return database.UpdateUserRow{}, sql.ErrNoRows
+	}
+	for _, other := range s.users {
+		if other.ID != arg.ID && (other.Email == arg.Email || arg.Username.Valid && other.Username == arg.Username) {
+			return database.UpdateUserRow{}, ErrUniqueViolation
+		}
+	}
+	u.Email = arg.Email
+	u.HashedPassword = arg.HashedPassword
+	if arg.Username.Valid {
+		u.Username = arg.Username
+	}
+	u.UpdatedAt = s.Now()
+	s.users[arg.ID] = u
+	return database.UpdateUserRow{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Email:       u.Email,
+		IsChirpyRed: u.IsChirpyRed,
+		Username:    u.Username,
+	}, nil
+}
+
+func (s *Store) DowngradeRed(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[id]; ok {
+		u.IsChirpyRed = false
+		s.users[id] = u
+	}
+	return nil
+}
+
+func (s *Store) RevokeAccessTokens(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[id]; ok {
+		now := s.Now()
+		u.TokensRevokedAt = sql.NullTime{Time: now, Valid: true}
+		u.UpdatedAt = now
+		s.users[id] = u
+	}
+	return nil
+}
+
+// likeUnescape undoes the escaping SearchUsers callers apply to % and _.
+var likeUnescape = strings.NewReplacer(`\\`, `\`, `\%`, `%`, `\_`, `_`)
+
+// SearchUsers matches like the case-insensitive LIKE in the query, which
+// for an escaped pattern is a substring match.
+func (s *Store) SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := strings.ToLower(likeUnescape.Replace(arg.Query))
+	var items []database.User
+	for _, u := range s.users {
+		if arg.Role != "" && u.Role != arg.Role {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(u.Email), query) && !strings.Contains(strings.ToLower(u.Username.String), query) {
+			continue
+		}
+		items = append(items, u)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return bytes.Compare(items[i].ID[:], items[j].ID[:]) < 0
+	})
+	start := min(int(arg.PageOffset), len(items))
+	end := min(start+int(arg.PageSize), len(items))
+	return items[start:end], nil
+}
+
+// ImportUser inserts arg as is, or replaces the user with its ID.
+func (s *Store) ImportUser(ctx context.Context, arg database.ImportUserParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.ID != arg.ID && (u.Email == arg.Email || (arg.Username.Valid && u.Username == arg.Username)) {
+			return ErrUniqueViolation
+		}
+	}
+	u := s.users[arg.ID]
+	u.ID = arg.ID
+	u.CreatedAt = arg.CreatedAt
+	u.UpdatedAt = arg.UpdatedAt
+	u.Email = arg.Email
+	u.HashedPassword = arg.HashedPassword
+	u.IsChirpyRed = arg.IsChirpyRed
+	u.Username = arg.Username
+	u.Role = arg.Role
+	u.SuspendedUntil = arg.SuspendedUntil
+	u.BanReason = arg.BanReason
+	u.DeletedAt = arg.DeletedAt
+	s.users[arg.ID] = u
+	return nil
+}
+
+func (s *Store) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[arg.ID]
+	if !ok {
+		return 0, nil
+	}
+	u.Role = arg.Role
+	u.UpdatedAt = s.Now()
+	s.users[arg.ID] = u
+	return 1, nil
+}
+
+func (s *Store) SuspendUser(ctx context.Context, arg database.SuspendUserParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[arg.ID]; ok {
+		u.SuspendedUntil = arg.SuspendedUntil
+		u.BanReason = arg.BanReason
+		u.UpdatedAt = s.Now()
+		s.users[arg.ID] = u
+	}
+	return nil
+}
+
+func (s *Store) SetUserDeleted(ctx context.Context, arg database.SetUserDeletedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[arg.ID]; ok {
+		u.DeletedAt = arg.DeletedAt
+		u.UpdatedAt = s.Now()
+		s.users[arg.ID] = u
+	}
+	return nil
+}
+
+func (s *Store) PurgeDeletedUsers(ctx context.Context, deletedBefore sql.NullTime) ([]uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []uuid.UUID
+	for id, u := range s.users {
+		if u.DeletedAt.Valid && deletedBefore.Valid && u.DeletedAt.Time.Before(deletedBefore.Time) {
+			s.deleteUser(id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *Store) UpgradeRed(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[id]; ok {
+		u.IsChirpyRed = true
+		s.users[id] = u
+	}
+	return nil
+}
+
+// Chirps
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.Chirp{}, ErrForeignKeyViolation
+	}
+	if arg.ReplyToID.Valid {
+		if _, ok := s.chirpIndex(arg.ReplyToID.UUID); !ok {
+			return database.Chirp{}, ErrForeignKeyViolation
+		}
+	}
+
+	now := s.Now()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		ReplyToID: arg.ReplyToID,
+		Status:    arg.Status,
+		PublishAt: arg.PublishAt,
+	}
+	s.chirps = append(s.chirps, chirp)
+	return chirp, nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteChirp(id)
+	return nil
+}
+
+func (s *Store) deleteChirp(id uuid.UUID) {
+	s.chirps = filter(s.chirps, func(c database.Chirp) bool { return c.ID != id })
+	for i, c := range s.chirps {
+		if c.ReplyToID.Valid && c.ReplyToID.UUID == id {
+			s.chirps[i].ReplyToID = uuid.NullUUID{}
+		}
+	}
+	for k := range s.likes {
+		if k.chirp == id {
+			delete(s.likes, k)
+		}
+	}
+	s.notifications = filter(s.notifications, func(n database.Notification) bool {
+		return !n.ChirpID.Valid || n.ChirpID.UUID != id
+	})
+	s.reports = filter(s.reports, func(r database.ChirpReport) bool { return r.ChirpID != id })
+}
+
+func (s *Store) chirpIndex(id uuid.UUID) (int, bool) {
+	for i, c := range s.chirps {
+		if c.ID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Store) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := append([]database.Chirp(nil), s.chirps...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return timelineTime(items[i]).Before(timelineTime(items[j]))
+	})
+	return items, nil
+}
+
+// timelineTime is COALESCE(publish_at, created_at).
+func timelineTime(c database.Chirp) time.Time {
+	if c.PublishAt.Valid {
+		return c.PublishAt.Time
+	}
+	return c.CreatedAt
+}
+
+func (s *Store) ListChirpsPage(ctx context.Context, arg database.ListChirpsPageParams) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := append([]database.Chirp(nil), s.chirps...)
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return bytes.Compare(items[i].ID[:], items[j].ID[:]) < 0
+	})
+	start := min(int(arg.PageOffset), len(items))
+	end := min(start+int(arg.PageSize), len(items))
+	return items[start:end], nil
+}
+
+// ImportChirp inserts arg as is, or replaces the chirp with its ID.
+func (s *Store) ImportChirp(ctx context.Context, arg database.ImportChirpParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	if arg.ReplyToID.Valid {
+		if _, ok := s.chirpIndex(arg.ReplyToID.UUID); !ok {
+			return ErrForeignKeyViolation
+		}
+	}
+
+	chirp := database.Chirp{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		ReplyToID: arg.ReplyToID,
+		Status:    arg.Status,
+		PublishAt: arg.PublishAt,
+	}
+	if i, ok := s.chirpIndex(arg.ID); ok {
+		s.chirps[i] = chirp
+		return nil
+	}
+	s.chirps = append(s.chirps, chirp)
+	return nil
+}
+
+func (s *Store) GetChirpsByStatus(ctx context.Context, status string) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := filter(s.chirps, func(c database.Chirp) bool { return c.Status == status })
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items, nil
+}
+
+func (s *Store) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return filter(s.chirps, func(c database.Chirp) bool { return c.UserID == userID }), nil
+}
+
+func (s *Store) GetSingleChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.chirpIndex(id)
+	if !ok {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return s.chirps[i], nil
+}
+
+func (s *Store) SetChirpStatus(ctx context.Context, arg database.SetChirpStatusParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i, ok := s.chirpIndex(arg.ID); ok {
+		s.chirps[i].Status = arg.Status
+		s.chirps[i].UpdatedAt = s.Now()
+	}
+	return nil
+}
+
+func (s *Store) SetUserChirpsStatus(ctx context.Context, arg database.SetUserChirpsStatusParams) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.Chirp
+	for i, c := range s.chirps {
+		if c.UserID == arg.UserID && c.Status == arg.FromStatus {
+			s.chirps[i].Status = arg.ToStatus
+			s.chirps[i].UpdatedAt = s.Now()
+			items = append(items, s.chirps[i])
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := filter(s.chirps, func(c database.Chirp) bool { return c.UserID == userID && c.Status == "scheduled" })
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PublishAt.Time.Before(items[j].PublishAt.Time)
+	})
+	return items, nil
+}
+
+func (s *Store) UpdateScheduledChirp(ctx context.Context, arg database.UpdateScheduledChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.chirpIndex(arg.ID)
+	if !ok || s.chirps[i].Status != "scheduled" {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	s.chirps[i].Body = arg.Body
+	s.chirps[i].PublishAt = arg.PublishAt
+	s.chirps[i].Status = arg.Status
+	s.chirps[i].UpdatedAt = s.Now()
+	return s.chirps[i], nil
+}
+
+func (s *Store) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.chirpIndex(arg.ID)
+	if !ok || s.chirps[i].UserID != arg.UserID || s.chirps[i].Status != "scheduled" {
+		return 0, nil
+	}
+	s.deleteChirp(arg.ID)
+	return 1, nil
+}
+
+// PublishDueChirps publishes up to arg.Limit scheduled chirps whose time
+// has come, skipping those by suspended or deleted users.
+func (s *Store) PublishDueChirps(ctx context.Context, arg database.PublishDueChirpsParams) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []int
+	for i, c := range s.chirps {
+		if c.Status != "scheduled" || c.PublishAt.Time.After(arg.Now) {
+			continue
+		}
+		author := s.users[c.UserID]
+		if author.DeletedAt.Valid || (author.SuspendedUntil.Valid && author.SuspendedUntil.Time.After(arg.Now)) {
+			continue
+		}
+		due = append(due, i)
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return s.chirps[due[i]].PublishAt.Time.Before(s.chirps[due[j]].PublishAt.Time)
+	})
+
+	var items []database.Chirp
+	for _, i := range due[:min(len(due), int(arg.Limit))] {
+		s.chirps[i].Status = "published"
+		s.chirps[i].PublishAt = sql.NullTime{Time: arg.Now, Valid: true}
+		s.chirps[i].UpdatedAt = arg.Now
+		s.chirps[i].AnnounceAt = arg.AnnounceAt
+		items = append(items, s.chirps[i])
+	}
+	return items, nil
+}
+
+// ClaimUnannouncedChirps hands out up to arg.Limit chirps whose
+// announcement is due at arg.Now, moving it to arg.AnnounceAt.
+func (s *Store) ClaimUnannouncedChirps(ctx context.Context, arg database.ClaimUnannouncedChirpsParams) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []int
+	for i, c := range s.chirps {
+		if c.AnnounceAt.Valid && !c.AnnounceAt.Time.After(arg.Now) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return s.chirps[due[i]].AnnounceAt.Time.Before(s.chirps[due[j]].AnnounceAt.Time)
+	})
+
+	var items []database.Chirp
+	for _, i := range due[:min(len(due), int(arg.Limit))] {
+		s.chirps[i].AnnounceAt = arg.AnnounceAt
+		items = append(items, s.chirps[i])
+	}
+	return items, nil
+}
+
+// ApproveHeldChirp publishes a held chirp at arg.Now, owing its
+// announcement until arg.AnnounceAt, or schedules it again when its
+// publish_at is still ahead.
+func (s *Store) ApproveHeldChirp(ctx context.Context, arg database.ApproveHeldChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.chirpIndex(arg.ID)
+	if !ok || s.chirps[i].Status != "held" {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	c := &s.chirps[i]
+	if c.PublishAt.Valid && c.PublishAt.Time.After(arg.Now) {
+		c.Status = "scheduled"
+	} else {
+		c.Status = "published"
+		c.PublishAt = sql.NullTime{Time: arg.Now, Valid: true}
+		c.AnnounceAt = arg.AnnounceAt
+	}
+	c.UpdatedAt = arg.Now
+	return *c, nil
+}
+
+func (s *Store) DeleteHeldChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.chirpIndex(id)
+	if !ok || s.chirps[i].Status != "held" {
+		return 0, nil
+	}
+	s.deleteChirp(id)
+	return 1, nil
+}
+
+func (s *Store) FinishChirpAnnouncement(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i, ok := s.chirpIndex(id); ok {
+		s.chirps[i].AnnounceAt = sql.NullTime{}
+	}
+	return nil
+}
+
+// Moderation rules
+
+func (s *Store) CreateModerationRule(ctx context.Context, arg database.CreateModerationRuleParams) (database.ModerationRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.modRules {
+		if r.Term == arg.Term {
+			return database.ModerationRule{}, ErrUniqueViolation
+		}
+	}
+	rule := database.ModerationRule{
+		ID:        uuid.New(),
+		CreatedAt: s.Now(),
+		Term:      arg.Term,
+		Action:    arg.Action,
+	}
+	s.modRules = append(s.modRules, rule)
+	return rule, nil
+}
+
+func (s *Store) DeleteModerationRule(ctx context.Context, id uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.modRules)
+	s.modRules = filter(s.modRules, func(r database.ModerationRule) bool { return r.ID != id })
+	return int64(before - len(s.modRules)), nil
+}
+
+func (s *Store) ListModerationRules(ctx context.Context) ([]database.ModerationRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := append([]database.ModerationRule(nil), s.modRules...)
+	sort.Slice(items, func(i, j int) bool { return items[i].Term < items[j].Term })
+	return items, nil
+}
+
+// Reports
+
+// CreateChirpReport returns sql.ErrNoRows when the user has already
+// reported the chirp, as the ON CONFLICT DO NOTHING query does.
+func (s *Store) CreateChirpReport(ctx context.Context, arg database.CreateChirpReportParams) (database.ChirpReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.ReporterID]; !ok {
+		return database.ChirpReport{}, ErrForeignKeyViolation
+	}
+	if _, ok := s.chirpIndex(arg.ChirpID); !ok {
+		return database.ChirpReport{}, ErrForeignKeyViolation
+	}
+	for _, r := range s.reports {
+		if r.ChirpID == arg.ChirpID && r.ReporterID == arg.ReporterID {
+			return database.ChirpReport{}, sql.ErrNoRows
+		}
+	}
+
+	report := database.ChirpReport{
+		ID:         uuid.New(),
+		CreatedAt:  s.Now(),
+		ChirpID:    arg.ChirpID,
+		ReporterID: arg.ReporterID,
+		Reason:     arg.Reason,
+		Details:    arg.Details,
+	}
+	s.reports = append(s.reports, report)
+	return report, nil
+}
+
+func (s *Store) ListOpenChirpReports(ctx context.Context) ([]database.ChirpReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := filter(s.reports, func(r database.ChirpReport) bool { return !r.ResolvedAt.Valid })
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items, nil
+}
+
+func (s *Store) ListUserChirpReports(ctx context.Context, reporterID uuid.UUID) ([]database.ChirpReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := filter(s.reports, func(r database.ChirpReport) bool { return r.ReporterID == reporterID })
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items, nil
+}
+
+func (s *Store) ResolveChirpReports(ctx context.Context, arg database.ResolveChirpReportsParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	now := s.Now()
+	for i, r := range s.reports {
+		if r.ChirpID == arg.ChirpID && !r.ResolvedAt.Valid {
+			s.reports[i].ResolvedAt = sql.NullTime{Time: now, Valid: true}
+			s.reports[i].ResolvedBy = arg.ResolvedBy
+			s.reports[i].Resolution = arg.Resolution
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Refresh tokens
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[arg.Token]; ok {
+		return database.RefreshToken{}, ErrUniqueViolation
+	}
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.RefreshToken{}, ErrForeignKeyViolation
+	}
+
+	now := s.Now()
+	token := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	s.tokens[token.Token] = token
+	return token, nil
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[token]
+	if !ok || t.RevokedAt.Valid || !t.ExpiresAt.After(s.Now()) {
+		return database.User{}, sql.ErrNoRows
+	}
+	u, ok := s.users[t.UserID]
+	if !ok {
+		return database.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (s *Store) DeleteExpiredRefreshTokens(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for k, t := range s.tokens {
+		if t.ExpiresAt.Before(expiredBefore) {
+			delete(s.tokens, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.RefreshToken
+	for _, t := range s.tokens {
+		if t.UserID == userID {
+			items = append(items, t)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+	return items, nil
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.tokens[token]; ok {
+		now := s.Now()
+		t.RevokedAt = sql.NullTime{Time: now, Valid: true}
+		t.UpdatedAt = now
+		s.tokens[token] = t
+	}
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	now := s.Now()
+	for k, t := range s.tokens {
+		if t.UserID == userID && !t.RevokedAt.Valid {
+			t.RevokedAt = sql.NullTime{Time: now, Valid: true}
+			t.UpdatedAt = now
+			s.tokens[k] = t
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Follows
+
+func (s *Store) FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, okFollower := s.users[arg.FollowerID]
+	_, okFollowee := s.users[arg.FolloweeID]
+	if !okFollower || !okFollowee {
+		return 0, ErrForeignKeyViolation
+	}
+	k := followKey{arg.FollowerID, arg.FolloweeID}
+	if _, ok := s.follows[k]; ok {
+		return 0, nil
+	}
+	s.follows[k] = database.Follow{FollowerID: arg.FollowerID, FolloweeID: arg.FolloweeID, CreatedAt: s.Now()}
+	return 1, nil
+}
+
+func (s *Store) GetFolloweeIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []uuid.UUID
+	for k := range s.follows {
+		if k.follower == followerID {
+			items = append(items, k.followee)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.follows, followKey{arg.FollowerID, arg.FolloweeID})
+	return nil
+}
+
+func (s *Store) ListUserFollows(ctx context.Context, userID uuid.UUID) ([]database.Follow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.Follow
+	for k, f := range s.follows {
+		if k.follower == userID || k.followee == userID {
+			items = append(items, f)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+	return items, nil
+}
+
+// Blocks
+
+func (s *Store) BlockUser(ctx context.Context, arg database.BlockUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range s.blocks {
+		if b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID {
+			return 0, nil
+		}
+	}
+	s.blocks = append(s.blocks, database.Block{BlockerID: arg.BlockerID, BlockedID: arg.BlockedID, CreatedAt: s.Now()})
+	return 1, nil
+}
+
+func (s *Store) CountBlocksBetween(ctx context.Context, arg database.CountBlocksBetweenParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, b := range s.blocks {
+		if (b.BlockerID == arg.UserID && b.BlockedID == arg.OtherID) || (b.BlockerID == arg.OtherID && b.BlockedID == arg.UserID) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) GetBlockedEitherWayIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []uuid.UUID
+	for _, b := range s.blocks {
+		var other uuid.UUID
+		switch userID {
+		case b.BlockerID:
+			other = b.BlockedID
+		case b.BlockedID:
+			other = b.BlockerID
+		default:
+			continue
+		}
+		if !slices.Contains(items, other) {
+			items = append(items, other)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.Block
+	for _, b := range s.blocks {
+		if b.BlockerID == blockerID {
+			items = append(items, b)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.blocks)
+	s.blocks = filter(s.blocks, func(b database.Block) bool {
+		return b.BlockerID != arg.BlockerID || b.BlockedID != arg.BlockedID
+	})
+	return int64(before - len(s.blocks)), nil
+}
+
+// Mutes
+
+func (s *Store) GetMutedUserIDs(ctx context.Context, muterID uuid.UUID) ([]uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []uuid.UUID
+	for _, m := range s.mutes {
+		if m.MuterID == muterID {
+			items = append(items, m.MutedID)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) ListMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.Mute
+	for _, m := range s.mutes {
+		if m.MuterID == muterID {
+			items = append(items, m)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) MuteUser(ctx context.Context, arg database.MuteUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.mutes {
+		if m.MuterID == arg.MuterID && m.MutedID == arg.MutedID {
+			return 0, nil
+		}
+	}
+	s.mutes = append(s.mutes, database.Mute{MuterID: arg.MuterID, MutedID: arg.MutedID, CreatedAt: s.Now()})
+	return 1, nil
+}
+
+func (s *Store) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.mutes)
+	s.mutes = filter(s.mutes, func(m database.Mute) bool {
+		return m.MuterID != arg.MuterID || m.MutedID != arg.MutedID
+	})
+	return int64(before - len(s.mutes)), nil
+}
+
+// Likes
+
+func (s *Store) LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return 0, ErrForeignKeyViolation
+	}
+	if _, ok := s.chirpIndex(arg.ChirpID); !ok {
+		return 0, ErrForeignKeyViolation
+	}
+	k := likeKey{arg.UserID, arg.ChirpID}
+	if _, ok := s.likes[k]; ok {
+		return 0, nil
+	}
+	s.likes[k] = database.Like{UserID: arg.UserID, ChirpID: arg.ChirpID, CreatedAt: s.Now()}
+	return 1, nil
+}
+
+func (s *Store) ListUserLikes(ctx context.Context, userID uuid.UUID) ([]database.Like, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.Like
+	for k, l := range s.likes {
+		if k.user == userID {
+			items = append(items, l)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+	return items, nil
+}
+
+func (s *Store) UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.likes, likeKey{arg.UserID, arg.ChirpID})
+	return nil
+}
+
+// Notifications
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, n := range s.notifications {
+		if n.UserID == userID && !n.ReadAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, okUser := s.users[arg.UserID]
+	_, okActor := s.users[arg.ActorID]
+	if !okUser || !okActor {
+		return database.Notification{}, ErrForeignKeyViolation
+	}
+	if arg.ChirpID.Valid {
+		if _, ok := s.chirpIndex(arg.ChirpID.UUID); !ok {
+			return database.Notification{}, ErrForeignKeyViolation
+		}
+	}
+
+	n := database.Notification{
+		ID:        uuid.New(),
+		CreatedAt: s.Now(),
+		UserID:    arg.UserID,
+		ActorID:   arg.ActorID,
+		Kind:      arg.Kind,
+		ChirpID:   arg.ChirpID,
+	}
+	s.notifications = append(s.notifications, n)
+	return n, nil
+}
+
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.NotificationPreference
+	for k, p := range s.prefs {
+		if k.user == userID {
+			items = append(items, p)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) ListNotifications(ctx context.Context, arg database.ListNotificationsParams) ([]database.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := filter(s.notifications, func(n database.Notification) bool {
+		if n.UserID != arg.UserID || (arg.UnreadOnly && n.ReadAt.Valid) {
+			return false
+		}
+		return n.CreatedAt.Before(arg.Before) ||
+			(n.CreatedAt.Equal(arg.Before) && bytes.Compare(n.ID[:], arg.BeforeID[:]) < 0)
+	})
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.After(items[j].CreatedAt)
+		}
+		return bytes.Compare(items[i].ID[:], items[j].ID[:]) > 0
+	})
+	if len(items) > int(arg.PageSize) {
+		items = items[:arg.PageSize]
+	}
+	return items, nil
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	for i, n := range s.notifications {
+		if n.UserID == userID && !n.ReadAt.Valid {
+			s.notifications[i].ReadAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, n := range s.notifications {
+		if n.ID == arg.ID && n.UserID == arg.UserID {
+			if !n.ReadAt.Valid {
+				s.notifications[i].ReadAt = sql.NullTime{Time: s.Now(), Valid: true}
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *Store) UpsertNotificationPreference(ctx context.Context, arg database.UpsertNotificationPreferenceParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	s.prefs[prefKey{arg.UserID, arg.Kind}] = database.NotificationPreference{
+		UserID: arg.UserID,
+		Kind:   arg.Kind,
+		InApp:  arg.InApp,
+		Email:  arg.Email,
+	}
+	return nil
+}
+
+// Chirp events
+
+func (s *Store) CreateChirpEvent(ctx context.Context, arg database.CreateChirpEventParams) (database.ChirpEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return database.ChirpEvent{}, ErrForeignKeyViolation
+	}
+	s.lastEventID++
+	event := database.ChirpEvent{
+		ID:        s.lastEventID,
+		CreatedAt: s.Now(),
+		Kind:      arg.Kind,
+		ChirpID:   arg.ChirpID,
+		UserID:    arg.UserID,
+		Body:      arg.Body,
+	}
+	s.events = append(s.events, event)
+	return event, nil
+}
+
+func (s *Store) GetChirpEvent(ctx context.Context, id int64) (database.ChirpEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.events {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return database.ChirpEvent{}, sql.ErrNoRows
+}
+
+func (s *Store) GetChirpEventsAfter(ctx context.Context, arg database.GetChirpEventsAfterParams) ([]database.ChirpEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []database.ChirpEvent
+	for _, e := range s.events {
+		if len(items) == int(arg.Limit) {
+			break
+		}
+		if e.ID > arg.ID {
+			items = append(items, e)
+		}
+	}
+	return items, nil
+}
+
+// Federation
+
+func (s *Store) AddRemoteFollower(ctx context.Context, arg database.AddRemoteFollowerParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	k := remoteFollowerKey{arg.UserID, arg.ActorID}
+	f, ok := s.remoteFolls[k]
+	if !ok {
+		f = database.RemoteFollower{UserID: arg.UserID, ActorID: arg.ActorID, CreatedAt: s.Now()}
+	}
+	f.Inbox = arg.Inbox
+	s.remoteFolls[k] = f
+	return nil
+}
+
+func (s *Store) ClaimDeliveries(ctx context.Context, arg database.ClaimDeliveriesParams) ([]database.ApDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	var items []database.ApDelivery
+	for i, d := range s.deliveries {
+		if len(items) == int(arg.Limit) {
+			break
+		}
+		if d.NextAttemptAt.After(now) || d.Attempts >= arg.Attempts {
+			continue
+		}
+		d.Attempts++
+		d.NextAttemptAt = now.Add(5 * time.Minute)
+		s.deliveries[i] = d
+		items = append(items, d)
+	}
+	return items, nil
+}
+
+func (s *Store) CountRemoteFollowers(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for k := range s.remoteFolls {
+		if k.user == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) CreateActorKey(ctx context.Context, arg database.CreateActorKeyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	if _, ok := s.actorKeys[arg.UserID]; ok {
+		return nil
+	}
+	s.actorKeys[arg.UserID] = database.ActorKey{
+		UserID:        arg.UserID,
+		CreatedAt:     s.Now(),
+		PublicKeyPem:  arg.PublicKeyPem,
+		PrivateKeyPem: arg.PrivateKeyPem,
+	}
+	return nil
+}
+
+func (s *Store) DeleteDelivery(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries = filter(s.deliveries, func(d database.ApDelivery) bool { return d.ID != id })
+	return nil
+}
+
+func (s *Store) EnqueueDelivery(ctx context.Context, arg database.EnqueueDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	now := s.Now()
+	s.lastDelivID++
+	s.deliveries = append(s.deliveries, database.ApDelivery{
+		ID:            s.lastDelivID,
+		CreatedAt:     now,
+		UserID:        arg.UserID,
+		Inbox:         arg.Inbox,
+		Payload:       arg.Payload,
+		NextAttemptAt: now,
+	})
+	return nil
+}
+
+func (s *Store) GetActorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.actorKeys[userID]
+	if !ok {
+		return database.ActorKey{}, sql.ErrNoRows
+	}
+	return k, nil
+}
+
+func (s *Store) GetRemoteFollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := map[string]bool{}
+	var items []string
+	for k, f := range s.remoteFolls {
+		if k.user == userID && !seen[f.Inbox] {
+			seen[f.Inbox] = true
+			items = append(items, f.Inbox)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) RemoveRemoteActor(ctx context.Context, actorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.remoteFolls {
+		if k.actor == actorID {
+			delete(s.remoteFolls, k)
+		}
+	}
+	return nil
+}
+
+func (s *Store) RemoveRemoteFollower(ctx context.Context, arg database.RemoveRemoteFollowerParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.remoteFolls, remoteFollowerKey{arg.UserID, arg.ActorID})
+	return nil
+}
+
+func (s *Store) RescheduleDelivery(ctx context.Context, arg database.RescheduleDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, d := range s.deliveries {
+		if d.ID == arg.ID {
+			s.deliveries[i].NextAttemptAt = arg.NextAttemptAt
+			s.deliveries[i].LastError = arg.LastError
+		}
+	}
+	return nil
+}
+
+// Data exports
+
+func (s *Store) CreateDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return database.DataExport{}, ErrForeignKeyViolation
+	}
+	export := database.DataExport{ID: uuid.New(), CreatedAt: s.Now(), UserID: userID}
+	s.exports = append(s.exports, export)
+	return export, nil
+}
+
+func (s *Store) GetDataExport(ctx context.Context, id uuid.UUID) (database.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.exports {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return database.DataExport{}, sql.ErrNoRows
+}
+
+func (s *Store) GetPendingDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.exports {
+		if e.UserID == userID && !e.CompletedAt.Valid {
+			return e, nil
+		}
+	}
+	return database.DataExport{}, sql.ErrNoRows
+}
+
+func (s *Store) ClaimDataExports(ctx context.Context, limit int32) ([]database.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	var items []database.DataExport
+	for i, e := range s.exports {
+		if len(items) == int(limit) {
+			break
+		}
+		if e.CompletedAt.Valid || (e.ClaimedUntil.Valid && e.ClaimedUntil.Time.After(now)) {
+			continue
+		}
+		e.ClaimedUntil = sql.NullTime{Time: now.Add(5 * time.Minute), Valid: true}
+		s.exports[i] = e
+		items = append(items, e)
+	}
+	return items, nil
+}
+
+func (s *Store) CompleteDataExport(ctx context.Context, arg database.CompleteDataExportParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, e := range s.exports {
+		if e.ID == arg.ID {
+			s.exports[i].CompletedAt = sql.NullTime{Time: s.Now(), Valid: true}
+			s.exports[i].Archive = bytes.Clone(arg.Archive)
+			s.exports[i].Error = arg.Error
+		}
+	}
+	return nil
+}
+
+func (s *Store) DeleteDataExportsBefore(ctx context.Context, createdAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.exports = filter(s.exports, func(e database.DataExport) bool { return !e.CreatedAt.Before(createdAt) })
+	return nil
+}
+
+// filter returns the elements of items for which keep is true, in a new
+// slice so callers may hand it out without holding the lock.
+func filter[T any](items []T, keep func(T) bool) []T {
+	var out []T
+	for _, item := range items {
+		if keep(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Stats
+
+func (s *Store) GetStats(ctx context.Context, now time.Time) (database.GetStatsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := database.GetStatsRow{
+		Users:   int64(len(s.users)),
+		Chirps:  int64(len(s.chirps)),
+		Follows: int64(len(s.follows)),
+		Likes:   int64(len(s.likes)),
+	}
+	for _, u := range s.users {
+		if u.IsChirpyRed {
+			stats.RedUsers++
+		}
+		if u.SuspendedUntil.Valid && u.SuspendedUntil.Time.After(now) {
+			stats.SuspendedUsers++
+		}
+		if u.DeletedAt.Valid {
+			stats.DeletedUsers++
+		}
+	}
+	for _, t := range s.tokens {
+		if !t.RevokedAt.Valid && t.ExpiresAt.After(now) {
+			stats.ActiveSessions++
+		}
+	}
+	return stats, nil
+}
+
+func (s *Store) CountChirpsByStatus(ctx context.Context) ([]database.CountChirpsByStatusRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int64{}
+	for _, c := range s.chirps {
+		counts[c.Status]++
+	}
+	var items []database.CountChirpsByStatusRow
+	for status, n := range counts {
+		items = append(items, database.CountChirpsByStatusRow{Status: status, Count: n})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Status < items[j].Status })
+	return items, nil
+}
@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/enderbd/chirpy/internal/store/memory"
+	"github.com/enderbd/chirpy/internal/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return memory.New()
+	})
+}
@@ -0,0 +1,581 @@
+// Package sqlite is a store.Store backed by SQLite, for local development
+// and small self-hosted installs.
+//
+// The queries live in sql/sqlite and are generated into package sqlitedb.
+// Their rows and parameters have the same shape as the Postgres ones, so
+// this package only converts between the two.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/sqlitedb"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open opens the SQLite database at path, which may also be ":memory:".
+// Foreign keys are enforced so deletes cascade as they do in Postgres.
+func Open(path string) (*sql.DB, error) {
+	dsn := path
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	dsn += "_foreign_keys=on&_busy_timeout=5000"
+	if path != ":memory:" {
+		dsn += "&_journal_mode=WAL"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if path == ":memory:" {
+		// Every connection to :memory: is a separate database.
+		db.SetMaxOpenConns(1)
+	}
+	return db, nil
+}
+
+type Store struct {
+	q *sqlitedb.Queries
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New(db sqlitedb.DBTX) *Store {
+	return &Store{q: sqlitedb.New(db)}
+}
+
+func convert[S, D any](items []S, f func(S) D) []D {
+	if items == nil {
+		return nil
+	}
+	out := make([]D, len(items))
+	for i, item := range items {
+		out[i] = f(item)
+	}
+	return out
+}
+
+func chirp(c sqlitedb.Chirp) database.Chirp { return database.Chirp(c) }
+
+func user(u sqlitedb.User) database.User { return database.User(u) }
+
+func block(b sqlitedb.Block) database.Block { return database.Block(b) }
+
+func mute(m sqlitedb.Mute) database.Mute { return database.Mute(m) }
+
+func moderationRule(r sqlitedb.ModerationRule) database.ModerationRule {
+	return database.ModerationRule(r)
+}
+
+func chirpReport(r sqlitedb.ChirpReport) database.ChirpReport { return database.ChirpReport(r) }
+
+func refreshToken(t sqlitedb.RefreshToken) database.RefreshToken { return database.RefreshToken(t) }
+
+func follow(f sqlitedb.Follow) database.Follow { return database.Follow(f) }
+
+func like(l sqlitedb.Like) database.Like { return database.Like(l) }
+
+func dataExport(e sqlitedb.DataExport) database.DataExport { return database.DataExport(e) }
+
+func chirpStatusCount(r sqlitedb.CountChirpsByStatusRow) database.CountChirpsByStatusRow {
+	return database.CountChirpsByStatusRow(r)
+}
+
+func chirpEvent(e sqlitedb.ChirpEvent) database.ChirpEvent { return database.ChirpEvent(e) }
+
+func notification(n sqlitedb.Notification) database.Notification { return database.Notification(n) }
+
+func notificationPreference(p sqlitedb.NotificationPreference) database.NotificationPreference {
+	return database.NotificationPreference(p)
+}
+
+func apDelivery(d sqlitedb.ApDelivery) database.ApDelivery {
+	return database.ApDelivery{
+		ID:            d.ID,
+		CreatedAt:     d.CreatedAt,
+		UserID:        d.UserID,
+		Inbox:         d.Inbox,
+		Payload:       d.Payload,
+		Attempts:      int32(d.Attempts),
+		NextAttemptAt: d.NextAttemptAt,
+		LastError:     d.LastError,
+	}
+}
+
+// Chirps
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	c, err := s.q.CreateChirp(ctx, sqlitedb.CreateChirpParams(arg))
+	return chirp(c), err
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteChirp(ctx, id)
+}
+
+func (s *Store) DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error) {
+	return s.q.DeleteScheduledChirp(ctx, sqlitedb.DeleteScheduledChirpParams(arg))
+}
+
+func (s *Store) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	items, err := s.q.GetChirps(ctx)
+	return convert(items, chirp), err
+}
+
+func (s *Store) ImportChirp(ctx context.Context, arg database.ImportChirpParams) error {
+	return s.q.ImportChirp(ctx, sqlitedb.ImportChirpParams(arg))
+}
+
+func (s *Store) ListChirpsPage(ctx context.Context, arg database.ListChirpsPageParams) ([]database.Chirp, error) {
+	items, err := s.q.ListChirpsPage(ctx, sqlitedb.ListChirpsPageParams{
+		PageSize:   int64(arg.PageSize),
+		PageOffset: int64(arg.PageOffset),
+	})
+	return convert(items, chirp), err
+}
+
+func (s *Store) GetChirpsByStatus(ctx context.Context, status string) ([]database.Chirp, error) {
+	items, err := s.q.GetChirpsByStatus(ctx, status)
+	return convert(items, chirp), err
+}
+
+func (s *Store) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	items, err := s.q.GetChirpsByUserID(ctx, userID)
+	return convert(items, chirp), err
+}
+
+func (s *Store) GetSingleChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	c, err := s.q.GetSingleChirp(ctx, id)
+	return chirp(c), err
+}
+
+func (s *Store) SetChirpStatus(ctx context.Context, arg database.SetChirpStatusParams) error {
+	return s.q.SetChirpStatus(ctx, sqlitedb.SetChirpStatusParams(arg))
+}
+
+func (s *Store) SetUserChirpsStatus(ctx context.Context, arg database.SetUserChirpsStatusParams) ([]database.Chirp, error) {
+	items, err := s.q.SetUserChirpsStatus(ctx, sqlitedb.SetUserChirpsStatusParams(arg))
+	return convert(items, chirp), err
+}
+
+func (s *Store) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	items, err := s.q.ListScheduledChirps(ctx, userID)
+	return convert(items, chirp), err
+}
+
+func (s *Store) UpdateScheduledChirp(ctx context.Context, arg database.UpdateScheduledChirpParams) (database.Chirp, error) {
+	c, err := s.q.UpdateScheduledChirp(ctx, sqlitedb.UpdateScheduledChirpParams(arg))
+	return chirp(c), err
+}
+
+func (s *Store) PublishDueChirps(ctx context.Context, arg database.PublishDueChirpsParams) ([]database.Chirp, error) {
+	items, err := s.q.PublishDueChirps(ctx, sqlitedb.PublishDueChirpsParams{Now: arg.Now, AnnounceAt: arg.AnnounceAt, Limit: int64(arg.Limit)})
+	return convert(items, chirp), err
+}
+
+func (s *Store) ClaimUnannouncedChirps(ctx context.Context, arg database.ClaimUnannouncedChirpsParams) ([]database.Chirp, error) {
+	items, err := s.q.ClaimUnannouncedChirps(ctx, sqlitedb.ClaimUnannouncedChirpsParams{Now: arg.Now, AnnounceAt: arg.AnnounceAt, Limit: int64(arg.Limit)})
+	return convert(items, chirp), err
+}
+
+func (s *Store) ApproveHeldChirp(ctx context.Context, arg database.ApproveHeldChirpParams) (database.Chirp, error) {
+	item, err := s.q.ApproveHeldChirp(ctx, sqlitedb.ApproveHeldChirpParams(arg))
+	return chirp(item), err
+}
+
+func (s *Store) DeleteHeldChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	return s.q.DeleteHeldChirp(ctx, id)
+}
+
+func (s *Store) FinishChirpAnnouncement(ctx context.Context, id uuid.UUID) error {
+	return s.q.FinishChirpAnnouncement(ctx, id)
+}
+
+// Moderation rules
+
+func (s *Store) CreateModerationRule(ctx context.Context, arg database.CreateModerationRuleParams) (database.ModerationRule, error) {
+	r, err := s.q.CreateModerationRule(ctx, sqlitedb.CreateModerationRuleParams(arg))
+	return moderationRule(r), err
+}
+
+func (s *Store) DeleteModerationRule(ctx context.Context, id uuid.UUID) (int64, error) {
+	return s.q.DeleteModerationRule(ctx, id)
+}
+
+func (s *Store) ListModerationRules(ctx context.Context) ([]database.ModerationRule, error) {
+	items, err := s.q.ListModerationRules(ctx)
+	return convert(items, moderationRule), err
+}
+
+// Reports
+
+func (s *Store) CreateChirpReport(ctx context.Context, arg database.CreateChirpReportParams) (database.ChirpReport, error) {
+	r, err := s.q.CreateChirpReport(ctx, sqlitedb.CreateChirpReportParams(arg))
+	return chirpReport(r), err
+}
+
+func (s *Store) ListOpenChirpReports(ctx context.Context) ([]database.ChirpReport, error) {
+	items, err := s.q.ListOpenChirpReports(ctx)
+	return convert(items, chirpReport), err
+}
+
+func (s *Store) ListUserChirpReports(ctx context.Context, reporterID uuid.UUID) ([]database.ChirpReport, error) {
+	items, err := s.q.ListUserChirpReports(ctx, reporterID)
+	return convert(items, chirpReport), err
+}
+
+func (s *Store) ResolveChirpReports(ctx context.Context, arg database.ResolveChirpReportsParams) (int64, error) {
+	return s.q.ResolveChirpReports(ctx, sqlitedb.ResolveChirpReportsParams(arg))
+}
+
+// Users
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	u, err := s.q.CreateUser(ctx, sqlitedb.CreateUserParams(arg))
+	return user(u), err
+}
+
+func (s *Store) DeleteUsers(ctx context.Context) error {
+	return s.q.DeleteUsers(ctx)
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	u, err := s.q.GetUserByEmail(ctx, email)
+	return user(u), err
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	u, err := s.q.GetUserByID(ctx, id)
+	return user(u), err
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username sql.NullString) (database.User, error) {
+	u, err := s.q.GetUserByUsername(ctx, username)
+	return user(u), err
+}
+
+func (s *Store) GetUsersByUsernames(ctx context.Context, usernames []string) ([]database.User, error) {
+	names := convert(usernames, func(name string) sql.NullString {
+		return sql.NullString{String: name, Valid: true}
+	})
+	items, err := s.q.GetUsersByUsernames(ctx, names)
+	return convert(items, user), err
+}
+
+func (s *Store) DowngradeRed(ctx context.Context, id uuid.UUID) error {
+	return s.q.DowngradeRed(ctx, id)
+}
+
+func (s *Store) RevokeAccessTokens(ctx context.Context, id uuid.UUID) error {
+	return s.q.RevokeAccessTokens(ctx, id)
+}
+
+func (s *Store) ImportUser(ctx context.Context, arg database.ImportUserParams) error {
+	return s.q.ImportUser(ctx, sqlitedb.ImportUserParams(arg))
+}
+
+func (s *Store) SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.User, error) {
+	items, err := s.q.SearchUsers(ctx, sqlitedb.SearchUsersParams{
+		Query:      arg.Query,
+		Role:       arg.Role,
+		PageSize:   int64(arg.PageSize),
+		PageOffset: int64(arg.PageOffset),
+	})
+	return convert(items, user), err
+}
+
+func (s *Store) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (int64, error) {
+	return s.q.SetUserRole(ctx, sqlitedb.SetUserRoleParams(arg))
+}
+
+func (s *Store) SuspendUser(ctx context.Context, arg database.SuspendUserParams) error {
+	return s.q.SuspendUser(ctx, sqlitedb.SuspendUserParams(arg))
+}
+
+func (s *Store) SetUserDeleted(ctx context.Context, arg database.SetUserDeletedParams) error {
+	return s.q.SetUserDeleted(ctx, sqlitedb.SetUserDeletedParams(arg))
+}
+
+func (s *Store) PurgeDeletedUsers(ctx context.Context, deletedBefore sql.NullTime) ([]uuid.UUID, error) {
+	return s.q.PurgeDeletedUsers(ctx, deletedBefore)
+}
+
+func (s *Store) SetUsername(ctx context.Context, arg database.SetUsernameParams) error {
+	return s.q.SetUsername(ctx, sqlitedb.SetUsernameParams(arg))
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.UpdateUserRow, error) {
+	row, err := s.q.UpdateUser(ctx, sqlitedb.UpdateUserParams(arg))
+	return database.UpdateUserRow(row), err
+}
+
+func (s *Store) UpgradeRed(ctx context.Context, id uuid.UUID) error {
+	return s.q.UpgradeRed(ctx, id)
+}
+
+// Refresh tokens
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	t, err := s.q.CreateRefreshToken(ctx, sqlitedb.CreateRefreshTokenParams(arg))
+	return database.RefreshToken(t), err
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	u, err := s.q.GetUserFromRefreshToken(ctx, token)
+	return user(u), err
+}
+
+func (s *Store) ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	items, err := s.q.ListUserRefreshTokens(ctx, userID)
+	return convert(items, refreshToken), err
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) error {
+	return s.q.RevokeRefreshToken(ctx, token)
+}
+
+func (s *Store) DeleteExpiredRefreshTokens(ctx context.Context, expiredBefore time.Time) (int64, error) {
+	return s.q.DeleteExpiredRefreshTokens(ctx, expiredBefore)
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// Follows
+
+func (s *Store) FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error) {
+	return s.q.FollowUser(ctx, sqlitedb.FollowUserParams(arg))
+}
+
+func (s *Store) GetFolloweeIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetFolloweeIDs(ctx, followerID)
+}
+
+func (s *Store) ListUserFollows(ctx context.Context, userID uuid.UUID) ([]database.Follow, error) {
+	items, err := s.q.ListUserFollows(ctx, userID)
+	return convert(items, follow), err
+}
+
+func (s *Store) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error {
+	return s.q.UnfollowUser(ctx, sqlitedb.UnfollowUserParams(arg))
+}
+
+// Blocks
+
+func (s *Store) BlockUser(ctx context.Context, arg database.BlockUserParams) (int64, error) {
+	return s.q.BlockUser(ctx, sqlitedb.BlockUserParams(arg))
+}
+
+func (s *Store) CountBlocksBetween(ctx context.Context, arg database.CountBlocksBetweenParams) (int64, error) {
+	return s.q.CountBlocksBetween(ctx, sqlitedb.CountBlocksBetweenParams(arg))
+}
+
+func (s *Store) GetBlockedEitherWayIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetBlockedEitherWayIDs(ctx, userID)
+}
+
+func (s *Store) ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	items, err := s.q.ListBlocks(ctx, blockerID)
+	return convert(items, block), err
+}
+
+func (s *Store) UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error) {
+	return s.q.UnblockUser(ctx, sqlitedb.UnblockUserParams(arg))
+}
+
+// Mutes
+
+func (s *Store) GetMutedUserIDs(ctx context.Context, muterID uuid.UUID) ([]uuid.UUID, error) {
+	return s.q.GetMutedUserIDs(ctx, muterID)
+}
+
+func (s *Store) ListMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	items, err := s.q.ListMutes(ctx, muterID)
+	return convert(items, mute), err
+}
+
+func (s *Store) MuteUser(ctx context.Context, arg database.MuteUserParams) (int64, error) {
+	return s.q.MuteUser(ctx, sqlitedb.MuteUserParams(arg))
+}
+
+func (s *Store) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) (int64, error) {
+	return s.q.UnmuteUser(ctx, sqlitedb.UnmuteUserParams(arg))
+}
+
+// Likes
+
+func (s *Store) LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error) {
+	return s.q.LikeChirp(ctx, sqlitedb.LikeChirpParams(arg))
+}
+
+func (s *Store) ListUserLikes(ctx context.Context, userID uuid.UUID) ([]database.Like, error) {
+	items, err := s.q.ListUserLikes(ctx, userID)
+	return convert(items, like), err
+}
+
+func (s *Store) UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) error {
+	return s.q.UnlikeChirp(ctx, sqlitedb.UnlikeChirpParams(arg))
+}
+
+// Notifications
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.CountUnreadNotifications(ctx, userID)
+}
+
+func (s *Store) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error) {
+	n, err := s.q.CreateNotification(ctx, sqlitedb.CreateNotificationParams(arg))
+	return notification(n), err
+}
+
+func (s *Store) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	items, err := s.q.GetNotificationPreferences(ctx, userID)
+	return convert(items, notificationPreference), err
+}
+
+func (s *Store) ListNotifications(ctx context.Context, arg database.ListNotificationsParams) ([]database.Notification, error) {
+	items, err := s.q.ListNotifications(ctx, sqlitedb.ListNotificationsParams{
+		UserID:     arg.UserID,
+		Before:     arg.Before,
+		BeforeID:   arg.BeforeID,
+		UnreadOnly: arg.UnreadOnly,
+		PageSize:   int64(arg.PageSize),
+	})
+	return convert(items, notification), err
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	return s.q.MarkAllNotificationsRead(ctx, userID)
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error) {
+	return s.q.MarkNotificationRead(ctx, sqlitedb.MarkNotificationReadParams(arg))
+}
+
+func (s *Store) UpsertNotificationPreference(ctx context.Context, arg database.UpsertNotificationPreferenceParams) error {
+	return s.q.UpsertNotificationPreference(ctx, sqlitedb.UpsertNotificationPreferenceParams(arg))
+}
+
+// Data exports
+
+func (s *Store) ClaimDataExports(ctx context.Context, limit int32) ([]database.DataExport, error) {
+	items, err := s.q.ClaimDataExports(ctx, int64(limit))
+	return convert(items, dataExport), err
+}
+
+func (s *Store) CompleteDataExport(ctx context.Context, arg database.CompleteDataExportParams) error {
+	return s.q.CompleteDataExport(ctx, sqlitedb.CompleteDataExportParams(arg))
+}
+
+func (s *Store) CreateDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error) {
+	e, err := s.q.CreateDataExport(ctx, userID)
+	return dataExport(e), err
+}
+
+func (s *Store) DeleteDataExportsBefore(ctx context.Context, createdAt time.Time) error {
+	return s.q.DeleteDataExportsBefore(ctx, createdAt)
+}
+
+func (s *Store) GetDataExport(ctx context.Context, id uuid.UUID) (database.DataExport, error) {
+	e, err := s.q.GetDataExport(ctx, id)
+	return dataExport(e), err
+}
+
+func (s *Store) GetPendingDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error) {
+	e, err := s.q.GetPendingDataExport(ctx, userID)
+	return dataExport(e), err
+}
+
+// Stats
+
+func (s *Store) GetStats(ctx context.Context, now time.Time) (database.GetStatsRow, error) {
+	stats, err := s.q.GetStats(ctx, now)
+	return database.GetStatsRow(stats), err
+}
+
+func (s *Store) CountChirpsByStatus(ctx context.Context) ([]database.CountChirpsByStatusRow, error) {
+	items, err := s.q.CountChirpsByStatus(ctx)
+	return convert(items, chirpStatusCount), err
+}
+
+// Chirp events
+
+func (s *Store) CreateChirpEvent(ctx context.Context, arg database.CreateChirpEventParams) (database.ChirpEvent, error) {
+	e, err := s.q.CreateChirpEvent(ctx, sqlitedb.CreateChirpEventParams(arg))
+	return chirpEvent(e), err
+}
+
+func (s *Store) GetChirpEvent(ctx context.Context, id int64) (database.ChirpEvent, error) {
+	e, err := s.q.GetChirpEvent(ctx, id)
+	return chirpEvent(e), err
+}
+
+func (s *Store) GetChirpEventsAfter(ctx context.Context, arg database.GetChirpEventsAfterParams) ([]database.ChirpEvent, error) {
+	items, err := s.q.GetChirpEventsAfter(ctx, sqlitedb.GetChirpEventsAfterParams{
+		ID:    arg.ID,
+		Limit: int64(arg.Limit),
+	})
+	return convert(items, chirpEvent), err
+}
+
+// Federation
+
+func (s *Store) AddRemoteFollower(ctx context.Context, arg database.AddRemoteFollowerParams) error {
+	return s.q.AddRemoteFollower(ctx, sqlitedb.AddRemoteFollowerParams(arg))
+}
+
+func (s *Store) ClaimDeliveries(ctx context.Context, arg database.ClaimDeliveriesParams) ([]database.ApDelivery, error) {
+	items, err := s.q.ClaimDeliveries(ctx, sqlitedb.ClaimDeliveriesParams{
+		Attempts: int64(arg.Attempts),
+		Limit:    int64(arg.Limit),
+	})
+	return convert(items, apDelivery), err
+}
+
+func (s *Store) CountRemoteFollowers(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.CountRemoteFollowers(ctx, userID)
+}
+
+func (s *Store) CreateActorKey(ctx context.Context, arg database.CreateActorKeyParams) error {
+	return s.q.CreateActorKey(ctx, sqlitedb.CreateActorKeyParams(arg))
+}
+
+func (s *Store) DeleteDelivery(ctx context.Context, id int64) error {
+	return s.q.DeleteDelivery(ctx, id)
+}
+
+func (s *Store) EnqueueDelivery(ctx context.Context, arg database.EnqueueDeliveryParams) error {
+	return s.q.EnqueueDelivery(ctx, sqlitedb.EnqueueDeliveryParams(arg))
+}
+
+func (s *Store) GetActorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	k, err := s.q.GetActorKey(ctx, userID)
+	return database.ActorKey(k), err
+}
+
+func (s *Store) GetRemoteFollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return s.q.GetRemoteFollowerInboxes(ctx, userID)
+}
+
+func (s *Store) RemoveRemoteActor(ctx context.Context, actorID string) error {
+	return s.q.RemoveRemoteActor(ctx, actorID)
+}
+
+func (s *Store) RemoveRemoteFollower(ctx context.Context, arg database.RemoveRemoteFollowerParams) error {
+	return s.q.RemoveRemoteFollower(ctx, sqlitedb.RemoveRemoteFollowerParams(arg))
+}
+
+func (s *Store) RescheduleDelivery(ctx context.Context, arg database.RescheduleDeliveryParams) error {
+	return s.q.RescheduleDelivery(ctx, sqlitedb.RescheduleDeliveryParams(arg))
+}
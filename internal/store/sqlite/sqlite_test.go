@@ -0,0 +1,30 @@
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/enderbd/chirpy/internal/store/sqlite"
+	"github.com/enderbd/chirpy/internal/store/storetest"
+	"github.com/pressly/goose/v3"
+)
+
+func TestConformance(t *testing.T) {
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		db, err := sqlite.Open(":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		if err := goose.Up(db, "../../../sql/sqlite/schema"); err != nil {
+			t.Fatalf("migrating: %s", err)
+		}
+		return sqlite.New(db)
+	})
+}
@@ -0,0 +1,179 @@
+// Package store describes the persistence Chirpy's handlers depend on.
+//
+// The sqlc-generated *database.Queries satisfies every interface here, and
+// package memory provides an in-process implementation for tests. Lookups
+// that find nothing return sql.ErrNoRows, whichever implementation is used.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type ChirpStore interface {
+	ApproveHeldChirp(ctx context.Context, arg database.ApproveHeldChirpParams) (database.Chirp, error)
+	ClaimUnannouncedChirps(ctx context.Context, arg database.ClaimUnannouncedChirpsParams) ([]database.Chirp, error)
+	CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error)
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+	DeleteHeldChirp(ctx context.Context, id uuid.UUID) (int64, error)
+	DeleteScheduledChirp(ctx context.Context, arg database.DeleteScheduledChirpParams) (int64, error)
+	FinishChirpAnnouncement(ctx context.Context, id uuid.UUID) error
+	GetChirps(ctx context.Context) ([]database.Chirp, error)
+	GetChirpsByStatus(ctx context.Context, status string) ([]database.Chirp, error)
+	GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+	GetSingleChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	ImportChirp(ctx context.Context, arg database.ImportChirpParams) error
+	ListChirpsPage(ctx context.Context, arg database.ListChirpsPageParams) ([]database.Chirp, error)
+	ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+	PublishDueChirps(ctx context.Context, arg database.PublishDueChirpsParams) ([]database.Chirp, error)
+	SetChirpStatus(ctx context.Context, arg database.SetChirpStatusParams) error
+	SetUserChirpsStatus(ctx context.Context, arg database.SetUserChirpsStatusParams) ([]database.Chirp, error)
+	UpdateScheduledChirp(ctx context.Context, arg database.UpdateScheduledChirpParams) (database.Chirp, error)
+}
+
+// ModerationStore holds the moderation rules admins add at runtime.
+type ModerationStore interface {
+	CreateModerationRule(ctx context.Context, arg database.CreateModerationRuleParams) (database.ModerationRule, error)
+	DeleteModerationRule(ctx context.Context, id uuid.UUID) (int64, error)
+	ListModerationRules(ctx context.Context) ([]database.ModerationRule, error)
+}
+
+// ReportStore holds the chirps users have reported for moderators.
+type ReportStore interface {
+	CreateChirpReport(ctx context.Context, arg database.CreateChirpReportParams) (database.ChirpReport, error)
+	ListOpenChirpReports(ctx context.Context) ([]database.ChirpReport, error)
+	ListUserChirpReports(ctx context.Context, reporterID uuid.UUID) ([]database.ChirpReport, error)
+	ResolveChirpReports(ctx context.Context, arg database.ResolveChirpReportsParams) (int64, error)
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	DeleteUsers(ctx context.Context) error
+	DowngradeRed(ctx context.Context, id uuid.UUID) error
+	GetUserByEmail(ctx context.Context, email string) (database.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error)
+	GetUserByUsername(ctx context.Context, username sql.NullString) (database.User, error)
+	GetUsersByUsernames(ctx context.Context, usernames []string) ([]database.User, error)
+	ImportUser(ctx context.Context, arg database.ImportUserParams) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore sql.NullTime) ([]uuid.UUID, error)
+	RevokeAccessTokens(ctx context.Context, id uuid.UUID) error
+	SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.User, error)
+	SetUserDeleted(ctx context.Context, arg database.SetUserDeletedParams) error
+	SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (int64, error)
+	SetUsername(ctx context.Context, arg database.SetUsernameParams) error
+	SuspendUser(ctx context.Context, arg database.SuspendUserParams) error
+	UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.UpdateUserRow, error)
+	UpgradeRed(ctx context.Context, id uuid.UUID) error
+}
+
+type TokenStore interface {
+	CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error)
+	DeleteExpiredRefreshTokens(ctx context.Context, expiredBefore time.Time) (int64, error)
+	GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error)
+	ListUserRefreshTokens(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type FollowStore interface {
+	FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error)
+	GetFolloweeIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error)
+	ListUserFollows(ctx context.Context, userID uuid.UUID) ([]database.Follow, error)
+	UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) error
+}
+
+// BlockStore holds blocks, which work both ways.
+type BlockStore interface {
+	BlockUser(ctx context.Context, arg database.BlockUserParams) (int64, error)
+	CountBlocksBetween(ctx context.Context, arg database.CountBlocksBetweenParams) (int64, error)
+	GetBlockedEitherWayIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error)
+	UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error)
+}
+
+type MuteStore interface {
+	GetMutedUserIDs(ctx context.Context, muterID uuid.UUID) ([]uuid.UUID, error)
+	ListMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error)
+	MuteUser(ctx context.Context, arg database.MuteUserParams) (int64, error)
+	UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) (int64, error)
+}
+
+type LikeStore interface {
+	LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error)
+	ListUserLikes(ctx context.Context, userID uuid.UUID) ([]database.Like, error)
+	UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) error
+}
+
+type NotificationStore interface {
+	CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error)
+	CreateNotification(ctx context.Context, arg database.CreateNotificationParams) (database.Notification, error)
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error)
+	ListNotifications(ctx context.Context, arg database.ListNotificationsParams) ([]database.Notification, error)
+	MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error
+	MarkNotificationRead(ctx context.Context, arg database.MarkNotificationReadParams) (int64, error)
+	UpsertNotificationPreference(ctx context.Context, arg database.UpsertNotificationPreferenceParams) error
+}
+
+// ExportStore holds the data export archives users ask for, built in the
+// background.
+type ExportStore interface {
+	ClaimDataExports(ctx context.Context, limit int32) ([]database.DataExport, error)
+	CompleteDataExport(ctx context.Context, arg database.CompleteDataExportParams) error
+	CreateDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error)
+	DeleteDataExportsBefore(ctx context.Context, createdAt time.Time) error
+	GetDataExport(ctx context.Context, id uuid.UUID) (database.DataExport, error)
+	GetPendingDataExport(ctx context.Context, userID uuid.UUID) (database.DataExport, error)
+}
+
+// StatsStore counts what is in the database for operators.
+type StatsStore interface {
+	CountChirpsByStatus(ctx context.Context) ([]database.CountChirpsByStatusRow, error)
+	GetStats(ctx context.Context, now time.Time) (database.GetStatsRow, error)
+}
+
+// EventStore is the chirp event log behind the SSE and WebSocket streams.
+type EventStore interface {
+	CreateChirpEvent(ctx context.Context, arg database.CreateChirpEventParams) (database.ChirpEvent, error)
+	GetChirpEvent(ctx context.Context, id int64) (database.ChirpEvent, error)
+	GetChirpEventsAfter(ctx context.Context, arg database.GetChirpEventsAfterParams) ([]database.ChirpEvent, error)
+}
+
+// FederationStore holds ActivityPub keys, remote followers and notes, and
+// the outgoing delivery queue.
+type FederationStore interface {
+	AddRemoteFollower(ctx context.Context, arg database.AddRemoteFollowerParams) error
+	ClaimDeliveries(ctx context.Context, arg database.ClaimDeliveriesParams) ([]database.ApDelivery, error)
+	CountRemoteFollowers(ctx context.Context, userID uuid.UUID) (int64, error)
+	CreateActorKey(ctx context.Context, arg database.CreateActorKeyParams) error
+	DeleteDelivery(ctx context.Context, id int64) error
+	EnqueueDelivery(ctx context.Context, arg database.EnqueueDeliveryParams) error
+	GetActorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error)
+	GetRemoteFollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error)
+	RemoveRemoteActor(ctx context.Context, actorID string) error
+	RemoveRemoteFollower(ctx context.Context, arg database.RemoveRemoteFollowerParams) error
+	RescheduleDelivery(ctx context.Context, arg database.RescheduleDeliveryParams) error
+}
+
+// Store is everything apiConfig needs from persistence.
+type Store interface {
+	ChirpStore
+	ModerationStore
+	ReportStore
+	UserStore
+	TokenStore
+	FollowStore
+	BlockStore
+	MuteStore
+	LikeStore
+	NotificationStore
+	ExportStore
+	StatsStore
+	EventStore
+	FederationStore
+}
+
+var _ Store = (*database.Queries)(nil)
@@ -0,0 +1,44 @@
+package store_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/enderbd/chirpy/internal/store/storetest"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+// TestPostgresConformance runs the suite against a scratch Postgres
+// database named by CHIRPY_TEST_DB_URL. Every table in it is emptied.
+func TestPostgresConformance(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("postgres"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(db, "../../sql/schema"); err != nil {
+		t.Fatalf("migrating: %s", err)
+	}
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		_, err := db.Exec(`TRUNCATE users, chirp_events, moderation_rules CASCADE`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database.New(db)
+	})
+}
@@ -0,0 +1,1329 @@
+// Package storetest is a conformance suite for store.Store implementations.
+// Every backend runs the same tests so handlers can rely on identical
+// behaviour whichever one is configured.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// Run runs the suite. newStore must return an empty store each time it is
+// called.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"Users", testUsers},
+		{"DeleteUsersCascades", testDeleteUsersCascades},
+		{"PurgeDeletedUsers", testPurgeDeletedUsers},
+		{"Chirps", testChirps},
+		{"Replies", testReplies},
+		{"ChirpStatus", testChirpStatus},
+		{"ScheduledChirps", testScheduledChirps},
+		{"ApproveHeldChirps", testApproveHeldChirps},
+		{"ModerationRules", testModerationRules},
+		{"UserRoles", testUserRoles},
+		{"SearchUsers", testSearchUsers},
+		{"RevokeUserTokens", testRevokeUserTokens},
+		{"ChirpReports", testChirpReports},
+		{"RefreshTokens", testRefreshTokens},
+		{"Follows", testFollows},
+		{"Blocks", testBlocks},
+		{"Mutes", testMutes},
+		{"Likes", testLikes},
+		{"Notifications", testNotifications},
+		{"NotificationPages", testNotificationPages},
+		{"NotificationPreferences", testNotificationPreferences},
+		{"ChirpEvents", testChirpEvents},
+		{"UserActivity", testUserActivity},
+		{"DataExports", testDataExports},
+		{"Import", testImport},
+		{"Stats", testStats},
+		{"ActorKeys", testActorKeys},
+		{"RemoteFollowers", testRemoteFollowers},
+		{"Deliveries", testDeliveries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func mustUser(t *testing.T, s store.Store, email, username string) database.User {
+	t.Helper()
+	u, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "hash",
+		Username:       sql.NullString{String: username, Valid: username != ""},
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %s", email, err)
+	}
+	return u
+}
+
+func mustChirp(t *testing.T, s store.Store, userID uuid.UUID, body string) database.Chirp {
+	t.Helper()
+	c, err := s.CreateChirp(context.Background(), database.CreateChirpParams{Body: body, UserID: userID, Status: "published"})
+	if err != nil {
+		t.Fatalf("CreateChirp: %s", err)
+	}
+	return c
+}
+
+func wantNoRows(t *testing.T, what string, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("%s: err = %v, want sql.ErrNoRows", what, err)
+	}
+}
+
+func testUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "alice")
+	if alice.ID == uuid.Nil || alice.CreatedAt.IsZero() || alice.IsChirpyRed {
+		t.Errorf("CreateUser returned %+v", alice)
+	}
+
+	_, err := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com", HashedPassword: "x"})
+	if err == nil {
+		t.Error("CreateUser with a duplicate email succeeded")
+	}
+
+	got, err := s.GetUserByEmail(ctx, "alice@example.com")
+	if err != nil || got.ID != alice.ID {
+		t.Errorf("GetUserByEmail = %+v, %v", got, err)
+	}
+	got, err = s.GetUserByID(ctx, alice.ID)
+	if err != nil || got.Email != alice.Email {
+		t.Errorf("GetUserByID = %+v, %v", got, err)
+	}
+	got, err = s.GetUserByUsername(ctx, sql.NullString{String: "alice", Valid: true})
+	if err != nil || got.ID != alice.ID {
+		t.Errorf("GetUserByUsername = %+v, %v", got, err)
+	}
+	_, err = s.GetUserByEmail(ctx, "nobody@example.com")
+	wantNoRows(t, "GetUserByEmail(unknown)", err)
+	_, err = s.GetUserByID(ctx, uuid.New())
+	wantNoRows(t, "GetUserByID(unknown)", err)
+
+	bob := mustUser(t, s, "bob@example.com", "")
+	err = s.SetUsername(ctx, database.SetUsernameParams{Username: sql.NullString{String: "alice", Valid: true}, ID: bob.ID})
+	if err == nil {
+		t.Error("SetUsername to a taken name succeeded")
+	}
+	err = s.SetUsername(ctx, database.SetUsernameParams{Username: sql.NullString{String: "bob", Valid: true}, ID: bob.ID})
+	if err != nil {
+		t.Fatalf("SetUsername: %s", err)
+	}
+
+	users, err := s.GetUsersByUsernames(ctx, []string{"alice", "bob", "nobody"})
+	if err != nil || len(users) != 2 {
+		t.Errorf("GetUsersByUsernames = %d users, %v; want 2", len(users), err)
+	}
+
+	row, err := s.UpdateUser(ctx, database.UpdateUserParams{Email: "bob@new.example.com", HashedPassword: "new", ID: bob.ID})
+	if err != nil || row.Email != "bob@new.example.com" || row.Username.String != "bob" {
+		t.Errorf("UpdateUser = %+v, %v", row, err)
+	}
+	_, err = s.UpdateUser(ctx, database.UpdateUserParams{Email: "x@example.com", HashedPassword: "x", ID: uuid.New()})
+	wantNoRows(t, "UpdateUser(unknown)", err)
+	// A taken username fails the whole update.
+	_, err = s.UpdateUser(ctx, database.UpdateUserParams{Email: "bob@newer.example.com", HashedPassword: "newer", Username: sql.NullString{String: "alice", Valid: true}, ID: bob.ID})
+	if err == nil {
+		t.Error("UpdateUser to a taken username succeeded")
+	}
+	if got, _ := s.GetUserByID(ctx, bob.ID); got.Email != "bob@new.example.com" || got.HashedPassword != "new" {
+		t.Errorf("after a failed UpdateUser got %+v", got)
+	}
+	row, err = s.UpdateUser(ctx, database.UpdateUserParams{Email: "bob@new.example.com", HashedPassword: "new", Username: sql.NullString{String: "bob", Valid: true}, ID: bob.ID})
+	if err != nil || row.Username.String != "bob" {
+		t.Errorf("UpdateUser with a username = %+v, %v", row, err)
+	}
+
+	if err := s.UpgradeRed(ctx, bob.ID); err != nil {
+		t.Fatalf("UpgradeRed: %s", err)
+	}
+	got, _ = s.GetUserByID(ctx, bob.ID)
+	if !got.IsChirpyRed || got.HashedPassword != "new" {
+		t.Errorf("after UpgradeRed got %+v", got)
+	}
+}
+
+func testDeleteUsersCascades(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	chirp := mustChirp(t, s, alice.ID, "hello")
+	_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token: "tok", UserID: alice.ID, ExpiresAt: time.Now().UTC().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteUsers(ctx); err != nil {
+		t.Fatalf("DeleteUsers: %s", err)
+	}
+	_, err = s.GetSingleChirp(ctx, chirp.ID)
+	wantNoRows(t, "GetSingleChirp after DeleteUsers", err)
+	_, err = s.GetUserFromRefreshToken(ctx, "tok")
+	wantNoRows(t, "GetUserFromRefreshToken after DeleteUsers", err)
+
+	// The email is free again.
+	mustUser(t, s, "alice@example.com", "")
+}
+
+func testPurgeDeletedUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	carol := mustUser(t, s, "carol@example.com", "")
+	chirp := mustChirp(t, s, alice.ID, "goodbye")
+
+	now := sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	for _, id := range []uuid.UUID{alice.ID, bob.ID} {
+		if err := s.SetUserDeleted(ctx, database.SetUserDeletedParams{DeletedAt: now, ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := s.GetUserByID(ctx, alice.ID)
+	if err != nil || !got.DeletedAt.Valid {
+		t.Errorf("GetUserByID after SetUserDeleted = %+v, %v", got, err)
+	}
+	// Bob restored the account.
+	if err := s.SetUserDeleted(ctx, database.SetUserDeletedParams{ID: bob.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	past := sql.NullTime{Time: now.Time.Add(-time.Hour), Valid: true}
+	if ids, err := s.PurgeDeletedUsers(ctx, past); err != nil || len(ids) != 0 {
+		t.Errorf("PurgeDeletedUsers before the deletion = %v, %v", ids, err)
+	}
+	future := sql.NullTime{Time: now.Time.Add(time.Hour), Valid: true}
+	ids, err := s.PurgeDeletedUsers(ctx, future)
+	if err != nil || len(ids) != 1 || ids[0] != alice.ID {
+		t.Errorf("PurgeDeletedUsers = %v, %v", ids, err)
+	}
+	_, err = s.GetUserByID(ctx, alice.ID)
+	wantNoRows(t, "GetUserByID after PurgeDeletedUsers", err)
+	_, err = s.GetSingleChirp(ctx, chirp.ID)
+	wantNoRows(t, "GetSingleChirp after PurgeDeletedUsers", err)
+	for _, id := range []uuid.UUID{bob.ID, carol.ID} {
+		if _, err := s.GetUserByID(ctx, id); err != nil {
+			t.Errorf("a user that wasn't deleted was purged: %v", err)
+		}
+	}
+}
+
+func testChirps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+
+	first := mustChirp(t, s, alice.ID, "one")
+	time.Sleep(2 * time.Millisecond)
+	second := mustChirp(t, s, bob.ID, "two")
+	if first.ReplyToID.Valid {
+		t.Errorf("CreateChirp without reply set ReplyToID: %+v", first)
+	}
+
+	all, err := s.GetChirps(ctx)
+	if err != nil || len(all) != 2 || all[0].ID != first.ID || all[1].ID != second.ID {
+		t.Errorf("GetChirps = %+v, %v", all, err)
+	}
+	byBob, err := s.GetChirpsByUserID(ctx, bob.ID)
+	if err != nil || len(byBob) != 1 || byBob[0].ID != second.ID {
+		t.Errorf("GetChirpsByUserID = %+v, %v", byBob, err)
+	}
+	got, err := s.GetSingleChirp(ctx, first.ID)
+	if err != nil || got.Body != "one" || got.UserID != alice.ID {
+		t.Errorf("GetSingleChirp = %+v, %v", got, err)
+	}
+
+	if err := s.DeleteChirp(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteChirp: %s", err)
+	}
+	_, err = s.GetSingleChirp(ctx, first.ID)
+	wantNoRows(t, "GetSingleChirp after delete", err)
+
+	_, err = s.CreateChirp(ctx, database.CreateChirpParams{Body: "orphan", UserID: uuid.New()})
+	if err == nil {
+		t.Error("CreateChirp for an unknown user succeeded")
+	}
+}
+
+func testReplies(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	parent := mustChirp(t, s, alice.ID, "parent")
+
+	reply, err := s.CreateChirp(ctx, database.CreateChirpParams{
+		Body:      "reply",
+		UserID:    alice.ID,
+		ReplyToID: uuid.NullUUID{UUID: parent.ID, Valid: true},
+	})
+	if err != nil || reply.ReplyToID.UUID != parent.ID {
+		t.Fatalf("CreateChirp reply = %+v, %v", reply, err)
+	}
+
+	if err := s.DeleteChirp(ctx, parent.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetSingleChirp(ctx, reply.ID)
+	if err != nil || got.ReplyToID.Valid {
+		t.Errorf("reply after parent deleted = %+v, %v; want ReplyToID cleared", got, err)
+	}
+}
+
+func testChirpStatus(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	published := mustChirp(t, s, alice.ID, "published")
+	held, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "held", UserID: alice.ID, Status: "held"})
+	if err != nil || held.Status != "held" || published.Status != "published" {
+		t.Fatalf("CreateChirp statuses = %q, %q, %v", published.Status, held.Status, err)
+	}
+
+	got, err := s.GetChirpsByStatus(ctx, "held")
+	if err != nil || len(got) != 1 || got[0].ID != held.ID {
+		t.Errorf("GetChirpsByStatus = %+v, %v", got, err)
+	}
+
+	if err := s.SetChirpStatus(ctx, database.SetChirpStatusParams{Status: "published", ID: held.ID}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := s.GetSingleChirp(ctx, held.ID)
+	if err != nil || after.Status != "published" {
+		t.Errorf("after SetChirpStatus = %+v, %v", after, err)
+	}
+	if got, _ := s.GetChirpsByStatus(ctx, "held"); len(got) != 0 {
+		t.Errorf("GetChirpsByStatus after publishing = %+v", got)
+	}
+
+	bob := mustUser(t, s, "bob@example.com", "")
+	bobs := mustChirp(t, s, bob.ID, "bob's")
+	if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "held again", UserID: alice.ID, Status: "held"}); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := s.SetUserChirpsStatus(ctx, database.SetUserChirpsStatusParams{ToStatus: "banned", UserID: alice.ID, FromStatus: "published"})
+	if err != nil || len(changed) != 2 {
+		t.Errorf("SetUserChirpsStatus = %+v, %v; want 2 chirps", changed, err)
+	}
+	for _, c := range changed {
+		if c.UserID != alice.ID || c.Status != "banned" {
+			t.Errorf("SetUserChirpsStatus returned %+v", c)
+		}
+	}
+	if got, _ := s.GetChirpsByStatus(ctx, "banned"); len(got) != 2 {
+		t.Errorf("banned chirps = %+v", got)
+	}
+	if got, _ := s.GetChirpsByStatus(ctx, "held"); len(got) != 1 {
+		t.Errorf("held chirps after SetUserChirpsStatus = %+v", got)
+	}
+	if got, _ := s.GetSingleChirp(ctx, bobs.ID); got.Status != "published" {
+		t.Errorf("another user's chirp = %+v", got)
+	}
+}
+
+func testScheduledChirps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	schedule := func(userID uuid.UUID, body string, at time.Time) database.Chirp {
+		t.Helper()
+		c, err := s.CreateChirp(ctx, database.CreateChirpParams{
+			Body: body, UserID: userID, Status: "scheduled", PublishAt: sql.NullTime{Time: at, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("CreateChirp(%s): %s", body, err)
+		}
+		return c
+	}
+	later := schedule(alice.ID, "later", now.Add(2*time.Hour))
+	soon := schedule(alice.ID, "soon", now.Add(time.Hour))
+	bobs := schedule(bob.ID, "bob's", now.Add(time.Hour))
+	published := mustChirp(t, s, alice.ID, "published")
+
+	got, err := s.ListScheduledChirps(ctx, alice.ID)
+	if err != nil || len(got) != 2 || got[0].ID != soon.ID || got[1].ID != later.ID {
+		t.Fatalf("ListScheduledChirps = %+v, %v; want soon then later", got, err)
+	}
+	if !got[0].PublishAt.Time.Equal(now.Add(time.Hour)) {
+		t.Errorf("PublishAt = %v, want %v", got[0].PublishAt.Time, now.Add(time.Hour))
+	}
+
+	edited, err := s.UpdateScheduledChirp(ctx, database.UpdateScheduledChirpParams{
+		Body: "much later", PublishAt: sql.NullTime{Time: now.Add(3 * time.Hour), Valid: true}, Status: "scheduled", ID: later.ID,
+	})
+	if err != nil || edited.Body != "much later" || !edited.PublishAt.Time.Equal(now.Add(3*time.Hour)) {
+		t.Errorf("UpdateScheduledChirp = %+v, %v", edited, err)
+	}
+
+	if n, err := s.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{ID: bobs.ID, UserID: alice.ID}); err != nil || n != 0 {
+		t.Errorf("DeleteScheduledChirp of another user's chirp = %d, %v", n, err)
+	}
+
+	if due, err := s.PublishDueChirps(ctx, database.PublishDueChirpsParams{Now: now, Limit: 10}); err != nil || len(due) != 0 {
+		t.Errorf("PublishDueChirps before any are due = %+v, %v", due, err)
+	}
+	until := sql.NullTime{Time: now.Add(24 * time.Hour), Valid: true}
+	if err := s.SuspendUser(ctx, database.SuspendUserParams{SuspendedUntil: until, BanReason: "spam", ID: bob.ID}); err != nil {
+		t.Fatal(err)
+	}
+	at := now.Add(90 * time.Minute)
+	lease := sql.NullTime{Time: at.Add(time.Minute), Valid: true}
+	due, err := s.PublishDueChirps(ctx, database.PublishDueChirpsParams{Now: at, AnnounceAt: lease, Limit: 10})
+	if err != nil || len(due) != 1 || due[0].ID != soon.ID {
+		t.Fatalf("PublishDueChirps = %+v, %v; want only alice's due chirp", due, err)
+	}
+	if due[0].Status != "published" || !due[0].CreatedAt.Equal(soon.CreatedAt) || !due[0].PublishAt.Time.Equal(at) || !due[0].AnnounceAt.Time.Equal(lease.Time) {
+		t.Errorf("published chirp = %+v, want status published at %v, still created at %v, announced by %v", due[0], at, soon.CreatedAt, lease.Time)
+	}
+	if due, _ := s.PublishDueChirps(ctx, database.PublishDueChirpsParams{Now: at, AnnounceAt: lease, Limit: 10}); len(due) != 0 {
+		t.Errorf("PublishDueChirps published %d chirps twice", len(due))
+	}
+	// Timelines go by when a chirp went out.
+	all, err := s.GetChirps(ctx)
+	var order []uuid.UUID
+	for _, c := range all {
+		if c.Status == "published" {
+			order = append(order, c.ID)
+		}
+	}
+	if err != nil || !slices.Equal(order, []uuid.UUID{published.ID, soon.ID}) {
+		t.Errorf("GetChirps = %+v, %v; want the scheduled chirp after the one published before it", all, err)
+	}
+
+	// Until the announcement is finished, it is handed out again once
+	// the lease runs out.
+	if got, err := s.ClaimUnannouncedChirps(ctx, database.ClaimUnannouncedChirpsParams{Now: at, AnnounceAt: lease, Limit: 10}); err != nil || len(got) != 0 {
+		t.Errorf("ClaimUnannouncedChirps during the lease = %+v, %v", got, err)
+	}
+	later2 := sql.NullTime{Time: lease.Time.Add(time.Minute), Valid: true}
+	got, err = s.ClaimUnannouncedChirps(ctx, database.ClaimUnannouncedChirpsParams{Now: lease.Time, AnnounceAt: later2, Limit: 10})
+	if err != nil || len(got) != 1 || got[0].ID != soon.ID || !got[0].AnnounceAt.Time.Equal(later2.Time) {
+		t.Errorf("ClaimUnannouncedChirps after the lease = %+v, %v", got, err)
+	}
+	if err := s.FinishChirpAnnouncement(ctx, soon.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.ClaimUnannouncedChirps(ctx, database.ClaimUnannouncedChirpsParams{Now: later2.Time.Add(time.Hour), AnnounceAt: later2, Limit: 10}); err != nil || len(got) != 0 {
+		t.Errorf("ClaimUnannouncedChirps after finishing = %+v, %v", got, err)
+	}
+
+	_, err = s.UpdateScheduledChirp(ctx, database.UpdateScheduledChirpParams{Body: "too late", Status: "scheduled", ID: soon.ID})
+	wantNoRows(t, "UpdateScheduledChirp after publishing", err)
+	if n, err := s.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{ID: soon.ID, UserID: alice.ID}); err != nil || n != 0 {
+		t.Errorf("DeleteScheduledChirp after publishing = %d, %v", n, err)
+	}
+	if n, err := s.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{ID: later.ID, UserID: alice.ID}); err != nil || n != 1 {
+		t.Errorf("DeleteScheduledChirp = %d, %v", n, err)
+	}
+	if got, _ := s.ListScheduledChirps(ctx, alice.ID); len(got) != 0 {
+		t.Errorf("ListScheduledChirps after cancelling = %+v", got)
+	}
+}
+
+func testApproveHeldChirps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	hold := func(body string, publishAt sql.NullTime) database.Chirp {
+		t.Helper()
+		c, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: body, UserID: alice.ID, Status: "held", PublishAt: publishAt})
+		if err != nil {
+			t.Fatalf("CreateChirp(%s): %s", body, err)
+		}
+		return c
+	}
+	plain := hold("plain", sql.NullTime{})
+	overdue := hold("overdue", sql.NullTime{Time: now.Add(-time.Hour), Valid: true})
+	ahead := hold("ahead", sql.NullTime{Time: now.Add(time.Hour), Valid: true})
+	rejected := hold("rejected", sql.NullTime{})
+	lease := sql.NullTime{Time: now.Add(time.Minute), Valid: true}
+
+	for _, c := range []database.Chirp{plain, overdue} {
+		got, err := s.ApproveHeldChirp(ctx, database.ApproveHeldChirpParams{Now: now, AnnounceAt: lease, ID: c.ID})
+		if err != nil || got.Status != "published" || !got.PublishAt.Time.Equal(now) || !got.AnnounceAt.Time.Equal(lease.Time) || !got.CreatedAt.Equal(c.CreatedAt) {
+			t.Errorf("ApproveHeldChirp(%s) = %+v, %v; want published at %v, announced by %v", c.Body, got, err, now, lease.Time)
+		}
+	}
+	got, err := s.ApproveHeldChirp(ctx, database.ApproveHeldChirpParams{Now: now, AnnounceAt: lease, ID: ahead.ID})
+	if err != nil || got.Status != "scheduled" || !got.PublishAt.Time.Equal(ahead.PublishAt.Time) || got.AnnounceAt.Valid {
+		t.Errorf("ApproveHeldChirp(ahead) = %+v, %v; want it scheduled as before", got, err)
+	}
+	_, err = s.ApproveHeldChirp(ctx, database.ApproveHeldChirpParams{Now: now, AnnounceAt: lease, ID: plain.ID})
+	wantNoRows(t, "ApproveHeldChirp twice", err)
+
+	if n, err := s.DeleteHeldChirp(ctx, plain.ID); err != nil || n != 0 {
+		t.Errorf("DeleteHeldChirp of an approved chirp = %d, %v", n, err)
+	}
+	if n, err := s.DeleteHeldChirp(ctx, rejected.ID); err != nil || n != 1 {
+		t.Errorf("DeleteHeldChirp = %d, %v", n, err)
+	}
+	_, err = s.ApproveHeldChirp(ctx, database.ApproveHeldChirpParams{Now: now, AnnounceAt: lease, ID: rejected.ID})
+	wantNoRows(t, "ApproveHeldChirp after rejecting", err)
+}
+
+func testModerationRules(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	zeta, err := s.CreateModerationRule(ctx, database.CreateModerationRuleParams{Term: "zeta", Action: "hold"})
+	if err != nil || zeta.ID == uuid.Nil || zeta.CreatedAt.IsZero() || zeta.Action != "hold" {
+		t.Fatalf("CreateModerationRule = %+v, %v", zeta, err)
+	}
+	alpha, err := s.CreateModerationRule(ctx, database.CreateModerationRuleParams{Term: "alpha", Action: "mask"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateModerationRule(ctx, database.CreateModerationRuleParams{Term: "zeta", Action: "reject"}); err == nil {
+		t.Error("CreateModerationRule with a duplicate term succeeded")
+	}
+
+	rules, err := s.ListModerationRules(ctx)
+	if err != nil || len(rules) != 2 || rules[0].ID != alpha.ID || rules[1].ID != zeta.ID {
+		t.Errorf("ListModerationRules = %+v, %v", rules, err)
+	}
+
+	if n, err := s.DeleteModerationRule(ctx, zeta.ID); err != nil || n != 1 {
+		t.Errorf("DeleteModerationRule = %d, %v", n, err)
+	}
+	if n, err := s.DeleteModerationRule(ctx, zeta.ID); err != nil || n != 0 {
+		t.Errorf("DeleteModerationRule again = %d, %v", n, err)
+	}
+}
+
+func testUserRoles(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	if alice.Role != "user" || alice.SuspendedUntil.Valid {
+		t.Fatalf("new user: role %q, suspended %v", alice.Role, alice.SuspendedUntil)
+	}
+
+	if n, err := s.SetUserRole(ctx, database.SetUserRoleParams{Role: "moderator", ID: alice.ID}); err != nil || n != 1 {
+		t.Errorf("SetUserRole = %d, %v", n, err)
+	}
+	if n, err := s.SetUserRole(ctx, database.SetUserRoleParams{Role: "moderator", ID: uuid.New()}); err != nil || n != 0 {
+		t.Errorf("SetUserRole for a missing user = %d, %v", n, err)
+	}
+	until := time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+	if err := s.SuspendUser(ctx, database.SuspendUserParams{SuspendedUntil: sql.NullTime{Time: until, Valid: true}, BanReason: "spam", ID: alice.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetUserByID(ctx, alice.ID)
+	if err != nil || got.Role != "moderator" || !got.SuspendedUntil.Valid || !got.SuspendedUntil.Time.Equal(until) || got.BanReason != "spam" {
+		t.Errorf("GetUserByID = %+v, %v", got, err)
+	}
+}
+
+func testSearchUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "alice")
+	bob := mustUser(t, s, "bob@example.org", "bobby")
+	carol := mustUser(t, s, "carol_1@example.com", "")
+	if _, err := s.SetUserRole(ctx, database.SetUserRoleParams{Role: "moderator", ID: bob.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(query, role string, size, offset int32) []uuid.UUID {
+		t.Helper()
+		users, err := s.SearchUsers(ctx, database.SearchUsersParams{Query: query, Role: role, PageSize: size, PageOffset: offset})
+		if err != nil {
+			t.Fatalf("SearchUsers(%q, %q): %s", query, role, err)
+		}
+		var ids []uuid.UUID
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		return ids
+	}
+	// Users created in the same instant are ordered by ID.
+	ordered := func(users ...database.User) []uuid.UUID {
+		slices.SortFunc(users, func(a, b database.User) int {
+			if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+				return c
+			}
+			return bytes.Compare(a.ID[:], b.ID[:])
+		})
+		var ids []uuid.UUID
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		return ids
+	}
+	all := ordered(alice, bob, carol)
+
+	for _, tt := range []struct {
+		query, role  string
+		size, offset int32
+		want         []uuid.UUID
+	}{
+		{size: 10, want: all},
+		{size: 2, offset: 1, want: all[1:]},
+		{size: 1, offset: 3},
+		{query: "EXAMPLE.COM", size: 10, want: ordered(alice, carol)},
+		{query: "bobb", size: 10, want: []uuid.UUID{bob.ID}},
+		{query: `l\_`, size: 10, want: []uuid.UUID{carol.ID}},
+		{role: "moderator", size: 10, want: []uuid.UUID{bob.ID}},
+		{query: "alice", role: "moderator", size: 10},
+	} {
+		if got := search(tt.query, tt.role, tt.size, tt.offset); !slices.Equal(got, tt.want) {
+			t.Errorf("SearchUsers(%q, %q, %d, %d) = %v, want %v", tt.query, tt.role, tt.size, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func testRevokeUserTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	expires := time.Now().UTC().Add(time.Hour)
+	for _, tok := range []database.CreateRefreshTokenParams{
+		{Token: "a1", UserID: alice.ID, ExpiresAt: expires},
+		{Token: "a2", UserID: alice.ID, ExpiresAt: expires},
+		{Token: "b1", UserID: bob.ID, ExpiresAt: expires},
+	} {
+		if _, err := s.CreateRefreshToken(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.RevokeRefreshToken(ctx, "a2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.RevokeUserRefreshTokens(ctx, alice.ID); err != nil || n != 1 {
+		t.Errorf("RevokeUserRefreshTokens = %d, %v", n, err)
+	}
+	_, err := s.GetUserFromRefreshToken(ctx, "a1")
+	wantNoRows(t, "revoked token", err)
+	if _, err := s.GetUserFromRefreshToken(ctx, "b1"); err != nil {
+		t.Errorf("another user's token was revoked: %v", err)
+	}
+
+	if err := s.RevokeAccessTokens(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpgradeRed(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DowngradeRed(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetUserByID(ctx, alice.ID)
+	if err != nil || !got.TokensRevokedAt.Valid || got.IsChirpyRed {
+		t.Errorf("GetUserByID = %+v, %v", got, err)
+	}
+}
+
+func testChirpReports(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	mod := mustUser(t, s, "mod@example.com", "")
+	first := mustChirp(t, s, alice.ID, "first")
+	second := mustChirp(t, s, alice.ID, "second")
+
+	report := func(chirpID, reporterID uuid.UUID) (database.ChirpReport, error) {
+		return s.CreateChirpReport(ctx, database.CreateChirpReportParams{ChirpID: chirpID, ReporterID: reporterID, Reason: "spam", Details: "ads"})
+	}
+	r1, err := report(first.ID, bob.ID)
+	if err != nil || r1.ID == uuid.Nil || r1.Reason != "spam" || r1.Details != "ads" || r1.ResolvedAt.Valid {
+		t.Fatalf("CreateChirpReport = %+v, %v", r1, err)
+	}
+	_, err = report(first.ID, bob.ID)
+	wantNoRows(t, "reporting a chirp twice", err)
+	if _, err := report(first.ID, mod.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := report(second.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := s.ListOpenChirpReports(ctx)
+	if err != nil || len(open) != 3 || open[0].ID != r1.ID {
+		t.Fatalf("ListOpenChirpReports = %+v, %v", open, err)
+	}
+
+	n, err := s.ResolveChirpReports(ctx, database.ResolveChirpReportsParams{
+		ResolvedBy: uuid.NullUUID{UUID: mod.ID, Valid: true},
+		Resolution: sql.NullString{String: "dismiss", Valid: true},
+		ChirpID:    first.ID,
+	})
+	if err != nil || n != 2 {
+		t.Errorf("ResolveChirpReports = %d, %v", n, err)
+	}
+	open, err = s.ListOpenChirpReports(ctx)
+	if err != nil || len(open) != 1 || open[0].ChirpID != second.ID {
+		t.Errorf("ListOpenChirpReports after resolving = %+v, %v", open, err)
+	}
+
+	if err := s.DeleteChirp(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if open, _ := s.ListOpenChirpReports(ctx); len(open) != 0 {
+		t.Errorf("reports outlived their chirp: %+v", open)
+	}
+}
+
+func testRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+
+	tok, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token: "live", UserID: alice.ID, ExpiresAt: time.Now().UTC().Add(time.Hour),
+	})
+	if err != nil || tok.Token != "live" || tok.RevokedAt.Valid {
+		t.Fatalf("CreateRefreshToken = %+v, %v", tok, err)
+	}
+	_, err = s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token: "expired", UserID: alice.ID, ExpiresAt: time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetUserFromRefreshToken(ctx, "live")
+	if err != nil || got.ID != alice.ID {
+		t.Errorf("GetUserFromRefreshToken = %+v, %v", got, err)
+	}
+	_, err = s.GetUserFromRefreshToken(ctx, "expired")
+	wantNoRows(t, "GetUserFromRefreshToken(expired)", err)
+
+	if err := s.RevokeRefreshToken(ctx, "live"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetUserFromRefreshToken(ctx, "live")
+	wantNoRows(t, "GetUserFromRefreshToken(revoked)", err)
+
+	n, err := s.DeleteExpiredRefreshTokens(ctx, time.Now().UTC())
+	if err != nil || n != 1 {
+		t.Errorf("DeleteExpiredRefreshTokens = %d, %v; want 1", n, err)
+	}
+	tokens, err := s.ListUserRefreshTokens(ctx, alice.ID)
+	if err != nil || len(tokens) != 1 || tokens[0].Token != "live" {
+		t.Errorf("ListUserRefreshTokens after DeleteExpiredRefreshTokens = %+v, %v", tokens, err)
+	}
+}
+
+func testFollows(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	carol := mustUser(t, s, "carol@example.com", "")
+
+	for i, want := range []int64{1, 0} {
+		n, err := s.FollowUser(ctx, database.FollowUserParams{FollowerID: alice.ID, FolloweeID: bob.ID})
+		if err != nil || n != want {
+			t.Errorf("FollowUser #%d = %d, %v; want %d", i+1, n, err, want)
+		}
+	}
+	s.FollowUser(ctx, database.FollowUserParams{FollowerID: alice.ID, FolloweeID: carol.ID})
+
+	ids, err := s.GetFolloweeIDs(ctx, alice.ID)
+	if err != nil || len(ids) != 2 {
+		t.Errorf("GetFolloweeIDs = %v, %v", ids, err)
+	}
+
+	if err := s.UnfollowUser(ctx, database.UnfollowUserParams{FollowerID: alice.ID, FolloweeID: bob.ID}); err != nil {
+		t.Fatal(err)
+	}
+	ids, _ = s.GetFolloweeIDs(ctx, alice.ID)
+	if len(ids) != 1 || ids[0] != carol.ID {
+		t.Errorf("GetFolloweeIDs after unfollow = %v", ids)
+	}
+}
+
+func testBlocks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	carol := mustUser(t, s, "carol@example.com", "")
+
+	for i, want := range []int64{1, 0} {
+		n, err := s.BlockUser(ctx, database.BlockUserParams{BlockerID: alice.ID, BlockedID: bob.ID})
+		if err != nil || n != want {
+			t.Errorf("BlockUser #%d = %d, %v; want %d", i+1, n, err, want)
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+	s.BlockUser(ctx, database.BlockUserParams{BlockerID: alice.ID, BlockedID: carol.ID})
+	s.BlockUser(ctx, database.BlockUserParams{BlockerID: bob.ID, BlockedID: alice.ID})
+
+	blocks, err := s.ListBlocks(ctx, alice.ID)
+	if err != nil || len(blocks) != 2 || blocks[0].BlockedID != bob.ID || blocks[1].BlockedID != carol.ID || blocks[0].CreatedAt.IsZero() {
+		t.Errorf("ListBlocks = %+v, %v", blocks, err)
+	}
+	// Bob blocked alice back, but he is listed once.
+	ids, err := s.GetBlockedEitherWayIDs(ctx, alice.ID)
+	if err != nil || len(ids) != 2 {
+		t.Errorf("GetBlockedEitherWayIDs = %v, %v", ids, err)
+	}
+	ids, _ = s.GetBlockedEitherWayIDs(ctx, carol.ID)
+	if len(ids) != 1 || ids[0] != alice.ID {
+		t.Errorf("GetBlockedEitherWayIDs for the blocked user = %v", ids)
+	}
+	for _, tt := range []struct {
+		a, b uuid.UUID
+		want int64
+	}{
+		{alice.ID, bob.ID, 2},
+		{carol.ID, alice.ID, 1},
+		{bob.ID, carol.ID, 0},
+	} {
+		if n, err := s.CountBlocksBetween(ctx, database.CountBlocksBetweenParams{UserID: tt.a, OtherID: tt.b}); err != nil || n != tt.want {
+			t.Errorf("CountBlocksBetween = %d, %v; want %d", n, err, tt.want)
+		}
+	}
+
+	if n, err := s.UnblockUser(ctx, database.UnblockUserParams{BlockerID: alice.ID, BlockedID: carol.ID}); err != nil || n != 1 {
+		t.Errorf("UnblockUser = %d, %v", n, err)
+	}
+	if n, _ := s.UnblockUser(ctx, database.UnblockUserParams{BlockerID: alice.ID, BlockedID: carol.ID}); n != 0 {
+		t.Errorf("UnblockUser again = %d", n)
+	}
+	if n, _ := s.CountBlocksBetween(ctx, database.CountBlocksBetweenParams{UserID: alice.ID, OtherID: carol.ID}); n != 0 {
+		t.Errorf("CountBlocksBetween after unblocking = %d", n)
+	}
+}
+
+func testMutes(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+
+	for i, want := range []int64{1, 0} {
+		n, err := s.MuteUser(ctx, database.MuteUserParams{MuterID: alice.ID, MutedID: bob.ID})
+		if err != nil || n != want {
+			t.Errorf("MuteUser #%d = %d, %v; want %d", i+1, n, err, want)
+		}
+	}
+
+	mutes, err := s.ListMutes(ctx, alice.ID)
+	if err != nil || len(mutes) != 1 || mutes[0].MutedID != bob.ID || mutes[0].CreatedAt.IsZero() {
+		t.Errorf("ListMutes = %+v, %v", mutes, err)
+	}
+	ids, err := s.GetMutedUserIDs(ctx, alice.ID)
+	if err != nil || len(ids) != 1 || ids[0] != bob.ID {
+		t.Errorf("GetMutedUserIDs = %v, %v", ids, err)
+	}
+	// Muting is one-sided.
+	if ids, _ := s.GetMutedUserIDs(ctx, bob.ID); len(ids) != 0 {
+		t.Errorf("GetMutedUserIDs for the muted user = %v", ids)
+	}
+
+	if n, err := s.UnmuteUser(ctx, database.UnmuteUserParams{MuterID: alice.ID, MutedID: bob.ID}); err != nil || n != 1 {
+		t.Errorf("UnmuteUser = %d, %v", n, err)
+	}
+	if ids, _ := s.GetMutedUserIDs(ctx, alice.ID); len(ids) != 0 {
+		t.Errorf("GetMutedUserIDs after unmuting = %v", ids)
+	}
+}
+
+func testLikes(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	chirp := mustChirp(t, s, alice.ID, "like me")
+
+	params := database.LikeChirpParams{UserID: alice.ID, ChirpID: chirp.ID}
+	for i, want := range []int64{1, 0} {
+		n, err := s.LikeChirp(ctx, params)
+		if err != nil || n != want {
+			t.Errorf("LikeChirp #%d = %d, %v; want %d", i+1, n, err, want)
+		}
+	}
+	if err := s.UnlikeChirp(ctx, database.UnlikeChirpParams(params)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.LikeChirp(ctx, params)
+	if err != nil || n != 1 {
+		t.Errorf("LikeChirp after unlike = %d, %v; want 1", n, err)
+	}
+}
+
+func testNotifications(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	chirp := mustChirp(t, s, alice.ID, "hi")
+
+	n, err := s.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  alice.ID,
+		ActorID: bob.ID,
+		Kind:    "like",
+		ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	})
+	if err != nil || n.ID == uuid.Nil || n.ReadAt.Valid || n.ChirpID.UUID != chirp.ID {
+		t.Fatalf("CreateNotification = %+v, %v", n, err)
+	}
+	_, err = s.CreateNotification(ctx, database.CreateNotificationParams{UserID: alice.ID, ActorID: bob.ID, Kind: "follow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := s.CountUnreadNotifications(ctx, alice.ID)
+	if err != nil || count != 2 {
+		t.Errorf("CountUnreadNotifications = %d, %v; want 2", count, err)
+	}
+
+	rows, err := s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: n.ID, UserID: bob.ID})
+	if err != nil || rows != 0 {
+		t.Errorf("MarkNotificationRead by another user = %d, %v; want 0", rows, err)
+	}
+	rows, err = s.MarkNotificationRead(ctx, database.MarkNotificationReadParams{ID: n.ID, UserID: alice.ID})
+	if err != nil || rows != 1 {
+		t.Errorf("MarkNotificationRead = %d, %v; want 1", rows, err)
+	}
+
+	unread, err := s.ListNotifications(ctx, database.ListNotificationsParams{
+		UserID:     alice.ID,
+		Before:     time.Now().UTC().Add(time.Hour),
+		UnreadOnly: true,
+		PageSize:   10,
+	})
+	if err != nil || len(unread) != 1 || unread[0].Kind != "follow" {
+		t.Errorf("ListNotifications(unread) = %+v, %v", unread, err)
+	}
+
+	if err := s.MarkAllNotificationsRead(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	count, _ = s.CountUnreadNotifications(ctx, alice.ID)
+	if count != 0 {
+		t.Errorf("CountUnreadNotifications after MarkAll = %d", count)
+	}
+
+	// Notifications about a chirp go away with it.
+	s.DeleteChirp(ctx, chirp.ID)
+	all, _ := s.ListNotifications(ctx, database.ListNotificationsParams{
+		UserID: alice.ID, Before: time.Now().UTC().Add(time.Hour), PageSize: 10,
+	})
+	if len(all) != 1 {
+		t.Errorf("ListNotifications after chirp delete = %d rows, want 1", len(all))
+	}
+}
+
+func testNotificationPages(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+
+	const total = 7
+	for i := 0; i < total; i++ {
+		_, err := s.CreateNotification(ctx, database.CreateNotificationParams{UserID: alice.ID, ActorID: bob.ID, Kind: "follow"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[uuid.UUID]bool{}
+	before, beforeID := time.Now().UTC().Add(time.Hour), uuid.Nil
+	var last database.Notification
+	for page := 0; page < total; page++ {
+		rows, err := s.ListNotifications(ctx, database.ListNotificationsParams{
+			UserID: alice.ID, Before: before, BeforeID: beforeID, PageSize: 3,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, n := range rows {
+			if seen[n.ID] {
+				t.Fatalf("notification %s returned twice", n.ID)
+			}
+			if !last.CreatedAt.IsZero() && n.CreatedAt.After(last.CreatedAt) {
+				t.Errorf("notifications out of order: %s after %s", n.CreatedAt, last.CreatedAt)
+			}
+			seen[n.ID] = true
+			last = n
+		}
+		before, beforeID = last.CreatedAt, last.ID
+	}
+	if len(seen) != total {
+		t.Errorf("paged through %d notifications, want %d", len(seen), total)
+	}
+}
+
+func testNotificationPreferences(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+
+	prefs, err := s.GetNotificationPreferences(ctx, alice.ID)
+	if err != nil || len(prefs) != 0 {
+		t.Errorf("GetNotificationPreferences = %+v, %v; want none", prefs, err)
+	}
+
+	for _, email := range []bool{true, false} {
+		err := s.UpsertNotificationPreference(ctx, database.UpsertNotificationPreferenceParams{
+			UserID: alice.ID, Kind: "like", InApp: false, Email: email,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	prefs, _ = s.GetNotificationPreferences(ctx, alice.ID)
+	if len(prefs) != 1 || prefs[0].InApp || prefs[0].Email {
+		t.Errorf("GetNotificationPreferences after upserts = %+v", prefs)
+	}
+}
+
+func testChirpEvents(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		e, err := s.CreateChirpEvent(ctx, database.CreateChirpEventParams{
+			Kind: "chirp.created", ChirpID: uuid.New(), UserID: alice.ID, Body: "hi",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) > 0 && e.ID <= ids[len(ids)-1] {
+			t.Errorf("event IDs not increasing: %d after %d", e.ID, ids[len(ids)-1])
+		}
+		ids = append(ids, e.ID)
+	}
+
+	e, err := s.GetChirpEvent(ctx, ids[1])
+	if err != nil || e.ID != ids[1] || e.Body != "hi" {
+		t.Errorf("GetChirpEvent = %+v, %v", e, err)
+	}
+	after, err := s.GetChirpEventsAfter(ctx, database.GetChirpEventsAfterParams{ID: ids[0], Limit: 1})
+	if err != nil || len(after) != 1 || after[0].ID != ids[1] {
+		t.Errorf("GetChirpEventsAfter = %+v, %v", after, err)
+	}
+}
+
+func testUserActivity(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	carol := mustUser(t, s, "carol@example.com", "")
+	chirp := mustChirp(t, s, bob.ID, "like me")
+
+	for _, f := range []database.FollowUserParams{
+		{FollowerID: alice.ID, FolloweeID: bob.ID},
+		{FollowerID: carol.ID, FolloweeID: alice.ID},
+		{FollowerID: bob.ID, FolloweeID: carol.ID},
+	} {
+		if _, err := s.FollowUser(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	follows, err := s.ListUserFollows(ctx, alice.ID)
+	if err != nil || len(follows) != 2 {
+		t.Errorf("ListUserFollows = %+v, %v", follows, err)
+	}
+
+	if _, err := s.LikeChirp(ctx, database.LikeChirpParams{UserID: alice.ID, ChirpID: chirp.ID}); err != nil {
+		t.Fatal(err)
+	}
+	likes, err := s.ListUserLikes(ctx, alice.ID)
+	if err != nil || len(likes) != 1 || likes[0].ChirpID != chirp.ID {
+		t.Errorf("ListUserLikes = %+v, %v", likes, err)
+	}
+
+	expires := time.Now().UTC().Add(time.Hour)
+	for _, tok := range []database.CreateRefreshTokenParams{
+		{Token: "a1", UserID: alice.ID, ExpiresAt: expires},
+		{Token: "b1", UserID: bob.ID, ExpiresAt: expires},
+	} {
+		if _, err := s.CreateRefreshToken(ctx, tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tokens, err := s.ListUserRefreshTokens(ctx, alice.ID)
+	if err != nil || len(tokens) != 1 || tokens[0].Token != "a1" {
+		t.Errorf("ListUserRefreshTokens = %+v, %v", tokens, err)
+	}
+
+	_, err = s.CreateChirpReport(ctx, database.CreateChirpReportParams{ChirpID: chirp.ID, ReporterID: alice.ID, Reason: "spam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, err := s.ListUserChirpReports(ctx, alice.ID)
+	if err != nil || len(reports) != 1 || reports[0].Reason != "spam" {
+		t.Errorf("ListUserChirpReports = %+v, %v", reports, err)
+	}
+	if reports, _ := s.ListUserChirpReports(ctx, bob.ID); len(reports) != 0 {
+		t.Errorf("ListUserChirpReports for the author = %+v", reports)
+	}
+}
+
+func testDataExports(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+
+	export, err := s.CreateDataExport(ctx, alice.ID)
+	if err != nil || export.UserID != alice.ID || export.CompletedAt.Valid {
+		t.Fatalf("CreateDataExport = %+v, %v", export, err)
+	}
+	if got, err := s.GetPendingDataExport(ctx, alice.ID); err != nil || got.ID != export.ID {
+		t.Errorf("GetPendingDataExport = %+v, %v", got, err)
+	}
+
+	claimed, err := s.ClaimDataExports(ctx, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != export.ID || !claimed[0].ClaimedUntil.Valid {
+		t.Fatalf("ClaimDataExports = %+v, %v", claimed, err)
+	}
+	// A claimed export isn't handed out again until its lease runs out.
+	if claimed, _ := s.ClaimDataExports(ctx, 10); len(claimed) != 0 {
+		t.Errorf("ClaimDataExports claimed %d exports twice", len(claimed))
+	}
+
+	archive := []byte("PK\x03\x04")
+	if err := s.CompleteDataExport(ctx, database.CompleteDataExportParams{Archive: archive, ID: export.ID}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetDataExport(ctx, export.ID)
+	if err != nil || !got.CompletedAt.Valid || !bytes.Equal(got.Archive, archive) || got.Error.Valid {
+		t.Errorf("GetDataExport = %+v, %v", got, err)
+	}
+	_, err = s.GetPendingDataExport(ctx, alice.ID)
+	wantNoRows(t, "GetPendingDataExport after completing", err)
+
+	if err := s.DeleteDataExportsBefore(ctx, time.Now().UTC().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetDataExport(ctx, export.ID); err != nil {
+		t.Errorf("a newer export was deleted: %v", err)
+	}
+	if err := s.DeleteDataExportsBefore(ctx, time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetDataExport(ctx, export.ID)
+	wantNoRows(t, "GetDataExport after DeleteDataExportsBefore", err)
+}
+
+func testImport(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	existing := mustUser(t, s, "alice@example.com", "alice")
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := database.ImportUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      created,
+		UpdatedAt:      created.Add(time.Hour),
+		Email:          "bob@example.com",
+		HashedPassword: "hash",
+		IsChirpyRed:    true,
+		Username:       sql.NullString{String: "bob", Valid: true},
+		Role:           "moderator",
+	}
+	if err := s.ImportUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetUserByID(ctx, user.ID)
+	if err != nil || !got.CreatedAt.Equal(created) || got.Email != user.Email || got.Role != "moderator" || !got.IsChirpyRed {
+		t.Errorf("GetUserByID after ImportUser = %+v, %v", got, err)
+	}
+	user.Email = "robert@example.com"
+	if err := s.ImportUser(ctx, user); err != nil {
+		t.Fatalf("ImportUser over an existing user: %v", err)
+	}
+	if got, _ := s.GetUserByID(ctx, user.ID); got.Email != user.Email {
+		t.Errorf("ImportUser didn't overwrite: email %q", got.Email)
+	}
+	clash := user
+	clash.ID = uuid.New()
+	if err := s.ImportUser(ctx, clash); err == nil {
+		t.Errorf("ImportUser with another user's email succeeded")
+	}
+
+	chirp := database.ImportChirpParams{
+		ID:        uuid.New(),
+		CreatedAt: created,
+		UpdatedAt: created,
+		Body:      "from the old server",
+		UserID:    user.ID,
+		Status:    "published",
+	}
+	if err := s.ImportChirp(ctx, chirp); err != nil {
+		t.Fatal(err)
+	}
+	later := mustChirp(t, s, existing.ID, "welcome")
+	chirp.Body = "edited"
+	if err := s.ImportChirp(ctx, chirp); err != nil {
+		t.Fatalf("ImportChirp over an existing chirp: %v", err)
+	}
+	if got, err := s.GetSingleChirp(ctx, chirp.ID); err != nil || got.Body != "edited" || !got.CreatedAt.Equal(created) {
+		t.Errorf("GetSingleChirp after ImportChirp = %+v, %v", got, err)
+	}
+	orphan := chirp
+	orphan.ID = uuid.New()
+	orphan.UserID = uuid.New()
+	if err := s.ImportChirp(ctx, orphan); err == nil {
+		t.Errorf("ImportChirp for a missing user succeeded")
+	}
+
+	page, err := s.ListChirpsPage(ctx, database.ListChirpsPageParams{PageSize: 1})
+	if err != nil || len(page) != 1 || page[0].ID != chirp.ID {
+		t.Errorf("ListChirpsPage first page = %+v, %v", page, err)
+	}
+	page, err = s.ListChirpsPage(ctx, database.ListChirpsPageParams{PageSize: 10, PageOffset: 1})
+	if err != nil || len(page) != 1 || page[0].ID != later.ID {
+		t.Errorf("ListChirpsPage second page = %+v, %v", page, err)
+	}
+}
+
+func testStats(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+	mustUser(t, s, "carol@example.com", "")
+	if err := s.UpgradeRed(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	s.SuspendUser(ctx, database.SuspendUserParams{SuspendedUntil: sql.NullTime{Time: now.Add(time.Hour), Valid: true}, ID: bob.ID})
+	s.SetUserDeleted(ctx, database.SetUserDeletedParams{DeletedAt: sql.NullTime{Time: now, Valid: true}, ID: bob.ID})
+	chirp := mustChirp(t, s, alice.ID, "hello")
+	mustChirp(t, s, alice.ID, "again")
+	mustChirp(t, s, bob.ID, "hi")
+	s.SetChirpStatus(ctx, database.SetChirpStatusParams{Status: "held", ID: chirp.ID})
+	s.FollowUser(ctx, database.FollowUserParams{FollowerID: bob.ID, FolloweeID: alice.ID})
+	s.LikeChirp(ctx, database.LikeChirpParams{UserID: bob.ID, ChirpID: chirp.ID})
+	for _, tok := range []struct {
+		token   string
+		expires time.Time
+	}{{"live", now.Add(time.Hour)}, {"expired", now.Add(-time.Hour)}} {
+		s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: tok.token, UserID: alice.ID, ExpiresAt: tok.expires})
+	}
+
+	got, err := s.GetStats(ctx, now)
+	want := database.GetStatsRow{
+		Users: 3, RedUsers: 1, SuspendedUsers: 1, DeletedUsers: 1,
+		Chirps: 3, Follows: 1, Likes: 1, ActiveSessions: 1,
+	}
+	if err != nil || got != want {
+		t.Errorf("GetStats = %+v, %v; want %+v", got, err, want)
+	}
+
+	counts, err := s.CountChirpsByStatus(ctx)
+	wantCounts := []database.CountChirpsByStatusRow{{Status: "held", Count: 1}, {Status: "published", Count: 2}}
+	if err != nil || !slices.Equal(counts, wantCounts) {
+		t.Errorf("CountChirpsByStatus = %+v, %v; want %+v", counts, err, wantCounts)
+	}
+}
+
+func testActorKeys(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+
+	_, err := s.GetActorKey(ctx, alice.ID)
+	wantNoRows(t, "GetActorKey before create", err)
+
+	for _, pem := range []string{"first", "second"} {
+		err := s.CreateActorKey(ctx, database.CreateActorKeyParams{UserID: alice.ID, PublicKeyPem: pem, PrivateKeyPem: pem})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, err := s.GetActorKey(ctx, alice.ID)
+	if err != nil || key.PublicKeyPem != "first" {
+		t.Errorf("GetActorKey = %+v, %v; the first key must win", key, err)
+	}
+}
+
+func testRemoteFollowers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+	bob := mustUser(t, s, "bob@example.com", "")
+
+	add := func(user uuid.UUID, actor, inbox string) {
+		t.Helper()
+		err := s.AddRemoteFollower(ctx, database.AddRemoteFollowerParams{UserID: user, ActorID: actor, Inbox: inbox})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(alice.ID, "https://a.example/u/1", "https://a.example/inbox")
+	add(alice.ID, "https://a.example/u/2", "https://a.example/inbox")
+	add(alice.ID, "https://b.example/u/1", "https://b.example/old")
+	add(alice.ID, "https://b.example/u/1", "https://b.example/inbox")
+	add(bob.ID, "https://b.example/u/1", "https://b.example/inbox")
+
+	count, err := s.CountRemoteFollowers(ctx, alice.ID)
+	if err != nil || count != 3 {
+		t.Errorf("CountRemoteFollowers = %d, %v; want 3", count, err)
+	}
+	inboxes, err := s.GetRemoteFollowerInboxes(ctx, alice.ID)
+	if err != nil || len(inboxes) != 2 {
+		t.Errorf("GetRemoteFollowerInboxes = %v, %v; want 2 distinct", inboxes, err)
+	}
+
+	s.RemoveRemoteFollower(ctx, database.RemoveRemoteFollowerParams{UserID: alice.ID, ActorID: "https://a.example/u/2"})
+	s.RemoveRemoteActor(ctx, "https://b.example/u/1")
+	if count, _ := s.CountRemoteFollowers(ctx, alice.ID); count != 1 {
+		t.Errorf("CountRemoteFollowers(alice) after removals = %d, want 1", count)
+	}
+	if count, _ := s.CountRemoteFollowers(ctx, bob.ID); count != 0 {
+		t.Errorf("CountRemoteFollowers(bob) after RemoveRemoteActor = %d, want 0", count)
+	}
+}
+
+func testDeliveries(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := mustUser(t, s, "alice@example.com", "")
+
+	for _, inbox := range []string{"https://a.example/inbox", "https://b.example/inbox", "https://c.example/inbox"} {
+		err := s.EnqueueDelivery(ctx, database.EnqueueDeliveryParams{UserID: alice.ID, Inbox: inbox, Payload: "{}"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed, err := s.ClaimDeliveries(ctx, database.ClaimDeliveriesParams{Attempts: 3, Limit: 2})
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("ClaimDeliveries = %d, %v; want 2", len(claimed), err)
+	}
+	for _, d := range claimed {
+		if d.Attempts != 1 {
+			t.Errorf("claimed delivery has %d attempts, want 1", d.Attempts)
+		}
+	}
+
+	// Claimed deliveries are leased, so only the third is due.
+	rest, _ := s.ClaimDeliveries(ctx, database.ClaimDeliveriesParams{Attempts: 3, Limit: 10})
+	if len(rest) != 1 {
+		t.Errorf("second ClaimDeliveries = %d, want 1", len(rest))
+	}
+
+	if err := s.DeleteDelivery(ctx, claimed[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	err = s.RescheduleDelivery(ctx, database.RescheduleDeliveryParams{
+		NextAttemptAt: time.Now().UTC().Add(-time.Second),
+		LastError:     sql.NullString{String: "timeout", Valid: true},
+		ID:            claimed[1].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := s.ClaimDeliveries(ctx, database.ClaimDeliveriesParams{Attempts: 3, Limit: 10})
+	if len(again) != 1 || again[0].ID != claimed[1].ID || again[0].Attempts != 2 || again[0].LastError.String != "timeout" {
+		t.Errorf("ClaimDeliveries after reschedule = %+v", again)
+	}
+
+	// Deliveries that used up their attempts are never claimed again.
+	s.RescheduleDelivery(ctx, database.RescheduleDeliveryParams{NextAttemptAt: time.Now().UTC().Add(-time.Second), ID: claimed[1].ID})
+	none, _ := s.ClaimDeliveries(ctx, database.ClaimDeliveriesParams{Attempts: 2, Limit: 10})
+	if len(none) != 0 {
+		t.Errorf("ClaimDeliveries past max attempts = %+v", none)
+	}
+}
@@ -0,0 +1,106 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// the hub gives up on it. A dropped client reconnects with Last-Event-ID
+// and catches up from the database.
+const subscriberBuffer = 64
+
+// SeenWindow is how many recent event IDs the hub remembers so that an
+// event published locally is not delivered again when the same event
+// comes back through LISTEN/NOTIFY.
+const SeenWindow = 1024
+
+type Event struct {
+	ID        int64
+	Type      string
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Body      string
+	CreatedAt time.Time
+}
+
+// Filter decides whether a subscriber is interested in an event.
+// A nil Filter accepts everything.
+type Filter func(Event) bool
+
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter Filter
+}
+
+// Hub fans events out to every subscriber whose filter matches. Slow
+// subscribers are disconnected rather than allowed to block publishers.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+	seen *SeenIDs
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[*Subscription]struct{}),
+		seen: NewSeenIDs(SeenWindow),
+	}
+}
+
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	c := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, filter: filter}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.c)
+	}
+}
+
+// Publish delivers the event to all matching subscribers. Events are
+// deduplicated by ID, so it is safe to publish the same event both locally
+// and from a database notification.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.seen.Add(e.ID) {
+		return
+	}
+
+	for sub := range h.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			delete(h.subs, sub)
+			close(sub.c)
+		}
+	}
+}
+
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
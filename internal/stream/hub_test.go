@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHubPublishFiltersAndDedupes(t *testing.T) {
+	hub := NewHub()
+	author := uuid.New()
+
+	all := hub.Subscribe(nil)
+	byAuthor := hub.Subscribe(func(e Event) bool { return e.UserID == author })
+
+	hub.Publish(Event{ID: 1, Type: EventChirpCreated, UserID: author})
+	hub.Publish(Event{ID: 2, Type: EventChirpCreated, UserID: uuid.New()})
+	hub.Publish(Event{ID: 1, Type: EventChirpCreated, UserID: author})
+
+	if got := len(all.C); got != 2 {
+		t.Fatalf("expected 2 events for unfiltered subscriber, got %d", got)
+	}
+	if got := len(byAuthor.C); got != 1 {
+		t.Fatalf("expected 1 event for filtered subscriber, got %d", got)
+	}
+	if e := <-byAuthor.C; e.ID != 1 {
+		t.Fatalf("expected event 1, got %d", e.ID)
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(nil)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		hub.Publish(Event{ID: int64(i + 1)})
+	}
+
+	if hub.Subscribers() != 0 {
+		t.Fatalf("expected slow subscriber to be dropped")
+	}
+	for range sub.C {
+	}
+	// Unsubscribing an already dropped subscriber must not panic.
+	hub.Unsubscribe(sub)
+}
+
+func TestSeenIDsOutOfOrder(t *testing.T) {
+	seen := NewSeenIDs(3)
+	for _, id := range []int64{5, 3, 4} {
+		if !seen.Add(id) {
+			t.Errorf("Add(%d) = false for a new ID", id)
+		}
+	}
+	if seen.Add(3) {
+		t.Error("Add(3) = true for a seen ID")
+	}
+	seen.Add(6)
+	if !seen.Add(5) {
+		t.Error("Add(5) = false after it left the window")
+	}
+}
@@ -0,0 +1,60 @@
+package stream
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the PostgreSQL NOTIFY channel the chirp_events trigger uses.
+const Channel = "chirp_events"
+
+// FetchFunc loads a stored event by ID.
+type FetchFunc func(ctx context.Context, id int64) (Event, error)
+
+// Listen forwards chirp events committed by any Chirpy instance to the hub
+// until ctx is cancelled. Notifications only carry the event ID, the event
+// itself is loaded with fetch.
+func Listen(ctx context.Context, dbURL string, hub *Hub, fetch FetchFunc) error {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("stream listener", "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(Channel); err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ping.C:
+			go listener.Ping()
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				continue
+			}
+			id, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				slog.Warn("stream listener: bad payload", "payload", n.Extra)
+				continue
+			}
+			e, err := fetch(ctx, id)
+			if err != nil {
+				slog.Error("stream listener: could not load event", "event_id", id, "error", err)
+				continue
+			}
+			hub.Publish(e)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package stream
+
+// SeenIDs remembers the last window event IDs it was given, in the order
+// they arrived. Event IDs are assigned when an event is written but become
+// visible when its transaction commits, so a lower ID can arrive after a
+// higher one; remembering the IDs themselves, rather than the highest one,
+// still lets it through.
+type SeenIDs struct {
+	seen map[int64]struct{}
+	ring []int64
+	next int
+}
+
+func NewSeenIDs(window int) *SeenIDs {
+	return &SeenIDs{
+		seen: make(map[int64]struct{}, window),
+		ring: make([]int64, 0, window),
+	}
+}
+
+// Add records id, returning false if it was already seen.
+func (s *SeenIDs) Add(id int64) bool {
+	if _, ok := s.seen[id]; ok {
+		return false
+	}
+	if len(s.ring) < cap(s.ring) {
+		s.ring = append(s.ring, id)
+	} else {
+		delete(s.seen, s.ring[s.next])
+		s.ring[s.next] = id
+		s.next = (s.next + 1) % len(s.ring)
+	}
+	s.seen[id] = struct{}{}
+	return true
+}
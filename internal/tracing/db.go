@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is looked up on every use so a provider installed later, as tests
+// do, is picked up.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/enderbd/chirpy/internal/tracing")
+}
+
+// DBTX is the query interface shared by the sqlc-generated packages.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// DB runs every query in a client span named after the sqlc query, with
+// the SQL but never its arguments. A span for QueryContext ends when the
+// query returns, not when its rows have been read.
+type DB struct {
+	DBTX
+	system string
+}
+
+// NewDB wraps db. system is the db.system attribute, e.g. postgresql.
+func NewDB(db DBTX, system string) *DB {
+	return &DB{DBTX: db, system: system}
+}
+
+func (db *DB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := queryName(query)
+	return tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", db.system),
+			attribute.String("db.operation.name", name),
+			attribute.String("db.query.text", query),
+		),
+	)
+}
+
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := db.start(ctx, query)
+	res, err := db.DBTX.ExecContext(ctx, query, args...)
+	end(span, err)
+	return res, err
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := db.start(ctx, query)
+	rows, err := db.DBTX.QueryContext(ctx, query, args...)
+	end(span, err)
+	return rows, err
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := db.start(ctx, query)
+	row := db.DBTX.QueryRowContext(ctx, query, args...)
+	end(span, row.Err())
+	return row
+}
+
+// queryName returns the sqlc name of query, taken from its leading
+// "-- name: GetUser :one" comment, or "db.query".
+func queryName(query string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(query), "\n")
+	if name, ok := strings.CutPrefix(first, "-- name: "); ok {
+		name, _, _ = strings.Cut(name, " ")
+		return name
+	}
+	return "db.query"
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a provider that keeps finished spans in memory for
+// the rest of the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return exporter
+}
+
+type fakeDB struct {
+	DBTX
+	err error
+}
+
+func (db fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, db.err
+}
+
+func TestDBSpans(t *testing.T) {
+	spans := recordSpans(t)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	const query = "-- name: DeleteUsers :exec\nDELETE FROM users"
+	NewDB(fakeDB{}, "postgresql").ExecContext(ctx, query, "secret-arg")
+	NewDB(fakeDB{err: errors.New("boom")}, "postgresql").ExecContext(ctx, query)
+	parent.End()
+
+	got := spans.GetSpans()
+	if len(got) != 3 {
+		t.Fatalf("got %d spans, want 3", len(got))
+	}
+	for _, span := range got[:2] {
+		if span.Name != "DeleteUsers" {
+			t.Errorf("span name = %q", span.Name)
+		}
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Error("query span is not a child of the request span")
+		}
+		for _, attr := range span.Attributes {
+			if strings.Contains(attr.Value.Emit(), "secret-arg") {
+				t.Errorf("argument recorded in %s", attr.Key)
+			}
+		}
+	}
+	if got[0].Status.Code == codes.Error || got[1].Status.Code != codes.Error {
+		t.Errorf("statuses = %v, %v", got[0].Status, got[1].Status)
+	}
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterFile, File: path, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "written-to-file")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Name":"written-to-file"`) || !strings.Contains(string(data), "chirpy") {
+		t.Errorf("span not exported to file:\n%s", data)
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Options{Exporter: "zipkin"}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -1,90 +1,357 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/enderbd/chirpy/internal/database"
-	"github.com/joho/godotenv"
+	"github.com/enderbd/chirpy/internal/activitypub"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/metrics"
+	"github.com/enderbd/chirpy/internal/moderation"
+	"github.com/enderbd/chirpy/internal/notify"
+	"github.com/enderbd/chirpy/internal/ratelimit"
+	"github.com/enderbd/chirpy/internal/storage"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/enderbd/chirpy/internal/stream"
+	"github.com/enderbd/chirpy/internal/tracing"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
-	db             *database.Queries
+	db             store.Store
+	fileRoot string
 	platform string
 	secret string
 	polkaKey string
+	hub *stream.Hub
+	notifyHub notificationHub
+	baseURL string
+	ap *activitypub.Client
+	mailer notify.Sender
+	// emails queues notifications for the email sender; nil without a
+	// mailer.
+	emails chan emailJob
+	metrics *metrics.Metrics
+
+	// limiter is nil when rate limiting is off.
+	limiter    ratelimit.Store
+	rateLimits map[string]rateRule
+	trustProxy bool
+
+	// moderation is swapped whenever the rules change. adminKey passes as
+	// an admin on the admin endpoints.
+	moderation     atomic.Pointer[moderation.Pipeline]
+	moderationFile string
+	adminKey       string
+
+	// deletionGrace is how long a deleted account waits to be purged.
+	deletionGrace time.Duration
+
+	workers      workerSet
+	readyChecks  []readyCheck
+	shuttingDown atomic.Bool
+
+	// stopping is closed when the server shuts down to end open streams,
+	// websockets tracks the hijacked connections Shutdown can't see.
+	stopMu     sync.Mutex
+	stopping   chan struct{}
+	websockets sync.WaitGroup
 }
 
 func main() {
-	const port = "8080"
-	const filePathRoot = "."
-
-	godotenv.Load()
-	dbUrl := os.Getenv("DB_URL")
-	if dbUrl == "" {
-		log.Fatal("DB_URL enviroment variable not found, please set it in .env file!")
+	conf, args, err := loadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-	
-	platform := os.Getenv("PLATFORM")
-	
-	secret := os.Getenv("SECRET")
-	if secret == "" {
-		log.Fatal("No secret for authentication set as enviroment variable!")
+	if len(args) > 0 && args[0] == "config" {
+		if cmdErr := runConfigCommand(os.Stdout, conf, args[1:]); cmdErr != nil {
+			log.Fatal(cmdErr)
+		}
+		// Print first so the problems can be read against the values.
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
-	polkaKey := os.Getenv("POLKA_KEY")
-	if polkaKey == "" {
-		log.Fatal("Plka key enviroment not found!")
+
+	logLevel, _ := logging.ParseLevel(conf.LogLevel)
+	logger, err := logging.New(os.Stderr, conf.LogFormat, logLevel)
+	if err != nil {
+		log.Fatal(err)
 	}
+	slog.SetDefault(logger)
 
-	db, err := sql.Open("postgres", dbUrl)
+	dbStore, db, err := storage.Open(conf.DBURL, conf.LogSlowQuery)
 	if err != nil {
 		log.Fatalf("Could not open the chirpy database: %s", err)
 	}
-	dbQueries := database.New(db)
 
+	if len(args) > 0 && args[0] == "migrate" {
+		err := storage.RunMigrateCommand(context.Background(), os.Stdout, db, conf.DBURL, args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	err = storage.Prepare(context.Background(), db, conf.DBURL, conf.Migrate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "bulk" {
+		err := runBulkCommand(context.Background(), os.Stdout, os.Stderr, os.Stdin, dbStore, args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	apiCfg := apiConfig{
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    conf.TraceExporter,
+		File:        conf.TraceFile,
+		SampleRatio: conf.TraceSampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Could not set up tracing: %s", err)
+	}
+
+	var mailer notify.Sender
+	if conf.SMTPAddr != "" {
+		mailer = notify.NewSMTPSender(conf.SMTPAddr, conf.SMTPFrom, conf.SMTPUsername, conf.SMTPPassword)
+	}
+
+	apiCfg := &apiConfig{
 		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform: platform,
-		secret: secret,
-		polkaKey: polkaKey,
+		db:             dbStore,
+		fileRoot: conf.FileRoot,
+		platform: conf.Platform,
+		secret: conf.Secret,
+		polkaKey: conf.PolkaKey,
+		hub: stream.NewHub(),
+		baseURL: conf.BaseURL,
+		ap: activitypub.NewClient("Chirpy (+" + conf.BaseURL + ")"),
+		mailer: mailer,
+		metrics: metrics.New(),
+		trustProxy: conf.TrustProxy,
+		moderationFile: conf.ModerationRules,
+		adminKey: conf.AdminKey,
+		deletionGrace: conf.DeletionGracePeriod,
+	}
+	apiCfg.metrics.RegisterDB(db, "chirpy")
+	if mailer != nil {
+		apiCfg.emails = make(chan emailJob, emailQueueSize)
 	}
 
+	apiCfg.rateLimits, _ = parseRateLimits(conf.RateLimits)
+	apiCfg.limiter, err = newRateLimiter(conf.RateLimitStore, dbStore)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := apiCfg.reloadModeration(context.Background()); err != nil {
+		log.Fatalf("Could not load moderation rules: %s", err)
+	}
+
+	migrator, err := storage.NewMigrator(db, conf.DBURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiCfg.readyChecks = []readyCheck{dbCheck(db), migrationsCheck(migrator)}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background workers get their own contexts so they keep running while
+	// in-flight requests that may depend on them drain. They are stopped
+	// in stages, see workerStage.
+
+	// With SQLite there is only ever one instance, so the local hub already
+	// sees every event.
+	if _, ok := storage.SQLitePath(conf.DBURL); !ok {
+		apiCfg.workers.Go(stageListeners, "stream_listener", func(ctx context.Context) error {
+			return stream.Listen(ctx, conf.DBURL, apiCfg.hub, apiCfg.fetchChirpEvent)
+		})
+	}
+
+	if apiCfg.federationEnabled() {
+		apiCfg.workers.Go(stageSenders, "delivery", func(ctx context.Context) error {
+			apiCfg.runDeliveryWorker(ctx)
+			return nil
+		})
+	}
+
+	if apiCfg.limiter != nil {
+		apiCfg.workers.Go(stageProducers, "rate_limit_pruner", func(ctx context.Context) error {
+			pruneRateLimits(ctx, apiCfg.limiter)
+			return nil
+		})
+	}
+
+	if apiCfg.emails != nil {
+		apiCfg.workers.Go(stageSenders, "email_sender", func(ctx context.Context) error {
+			apiCfg.runEmailSender(ctx)
+			return nil
+		})
+	}
+
+	apiCfg.workers.Go(stageProducers, "moderation_reloader", func(ctx context.Context) error {
+		apiCfg.runModerationReloader(ctx)
+		return nil
+	})
+
+	apiCfg.workers.Go(stageProducers, "export_builder", func(ctx context.Context) error {
+		apiCfg.runExportBuilder(ctx)
+		return nil
+	})
+
+	apiCfg.workers.Go(stageProducers, "account_purger", func(ctx context.Context) error {
+		apiCfg.runAccountPurger(ctx)
+		return nil
+	})
+
+	apiCfg.workers.Go(stageProducers, "chirp_publisher", func(ctx context.Context) error {
+		apiCfg.runChirpPublisher(ctx)
+		return nil
+	})
+
+	server := apiCfg.newServer(":"+conf.Port, conf.Timeouts)
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Serving files", "root", conf.FileRoot, "port", conf.Port)
+	err = apiCfg.serve(ctx, server, ln, conf.Timeouts)
+	if err != nil {
+		slog.Error("Server stopped", logging.Err(err))
+	}
+
+	apiCfg.workers.Stop()
+	if err := db.Close(); err != nil {
+		slog.Error("Could not close the database", logging.Err(err))
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Could not flush traces", logging.Err(err))
+	}
+	slog.Info("Shutdown complete")
+}
+
+// routes registers every endpoint on a new mux, wrapped in the tracing,
+// logging, metrics and rate limiting middleware.
+func (cfg *apiConfig) routes() http.Handler {
+	if cfg.metrics == nil {
+		cfg.metrics = metrics.New()
+	}
 	mux := http.NewServeMux()
 
-	fs := http.FileServer(http.Dir(filePathRoot))
-	mux.Handle("/app/", http.StripPrefix("/app", apiCfg.middlewareMetricsInc(fs)))
+	fs := http.FileServer(http.Dir(cfg.fileRoot))
+	mux.Handle("/app/", http.StripPrefix("/app", cfg.middlewareMetricsInc(fs)))
 
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpgradeRed)
+	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
+	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerUpgradeRed)
 
 
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
+	mux.HandleFunc("GET /api/healthz", handlerLiveness)
+	mux.HandleFunc("GET /api/livez", handlerLiveness)
+	mux.HandleFunc("GET /api/readyz", cfg.handlerReadyz)
 
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetSingleChirp)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("POST /api/chirps", cfg.handlerCreateChirp)
+	mux.HandleFunc("GET /api/chirps", cfg.handlerGetChirps)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handlerGetSingleChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.handlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.handlerUnlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/reports", cfg.handlerReportChirp)
 
-	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
-	mux.HandleFunc("POST /api/login", apiCfg.handlerLoginUser)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	mux.HandleFunc("GET /api/moderation/reports", cfg.requireRole(roleModerator, cfg.handlerListReports))
+	mux.HandleFunc("POST /api/moderation/chirps/{chirpID}", cfg.requireRole(roleModerator, cfg.handlerModerateChirp))
 
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("POST /api/users", cfg.handlerCreateUser)
+	mux.HandleFunc("POST /api/login", cfg.handlerLoginUser)
+	mux.HandleFunc("PUT /api/users", cfg.handlerUpdateUser)
+	mux.HandleFunc("DELETE /api/users/me", cfg.handlerDeleteAccount)
+	mux.HandleFunc("POST /api/users/me/export", cfg.handlerRequestExport)
+	mux.HandleFunc("GET /api/users/me/exports/{exportID}", cfg.handlerGetExport)
+	mux.HandleFunc("GET /api/users/me/scheduled", cfg.handlerListScheduledChirps)
+	mux.HandleFunc("PUT /api/users/me/scheduled/{chirpID}", cfg.handlerUpdateScheduledChirp)
+	mux.HandleFunc("DELETE /api/users/me/scheduled/{chirpID}", cfg.handlerCancelScheduledChirp)
+	mux.HandleFunc("GET /api/exports/{exportID}/download", cfg.handlerDownloadExport)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.handlerUnfollowUser)
 
-	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+	mux.HandleFunc("GET /api/blocks", cfg.handlerListBlocks)
+	mux.HandleFunc("POST /api/blocks", cfg.handlerBlockUser)
+	mux.HandleFunc("DELETE /api/blocks/{userID}", cfg.handlerUnblockUser)
+	mux.HandleFunc("GET /api/mutes", cfg.handlerListMutes)
+	mux.HandleFunc("POST /api/mutes", cfg.handlerMuteUser)
+	mux.HandleFunc("DELETE /api/mutes/{userID}", cfg.handlerUnmuteUser)
+
+	mux.HandleFunc("GET /api/notifications", cfg.handlerListNotifications)
+	mux.HandleFunc("POST /api/notifications/read", cfg.handlerMarkAllNotificationsRead)
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", cfg.handlerMarkNotificationRead)
+	mux.HandleFunc("GET /api/notifications/preferences", cfg.handlerGetNotificationPreferences)
+	mux.HandleFunc("PUT /api/notifications/preferences", cfg.handlerUpdateNotificationPreferences)
+
+	mux.HandleFunc("GET /users/{userID}/feed.rss", cfg.handlerUserFeedRSS)
+	mux.HandleFunc("GET /users/{userID}/feed.atom", cfg.handlerUserFeedAtom)
+	mux.HandleFunc("GET /tags/{tag}/feed.rss", cfg.handlerTagFeedRSS)
+	mux.HandleFunc("GET /tags/{tag}/feed.atom", cfg.handlerTagFeedAtom)
+
+	if cfg.federationEnabled() {
+		mux.HandleFunc("GET /.well-known/webfinger", cfg.handlerWebFinger)
+		mux.HandleFunc("GET /ap/users/{userID}", cfg.handlerActor)
+		mux.HandleFunc("GET /ap/users/{userID}/outbox", cfg.handlerOutbox)
+		mux.HandleFunc("GET /ap/users/{userID}/followers", cfg.handlerFollowers)
+		mux.HandleFunc("POST /ap/users/{userID}/inbox", cfg.handlerInbox)
+		mux.HandleFunc("POST /ap/inbox", cfg.handlerInbox)
+		mux.HandleFunc("GET /ap/chirps/{chirpID}", cfg.handlerNote)
 	}
-	log.Printf("Serving files from %s on port: %s\n", filePathRoot, port)
-	log.Fatal(server.ListenAndServe())
 
+	mux.HandleFunc("GET /api/stream", cfg.handlerStream)
+	mux.HandleFunc("GET /api/ws", cfg.handlerWebSocket)
+
+	mux.HandleFunc("GET /admin/metrics", cfg.requireRole(roleAdmin, cfg.handlerMetrics))
+	mux.Handle("GET /metrics", cfg.metrics.Handler())
+	mux.HandleFunc("POST /admin/reset", cfg.requireRole(roleAdmin, cfg.handlerReset))
+
+	mux.HandleFunc("GET /admin/api/moderation/rules", cfg.requireRole(roleAdmin, cfg.handlerListModerationRules))
+	mux.HandleFunc("POST /admin/api/moderation/rules", cfg.requireRole(roleAdmin, cfg.handlerCreateModerationRule))
+	mux.HandleFunc("DELETE /admin/api/moderation/rules/{ruleID}", cfg.requireRole(roleAdmin, cfg.handlerDeleteModerationRule))
+	mux.HandleFunc("GET /admin/api/moderation/held", cfg.requireRole(roleAdmin, cfg.handlerListHeldChirps))
+	mux.HandleFunc("POST /admin/api/moderation/held/{chirpID}/approve", cfg.requireRole(roleAdmin, cfg.handlerApproveHeldChirp))
+	mux.HandleFunc("POST /admin/api/moderation/held/{chirpID}/reject", cfg.requireRole(roleAdmin, cfg.handlerRejectHeldChirp))
+
+	mux.HandleFunc("GET /admin/api/users", cfg.requireRole(roleAdmin, cfg.handlerListUsers))
+	mux.HandleFunc("GET /admin/api/users/{userID}", cfg.requireRole(roleAdmin, cfg.handlerAdminGetUser))
+	mux.HandleFunc("PUT /admin/api/users/{userID}/role", cfg.requireRole(roleAdmin, cfg.handlerSetUserRole))
+	mux.HandleFunc("POST /admin/api/users/{userID}/suspension", cfg.requireRole(roleAdmin, cfg.handlerSuspendUser))
+	mux.HandleFunc("DELETE /admin/api/users/{userID}/suspension", cfg.requireRole(roleAdmin, cfg.handlerUnsuspendUser))
+	mux.HandleFunc("POST /admin/api/users/{userID}/ban", cfg.requireRole(roleAdmin, cfg.handlerBanUser))
+	mux.HandleFunc("DELETE /admin/api/users/{userID}/ban", cfg.requireRole(roleAdmin, cfg.handlerUnsuspendUser))
+	mux.HandleFunc("POST /admin/api/users/{userID}/red", cfg.requireRole(roleAdmin, cfg.handlerGrantRed))
+	mux.HandleFunc("DELETE /admin/api/users/{userID}/red", cfg.requireRole(roleAdmin, cfg.handlerRevokeRed))
+	mux.HandleFunc("POST /admin/api/users/{userID}/tokens/revoke", cfg.requireRole(roleAdmin, cfg.handlerRevokeUserTokens))
+	mux.HandleFunc("GET /admin/api/bulk/export", cfg.requireRole(roleAdmin, cfg.handlerBulkExport))
+	mux.HandleFunc("POST /admin/api/bulk/import", cfg.requireRole(roleAdmin, cfg.handlerBulkImport))
+
+	return otelhttp.NewHandler(cfg.middlewareObserve(cfg.middlewareRateLimit(mux)), "http.server")
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/store/memory"
+	"github.com/enderbd/chirpy/internal/stream"
+)
+
+const testSecret = "test-secret"
+
+// newTestServer serves the real routes backed by an in-memory store.
+func newTestServer(t *testing.T) (*apiConfig, *httptest.Server) {
+	t.Helper()
+	cfg := &apiConfig{
+		db:       memory.New(),
+		platform: "dev",
+		secret:   testSecret,
+		polkaKey: "test-polka-key",
+		hub:      stream.NewHub(),
+	}
+	srv := httptest.NewServer(cfg.routes())
+	t.Cleanup(srv.Close)
+	return cfg, srv
+}
+
+// doJSON sends body as JSON and decodes the JSON response into out, if
+// out is non-nil. It returns the status code.
+func doJSON(t *testing.T, srv *httptest.Server, method, path, token string, body, out any) int {
+	t.Helper()
+	if token != "" {
+		token = "Bearer " + token
+	}
+	return doJSONAuth(t, srv, method, path, token, body, out)
+}
+
+// doJSONAuth is doJSON with the whole Authorization header given.
+func doJSONAuth(t *testing.T, srv *httptest.Server, method, path, authorization string, body, out any) int {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %s", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// createTestUser signs up and logs in, returning the logged in user.
+func createTestUser(t *testing.T, srv *httptest.Server, email, username string) User {
+	t.Helper()
+
+	params := map[string]string{"email": email, "password": "hunter2", "username": username}
+	if code := doJSON(t, srv, "POST", "/api/users", "", params, nil); code != http.StatusCreated {
+		t.Fatalf("create user %s: status %d", email, code)
+	}
+	var user User
+	if code := doJSON(t, srv, "POST", "/api/login", "", params, &user); code != http.StatusOK {
+		t.Fatalf("login %s: status %d", email, code)
+	}
+	return user
+}
+
+// nextSecond sleeps into the next whole second, past which access tokens
+// are no longer caught by a revocation made before the call.
+func nextSecond() {
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+}
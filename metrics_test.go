@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	cfg, srv := newTestServer(t)
+
+	user := createTestUser(t, srv, "metrics@example.com", "metrics")
+	doJSON(t, srv, "POST", "/api/login", "", map[string]string{"email": "metrics@example.com", "password": "wrong"}, nil)
+	doJSON(t, srv, "POST", "/api/chirps", user.Token, map[string]string{"body": "counted"}, nil)
+	postWebhook(t, srv, "wrong-key", map[string]any{"event": "user.upgraded"})
+	postWebhook(t, srv, cfg.polkaKey, map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": user.ID.String()}})
+	doJSON(t, srv, "GET", "/api/chirps/not-a-uuid/nothing", "", nil, nil)
+
+	resp, err := srv.Client().Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+
+	for _, want := range []string{
+		`chirpy_logins_total{result="success"} 1`,
+		`chirpy_logins_total{result="failure"} 1`,
+		`chirpy_chirps_created_total 1`,
+		`chirpy_webhooks_total{outcome="unauthorized"} 1`,
+		`chirpy_webhooks_total{outcome="upgraded"} 1`,
+		`chirpy_http_requests_total{method="POST",route="POST /api/chirps",status="201"} 1`,
+		`chirpy_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`chirpy_http_request_duration_seconds_count{method="POST",route="POST /api/login"} 2`,
+		// The scrape itself is in flight.
+		`chirpy_http_requests_in_flight 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %s", want)
+		}
+	}
+
+	// The admin page for humans is still there, for admins only.
+	if code := doJSON(t, srv, "GET", "/admin/metrics", user.Token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("admin metrics as a user: status %d", code)
+	}
+	cfg.adminKey = testAdminKey
+	req, err := http.NewRequest("GET", srv.URL+"/admin/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "ApiKey "+testAdminKey)
+	resp, err = srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("admin metrics Content-Type = %q", ct)
+	}
+}
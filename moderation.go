@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// Chirp statuses. Held chirps wait for a moderator and hidden ones were
+// taken down by one; banned ones are set aside while their author is
+// banned, and withdrawn ones while their author's account is waiting to be
+// deleted. Only their author can see any of them. Scheduled chirps wait
+// for their publish_at and are kept out of every listing until then, their
+// author's included.
+const (
+	chirpPublished = "published"
+	chirpHeld      = "held"
+	chirpHidden    = "hidden"
+	chirpBanned    = "banned"
+	chirpWithdrawn = "withdrawn"
+	chirpScheduled = "scheduled"
+)
+
+// moderationReloadInterval is how long rules added on another instance
+// take to apply here.
+const moderationReloadInterval = time.Minute
+
+// moderationReport tells an author which rules their chirp broke.
+type moderationReport struct {
+	Action string      `json:"action"`
+	Rules  []firedRule `json:"rules"`
+}
+
+type firedRule struct {
+	Term   string `json:"term"`
+	Action string `json:"action"`
+	Source string `json:"source"`
+	// Match is the text of the chirp that broke the rule, as written.
+	Match string `json:"match"`
+}
+
+// newModerationReport returns nil when no rule fired.
+func newModerationReport(body string, d moderation.Decision) *moderationReport {
+	if len(d.Matches) == 0 {
+		return nil
+	}
+	report := &moderationReport{Action: string(d.Action)}
+	for _, m := range d.Matches {
+		report.Rules = append(report.Rules, firedRule{
+			Term:   m.Rule.Term,
+			Action: string(m.Rule.Action),
+			Source: m.Rule.Source,
+			Match:  body[m.Start:m.End],
+		})
+	}
+	return report
+}
+
+// moderationPipeline returns the pipeline built by the last reload, or one
+// with the default rules before the first.
+func (cfg *apiConfig) moderationPipeline() *moderation.Pipeline {
+	if p := cfg.moderation.Load(); p != nil {
+		return p
+	}
+	wl, _ := moderation.NewWordlist(moderation.DefaultRules)
+	return moderation.NewPipeline(wl)
+}
+
+// staticModerationRules are the rules from MODERATION_RULES, or the
+// defaults without it.
+func (cfg *apiConfig) staticModerationRules() ([]moderation.Rule, error) {
+	if cfg.moderationFile == "" {
+		return slices.Clone(moderation.DefaultRules), nil
+	}
+	return readModerationRules(cfg.moderationFile)
+}
+
+func readModerationRules(path string) ([]moderation.Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := moderation.ParseRules(f, moderation.SourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// reloadModeration rebuilds the pipeline from the rules file and the
+// database. Database rules come last, so an admin can change the action of
+// a word from the file.
+func (cfg *apiConfig) reloadModeration(ctx context.Context) error {
+	rules, err := cfg.staticModerationRules()
+	if err != nil {
+		return err
+	}
+	stored, err := cfg.db.ListModerationRules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, r := range stored {
+		rules = append(rules, moderation.Rule{Term: r.Term, Action: moderation.Action(r.Action), Source: moderation.SourceDatabase})
+	}
+
+	wl, err := moderation.NewWordlist(rules)
+	if err != nil {
+		return err
+	}
+	cfg.moderation.Store(moderation.NewPipeline(wl))
+	return nil
+}
+
+func (cfg *apiConfig) runModerationReloader(ctx context.Context) {
+	ticker := time.NewTicker(moderationReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.reloadModeration(ctx); err != nil {
+				logging.FromContext(ctx).Error("Could not reload moderation rules", logging.Err(err))
+			}
+		}
+	}
+}
+
+// chirpVisible reports whether viewer, uuid.Nil when signed out, may see
+// chirp.
+func chirpVisible(chirp database.Chirp, viewer uuid.UUID) bool {
+	if chirp.Status == chirpScheduled {
+		return false
+	}
+	return chirp.Status == chirpPublished || (viewer != uuid.Nil && chirp.UserID == viewer)
+}
+
+// publishedChirps drops the chirps only their authors may see, for
+// listings that are the same for everyone.
+func publishedChirps(chirps []database.Chirp) []database.Chirp {
+	return slices.DeleteFunc(chirps, func(c database.Chirp) bool {
+		return c.Status != chirpPublished
+	})
+}
+
+// optionalUser returns the signed in user on endpoints that don't require
+// one, or uuid.Nil when there is no valid access token.
+func (cfg *apiConfig) optionalUser(w http.ResponseWriter, r *http.Request) uuid.UUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil
+	}
+	userId, err := cfg.validateToken(r.Context(), token)
+	if err != nil {
+		return uuid.Nil
+	}
+	setRequestUser(w, userId)
+	return userId
+}
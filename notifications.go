@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/chirptext"
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/notify"
+	"github.com/google/uuid"
+)
+
+const (
+	emailTimeout = 30 * time.Second
+	// emailQueueSize is how many notification emails may wait for a
+	// sender before new ones are dropped.
+	emailQueueSize = 256
+	emailSenders   = 4
+	// emailDrainTimeout bounds how long shutting down waits for queued
+	// emails to go out.
+	emailDrainTimeout = 30 * time.Second
+)
+
+// notificationBuffer is how many notifications a WebSocket connection may
+// fall behind before the hub gives up on it.
+const notificationBuffer = 16
+
+// notificationHub hands new in-app notifications to the WebSocket
+// connections of the user they are for. It only reaches connections to
+// this instance; the others see them through the REST API. The zero value
+// is ready to use.
+type notificationHub struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan Notification]struct{}
+}
+
+func (h *notificationHub) subscribe(userID uuid.UUID) chan Notification {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs == nil {
+		h.subs = map[uuid.UUID]map[chan Notification]struct{}{}
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[chan Notification]struct{}{}
+	}
+	c := make(chan Notification, notificationBuffer)
+	h.subs[userID][c] = struct{}{}
+	return c
+}
+
+func (h *notificationHub) unsubscribe(userID uuid.UUID, c chan Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(userID, c)
+}
+
+func (h *notificationHub) remove(userID uuid.UUID, c chan Notification) {
+	if _, ok := h.subs[userID][c]; !ok {
+		return
+	}
+	delete(h.subs[userID], c)
+	if len(h.subs[userID]) == 0 {
+		delete(h.subs, userID)
+	}
+	close(c)
+}
+
+// publish delivers n to userID's connections, dropping any that have
+// fallen behind.
+func (h *notificationHub) publish(userID uuid.UUID, n Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.subs[userID] {
+		select {
+		case c <- n:
+		default:
+			h.remove(userID, c)
+		}
+	}
+}
+
+type notificationPreference struct {
+	Kind  string `json:"kind"`
+	InApp bool   `json:"in_app"`
+	Email bool   `json:"email"`
+}
+
+// notificationPreferences returns the user's settings for every kind.
+// Kinds the user never configured are shown in the app but not emailed.
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) ([]notificationPreference, error) {
+	stored, err := cfg.db.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := make([]notificationPreference, 0, len(notify.Kinds))
+	for _, kind := range notify.Kinds {
+		pref := notificationPreference{Kind: kind, InApp: true}
+		for _, s := range stored {
+			if s.Kind == kind {
+				pref.InApp = s.InApp
+				pref.Email = s.Email
+			}
+		}
+		prefs = append(prefs, pref)
+	}
+	return prefs, nil
+}
+
+// notify tells userID that actorID did something of the given kind,
+// honouring the user's preferences, blocks and mutes. Failures are logged, never returned:
+// a missed notification must not fail the action that caused it.
+func (cfg *apiConfig) notify(ctx context.Context, userID, actorID uuid.UUID, kind string, chirpID uuid.NullUUID) {
+	if userID == actorID {
+		return
+	}
+	hidden, err := cfg.hiddenAuthors(ctx, userID, true)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not load blocked users", "user_id", userID, logging.Err(err))
+		return
+	}
+	if hidden[actorID] {
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not load notification preferences", "user_id", userID, logging.Err(err))
+		return
+	}
+	var pref notificationPreference
+	for _, p := range prefs {
+		if p.Kind == kind {
+			pref = p
+		}
+	}
+
+	if pref.InApp {
+		n, err := cfg.db.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:  userID,
+			ActorID: actorID,
+			Kind:    kind,
+			ChirpID: chirpID,
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Could not store notification", "kind", kind, "user_id", userID, logging.Err(err))
+		} else {
+			cfg.notifyHub.publish(userID, notificationFromDB(n))
+		}
+	}
+
+	if pref.Email && cfg.emails != nil {
+		job := emailJob{
+			logger:  logging.FromContext(ctx),
+			userID:  userID,
+			actorID: actorID,
+			kind:    kind,
+			chirpID: chirpID,
+		}
+		select {
+		case cfg.emails <- job:
+		default:
+			logging.FromContext(ctx).Warn("Email queue is full, dropping notification email", "kind", kind, "user_id", userID)
+		}
+	}
+}
+
+// emailJob is a notification waiting to be emailed. It keeps the logger of
+// the request that caused it.
+type emailJob struct {
+	logger          *slog.Logger
+	userID, actorID uuid.UUID
+	kind            string
+	chirpID         uuid.NullUUID
+}
+
+// runEmailSender emails queued notifications with emailSenders at a time
+// until ctx is cancelled. It is stopped after everything that queues
+// emails, so it then sends what is left in the queue, giving up on the rest
+// after emailDrainTimeout.
+func (cfg *apiConfig) runEmailSender(ctx context.Context) {
+	// An email being sent when ctx is cancelled is finished.
+	sendCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+	for range emailSenders {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-cfg.emails:
+					cfg.emailNotification(sendCtx, job)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	drainCtx, cancel := context.WithTimeout(sendCtx, emailDrainTimeout)
+	defer cancel()
+	for range emailSenders {
+		wg.Go(func() {
+			for drainCtx.Err() == nil {
+				select {
+				case job := <-cfg.emails:
+					cfg.emailNotification(drainCtx, job)
+				default:
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func (cfg *apiConfig) emailNotification(ctx context.Context, job emailJob) {
+	ctx, cancel := context.WithTimeout(logging.WithLogger(ctx, job.logger), emailTimeout)
+	defer cancel()
+	userID, actorID, kind, chirpID := job.userID, job.actorID, job.kind, job.chirpID
+
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not load user for email notification", "user_id", userID, logging.Err(err))
+		return
+	}
+	actor, err := cfg.db.GetUserByID(ctx, actorID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not load user for email notification", "user_id", actorID, logging.Err(err))
+		return
+	}
+
+	msg := notificationEmail(preferredUsername(actor), kind)
+	msg.To = user.Email
+	if chirpID.Valid {
+		if chirp, err := cfg.db.GetSingleChirp(ctx, chirpID.UUID); err == nil {
+			msg.Body += "\n\n" + chirp.Body
+		}
+	}
+
+	err = cfg.mailer.Send(ctx, msg)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not email notification", "kind", kind, "user_id", userID, logging.Err(err))
+	}
+}
+
+func notificationEmail(actor, kind string) notify.Message {
+	switch kind {
+	case notify.KindMention:
+		return notify.Message{Subject: actor + " mentioned you on Chirpy", Body: actor + " mentioned you in a chirp."}
+	case notify.KindReply:
+		return notify.Message{Subject: actor + " replied to you on Chirpy", Body: actor + " replied to your chirp."}
+	case notify.KindLike:
+		return notify.Message{Subject: actor + " liked your chirp", Body: actor + " liked your chirp."}
+	case notify.KindFollow:
+		return notify.Message{Subject: actor + " followed you on Chirpy", Body: actor + " started following you."}
+	}
+	return notify.Message{Subject: "New activity on Chirpy", Body: fmt.Sprintf("%s: %s", kind, actor)}
+}
+
+// notifyChirp notifies the author of the chirp being replied to and every
+// mentioned user. Someone mentioned in a reply to their own chirp only gets
+// the reply notification.
+func (cfg *apiConfig) notifyChirp(ctx context.Context, chirp database.Chirp, replyTo *database.Chirp) {
+	chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+
+	notified := map[uuid.UUID]bool{chirp.UserID: true}
+	if replyTo != nil {
+		cfg.notify(ctx, replyTo.UserID, chirp.UserID, notify.KindReply, chirpID)
+		notified[replyTo.UserID] = true
+	}
+
+	names := chirptext.Mentions(chirp.Body)
+	if len(names) == 0 {
+		return
+	}
+	users, err := cfg.db.GetUsersByUsernames(ctx, names)
+	if err != nil {
+		logging.FromContext(ctx).Error("Could not look up mentioned users", logging.Err(err))
+		return
+	}
+	for _, user := range users {
+		if notified[user.ID] {
+			continue
+		}
+		notified[user.ID] = true
+		cfg.notify(ctx, user.ID, chirp.UserID, notify.KindMention, chirpID)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/notify"
+)
+
+type fakeMailer struct {
+	sent chan notify.Message
+}
+
+func (m fakeMailer) Send(ctx context.Context, msg notify.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+func TestNotificationEmails(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	mailer := fakeMailer{sent: make(chan notify.Message, 10)}
+	cfg.mailer = mailer
+	cfg.emails = make(chan emailJob, 1)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	prefs := []notificationPreference{{Kind: notify.KindMention, InApp: true, Email: true}}
+	if code := doJSON(t, srv, "PUT", "/api/notifications/preferences", bob.Token, prefs, nil); code != http.StatusOK && code != http.StatusNoContent {
+		t.Fatalf("setting preferences: status %d", code)
+	}
+
+	// With no sender running the queue fills up, and chirping carries on
+	// without the emails that don't fit.
+	for _, body := range []string{"hi @bob", "hi again @bob"} {
+		if code := doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": body}, nil); code != http.StatusCreated {
+			t.Fatalf("chirping %q: status %d", body, code)
+		}
+	}
+
+	cfg.workers.Go(stageSenders, "email_sender", func(ctx context.Context) error {
+		cfg.runEmailSender(ctx)
+		return nil
+	})
+	t.Cleanup(cfg.workers.Stop)
+
+	select {
+	case msg := <-mailer.sent:
+		if msg.To != "bob@example.com" || msg.Subject != "alice mentioned you on Chirpy" {
+			t.Errorf("sent %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email was sent")
+	}
+	select {
+	case msg := <-mailer.sent:
+		t.Errorf("sent %+v past a full queue", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEmailSenderDrainsQueue(t *testing.T) {
+	cfg, srv := newTestServer(t)
+	mailer := fakeMailer{sent: make(chan notify.Message, 10)}
+	cfg.mailer = mailer
+	cfg.emails = make(chan emailJob, 10)
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	bob := createTestUser(t, srv, "bob@example.com", "bob")
+	prefs := []notificationPreference{{Kind: notify.KindMention, InApp: true, Email: true}}
+	doJSON(t, srv, "PUT", "/api/notifications/preferences", bob.Token, prefs, nil)
+	for range 3 {
+		doJSON(t, srv, "POST", "/api/chirps", alice.Token, map[string]string{"body": "hi @bob"}, nil)
+	}
+
+	// Stopped before it got to the queue, the sender still empties it.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	cfg.runEmailSender(ctx)
+	if n := len(mailer.sent); n != 3 {
+		t.Errorf("sent %d emails, want 3", n)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/auth"
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/enderbd/chirpy/internal/ratelimit"
+	"github.com/enderbd/chirpy/internal/store"
+)
+
+// Rate limit stores, chosen by RATE_LIMIT_STORE.
+const (
+	rateLimitMemory   = "memory"
+	rateLimitPostgres = "postgres"
+	rateLimitOff      = "off"
+)
+
+// Plans a limit can depend on.
+const (
+	planFree = "free"
+	planRed  = "red"
+)
+
+const rateLimitPruneInterval = time.Minute
+
+// rateRule limits one route pattern. Chirpy Red members get red; everyone
+// else, signed in or not, gets free.
+type rateRule struct {
+	free ratelimit.Limit
+	red  ratelimit.Limit
+}
+
+// defaultRateLimits covers the endpoints that are cheapest to abuse.
+// Routes without a rule aren't limited.
+var defaultRateLimits = map[string]rateRule{
+	"POST /api/chirps": {
+		free: ratelimit.Limit{Requests: 10, Period: time.Minute},
+		red:  ratelimit.Limit{Requests: 60, Period: time.Minute},
+	},
+	"POST /api/login": {
+		free: ratelimit.Limit{Requests: 10, Period: time.Minute},
+		red:  ratelimit.Limit{Requests: 10, Period: time.Minute},
+	},
+	"POST /api/users": {
+		free: ratelimit.Limit{Requests: 5, Period: time.Hour},
+		red:  ratelimit.Limit{Requests: 5, Period: time.Hour},
+	},
+	"POST /api/users/me/export": {
+		free: ratelimit.Limit{Requests: 3, Period: time.Hour},
+		red:  ratelimit.Limit{Requests: 3, Period: time.Hour},
+	},
+}
+
+// parseRateLimits applies RATE_LIMITS to the default rules. It holds rules
+// separated by semicolons, each a route pattern, =, the free limit and
+// optionally a comma and the Chirpy Red limit, e.g.
+//
+//	POST /api/chirps=20/m,120/m; POST /api/login=5/m
+//
+// Without a Red limit both plans get the same one. A limit of off removes
+// the rule.
+func parseRateLimits(s string) (map[string]rateRule, error) {
+	rules := map[string]rateRule{}
+	for pattern, rule := range defaultRateLimits {
+		rules[pattern] = rule
+	}
+
+	var errs []error
+	for entry := range strings.SplitSeq(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		pattern, limits, ok := strings.Cut(entry, "=")
+		pattern = strings.Join(strings.Fields(pattern), " ")
+		if !ok || pattern == "" {
+			errs = append(errs, fmt.Errorf("rule %q must look like POST /api/chirps=10/m", strings.TrimSpace(entry)))
+			continue
+		}
+		if strings.TrimSpace(limits) == "off" {
+			delete(rules, pattern)
+			continue
+		}
+
+		free, red, hasRed := strings.Cut(limits, ",")
+		var rule rateRule
+		var err error
+		if rule.free, err = ratelimit.ParseLimit(free); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", pattern, err))
+			continue
+		}
+		rule.red = rule.free
+		if hasRed {
+			if rule.red, err = ratelimit.ParseLimit(red); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", pattern, err))
+				continue
+			}
+		}
+		rules[pattern] = rule
+	}
+	return rules, errors.Join(errs...)
+}
+
+// newRateLimiter returns the store named by RATE_LIMIT_STORE, or nil when
+// rate limiting is off.
+func newRateLimiter(kind string, db store.Store) (ratelimit.Store, error) {
+	switch kind {
+	case rateLimitMemory:
+		return ratelimit.NewMemory(), nil
+	case rateLimitPostgres:
+		queries, ok := db.(ratelimit.Queries)
+		if !ok {
+			return nil, errors.New("the postgres rate limit store needs a Postgres database")
+		}
+		return ratelimit.NewPostgres(queries), nil
+	}
+	return nil, nil
+}
+
+// middlewareRateLimit spends a request from the caller's bucket for the
+// matched route before letting the mux serve it. Signed in callers are
+// limited by user, everyone else by address. If the store fails the
+// request is let through rather than failing with it.
+func (cfg *apiConfig) middlewareRateLimit(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.limiter == nil {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		_, pattern := mux.Handler(r)
+		rule, ok := cfg.rateLimits[pattern]
+		if !ok {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		key, plan := cfg.rateLimitKey(r, rule)
+		limit := rule.free
+		if plan == planRed {
+			limit = rule.red
+		}
+		res, err := cfg.limiter.Take(r.Context(), pattern+" "+key, limit)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Could not check the rate limit", "route", pattern, logging.Err(err))
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit.Requests, ceilSeconds(limit.Period)))
+		if !res.Allowed {
+			// The mux never sees the request, so name the route for the
+			// access log and metrics here.
+			r.Pattern = pattern
+			cfg.metrics.RateLimited(pattern, plan)
+			h.Set("Retry-After", ceilSeconds(max(res.RetryAfter, time.Second)))
+			respondWithError(w, http.StatusTooManyRequests, "Too many requests, try again later", nil)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey names the bucket a request is counted against, and the plan
+// that sets its size. Signed in callers are keyed by the subject of their
+// token, checked by signature alone; whether the token is revoked or the
+// user suspended is left to the handler. The user is only loaded, for their
+// plan, when rule gives Chirpy Red a different limit.
+func (cfg *apiConfig) rateLimitKey(r *http.Request, rule rateRule) (key, plan string) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return "ip:" + cfg.clientIP(r), planFree
+	}
+	userID, err := auth.ValidateJWT(r.Context(), token, cfg.secret)
+	if err != nil {
+		return "ip:" + cfg.clientIP(r), planFree
+	}
+
+	plan = planFree
+	if rule.red != rule.free {
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err == nil && user.IsChirpyRed {
+			plan = planRed
+		}
+	}
+	return "user:" + userID.String(), plan
+}
+
+// clientIP is the address a request came from. With TRUST_PROXY it is the
+// last X-Forwarded-For entry, the one added by the proxy in front of us;
+// anything before it is whatever the client sent. IPv6 clients are grouped
+// by /64, as one host usually has the whole prefix.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if forwarded := r.Header.Values("X-Forwarded-For"); cfg.trustProxy && len(forwarded) > 0 {
+		last := forwarded[len(forwarded)-1]
+		addr = strings.TrimSpace(last[strings.LastIndex(last, ",")+1:])
+	}
+
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return addr
+	}
+	ip = ip.Unmap()
+	if ip.Is6() {
+		prefix, _ := ip.Prefix(64)
+		return prefix.String()
+	}
+	return ip.String()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// pruneRateLimits drops refilled buckets so idle callers don't take up
+// space forever.
+func pruneRateLimits(ctx context.Context, limiter ratelimit.Store) {
+	ticker := time.NewTicker(rateLimitPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := limiter.Prune(ctx); err != nil {
+				logging.FromContext(ctx).Error("Could not prune rate limits", logging.Err(err))
+			}
+		}
+	}
+}
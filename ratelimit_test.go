@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/enderbd/chirpy/internal/ratelimit"
+	"github.com/enderbd/chirpy/internal/store"
+	"github.com/google/uuid"
+)
+
+// newLimitedServer is newTestServer with the given rules enforced.
+func newLimitedServer(t *testing.T, rules string) (*apiConfig, *httptest.Server) {
+	t.Helper()
+	cfg, srv := newTestServer(t)
+	limits, err := parseRateLimits(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.rateLimits = limits
+	cfg.limiter = ratelimit.NewMemory()
+	return cfg, srv
+}
+
+func postLogin(t *testing.T, srv *httptest.Server, forwardedFor string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("POST", srv.URL+"/api/login", strings.NewReader(`{}`))
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	cfg, srv := newLimitedServer(t, "POST /api/login=2/h")
+
+	for i := 1; i >= 0; i-- {
+		resp := postLogin(t, srv, "")
+		if resp.StatusCode == http.StatusTooManyRequests {
+			t.Fatal("limited too early")
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != strconv.Itoa(i) {
+			t.Errorf("RateLimit-Remaining = %q, want %d", got, i)
+		}
+	}
+
+	resp := postLogin(t, srv, "")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429", resp.StatusCode)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "3600",
+		"RateLimit-Policy":    "2;w=3600",
+		"Retry-After":         "1800",
+	} {
+		if got := resp.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// Only a trusted proxy's X-Forwarded-For picks the bucket.
+	if resp := postLogin(t, srv, "203.0.113.7"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("spoofed X-Forwarded-For got status %d", resp.StatusCode)
+	}
+	cfg.trustProxy = true
+	if resp := postLogin(t, srv, "198.51.100.1, 203.0.113.7"); resp.StatusCode == http.StatusTooManyRequests {
+		t.Error("a different client shared the bucket")
+	}
+
+	// Routes without a rule are never limited.
+	for range 3 {
+		if code := doJSON(t, srv, "GET", "/api/chirps", "", nil, nil); code != http.StatusOK {
+			t.Fatalf("GET /api/chirps: status %d", code)
+		}
+	}
+}
+
+func TestRateLimitByPlan(t *testing.T) {
+	cfg, srv := newLimitedServer(t, "POST /api/chirps=1/h,3/h")
+	free := createTestUser(t, srv, "free@example.com", "free")
+	red := createTestUser(t, srv, "red@example.com", "red")
+	if err := cfg.db.UpgradeRed(t.Context(), red.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(token string) int {
+		return doJSON(t, srv, "POST", "/api/chirps", token, map[string]string{"body": "hello"}, nil)
+	}
+	if code := post(free.Token); code != http.StatusCreated {
+		t.Fatalf("first free chirp: status %d", code)
+	}
+	if code := post(free.Token); code != http.StatusTooManyRequests {
+		t.Errorf("second free chirp: status %d, want 429", code)
+	}
+	for i := range 3 {
+		if code := post(red.Token); code != http.StatusCreated {
+			t.Fatalf("Red chirp %d: status %d", i+1, code)
+		}
+	}
+	if code := post(red.Token); code != http.StatusTooManyRequests {
+		t.Errorf("fourth Red chirp: status %d, want 429", code)
+	}
+}
+
+// userLookups counts how often the user behind a token is loaded.
+type userLookups struct {
+	store.Store
+	n atomic.Int32
+}
+
+func (s *userLookups) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.n.Add(1)
+	return s.Store.GetUserByID(ctx, id)
+}
+
+func TestRateLimitKeyByToken(t *testing.T) {
+	cfg, srv := newLimitedServer(t, "POST /api/login=1/h")
+	alice := createTestUser(t, srv, "alice@example.com", "alice")
+	// Start over from the login createTestUser spent.
+	cfg.limiter = ratelimit.NewMemory()
+	lookups := &userLookups{Store: cfg.db}
+	cfg.db = lookups
+
+	login := func(token string) int {
+		return doJSON(t, srv, "POST", "/api/login", token, map[string]string{}, nil)
+	}
+	if code := login(alice.Token); code == http.StatusTooManyRequests {
+		t.Fatal("signed in caller limited too early")
+	}
+	// Anonymous callers from the same address have their own bucket.
+	if code := login(""); code == http.StatusTooManyRequests {
+		t.Error("anonymous caller shared the signed in caller's bucket")
+	}
+	if code := login(alice.Token); code != http.StatusTooManyRequests {
+		t.Errorf("second signed in login: status %d, want 429", code)
+	}
+	if n := lookups.n.Load(); n != 0 {
+		t.Errorf("loaded the user %d times for a rule that ignores the plan", n)
+	}
+
+}
+
+func TestParseRateLimits(t *testing.T) {
+	rules, err := parseRateLimits(" POST  /api/chirps = 20/m, 120/m ; POST /api/login=off; GET /api/chirps=100/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]rateRule{
+		"POST /api/chirps":          {free: ratelimit.Limit{Requests: 20, Period: time.Minute}, red: ratelimit.Limit{Requests: 120, Period: time.Minute}},
+		"GET /api/chirps":           {free: ratelimit.Limit{Requests: 100, Period: time.Minute}, red: ratelimit.Limit{Requests: 100, Period: time.Minute}},
+		"POST /api/users":           defaultRateLimits["POST /api/users"],
+		"POST /api/users/me/export": defaultRateLimits["POST /api/users/me/export"],
+	}
+	if len(rules) != len(want) {
+		t.Errorf("got %d rules, want %d: %v", len(rules), len(want), rules)
+	}
+	for pattern, rule := range want {
+		if rules[pattern] != rule {
+			t.Errorf("%s: got %v, want %v", pattern, rules[pattern], rule)
+		}
+	}
+
+	_, err = parseRateLimits("POST /api/chirps=lots; =1/m; POST /api/users=1/m,none")
+	if err == nil || strings.Count(err.Error(), "\n") != 2 {
+		t.Errorf("want three problems, got %v", err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remote, forwarded string
+		trust             bool
+		want              string
+	}{
+		{remote: "192.0.2.1:1234", want: "192.0.2.1"},
+		{remote: "192.0.2.1:1234", forwarded: "198.51.100.1", want: "192.0.2.1"},
+		{remote: "192.0.2.1:1234", forwarded: "10.0.0.1, 198.51.100.1", trust: true, want: "198.51.100.1"},
+		{remote: "[2001:db8:1:2:3:4:5:6]:1234", want: "2001:db8:1:2::/64"},
+		{remote: "[::ffff:192.0.2.1]:1234", want: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		cfg := &apiConfig{trustProxy: tt.trust}
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := cfg.clientIP(r); got != tt.want {
+			t.Errorf("clientIP(%s, %q, %v) = %q, want %q", tt.remote, tt.forwarded, tt.trust, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/logging"
+	"github.com/google/uuid"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// responseRecorder remembers what the access log line needs to know about
+// a response. Handlers reach it through setRequestUser and respondWithError.
+type responseRecorder struct {
+	http.ResponseWriter
+	logger *slog.Logger
+	status int
+	bytes  int
+	userID uuid.UUID
+	err    error
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *responseRecorder) Flush() {
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Hijack is asserted directly by the WebSocket upgrader.
+func (rec *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// middlewareObserve gives every request an ID, echoed in X-Request-ID and
+// taken from the client when it sends a sensible one, puts a logger tagged
+// with it in the request context, and logs and measures every request.
+func (cfg *apiConfig) middlewareObserve(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		defer cfg.metrics.RequestStarted()()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := slog.Default().With(slog.String("request_id", id))
+		span := trace.SpanFromContext(r.Context())
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			logger = logger.With(slog.String("trace_id", sc.TraceID().String()))
+		}
+		ctx := logging.WithRequestID(r.Context(), id)
+		ctx = logging.WithLogger(ctx, logger)
+		r = r.WithContext(ctx)
+
+		rec := &responseRecorder{ResponseWriter: w, logger: logger}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		elapsed := time.Since(start)
+		cfg.metrics.ObserveRequest(r.Method, route, status, elapsed)
+		// The route is only known once the mux has matched the request.
+		// Most patterns already start with the method.
+		spanName := route
+		if !strings.HasPrefix(route, r.Method+" ") {
+			spanName = r.Method + " " + route
+		}
+		span.SetName(spanName)
+		span.SetAttributes(semconv.HTTPRoute(route))
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", elapsed),
+			slog.Int("bytes", rec.bytes),
+		}
+		if rec.userID != uuid.Nil {
+			attrs = append(attrs, slog.String("user_id", rec.userID.String()))
+		}
+		if rec.err != nil {
+			attrs = append(attrs, logging.Err(rec.err))
+		}
+		level := slog.LevelInfo
+		if status > 499 {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(context.Background(), level, "request", attrs...)
+	})
+}
+
+// validRequestID accepts IDs of up to 128 URL-safe characters so a client
+// can't inject anything odd into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// setRequestUser records the authenticated user for the access log.
+func setRequestUser(w http.ResponseWriter, userID uuid.UUID) {
+	if rec, ok := w.(*responseRecorder); ok {
+		rec.userID = userID
+	}
+}
+
+// responseLogger returns the request-scoped logger behind w.
+func responseLogger(w http.ResponseWriter) *slog.Logger {
+	if rec, ok := w.(*responseRecorder); ok {
+		return rec.logger
+	}
+	return slog.Default()
+}
+
+// recordError attaches err to the access log line unless one is already
+// recorded.
+func recordError(w http.ResponseWriter, err error) {
+	if rec, ok := w.(*responseRecorder); ok && rec.err == nil && err != nil && !errors.Is(err, context.Canceled) {
+		rec.err = err
+	}
+}
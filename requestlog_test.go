@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// captureLogs sends the default logger to a buffer for the rest of the
+// test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestRequestID(t *testing.T) {
+	_, srv := newTestServer(t)
+
+	resp, err := srv.Client().Get(srv.URL + "/api/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(requestIDHeader) == "" {
+		t.Error("no request ID assigned")
+	}
+
+	for id, keep := range map[string]bool{
+		"trace-1234.abc":         true,
+		"bad id level=ERROR":     false,
+		strings.Repeat("a", 200): false,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/healthz", nil)
+		req.Header.Set(requestIDHeader, id)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		got := resp.Header.Get(requestIDHeader)
+		if (got == id) != keep || got == "" {
+			t.Errorf("sent %q, got %q back", id, got)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	logs := captureLogs(t)
+	_, srv := newTestServer(t)
+
+	user := createTestUser(t, srv, "log@example.com", "logger")
+	logs.Reset()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/notifications", nil)
+	req.Header.Set("Authorization", "Bearer "+user.Token)
+	req.Header.Set(requestIDHeader, "req-42")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var access string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "msg=request") {
+			access = line
+		}
+		if !strings.Contains(line, "request_id=req-42") && line != "" {
+			t.Errorf("log line without the request ID: %s", line)
+		}
+	}
+	for _, want := range []string{
+		"method=GET", `route="GET /api/notifications"`, "status=200",
+		"user_id=" + user.ID.String(), "duration=",
+	} {
+		if !strings.Contains(access, want) {
+			t.Errorf("access log missing %s: %s", want, access)
+		}
+	}
+
+	logs.Reset()
+	doJSON(t, srv, http.MethodGet, "/api/notifications", "not-a-jwt", nil, nil)
+	if !strings.Contains(logs.String(), "status=401") || !strings.Contains(logs.String(), "error=") {
+		t.Errorf("failed request not logged with its error: %s", logs.String())
+	}
+}
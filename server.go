@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// serverTimeouts bound how long a single connection may hold the server.
+// WriteTimeout does not apply to the SSE and WebSocket streams, they clear
+// their own deadlines.
+type serverTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+	// ShutdownDelay is how long /api/readyz reports not ready before the
+	// server stops accepting connections, so load balancers can stop
+	// sending traffic first.
+	ShutdownDelay time.Duration
+	// Shutdown is how long in-flight requests get to finish once a
+	// shutdown has started.
+	Shutdown time.Duration
+}
+
+var defaultServerTimeouts = serverTimeouts{
+	ReadHeader: 5 * time.Second,
+	Read:       15 * time.Second,
+	Write:      30 * time.Second,
+	Idle:       2 * time.Minute,
+	Shutdown:   20 * time.Second,
+}
+
+func (cfg *apiConfig) newServer(addr string, t serverTimeouts) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           cfg.routes(),
+		ReadHeaderTimeout: t.ReadHeader,
+		ReadTimeout:       t.Read,
+		WriteTimeout:      t.Write,
+		IdleTimeout:       t.Idle,
+	}
+	// Shutdown waits for idle connections, which streams never become, and
+	// ignores hijacked ones, so streams are told to end themselves.
+	server.RegisterOnShutdown(cfg.stopStreams)
+	return server
+}
+
+// streamsStopping is closed once the server starts shutting down.
+func (cfg *apiConfig) streamsStopping() <-chan struct{} {
+	cfg.stopMu.Lock()
+	defer cfg.stopMu.Unlock()
+	if cfg.stopping == nil {
+		cfg.stopping = make(chan struct{})
+	}
+	return cfg.stopping
+}
+
+// stopStreams ends every open SSE and WebSocket stream. Safe to call more
+// than once.
+func (cfg *apiConfig) stopStreams() {
+	stopping := cfg.streamsStopping()
+	cfg.stopMu.Lock()
+	defer cfg.stopMu.Unlock()
+	select {
+	case <-stopping:
+	default:
+		close(cfg.stopping)
+	}
+}
+
+// serve runs server on ln until ctx is cancelled. It then reports not
+// ready for t.ShutdownDelay, stops accepting connections and gives
+// in-flight requests and open WebSockets t.Shutdown to finish. Connections
+// still open after that are closed.
+func (cfg *apiConfig) serve(ctx context.Context, server *http.Server, ln net.Listener, t serverTimeouts) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	cfg.shuttingDown.Store(true)
+	if t.ShutdownDelay > 0 {
+		slog.Info("Shutting down, reporting not ready", "delay", t.ShutdownDelay)
+		time.Sleep(t.ShutdownDelay)
+	}
+
+	slog.Info("Shutting down, draining connections", "deadline", t.Shutdown)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), t.Shutdown)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		server.Close()
+		return fmt.Errorf("could not drain connections: %w", err)
+	}
+	if !waitTimeout(&cfg.websockets, shutdownCtx) {
+		return errors.New("could not drain connections: websockets still open")
+	}
+	return nil
+}
+
+// waitTimeout waits for wg until ctx is done and reports whether wg
+// finished first.
+func waitTimeout(wg *sync.WaitGroup, ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
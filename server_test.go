@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/store/memory"
+	"github.com/enderbd/chirpy/internal/stream"
+)
+
+func TestServeDrainsOnShutdown(t *testing.T) {
+	cfg := &apiConfig{
+		db:       memory.New(),
+		platform: "dev",
+		secret:   testSecret,
+		polkaKey: "test-polka-key",
+		hub:      stream.NewHub(),
+	}
+	server := cfg.newServer("", defaultServerTimeouts)
+	routes := server.Handler
+	started := make(chan struct{})
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			io.WriteString(w, "done")
+			return
+		}
+		routes.ServeHTTP(w, r)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + ln.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		t := defaultServerTimeouts
+		t.Shutdown = 5 * time.Second
+		served <- cfg.serve(ctx, server, ln, t)
+	}()
+
+	resp, err := http.Get(base + "/api/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+	if _, err := stream.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-started
+	cancel()
+
+	if got := <-slow; got != "done" {
+		t.Errorf("in-flight request got %q, want it to finish", got)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serve: %s", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("serve did not return; the stream kept the server open")
+	}
+	if _, err := io.ReadAll(stream); err != nil {
+		t.Errorf("stream did not end cleanly: %s", err)
+	}
+
+	if _, err := http.Get(base + "/api/healthz"); err == nil {
+		t.Error("server still accepting connections after shutdown")
+	}
+}
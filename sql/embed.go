@@ -0,0 +1,12 @@
+// Package sql holds Chirpy's schema and queries. It embeds the migrations
+// for each backend so every binary that manages the database runs the
+// same ones.
+package sql
+
+import "embed"
+
+// Migrations holds schema/*.sql for Postgres and sqlite/schema/*.sql for
+// SQLite.
+//
+//go:embed schema/*.sql sqlite/schema/*.sql
+var Migrations embed.FS
@@ -0,0 +1,71 @@
+-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, created_at, public_key_pem, private_key_pem)
+VALUES (
+	$1,
+	NOW(),
+	$2,
+	$3
+)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetActorKey :one
+SELECT * FROM actor_keys
+WHERE user_id = $1;
+
+-- name: AddRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_id, inbox, created_at)
+VALUES (
+	$1,
+	$2,
+	$3,
+	NOW()
+)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET inbox = EXCLUDED.inbox;
+
+-- name: RemoveRemoteFollower :exec
+DELETE FROM remote_followers
+WHERE user_id = $1 AND actor_id = $2;
+
+-- name: RemoveRemoteActor :exec
+DELETE FROM remote_followers
+WHERE actor_id = $1;
+
+-- name: GetRemoteFollowerInboxes :many
+SELECT DISTINCT inbox FROM remote_followers
+WHERE user_id = $1;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM remote_followers
+WHERE user_id = $1;
+
+-- name: EnqueueDelivery :exec
+INSERT INTO ap_deliveries (created_at, user_id, inbox, payload, attempts, next_attempt_at)
+VALUES (
+	NOW(),
+	$1,
+	$2,
+	$3,
+	0,
+	NOW()
+);
+
+-- name: ClaimDeliveries :many
+UPDATE ap_deliveries
+SET attempts = attempts + 1, next_attempt_at = NOW() + INTERVAL '5 minutes'
+WHERE id IN (
+	SELECT id FROM ap_deliveries
+	WHERE next_attempt_at <= NOW() AND ap_deliveries.attempts < $1
+	ORDER BY id
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: DeleteDelivery :exec
+DELETE FROM ap_deliveries
+WHERE id = $1;
+
+-- name: RescheduleDelivery :exec
+UPDATE ap_deliveries
+SET next_attempt_at = $1, last_error = $2
+WHERE id = $3;
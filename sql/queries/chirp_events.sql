@@ -0,0 +1,20 @@
+-- name: CreateChirpEvent :one
+INSERT INTO chirp_events (created_at, kind, chirp_id, user_id, body)
+VALUES (
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4
+)
+RETURNING *;
+
+-- name: GetChirpEvent :one
+SELECT * FROM chirp_events
+WHERE id = $1;
+
+-- name: GetChirpEventsAfter :many
+SELECT * FROM chirp_events
+WHERE id > $1
+ORDER BY id ASC
+LIMIT $2;
@@ -1,17 +1,20 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at)
 VALUES (
 	gen_random_uuid(),
 	NOW(),
 	NOW(),
 	$1,
-	$2
+	$2,
+	$3,
+	$4,
+	$5
 )
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * from chirps
-ORDER BY created_at ASC;
+ORDER BY COALESCE(publish_at, created_at) ASC;
 
 -- name: GetSingleChirp :one
 SELECT * from chirps
@@ -24,3 +27,88 @@ WHERE id=$1;
 -- name: GetChirpsByUserID :many
 SELECT * FROM chirps
 WHERE user_id = $1;
+
+-- name: GetChirpsByStatus :many
+SELECT * FROM chirps
+WHERE status = $1
+ORDER BY created_at ASC;
+
+-- name: SetChirpStatus :exec
+UPDATE chirps SET status = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: SetUserChirpsStatus :many
+UPDATE chirps SET status = sqlc.arg(to_status), updated_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND status = sqlc.arg(from_status)
+RETURNING *;
+
+-- name: ListChirpsPage :many
+SELECT * FROM chirps
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: ImportChirp :exec
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (id) DO UPDATE SET
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	body = excluded.body,
+	user_id = excluded.user_id,
+	reply_to_id = excluded.reply_to_id,
+	status = excluded.status,
+	publish_at = excluded.publish_at;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND status = 'scheduled'
+ORDER BY publish_at ASC;
+
+-- name: UpdateScheduledChirp :one
+UPDATE chirps SET body = $1, publish_at = $2, status = $3, updated_at = NOW()
+WHERE id = $4 AND status = 'scheduled'
+RETURNING *;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status = 'scheduled';
+
+-- name: PublishDueChirps :many
+UPDATE chirps SET status = 'published', publish_at = sqlc.arg(now), updated_at = sqlc.arg(now), announce_at = sqlc.arg(announce_at)
+WHERE id IN (
+	SELECT id FROM chirps
+	WHERE status = 'scheduled' AND publish_at <= sqlc.arg(now)
+		AND user_id NOT IN (SELECT id FROM users WHERE deleted_at IS NOT NULL OR suspended_until > sqlc.arg(now))
+	ORDER BY publish_at
+	LIMIT sqlc.arg('limit')
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ClaimUnannouncedChirps :many
+UPDATE chirps SET announce_at = sqlc.arg(announce_at)
+WHERE id IN (
+	SELECT id FROM chirps
+	WHERE announce_at <= sqlc.arg(now)
+	ORDER BY announce_at
+	LIMIT sqlc.arg('limit')
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: FinishChirpAnnouncement :exec
+UPDATE chirps SET announce_at = NULL
+WHERE id = $1;
+
+-- name: ApproveHeldChirp :one
+UPDATE chirps SET
+	status = CASE WHEN publish_at > sqlc.arg(now) THEN 'scheduled' ELSE 'published' END,
+	publish_at = CASE WHEN publish_at > sqlc.arg(now) THEN publish_at ELSE sqlc.arg(now) END,
+	announce_at = CASE WHEN publish_at > sqlc.arg(now) THEN NULL ELSE sqlc.arg(announce_at) END,
+	updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND status = 'held'
+RETURNING *;
+
+-- name: DeleteHeldChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND status = 'held';
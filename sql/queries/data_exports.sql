@@ -0,0 +1,38 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, user_id)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1
+)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1;
+
+-- name: GetPendingDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = $1 AND completed_at IS NULL
+ORDER BY created_at
+LIMIT 1;
+
+-- name: ClaimDataExports :many
+UPDATE data_exports
+SET claimed_until = NOW() + INTERVAL '5 minutes'
+WHERE id IN (
+	SELECT id FROM data_exports
+	WHERE completed_at IS NULL AND (claimed_until IS NULL OR claimed_until <= NOW())
+	ORDER BY created_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports SET completed_at = NOW(), archive = $1, error = $2
+WHERE id = $3;
+
+-- name: DeleteDataExportsBefore :exec
+DELETE FROM data_exports
+WHERE created_at < $1;
@@ -0,0 +1,17 @@
+-- name: ListModerationRules :many
+SELECT * FROM moderation_rules
+ORDER BY term ASC;
+
+-- name: CreateModerationRule :one
+INSERT INTO moderation_rules (id, created_at, term, action)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2
+)
+RETURNING *;
+
+-- name: DeleteModerationRule :execrows
+DELETE FROM moderation_rules
+WHERE id = $1;
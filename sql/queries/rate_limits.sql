@@ -0,0 +1,19 @@
+-- name: TakeRateLimit :one
+INSERT INTO rate_limits (key, full_at)
+VALUES (
+	sqlc.arg(key),
+	NOW() + sqlc.arg(interval_us)::bigint * INTERVAL '1 microsecond'
+)
+ON CONFLICT (key) DO UPDATE
+SET full_at = GREATEST(rate_limits.full_at, NOW()) + sqlc.arg(interval_us)::bigint * INTERVAL '1 microsecond'
+WHERE GREATEST(rate_limits.full_at, NOW()) + sqlc.arg(interval_us)::bigint * INTERVAL '1 microsecond'
+	<= NOW() + sqlc.arg(period_us)::bigint * INTERVAL '1 microsecond'
+RETURNING full_at, NOW()::timestamp AS now;
+
+-- name: GetRateLimit :one
+SELECT full_at, NOW()::timestamp AS now FROM rate_limits
+WHERE key = $1;
+
+-- name: DeleteFullRateLimits :exec
+DELETE FROM rate_limits
+WHERE full_at < NOW();
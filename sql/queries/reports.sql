@@ -0,0 +1,26 @@
+-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (id, created_at, chirp_id, reporter_id, reason, details)
+VALUES (
+	gen_random_uuid(),
+	NOW(),
+	$1,
+	$2,
+	$3,
+	$4
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: ListOpenChirpReports :many
+SELECT * FROM chirp_reports
+WHERE resolved_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: ResolveChirpReports :execrows
+UPDATE chirp_reports SET resolved_at = NOW(), resolved_by = $1, resolution = $2
+WHERE chirp_id = $3 AND resolved_at IS NULL;
+
+-- name: ListUserChirpReports :many
+SELECT * FROM chirp_reports
+WHERE reporter_id = $1
+ORDER BY created_at;
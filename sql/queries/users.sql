@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
 VALUES (
 	gen_random_uuid(),
 	NOW(),
 	NOW(),
 	$1,
-	$2
+	$2,
+	$3
 	)
 RETURNING *;
 
@@ -18,10 +19,75 @@ SELECT * FROM users
 WHERE email=$1;
 
 -- name: UpdateUser :one
-UPDATE users SET email=$1, hashed_password=$2, updated_at=NOW()
-WHERE id  = $3
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
+UPDATE users SET email=$1, hashed_password=$2, username=COALESCE($3, username), updated_at=NOW()
+WHERE id  = $4
+RETURNING id, created_at, updated_at, email, is_chirpy_red, username;
 
 -- name: UpgradeRed :exec
 UPDATE users set is_chirpy_red=true
 WHERE id=$1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id=$1;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users
+WHERE username=$1;
+
+-- name: SetUsername :exec
+UPDATE users SET username=$1, updated_at=NOW()
+WHERE id=$2;
+
+-- name: GetUsersByUsernames :many
+SELECT * FROM users
+WHERE username = ANY(sqlc.arg(usernames)::text[]);
+
+-- name: SetUserRole :execrows
+UPDATE users SET role=$1, updated_at=NOW()
+WHERE id=$2;
+
+-- name: SuspendUser :exec
+UPDATE users SET suspended_until=$1, ban_reason=$2, updated_at=NOW()
+WHERE id=$3;
+
+-- name: DowngradeRed :exec
+UPDATE users set is_chirpy_red=false
+WHERE id=$1;
+
+-- name: RevokeAccessTokens :exec
+UPDATE users SET tokens_revoked_at=NOW(), updated_at=NOW()
+WHERE id=$1;
+
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE (sqlc.arg(query)::text = ''
+	OR email ILIKE '%' || sqlc.arg(query) || '%' ESCAPE '\'
+	OR username ILIKE '%' || sqlc.arg(query) || '%' ESCAPE '\')
+AND (sqlc.arg(role)::text = '' OR role = sqlc.arg(role))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: SetUserDeleted :exec
+UPDATE users SET deleted_at=$1, updated_at=NOW()
+WHERE id=$2;
+
+-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < sqlc.arg(deleted_before)
+RETURNING id;
+
+-- name: ImportUser :exec
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, ban_reason, deleted_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (id) DO UPDATE SET
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	email = excluded.email,
+	hashed_password = excluded.hashed_password,
+	is_chirpy_red = excluded.is_chirpy_red,
+	username = excluded.username,
+	role = excluded.role,
+	suspended_until = excluded.suspended_until,
+	ban_reason = excluded.ban_reason,
+	deleted_at = excluded.deleted_at;
@@ -1,7 +1,7 @@
--- +goose up
+-- +goose Up
 alter table users
 	add column hashed_password text not null default 'unset';
 
--- +goose down
+-- +goose Down
 alter table users
 	drop column hashed_password;
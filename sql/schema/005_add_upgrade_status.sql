@@ -1,7 +1,7 @@
--- +goose up
+-- +goose Up
 alter table users
 	add column is_chirpy_red BOOLEAN not null default false;
 
--- +goose down
+-- +goose Down
 alter table users
 	drop column is_chirpy_red;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE follows (
+	follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (follower_id, followee_id)
+);
+
+-- +goose Down
+DROP TABLE follows;
@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE actor_keys (
+	user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	public_key_pem TEXT NOT NULL,
+	private_key_pem TEXT NOT NULL
+);
+
+CREATE TABLE remote_followers (
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id TEXT NOT NULL,
+	inbox TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, actor_id)
+);
+
+CREATE TABLE remote_notes (
+	id TEXT PRIMARY KEY,
+	actor_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	published TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE ap_deliveries (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	inbox TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL,
+	last_error TEXT
+);
+
+CREATE INDEX ap_deliveries_due ON ap_deliveries (next_attempt_at);
+
+-- +goose Down
+DROP TABLE ap_deliveries;
+DROP TABLE remote_notes;
+DROP TABLE remote_followers;
+DROP TABLE actor_keys;
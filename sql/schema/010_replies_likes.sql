@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE chirps
+	ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE TABLE likes (
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, chirp_id)
+);
+
+-- +goose Down
+DROP TABLE likes;
+ALTER TABLE chirps
+	DROP COLUMN reply_to_id;
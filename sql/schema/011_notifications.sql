@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE notifications (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+	read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_by_user ON notifications (user_id, created_at DESC, id DESC);
+
+CREATE TABLE notification_preferences (
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	in_app BOOLEAN NOT NULL,
+	email BOOLEAN NOT NULL,
+	PRIMARY KEY (user_id, kind)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
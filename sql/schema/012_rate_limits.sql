@@ -0,0 +1,12 @@
+-- +goose Up
+-- full_at is when the bucket will have refilled completely. A row past it
+-- is the same as no row at all, so those are pruned.
+CREATE TABLE rate_limits (
+	key TEXT PRIMARY KEY,
+	full_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX rate_limits_by_full_at ON rate_limits (full_at);
+
+-- +goose Down
+DROP TABLE rate_limits;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- status is published, or held while a moderator reviews the chirp.
+ALTER TABLE chirps ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+
+CREATE INDEX chirps_by_status ON chirps (status) WHERE status <> 'published';
+
+CREATE TABLE moderation_rules (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	term TEXT NOT NULL UNIQUE,
+	action TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE moderation_rules;
+DROP INDEX chirps_by_status;
+ALTER TABLE chirps DROP COLUMN status;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- ban_reason says why the user is suspended. A ban is a suspension that
+-- doesn't end; a banned user's chirps may be set aside with status banned.
+ALTER TABLE users ADD COLUMN ban_reason TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN ban_reason;
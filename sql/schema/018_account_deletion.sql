@@ -0,0 +1,10 @@
+-- +goose Up
+-- deleted_at is when the user asked to delete their account. Logging in
+-- before the grace period ends restores it; after that the user is
+-- deleted for good, along with everything that references them.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+CREATE INDEX users_deleted ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX users_deleted;
+ALTER TABLE users DROP COLUMN deleted_at;
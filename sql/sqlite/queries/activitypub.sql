@@ -0,0 +1,70 @@
+-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, created_at, public_key_pem, private_key_pem)
+VALUES (
+	?,
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?
+)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetActorKey :one
+SELECT * FROM actor_keys
+WHERE user_id = ?;
+
+-- name: AddRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_id, inbox, created_at)
+VALUES (
+	?,
+	?,
+	?,
+	strftime('%Y-%m-%d %H:%M:%f', 'now')
+)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET inbox = EXCLUDED.inbox;
+
+-- name: RemoveRemoteFollower :exec
+DELETE FROM remote_followers
+WHERE user_id = ? AND actor_id = ?;
+
+-- name: RemoveRemoteActor :exec
+DELETE FROM remote_followers
+WHERE actor_id = ?;
+
+-- name: GetRemoteFollowerInboxes :many
+SELECT DISTINCT inbox FROM remote_followers
+WHERE user_id = ?;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM remote_followers
+WHERE user_id = ?;
+
+-- name: EnqueueDelivery :exec
+INSERT INTO ap_deliveries (created_at, user_id, inbox, payload, attempts, next_attempt_at)
+VALUES (
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?,
+	?,
+	0,
+	strftime('%Y-%m-%d %H:%M:%f', 'now')
+);
+
+-- name: ClaimDeliveries :many
+UPDATE ap_deliveries
+SET attempts = attempts + 1, next_attempt_at = strftime('%Y-%m-%d %H:%M:%f', 'now', '+5 minutes')
+WHERE id IN (
+	SELECT id FROM ap_deliveries
+	WHERE next_attempt_at <= strftime('%Y-%m-%d %H:%M:%f', 'now') AND ap_deliveries.attempts < ?
+	ORDER BY id
+	LIMIT ?
+)
+RETURNING *;
+
+-- name: DeleteDelivery :exec
+DELETE FROM ap_deliveries
+WHERE id = ?;
+
+-- name: RescheduleDelivery :exec
+UPDATE ap_deliveries
+SET next_attempt_at = ?, last_error = ?
+WHERE id = ?;
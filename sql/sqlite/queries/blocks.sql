@@ -0,0 +1,27 @@
+-- name: BlockUser :execrows
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+	?,
+	?,
+	strftime('%Y-%m-%d %H:%M:%f', 'now')
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks
+WHERE blocker_id = ? AND blocked_id = ?;
+
+-- name: ListBlocks :many
+SELECT * FROM blocks
+WHERE blocker_id = ?
+ORDER BY created_at ASC;
+
+-- name: GetBlockedEitherWayIDs :many
+SELECT blocked_id FROM blocks WHERE blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id FROM blocks WHERE blocked_id = sqlc.arg(user_id);
+
+-- name: CountBlocksBetween :one
+SELECT COUNT(*) FROM blocks
+WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = sqlc.arg(other_id))
+OR (blocker_id = sqlc.arg(other_id) AND blocked_id = sqlc.arg(user_id));
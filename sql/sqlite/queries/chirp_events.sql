@@ -0,0 +1,20 @@
+-- name: CreateChirpEvent :one
+INSERT INTO chirp_events (created_at, kind, chirp_id, user_id, body)
+VALUES (
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?,
+	?,
+	?
+)
+RETURNING *;
+
+-- name: GetChirpEvent :one
+SELECT * FROM chirp_events
+WHERE id = ?;
+
+-- name: GetChirpEventsAfter :many
+SELECT * FROM chirp_events
+WHERE id > ?
+ORDER BY id ASC
+LIMIT ?;
@@ -0,0 +1,112 @@
+-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at)
+VALUES (
+	lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?,
+	?,
+	?,
+	?
+)
+RETURNING *;
+
+-- name: GetChirps :many
+SELECT * from chirps
+ORDER BY COALESCE(publish_at, created_at) ASC;
+
+-- name: GetSingleChirp :one
+SELECT * from chirps
+WHERE id=?;
+
+-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id=?;
+
+-- name: GetChirpsByUserID :many
+SELECT * FROM chirps
+WHERE user_id = ?;
+
+-- name: GetChirpsByStatus :many
+SELECT * FROM chirps
+WHERE status = ?
+ORDER BY created_at ASC;
+
+-- name: SetChirpStatus :exec
+UPDATE chirps SET status = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: SetUserChirpsStatus :many
+UPDATE chirps SET status = sqlc.arg(to_status), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = sqlc.arg(user_id) AND status = sqlc.arg(from_status)
+RETURNING *;
+
+-- name: ListChirpsPage :many
+SELECT * FROM chirps
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: ImportChirp :exec
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, status, publish_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	body = excluded.body,
+	user_id = excluded.user_id,
+	reply_to_id = excluded.reply_to_id,
+	status = excluded.status,
+	publish_at = excluded.publish_at;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = ? AND status = 'scheduled'
+ORDER BY publish_at ASC;
+
+-- name: UpdateScheduledChirp :one
+UPDATE chirps SET body = ?, publish_at = ?, status = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ? AND status = 'scheduled'
+RETURNING *;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = ? AND user_id = ? AND status = 'scheduled';
+
+-- name: PublishDueChirps :many
+UPDATE chirps SET status = 'published', publish_at = sqlc.arg(now), updated_at = sqlc.arg(now), announce_at = sqlc.arg(announce_at)
+WHERE id IN (
+	SELECT id FROM chirps
+	WHERE status = 'scheduled' AND publish_at <= sqlc.arg(now)
+		AND user_id NOT IN (SELECT id FROM users WHERE deleted_at IS NOT NULL OR suspended_until > sqlc.arg(now))
+	ORDER BY publish_at
+	LIMIT sqlc.arg('limit')
+)
+RETURNING *;
+
+-- name: ClaimUnannouncedChirps :many
+UPDATE chirps SET announce_at = sqlc.arg(announce_at)
+WHERE id IN (
+	SELECT id FROM chirps
+	WHERE announce_at <= sqlc.arg(now)
+	ORDER BY announce_at
+	LIMIT sqlc.arg('limit')
+)
+RETURNING *;
+
+-- name: FinishChirpAnnouncement :exec
+UPDATE chirps SET announce_at = NULL
+WHERE id = ?;
+
+-- name: ApproveHeldChirp :one
+UPDATE chirps SET
+	status = CASE WHEN publish_at > sqlc.arg(now) THEN 'scheduled' ELSE 'published' END,
+	publish_at = CASE WHEN publish_at > sqlc.arg(now) THEN publish_at ELSE sqlc.arg(now) END,
+	announce_at = CASE WHEN publish_at > sqlc.arg(now) THEN NULL ELSE sqlc.arg(announce_at) END,
+	updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND status = 'held'
+RETURNING *;
+
+-- name: DeleteHeldChirp :execrows
+DELETE FROM chirps
+WHERE id = ? AND status = 'held';
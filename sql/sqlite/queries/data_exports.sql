@@ -0,0 +1,37 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, user_id)
+VALUES (
+	lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?
+)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = ?;
+
+-- name: GetPendingDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = ? AND completed_at IS NULL
+ORDER BY created_at
+LIMIT 1;
+
+-- name: ClaimDataExports :many
+UPDATE data_exports
+SET claimed_until = strftime('%Y-%m-%d %H:%M:%f', 'now', '+5 minutes')
+WHERE id IN (
+	SELECT id FROM data_exports
+	WHERE completed_at IS NULL AND (claimed_until IS NULL OR claimed_until <= strftime('%Y-%m-%d %H:%M:%f', 'now'))
+	ORDER BY created_at
+	LIMIT ?
+)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports SET completed_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), archive = ?, error = ?
+WHERE id = ?;
+
+-- name: DeleteDataExportsBefore :exec
+DELETE FROM data_exports
+WHERE created_at < ?;
@@ -0,0 +1,21 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+	?,
+	?,
+	strftime('%Y-%m-%d %H:%M:%f', 'now')
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = ? AND followee_id = ?;
+
+-- name: GetFolloweeIDs :many
+SELECT followee_id FROM follows
+WHERE follower_id = ?;
+
+-- name: ListUserFollows :many
+SELECT * FROM follows
+WHERE follower_id = sqlc.arg(user_id) OR followee_id = sqlc.arg(user_id)
+ORDER BY created_at;
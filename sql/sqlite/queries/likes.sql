@@ -0,0 +1,17 @@
+-- name: LikeChirp :execrows
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES (
+	?,
+	?,
+	strftime('%Y-%m-%d %H:%M:%f', 'now')
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM likes
+WHERE user_id = ? AND chirp_id = ?;
+
+-- name: ListUserLikes :many
+SELECT * FROM likes
+WHERE user_id = ?
+ORDER BY created_at;
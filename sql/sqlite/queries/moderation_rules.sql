@@ -0,0 +1,17 @@
+-- name: ListModerationRules :many
+SELECT * FROM moderation_rules
+ORDER BY term ASC;
+
+-- name: CreateModerationRule :one
+INSERT INTO moderation_rules (id, created_at, term, action)
+VALUES (
+	lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?
+)
+RETURNING *;
+
+-- name: DeleteModerationRule :execrows
+DELETE FROM moderation_rules
+WHERE id = ?;
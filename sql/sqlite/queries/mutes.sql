@@ -0,0 +1,21 @@
+-- name: MuteUser :execrows
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (
+	?,
+	?,
+	strftime('%Y-%m-%d %H:%M:%f', 'now')
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM mutes
+WHERE muter_id = ? AND muted_id = ?;
+
+-- name: ListMutes :many
+SELECT * FROM mutes
+WHERE muter_id = ?
+ORDER BY created_at ASC;
+
+-- name: GetMutedUserIDs :many
+SELECT muted_id FROM mutes
+WHERE muter_id = ?;
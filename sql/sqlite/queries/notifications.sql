@@ -0,0 +1,46 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, actor_id, kind, chirp_id, read_at)
+VALUES (
+	lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?,
+	?,
+	?,
+	NULL
+)
+RETURNING *;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = ?1
+AND (julianday(created_at) < julianday(?2) OR (julianday(created_at) = julianday(?2) AND id < ?3))
+AND (CAST(?4 AS BOOLEAN) = false OR read_at IS NULL)
+ORDER BY created_at DESC, id DESC
+LIMIT ?5;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = ? AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications SET read_at = COALESCE(read_at, strftime('%Y-%m-%d %H:%M:%f', 'now'))
+WHERE id = ? AND user_id = ?;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications SET read_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = ? AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = ?;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, kind, in_app, email)
+VALUES (
+	?,
+	?,
+	?,
+	?
+)
+ON CONFLICT (user_id, kind) DO UPDATE SET in_app = EXCLUDED.in_app, email = EXCLUDED.email;
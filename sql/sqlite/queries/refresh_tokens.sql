@@ -0,0 +1,35 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+VALUES (
+	?,
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?,
+	NULL
+	)
+RETURNING *;
+
+-- name: GetUserFromRefreshToken :one
+SELECT users.* FROM users
+JOIN refresh_tokens ON users.id = refresh_tokens.user_id
+WHERE refresh_tokens.token = ?
+AND revoked_at IS NULL
+AND expires_at > strftime('%Y-%m-%d %H:%M:%f', 'now');
+
+-- name: RevokeRefreshToken :exec
+UPDATE refresh_tokens SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at=strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE token = ?;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = ? AND revoked_at IS NULL;
+
+-- name: ListUserRefreshTokens :many
+SELECT * FROM refresh_tokens
+WHERE user_id = ?
+ORDER BY created_at;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < sqlc.arg(expired_before);
@@ -0,0 +1,26 @@
+-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (id, created_at, chirp_id, reporter_id, reason, details)
+VALUES (
+	lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?,
+	?,
+	?
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: ListOpenChirpReports :many
+SELECT * FROM chirp_reports
+WHERE resolved_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: ResolveChirpReports :execrows
+UPDATE chirp_reports SET resolved_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), resolved_by = ?, resolution = ?
+WHERE chirp_id = ? AND resolved_at IS NULL;
+
+-- name: ListUserChirpReports :many
+SELECT * FROM chirp_reports
+WHERE reporter_id = ?
+ORDER BY created_at;
@@ -0,0 +1,15 @@
+-- name: GetStats :one
+SELECT
+	(SELECT COUNT(*) FROM users) AS users,
+	(SELECT COUNT(*) FROM users WHERE is_chirpy_red) AS red_users,
+	(SELECT COUNT(*) FROM users WHERE suspended_until > sqlc.arg(now)) AS suspended_users,
+	(SELECT COUNT(*) FROM users WHERE deleted_at IS NOT NULL) AS deleted_users,
+	(SELECT COUNT(*) FROM chirps) AS chirps,
+	(SELECT COUNT(*) FROM follows) AS follows,
+	(SELECT COUNT(*) FROM likes) AS likes,
+	(SELECT COUNT(*) FROM refresh_tokens WHERE revoked_at IS NULL AND expires_at > sqlc.arg(now)) AS active_sessions;
+
+-- name: CountChirpsByStatus :many
+SELECT status, COUNT(*) AS count FROM chirps
+GROUP BY status
+ORDER BY status;
@@ -0,0 +1,93 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
+VALUES (
+	lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	strftime('%Y-%m-%d %H:%M:%f', 'now'),
+	?,
+	?,
+	?
+	)
+RETURNING *;
+
+
+-- name: DeleteUsers :exec
+DELETE FROM users;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email=?;
+
+-- name: UpdateUser :one
+UPDATE users SET email=?, hashed_password=?, username=COALESCE(?, username), updated_at=strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id  = ?
+RETURNING id, created_at, updated_at, email, is_chirpy_red, username;
+
+-- name: UpgradeRed :exec
+UPDATE users set is_chirpy_red=true
+WHERE id=?;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id=?;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users
+WHERE username=?;
+
+-- name: SetUsername :exec
+UPDATE users SET username=?, updated_at=strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id=?;
+
+-- name: GetUsersByUsernames :many
+SELECT * FROM users
+WHERE username IN (sqlc.slice(usernames));
+
+-- name: SetUserRole :execrows
+UPDATE users SET role=?, updated_at=strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id=?;
+
+-- name: SuspendUser :exec
+UPDATE users SET suspended_until=?, ban_reason=?, updated_at=strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id=?;
+
+-- name: DowngradeRed :exec
+UPDATE users set is_chirpy_red=false
+WHERE id=?;
+
+-- name: RevokeAccessTokens :exec
+UPDATE users SET tokens_revoked_at=strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at=strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id=?;
+
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE (sqlc.arg(query) = ''
+	OR email LIKE '%' || sqlc.arg(query) || '%' ESCAPE '\'
+	OR username LIKE '%' || sqlc.arg(query) || '%' ESCAPE '\')
+AND (sqlc.arg(role) = '' OR role = sqlc.arg(role))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: SetUserDeleted :exec
+UPDATE users SET deleted_at=?, updated_at=strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id=?;
+
+-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < sqlc.arg(deleted_before)
+RETURNING id;
+
+-- name: ImportUser :exec
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, ban_reason, deleted_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	email = excluded.email,
+	hashed_password = excluded.hashed_password,
+	is_chirpy_red = excluded.is_chirpy_red,
+	username = excluded.username,
+	role = excluded.role,
+	suspended_until = excluded.suspended_until,
+	ban_reason = excluded.ban_reason,
+	deleted_at = excluded.deleted_at;
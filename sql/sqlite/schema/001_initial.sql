@@ -0,0 +1,123 @@
+-- +goose Up
+-- The SQLite schema starts from the state of sql/schema at 011. UUIDs are
+-- stored as text and timestamps as UTC text.
+CREATE TABLE users (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	email TEXT NOT NULL UNIQUE,
+	hashed_password TEXT NOT NULL DEFAULT 'unset',
+	is_chirpy_red BOOLEAN NOT NULL DEFAULT false,
+	username TEXT UNIQUE
+);
+
+CREATE TABLE chirps (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	body TEXT NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL
+);
+
+CREATE TABLE refresh_tokens (
+	token TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP
+);
+
+CREATE TABLE follows (
+	follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (follower_id, followee_id)
+);
+
+CREATE TABLE chirp_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at TIMESTAMP NOT NULL,
+	kind TEXT NOT NULL,
+	chirp_id UUID NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	body TEXT NOT NULL
+);
+
+CREATE TABLE actor_keys (
+	user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	public_key_pem TEXT NOT NULL,
+	private_key_pem TEXT NOT NULL
+);
+
+CREATE TABLE remote_followers (
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id TEXT NOT NULL,
+	inbox TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, actor_id)
+);
+
+CREATE TABLE remote_notes (
+	id TEXT PRIMARY KEY,
+	actor_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	published TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE ap_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	inbox TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL,
+	last_error TEXT
+);
+
+CREATE INDEX ap_deliveries_due ON ap_deliveries (next_attempt_at);
+
+CREATE TABLE likes (
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE TABLE notifications (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+	read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_by_user ON notifications (user_id, created_at DESC, id DESC);
+
+CREATE TABLE notification_preferences (
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	in_app BOOLEAN NOT NULL,
+	email BOOLEAN NOT NULL,
+	PRIMARY KEY (user_id, kind)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
+DROP TABLE likes;
+DROP TABLE ap_deliveries;
+DROP TABLE remote_notes;
+DROP TABLE remote_followers;
+DROP TABLE actor_keys;
+DROP TABLE chirp_events;
+DROP TABLE follows;
+DROP TABLE refresh_tokens;
+DROP TABLE chirps;
+DROP TABLE users;
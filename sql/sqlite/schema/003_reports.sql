@@ -0,0 +1,27 @@
+-- +goose Up
+-- role is user or moderator; moderators work through reported chirps.
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+-- suspended_until is set while a moderator has suspended the user.
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP;
+
+-- A report stays open until a moderator acts on its chirp, which resolves
+-- every open report on that chirp.
+CREATE TABLE chirp_reports (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+	reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	reason TEXT NOT NULL,
+	details TEXT NOT NULL DEFAULT '',
+	resolved_at TIMESTAMP,
+	resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	resolution TEXT,
+	UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX chirp_reports_open ON chirp_reports (created_at) WHERE resolved_at IS NULL;
+
+-- +goose Down
+DROP TABLE chirp_reports;
+ALTER TABLE users DROP COLUMN suspended_until;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Access tokens issued before tokens_revoked_at are no longer accepted.
+ALTER TABLE users ADD COLUMN tokens_revoked_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN tokens_revoked_at;
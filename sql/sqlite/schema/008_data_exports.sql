@@ -0,0 +1,17 @@
+-- +goose Up
+-- A data export is an archive of everything Chirpy holds about a user,
+-- built in the background. claimed_until leases an export to the instance
+-- building it; error is set instead of archive when the build failed.
+CREATE TABLE data_exports (
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	claimed_until TIMESTAMP,
+	completed_at TIMESTAMP,
+	archive BLOB,
+	error TEXT
+);
+CREATE INDEX data_exports_pending ON data_exports (created_at) WHERE completed_at IS NULL;
+
+-- +goose Down
+DROP TABLE data_exports;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- publish_at is when a scheduled chirp goes out. The publisher sets its
+-- status to published then, and leaves publish_at as a record of the
+-- schedule.
+ALTER TABLE chirps ADD COLUMN publish_at TIMESTAMP;
+CREATE INDEX chirps_scheduled ON chirps (publish_at) WHERE status = 'scheduled';
+
+-- +goose Down
+DROP INDEX chirps_scheduled;
+ALTER TABLE chirps DROP COLUMN publish_at;
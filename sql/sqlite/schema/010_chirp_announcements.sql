@@ -0,0 +1,13 @@
+-- +goose Up
+-- announce_at is set while a published chirp still has to be announced,
+-- to when another instance may take the announcement over. It is set in
+-- the same statement that publishes a scheduled chirp or approves a held
+-- one, and cleared once the chirp has been announced, so a crash in
+-- between only delays the announcement. publish_at becomes when the chirp
+-- actually went out.
+ALTER TABLE chirps ADD COLUMN announce_at TIMESTAMP;
+CREATE INDEX chirps_unannounced ON chirps (announce_at) WHERE announce_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_unannounced;
+ALTER TABLE chirps DROP COLUMN announce_at;
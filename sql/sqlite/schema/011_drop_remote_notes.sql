@@ -0,0 +1,12 @@
+-- +goose Up
+-- Notes from remote servers were stored but never shown anywhere.
+DROP TABLE remote_notes;
+
+-- +goose Down
+CREATE TABLE remote_notes (
+	id TEXT PRIMARY KEY,
+	actor_id TEXT NOT NULL,
+	content TEXT NOT NULL,
+	published TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
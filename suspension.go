@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/enderbd/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// defaultSuspension is how long suspend lasts when the moderator doesn't
+// say.
+const defaultSuspension = 7 * 24 * time.Hour
+
+// banEnd is the suspended_until of a ban, a suspension that doesn't end.
+var banEnd = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+var errUserSuspended = errors.New("user is suspended")
+
+// suspended reports whether user is suspended now.
+func suspended(user database.User) bool {
+	return user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now().UTC())
+}
+
+// banned reports whether user's suspension never ends.
+func banned(user database.User) bool {
+	return user.SuspendedUntil.Valid && !user.SuspendedUntil.Time.Before(banEnd)
+}
+
+// suspensionError turns away a suspended user, telling them until when and
+// why. It matches errUserSuspended.
+type suspensionError struct {
+	user database.User
+}
+
+func (e suspensionError) Error() string {
+	msg := "Your account is suspended until " + e.user.SuspendedUntil.Time.Format(time.RFC3339)
+	if banned(e.user) {
+		msg = "Your account is banned"
+	}
+	if e.user.BanReason != "" {
+		msg += ": " + e.user.BanReason
+	}
+	return msg
+}
+
+func (e suspensionError) Is(target error) bool {
+	return target == errUserSuspended
+}
+
+// respondWithTokenError explains why an access token was refused: a 403
+// with the reason for suspended users, a 401 otherwise.
+func respondWithTokenError(w http.ResponseWriter, err error) {
+	var suspension suspensionError
+	if errors.As(err, &suspension) {
+		respondWithError(w, http.StatusForbidden, suspension.Error(), err)
+		return
+	}
+	respondWithError(w, http.StatusUnauthorized, "Could not validate JWT", err)
+}
+
+// suspendUser suspends a user until until, banning them when it is banEnd,
+// and signs them out everywhere. hideChirps sets their published chirps
+// aside until the suspension is lifted; clients and remote servers that
+// already have them keep them.
+func (cfg *apiConfig) suspendUser(ctx context.Context, userID uuid.UUID, until time.Time, reason string, hideChirps bool) error {
+	err := cfg.db.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedUntil: sql.NullTime{Time: until, Valid: true},
+		BanReason:      reason,
+		ID:             userID,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.db.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	if hideChirps {
+		_, err = cfg.db.SetUserChirpsStatus(ctx, database.SetUserChirpsStatusParams{
+			ToStatus:   chirpBanned,
+			UserID:     userID,
+			FromStatus: chirpPublished,
+		})
+	}
+	return err
+}
+
+// liftSuspension ends a user's suspension or ban and puts back any chirps
+// it set aside.
+func (cfg *apiConfig) liftSuspension(ctx context.Context, userID uuid.UUID) error {
+	if err := cfg.db.SuspendUser(ctx, database.SuspendUserParams{ID: userID}); err != nil {
+		return err
+	}
+	_, err := cfg.db.SetUserChirpsStatus(ctx, database.SetUserChirpsStatusParams{
+		ToStatus:   chirpPublished,
+		UserID:     userID,
+		FromStatus: chirpBanned,
+	})
+	return err
+}
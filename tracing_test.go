@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceparentPropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	logs := captureLogs(t)
+	_, srv := newTestServer(t)
+	createTestUser(t, srv, "trace@example.com", "tracer")
+	exporter.Reset()
+	logs.Reset()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	body, _ := json.Marshal(map[string]string{"email": "trace@example.com", "password": "hunter2"})
+	req, _ := http.NewRequest("POST", srv.URL+"/api/login", bytes.NewReader(body))
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	names := map[string]bool{}
+	for _, span := range exporter.GetSpans() {
+		if span.SpanContext.TraceID().String() != traceID {
+			t.Errorf("span %s is not part of the caller's trace", span.Name)
+		}
+		names[span.Name] = true
+	}
+	for _, want := range []string{"POST /api/login", "auth.CheckPasswordHash", "auth.MakeJWT"} {
+		if !names[want] {
+			t.Errorf("no %s span, got %v", want, names)
+		}
+	}
+
+	if !strings.Contains(logs.String(), "trace_id="+traceID) {
+		t.Errorf("access log does not carry the trace ID: %s", logs.String())
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/enderbd/chirpy/internal/logging"
+)
+
+// workerStage orders how background workers stop: every worker of a stage
+// has returned before the next stage is told to stop.
+type workerStage int
+
+const (
+	// stageProducers make work for the senders, such as the chirp
+	// publisher and the account purger.
+	stageProducers workerStage = iota
+	// stageSenders drain what was queued before they stop: emails and
+	// ActivityPub deliveries.
+	stageSenders
+	// stageListeners keep this instance in step with the others until the
+	// end.
+	stageListeners
+	workerStages
+)
+
+// workerSet runs the long-lived background goroutines and remembers which
+// of them are still running, for the readiness check.
+type workerSet struct {
+	mu      sync.Mutex
+	stages  [workerStages]workerGroup
+	stopped map[string]error
+}
+
+type workerGroup struct {
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Go runs fn in stage until it returns. A worker that returns before Stop
+// cancels its context has died and makes the instance unready.
+func (ws *workerSet) Go(stage workerStage, name string, fn func(ctx context.Context) error) {
+	ws.mu.Lock()
+	group := &ws.stages[stage]
+	if group.ctx == nil {
+		group.ctx, group.cancel = context.WithCancel(context.Background())
+	}
+	ctx := group.ctx
+	ws.mu.Unlock()
+
+	group.wg.Go(func() {
+		err := fn(ctx)
+		if ctx.Err() == nil {
+			if err == nil {
+				err = fmt.Errorf("%s exited", name)
+			}
+			slog.Error("Background worker stopped", "worker", name, logging.Err(err))
+		}
+
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		if ws.stopped == nil {
+			ws.stopped = map[string]error{}
+		}
+		ws.stopped[name] = err
+	})
+}
+
+// Stop cancels the workers a stage at a time, waiting for each stage to
+// return before moving on to the next.
+func (ws *workerSet) Stop() {
+	for stage := range workerStages {
+		ws.mu.Lock()
+		group := &ws.stages[stage]
+		cancel := group.cancel
+		ws.mu.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+		group.wg.Wait()
+	}
+}
+
+// check reports an error naming every worker that is no longer running.
+func (ws *workerSet) check(context.Context) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var dead []string
+	for name, err := range ws.stopped {
+		if err != nil {
+			dead = append(dead, fmt.Sprintf("%s: %s", name, err))
+		} else {
+			dead = append(dead, name+" stopped")
+		}
+	}
+	if len(dead) == 0 {
+		return nil
+	}
+	sort.Strings(dead)
+	return fmt.Errorf("workers not running: %v", dead)
+}